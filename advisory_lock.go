@@ -0,0 +1,187 @@
+package gh
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ErrLockNotHeld is returned when releasing an advisory lock that is not held.
+var ErrLockNotHeld = errors.New("advisory lock not held")
+
+// AdvisoryKey derives an advisory lock key from a name, e.g AdvisoryKey("nightly-report").
+func AdvisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// SessionLock is a session-level advisory lock held on a dedicated connection.
+// It must be released with Unlock, which returns the connection to the pool.
+type SessionLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// AdvisoryLock waits until the session-level advisory lock key is acquired (pg_advisory_lock)
+// or ctx is canceled. The lock is held on a dedicated connection until Unlock is called.
+//
+//	lock, err := gh.AdvisoryLock(ctx, db, gh.AdvisoryKey("nightly-report"))
+//	if err != nil {
+//		return err
+//	}
+//	defer lock.Unlock(context.Background())
+func AdvisoryLock(ctx context.Context, db *gorm.DB, key int64) (*SessionLock, error) {
+	conn, err := lockConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+	}
+	return &SessionLock{conn: conn, key: key}, nil
+}
+
+// TryAdvisoryLock acquires the session-level advisory lock key if it is available
+// (pg_try_advisory_lock), without waiting. The lock is nil if it is held by another session.
+func TryAdvisoryLock(ctx context.Context, db *gorm.DB, key int64) (*SessionLock, error) {
+	conn, err := lockConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+	}
+
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &SessionLock{conn: conn, key: key}, nil
+}
+
+func lockConn(ctx context.Context, db *gorm.DB) (*sql.Conn, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return sqlDB.Conn(ctx)
+}
+
+// Key returns the key of the lock.
+func (l *SessionLock) Key() int64 {
+	return l.key
+}
+
+// Unlock releases the lock and returns its connection to the pool.
+// If the lock can not be released, the connection is discarded, which also releases the lock.
+func (l *SessionLock) Unlock(ctx context.Context) error {
+	var released bool
+	err := l.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", l.key).Scan(&released)
+	if err != nil {
+		discardConn(l.conn)
+		return fmt.Errorf("failed to release advisory lock %d: %w", l.key, err)
+	}
+
+	l.conn.Close()
+	if !released {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// WithAdvisoryLock runs fn in a transaction holding the transaction-level advisory lock key
+// (pg_advisory_xact_lock), waiting for it if needed. The lock is released when the transaction ends.
+func WithAdvisoryLock(ctx context.Context, db *gorm.DB, key int64, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", key).Error; err != nil {
+			return fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+		}
+		return fn(tx)
+	})
+}
+
+// WithTryAdvisoryLock is like WithAdvisoryLock but does not wait (pg_try_advisory_xact_lock):
+// if the lock is held by another session, fn is not called and false is returned.
+// It suits distributed-singleton jobs that should run on one instance only.
+func WithTryAdvisoryLock(ctx context.Context, db *gorm.DB, key int64, fn func(tx *gorm.DB) error) (bool, error) {
+	var acquired bool
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", key).Scan(&acquired).Error; err != nil {
+			return fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+		}
+
+		if !acquired {
+			return nil
+		}
+		return fn(tx)
+	})
+	return acquired, err
+}
+
+// AdvisoryMutex is a distributed mutex backed by a session-level advisory lock.
+// Unlike sync.Mutex, it excludes other processes using the same key.
+// An AdvisoryMutex is safe for concurrent use and, like sync.Mutex, is not reentrant.
+type AdvisoryMutex struct {
+	db  *gorm.DB
+	key int64
+
+	mu   sync.Mutex
+	lock *SessionLock
+}
+
+// NewAdvisoryMutex creates a mutex on the advisory lock derived from name.
+func NewAdvisoryMutex(db *gorm.DB, name string) *AdvisoryMutex {
+	return &AdvisoryMutex{db: db, key: AdvisoryKey(name)}
+}
+
+// Lock waits until the mutex is acquired or ctx is canceled.
+func (m *AdvisoryMutex) Lock(ctx context.Context) error {
+	lock, err := AdvisoryLock(ctx, m.db, m.key)
+	if err != nil {
+		return err
+	}
+	m.hold(lock)
+	return nil
+}
+
+// TryLock acquires the mutex if it is available and reports whether it did.
+func (m *AdvisoryMutex) TryLock(ctx context.Context) (bool, error) {
+	lock, err := TryAdvisoryLock(ctx, m.db, m.key)
+	if err != nil || lock == nil {
+		return false, err
+	}
+	m.hold(lock)
+	return true, nil
+}
+
+func (m *AdvisoryMutex) hold(lock *SessionLock) {
+	// Session locks on different connections exclude each other,
+	// so no other goroutine can hold the mutex at this point.
+	m.mu.Lock()
+	m.lock = lock
+	m.mu.Unlock()
+}
+
+// Unlock releases the mutex. It returns ErrLockNotHeld if the mutex is not locked.
+func (m *AdvisoryMutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	lock := m.lock
+	m.lock = nil
+	m.mu.Unlock()
+
+	if lock == nil {
+		return ErrLockNotHeld
+	}
+	return lock.Unlock(ctx)
+}
@@ -0,0 +1,74 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAdvisoryLock(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+	key := gh.AdvisoryKey("nightly-report")
+
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	lock, err := gh.AdvisoryLock(ctx, db, key)
+	assert.NoError(t, err)
+	assert.Equal(t, key, lock.Key())
+	assert.NoError(t, lock.Unlock(ctx))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(false))
+
+	lock, err = gh.TryAdvisoryLock(ctx, db, key)
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTryAdvisoryLock(t *testing.T) {
+	db, mock := mockDB(t)
+	key := gh.AdvisoryKey("nightly-report")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectCommit()
+
+	called := false
+	ran, err := gh.WithTryAdvisoryLock(context.Background(), db, key, func(tx *gorm.DB) error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.True(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdvisoryMutex(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+	m := gh.NewAdvisoryMutex(db, "nightly-report")
+
+	assert.ErrorIs(t, m.Unlock(ctx), gh.ErrLockNotHeld)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	locked, err := m.TryLock(ctx)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	assert.NoError(t, m.Unlock(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,247 @@
+package gh
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// association returns the association field of model, e.g "Tags", on the chain.
+func (gdb *GormDB) association(model any, field string) (*gorm.Association, error) {
+	assoc := gdb.db.Model(model).Association(field)
+	if assoc.Error != nil {
+		return nil, fmt.Errorf("invalid association %s of %T: %w", field, model, assoc.Error)
+	}
+	return assoc, nil
+}
+
+// AppendAssoc appends values to the association field of model, a pointer to a struct with a primary key.
+// For many2many and has many associations values are added, for belongs to and has one they replace the current one.
+// New values are inserted and existing values updated.
+//
+//	err := gdb.AppendAssoc(&patient, "Allergies", []Allergy{{Name: "Penicillin"}})
+func (gdb *GormDB) AppendAssoc(model any, field string, values ...any) error {
+	assoc, err := gdb.association(model, field)
+	if err != nil {
+		return err
+	}
+
+	if err := assoc.Append(values...); err != nil {
+		return fmt.Errorf("failed to append %s of %T: %w", field, model, err)
+	}
+	return nil
+}
+
+// ReplaceAssoc replaces the association field of model with values.
+// Removed values are unlinked (their foreign keys set to NULL, or their join rows deleted), not deleted.
+func (gdb *GormDB) ReplaceAssoc(model any, field string, values ...any) error {
+	assoc, err := gdb.association(model, field)
+	if err != nil {
+		return err
+	}
+
+	if err := assoc.Replace(values...); err != nil {
+		return fmt.Errorf("failed to replace %s of %T: %w", field, model, err)
+	}
+	return nil
+}
+
+// ClearAssoc unlinks all the values of the association field of model, without deleting them.
+func (gdb *GormDB) ClearAssoc(model any, field string) error {
+	assoc, err := gdb.association(model, field)
+	if err != nil {
+		return err
+	}
+
+	if err := assoc.Clear(); err != nil {
+		return fmt.Errorf("failed to clear %s of %T: %w", field, model, err)
+	}
+	return nil
+}
+
+// CountAssoc returns the number of values of the association field of model,
+// filtered by the conditions of the chain.
+func (gdb *GormDB) CountAssoc(model any, field string) (int64, error) {
+	assoc, err := gdb.association(model, field)
+	if err != nil {
+		return 0, err
+	}
+
+	count := assoc.Count()
+	if assoc.Error != nil {
+		return 0, fmt.Errorf("failed to count %s of %T: %w", field, model, assoc.Error)
+	}
+	return count, nil
+}
+
+// AssocChanges are the values linked and unlinked by SyncAssoc.
+type AssocChanges struct {
+	Attached []any
+	Detached []any
+}
+
+// joinTable describes the join table of a many2many association.
+type joinTable struct {
+	name       string
+	owner      map[string]any // join table column => primary key of the model
+	foreignKey string         // join table column referencing the associated values
+}
+
+// joinTableOf returns the join table of the many2many association field of model.
+func (gdb *GormDB) joinTableOf(model any, field string) (*joinTable, error) {
+	stmt := &gorm.Statement{DB: gdb.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	rel, ok := stmt.Schema.Relationships.Relations[field]
+	if !ok || rel.Type != schema.Many2Many || rel.JoinTable == nil {
+		return nil, fmt.Errorf("%s of %T is not a many2many association", field, model)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(model))
+	jt := &joinTable{name: rel.JoinTable.Table, owner: map[string]any{}}
+	for _, ref := range rel.References {
+		if !ref.OwnPrimaryKey {
+			if jt.foreignKey != "" {
+				return nil, fmt.Errorf("%s of %T has a composite key", field, model)
+			}
+			jt.foreignKey = ref.ForeignKey.DBName
+			continue
+		}
+
+		value, zero := ref.PrimaryKey.ValueOf(stmt.Context, rv)
+		if zero {
+			return nil, fmt.Errorf("%T has no primary key", model)
+		}
+		jt.owner[ref.ForeignKey.DBName] = value
+	}
+	return jt, nil
+}
+
+// AttachAssoc links model to the values with the primary keys ids through the join table of its many2many
+// association field, setting the extra columns of pivot, e.g the quantity or the role. The values must exist.
+// Existing links are kept, their pivot columns updated.
+//
+//	err := gdb.AttachAssoc(&order, "Products", []any{3, 5}, map[string]any{"quantity": 2})
+func (gdb *GormDB) AttachAssoc(model any, field string, ids []any, pivot map[string]any) error {
+	jt, err := gdb.joinTableOf(model, field)
+	if err != nil {
+		return err
+	}
+	return jt.attach(gdb.db.Session(&gorm.Session{NewDB: true}), ids, pivot)
+}
+
+// DetachAssoc unlinks model from the values with the primary keys ids, or all its values if ids is empty,
+// by deleting the rows of the join table of its many2many association field.
+func (gdb *GormDB) DetachAssoc(model any, field string, ids ...any) error {
+	jt, err := gdb.joinTableOf(model, field)
+	if err != nil {
+		return err
+	}
+	return jt.detach(gdb.db.Session(&gorm.Session{NewDB: true}), ids)
+}
+
+// SyncAssoc links model to exactly the values with the primary keys ids through the join table of
+// its many2many association field, inserting the missing links, with the extra columns of pivot,
+// and deleting the others. Kept links are untouched. It returns the ids attached and detached.
+//
+//	changes, err := gdb.SyncAssoc(&user, "Roles", []any{1, 4}, map[string]any{"created_by": admin.ID})
+func (gdb *GormDB) SyncAssoc(model any, field string, ids []any, pivot map[string]any) (AssocChanges, error) {
+	changes := AssocChanges{}
+	jt, err := gdb.joinTableOf(model, field)
+	if err != nil {
+		return changes, err
+	}
+
+	err = gdb.Transaction(func(tx *GormDB) error {
+		db := tx.db.Session(&gorm.Session{NewDB: true})
+
+		current := []any{}
+		if err := db.Table(jt.name).Where(jt.owner).Pluck(jt.foreignKey, &current).Error; err != nil {
+			return fmt.Errorf("failed to read %s: %w", jt.name, err)
+		}
+
+		linked := make(map[string]bool, len(current))
+		for _, id := range current {
+			linked[fmt.Sprint(id)] = true
+		}
+
+		desired := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			key := fmt.Sprint(id)
+			if !desired[key] && !linked[key] {
+				changes.Attached = append(changes.Attached, id)
+			}
+			desired[key] = true
+		}
+
+		for _, id := range current {
+			if !desired[fmt.Sprint(id)] {
+				changes.Detached = append(changes.Detached, id)
+			}
+		}
+
+		if len(changes.Detached) > 0 {
+			if err := jt.detach(db, changes.Detached); err != nil {
+				return err
+			}
+		}
+		if len(changes.Attached) > 0 {
+			return jt.attach(db, changes.Attached, pivot)
+		}
+		return nil
+	})
+	if err != nil {
+		return AssocChanges{}, err
+	}
+	return changes, nil
+}
+
+func (jt *joinTable) attach(db *gorm.DB, ids []any, pivot map[string]any) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	conflict := clause.OnConflict{DoNothing: len(pivot) == 0}
+	rows := make([]map[string]any, len(ids))
+	for i, id := range ids {
+		row := map[string]any{jt.foreignKey: id}
+		for column, value := range jt.owner {
+			row[column] = value
+		}
+		for column, value := range pivot {
+			row[column] = value
+		}
+		rows[i] = row
+	}
+
+	for _, column := range append(slices.Sorted(maps.Keys(jt.owner)), jt.foreignKey) {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: column})
+	}
+	if len(pivot) > 0 {
+		conflict.DoUpdates = clause.AssignmentColumns(slices.Sorted(maps.Keys(pivot)))
+	}
+
+	if err := db.Table(jt.name).Clauses(conflict).Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to attach %s: %w", jt.name, err)
+	}
+	return nil
+}
+
+func (jt *joinTable) detach(db *gorm.DB, ids []any) error {
+	db = db.Table(jt.name).Where(jt.owner)
+	if len(ids) > 0 {
+		db = db.Where(quoteName(jt.foreignKey)+" IN ?", ids)
+	}
+
+	if err := db.Delete(map[string]any{}).Error; err != nil {
+		return fmt.Errorf("failed to detach %s: %w", jt.name, err)
+	}
+	return nil
+}
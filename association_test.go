@@ -0,0 +1,105 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type assocPatient struct {
+	ID        uint
+	Allergies []assocAllergy `gorm:"many2many:patient_allergies"`
+	Visits    []assocVisit   `gorm:"foreignKey:PatientID"`
+}
+
+type assocAllergy struct {
+	ID   uint
+	Name string
+}
+
+type assocVisit struct {
+	ID        uint
+	PatientID *uint
+}
+
+func TestAssociations(t *testing.T) {
+	db, mock := mockDB(t)
+	gdb := gh.WrapDB(db).WithContext(context.Background())
+	patient := &assocPatient{ID: 1}
+
+	mock.ExpectQuery(`INSERT INTO "assoc_allergies" \("name"\) VALUES \(\$1\) ON CONFLICT DO NOTHING RETURNING "id"`).
+		WithArgs("Penicillin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO "patient_allergies" \("assoc_patient_id","assoc_allergy_id"\) VALUES \(\$1,\$2\) ON CONFLICT DO NOTHING`).
+		WithArgs(1, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gdb.AppendAssoc(patient, "Allergies", []assocAllergy{{Name: "Penicillin"}}))
+	assert.Equal(t, []assocAllergy{{ID: 7, Name: "Penicillin"}}, patient.Allergies)
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "assoc_visits" WHERE "assoc_visits"."patient_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	count, err := gdb.CountAssoc(patient, "Visits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	mock.ExpectExec(`UPDATE "assoc_visits" SET "patient_id"=\$1 WHERE "assoc_visits"."patient_id" = \$2`).
+		WithArgs(nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	require.NoError(t, gdb.ClearAssoc(patient, "Visits"))
+
+	_, err = gdb.CountAssoc(patient, "Unknown")
+	assert.ErrorContains(t, err, "invalid association Unknown")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type pivotOrder struct {
+	ID       uint
+	Products []pivotProduct `gorm:"many2many:order_products"`
+}
+
+type pivotProduct struct {
+	ID uint
+}
+
+func TestPivotAssociations(t *testing.T) {
+	db, mock := mockDB(t)
+	gdb := gh.WrapDB(db)
+	order := &pivotOrder{ID: 1}
+
+	mock.ExpectExec(`INSERT INTO "order_products" \("pivot_order_id","pivot_product_id","quantity"\) VALUES \(\$1,\$2,\$3\),\(\$4,\$5,\$6\) `+
+		`ON CONFLICT \("pivot_order_id","pivot_product_id"\) DO UPDATE SET "quantity"="excluded"."quantity"`).
+		WithArgs(1, 3, 2, 1, 5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	require.NoError(t, gdb.AttachAssoc(order, "Products", []any{3, 5}, map[string]any{"quantity": 2}))
+
+	mock.ExpectExec(`DELETE FROM "order_products" WHERE "pivot_order_id" = \$1 AND "pivot_product_id" IN \(\$2\)`).
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gdb.DetachAssoc(order, "Products", 5))
+
+	// Sync only inserts and deletes the differences.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "pivot_product_id" FROM "order_products" WHERE "pivot_order_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pivot_product_id"}).AddRow(3).AddRow(4))
+	mock.ExpectExec(`DELETE FROM "order_products" WHERE "pivot_order_id" = \$1 AND "pivot_product_id" IN \(\$2\)`).
+		WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "order_products" \("pivot_order_id","pivot_product_id"\) VALUES \(\$1,\$2\) ON CONFLICT \("pivot_order_id","pivot_product_id"\) DO NOTHING`).
+		WithArgs(1, 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	changes, err := gdb.SyncAssoc(order, "Products", []any{3, 6, 6}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gh.AssocChanges{Attached: []any{6}, Detached: []any{int64(4)}}, changes)
+
+	_, err = gdb.SyncAssoc(&assocPatient{ID: 1}, "Visits", nil, nil)
+	assert.ErrorContains(t, err, "not a many2many association")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
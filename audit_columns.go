@@ -0,0 +1,136 @@
+package gh
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor (usually the ID of the logged in user)
+// responsible for the changes made with the context.
+// The actor is used by the AuditColumns plugin to fill created_by, updated_by and deleted_by columns.
+func WithActor(ctx context.Context, actor any) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx by WithActor.
+func ActorFromContext(ctx context.Context) (any, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	actor := ctx.Value(actorKey{})
+	return actor, actor != nil
+}
+
+// AuditColumns is a gorm plugin that fills the created_by, updated_by and deleted_by
+// columns from the actor stored in the statement context with WithActor.
+// Models without these columns and statements without an actor are left untouched.
+//
+// Usage:
+//
+//	db.Use(&gh.AuditColumns{})
+//	ctx := gh.WithActor(r.Context(), user.ID)
+//	gh.WrapDB(db).WithContext(ctx).Create(&invoice)
+type AuditColumns struct {
+	CreatedBy string // created_by column, default: created_by
+	UpdatedBy string // updated_by column, default: updated_by
+	DeletedBy string // deleted_by column, default: deleted_by
+}
+
+// Name implements gorm.Plugin.
+func (p *AuditColumns) Name() string {
+	return "gh:audit_columns"
+}
+
+// Initialize implements gorm.Plugin by registering the create, update and delete callbacks.
+func (p *AuditColumns) Initialize(db *gorm.DB) error {
+	if p.CreatedBy == "" {
+		p.CreatedBy = "created_by"
+	}
+	if p.UpdatedBy == "" {
+		p.UpdatedBy = "updated_by"
+	}
+	if p.DeletedBy == "" {
+		p.DeletedBy = "deleted_by"
+	}
+
+	err := db.Callback().Create().Before("gorm:create").Register("gh:audit_columns_create", p.beforeCreate)
+	if err != nil {
+		return err
+	}
+
+	err = db.Callback().Update().Before("gorm:update").Register("gh:audit_columns_update", p.beforeUpdate)
+	if err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("gh:audit_columns_delete", p.beforeDelete)
+}
+
+func (p *AuditColumns) beforeCreate(db *gorm.DB) {
+	actor, ok := ActorFromContext(db.Statement.Context)
+	if !ok || db.Error != nil {
+		return
+	}
+
+	for _, column := range []string{p.CreatedBy, p.UpdatedBy} {
+		if hasColumn(db.Statement, column) {
+			db.Statement.SetColumn(column, actor, true)
+		}
+	}
+}
+
+func (p *AuditColumns) beforeUpdate(db *gorm.DB) {
+	actor, ok := ActorFromContext(db.Statement.Context)
+	if !ok || db.Error != nil {
+		return
+	}
+
+	if hasColumn(db.Statement, p.UpdatedBy) {
+		db.Statement.SetColumn(p.UpdatedBy, actor, true)
+	}
+}
+
+// beforeDelete records the actor for soft deletes. gorm builds the soft delete UPDATE itself,
+// replacing the SET clause, so deleted_by is appended to it by the builder of the clause,
+// and written by the same statement as deleted_at.
+func (p *AuditColumns) beforeDelete(db *gorm.DB) {
+	actor, ok := ActorFromContext(db.Statement.Context)
+	if !ok || db.Error != nil || db.Statement.Unscoped || db.Statement.Schema == nil {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField(p.DeletedBy)
+	if field == nil || !hasSoftDelete(db.Statement) {
+		return
+	}
+
+	deletedBy := clause.Assignment{Column: clause.Column{Name: field.DBName}, Value: actor}
+	db.Statement.Clauses["SET"] = clause.Clause{
+		Name: "SET",
+		Builder: func(c clause.Clause, builder clause.Builder) {
+			set, _ := c.Expression.(clause.Set)
+			builder.WriteString("SET ")
+			append(set[:len(set):len(set)], deletedBy).Build(builder)
+		},
+	}
+}
+
+// hasColumn reports whether the statement's model has a field for column.
+func hasColumn(stmt *gorm.Statement, column string) bool {
+	return stmt.Schema != nil && stmt.Schema.LookUpField(column) != nil
+}
+
+// hasSoftDelete reports whether the model has a gorm.DeletedAt field.
+func hasSoftDelete(stmt *gorm.Statement) bool {
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == deletedAt {
+			return true
+		}
+	}
+	return false
+}
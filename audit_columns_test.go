@@ -0,0 +1,65 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type auditedInvoice struct {
+	ID        uint
+	Amount    int
+	CreatedBy uint
+	UpdatedBy uint
+}
+
+func TestAuditColumns(t *testing.T) {
+	db := dryRunDB(t)
+	assert.NoError(t, db.Use(&gh.AuditColumns{}))
+
+	ctx := gh.WithActor(context.Background(), uint(42))
+
+	invoice := auditedInvoice{Amount: 100}
+	stmt := db.WithContext(ctx).Create(&invoice).Statement
+	assert.Equal(t, uint(42), invoice.CreatedBy)
+	assert.Equal(t, uint(42), invoice.UpdatedBy)
+	assert.Contains(t, stmt.SQL.String(), `"created_by","updated_by"`)
+
+	invoice = auditedInvoice{ID: 1, Amount: 200, CreatedBy: 7}
+	stmt = db.WithContext(ctx).Save(&invoice).Statement
+	assert.Equal(t, uint(7), invoice.CreatedBy)
+	assert.Equal(t, uint(42), invoice.UpdatedBy)
+	assert.Contains(t, stmt.SQL.String(), `"updated_by"=`)
+
+	// No actor in context
+	invoice = auditedInvoice{Amount: 300}
+	db.Create(&invoice)
+	assert.Zero(t, invoice.CreatedBy)
+
+	actor, ok := gh.ActorFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, uint(42), actor)
+}
+
+type archivedInvoice struct {
+	ID        uint
+	DeletedBy uint
+	DeletedAt gorm.DeletedAt
+}
+
+func TestAuditColumnsSoftDelete(t *testing.T) {
+	db := dryRunDB(t)
+	assert.NoError(t, db.Use(&gh.AuditColumns{}))
+	ctx := gh.WithActor(context.Background(), uint(42))
+
+	// deleted_by is set by the soft delete itself, so it is not written if the delete fails.
+	stmt := db.WithContext(ctx).Delete(&archivedInvoice{ID: 1}).Statement
+	assert.Equal(t, `UPDATE "archived_invoices" SET "deleted_at"=$1,"deleted_by"=$2 WHERE "archived_invoices"."id" = $3 AND "archived_invoices"."deleted_at" IS NULL`, stmt.SQL.String())
+	assert.Equal(t, uint(42), stmt.Vars[1])
+
+	stmt = db.Delete(&archivedInvoice{ID: 1}).Statement
+	assert.Equal(t, `UPDATE "archived_invoices" SET "deleted_at"=$1 WHERE "archived_invoices"."id" = $2 AND "archived_invoices"."deleted_at" IS NULL`, stmt.SQL.String())
+}
@@ -0,0 +1,297 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Audit actions recorded in AuditLog.Action.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditLog is a single entry of the audit trail stored in the audit_logs table.
+// Before is NULL for creates and After is NULL for deletes.
+type AuditLog struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EntityTable string    `gorm:"not null;index:idx_audit_logs_entity" json:"entity_table"`
+	EntityID    string    `gorm:"not null;index:idx_audit_logs_entity" json:"entity_id"`
+	Action      string    `gorm:"not null" json:"action"`
+	Actor       string    `json:"actor"`
+	Before      JSONB     `json:"before"`
+	After       JSONB     `json:"after"`
+	CreatedAt   time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// TableName implements gorm's Tabler interface.
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditTrail is a gorm plugin that records a before/after JSON snapshot of every
+// create, update and delete on the registered models into the audit_logs table.
+// Only rows identified by their primary key or by the statement's WHERE clause are audited.
+//
+// The audit_logs table must be migrated before use:
+//
+//	db.AutoMigrate(&gh.AuditLog{})
+//	db.Use(gh.NewAuditTrail(&Patient{}, &Invoice{}))
+type AuditTrail struct {
+	models []any
+	tables map[string]bool
+}
+
+// NewAuditTrail creates an AuditTrail plugin for the given models.
+func NewAuditTrail(models ...any) *AuditTrail {
+	return &AuditTrail{models: models, tables: map[string]bool{}}
+}
+
+// Name implements gorm.Plugin.
+func (a *AuditTrail) Name() string {
+	return "gh:audit_trail"
+}
+
+// Initialize implements gorm.Plugin.
+func (a *AuditTrail) Initialize(db *gorm.DB) error {
+	for _, model := range a.models {
+		table, err := tableName(db, model)
+		if err != nil {
+			return err
+		}
+		a.tables[table] = true
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("gh:audit_trail_create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gh:audit_trail_before_update", a.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("gh:audit_trail_update", a.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("gh:audit_trail_before_delete", a.before); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("gh:audit_trail_delete", a.afterDelete)
+}
+
+func (a *AuditTrail) enabled(db *gorm.DB) bool {
+	return db.Error == nil && !db.Statement.DryRun && db.Statement.Schema != nil && a.tables[db.Statement.Table]
+}
+
+func (a *AuditTrail) before(db *gorm.DB) {
+	if !a.enabled(db) {
+		return
+	}
+
+	rows, err := snapshotRows(db, true)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet("gh:audit_before", rows)
+}
+
+func (a *AuditTrail) afterCreate(db *gorm.DB) {
+	if !a.enabled(db) {
+		return
+	}
+
+	after, err := snapshotRows(db, false)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	a.record(db, AuditCreate, nil, after)
+}
+
+func (a *AuditTrail) afterUpdate(db *gorm.DB) {
+	if !a.enabled(db) {
+		return
+	}
+
+	before := instanceRows(db, "gh:audit_before")
+	if len(before) == 0 {
+		return
+	}
+
+	after, err := reloadRows(db, before)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	a.record(db, AuditUpdate, before, after)
+}
+
+func (a *AuditTrail) afterDelete(db *gorm.DB) {
+	if !a.enabled(db) {
+		return
+	}
+	a.record(db, AuditDelete, instanceRows(db, "gh:audit_before"), nil)
+}
+
+func (a *AuditTrail) record(db *gorm.DB, action string, before, after []map[string]any) {
+	var actor string
+	if v, ok := ActorFromContext(db.Statement.Context); ok {
+		actor = fmt.Sprint(v)
+	}
+
+	now := db.NowFunc()
+	byKey := map[string]*AuditLog{}
+	logs := []*AuditLog{}
+
+	entry := func(row map[string]any) *AuditLog {
+		key := rowKey(db.Statement.Schema, row)
+		if log, ok := byKey[key]; ok {
+			return log
+		}
+
+		log := &AuditLog{
+			EntityTable: db.Statement.Table,
+			EntityID:    key,
+			Action:      action,
+			Actor:       actor,
+			CreatedAt:   now,
+		}
+		byKey[key] = log
+		logs = append(logs, log)
+		return log
+	}
+
+	for _, row := range before {
+		data, err := json.Marshal(row)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		entry(row).Before = data
+	}
+
+	for _, row := range after {
+		data, err := json.Marshal(row)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		entry(row).After = data
+	}
+
+	if len(logs) == 0 {
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&logs).Error
+	if err != nil {
+		db.AddError(fmt.Errorf("failed to write audit logs: %w", err))
+	}
+}
+
+// AuditHistory returns the audit trail of the model's row identified by id, oldest first.
+// Filters applied on the chain (e.g DateRange("created_at", ...)) are applied to the audit logs.
+func (gdb *GormDB) AuditHistory(model any, id any) ([]AuditLog, error) {
+	table, err := tableName(gdb.db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []AuditLog{}
+	err = gdb.db.Where("entity_table = ? AND entity_id = ?", table, fmt.Sprint(id)).
+		Order("created_at, id").Find(&logs).Error
+	return logs, err
+}
+
+// tableName returns the table name of model.
+func tableName(db *gorm.DB, model any) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+	return stmt.Table, nil
+}
+
+// snapshotRows loads the current rows affected by the statement as column maps.
+// Rows are identified by the primary keys of the statement's model value and,
+// if useWhere is true, by the statement's WHERE clause.
+func snapshotRows(db *gorm.DB, useWhere bool) ([]map[string]any, error) {
+	stmt := db.Statement
+	var conds []clause.Expression
+
+	_, pkValues := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+	if len(pkValues) > 0 {
+		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, pkValues)
+		conds = append(conds, clause.IN{Column: column, Values: values})
+	}
+
+	if useWhere {
+		if where, ok := stmt.Clauses["WHERE"]; ok {
+			if expr, ok := where.Expression.(clause.Where); ok {
+				conds = append(conds, expr.Exprs...)
+			}
+		}
+	}
+
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	rows := []map[string]any{}
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Clauses(clause.Where{Exprs: conds}).
+		Find(&rows).Error
+	return rows, err
+}
+
+// reloadRows loads the current state of rows by their primary keys.
+func reloadRows(db *gorm.DB, rows []map[string]any) ([]map[string]any, error) {
+	stmt := db.Statement
+	if len(stmt.Schema.PrimaryFieldDBNames) == 0 {
+		return nil, nil
+	}
+
+	pkValues := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values := make([]any, len(stmt.Schema.PrimaryFieldDBNames))
+		for i, name := range stmt.Schema.PrimaryFieldDBNames {
+			values[i] = row[name]
+		}
+		pkValues = append(pkValues, values)
+	}
+
+	column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, pkValues)
+	reloaded := []map[string]any{}
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Table(stmt.Table).
+		Clauses(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}}).
+		Find(&reloaded).Error
+	return reloaded, err
+}
+
+// rowKey returns the primary key of row as a string. Composite keys are joined with a comma.
+func rowKey(s *schema.Schema, row map[string]any) string {
+	parts := make([]string, len(s.PrimaryFieldDBNames))
+	for i, name := range s.PrimaryFieldDBNames {
+		parts[i] = fmt.Sprint(row[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+func instanceRows(db *gorm.DB, key string) []map[string]any {
+	v, ok := db.InstanceGet(key)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]map[string]any)
+	return rows
+}
@@ -0,0 +1,89 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditedPatient struct {
+	ID   uint
+	Name string
+}
+
+const insertAuditLog = `INSERT INTO "audit_logs" ("entity_table","entity_id","action","actor","before","after","created_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`
+
+func TestAuditTrail(t *testing.T) {
+	db, mock := mockDB(t)
+	require.NoError(t, db.Use(gh.NewAuditTrail(&auditedPatient{})))
+	ctx := gh.WithActor(context.Background(), "dr.smith")
+
+	// Creates record the row as inserted.
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audited_patients" ("name") VALUES ($1) RETURNING "id"`)).
+		WithArgs("John").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audited_patients" WHERE "audited_patients"."id" = $1`)).
+		WithArgs(uint(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John"))
+	mock.ExpectQuery(regexp.QuoteMeta(insertAuditLog)).
+		WithArgs("audited_patients", "1", gh.AuditCreate, "dr.smith", nil, `{"id":1,"name":"John"}`, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	patient := auditedPatient{Name: "John"}
+	require.NoError(t, db.WithContext(ctx).Create(&patient).Error)
+
+	// Updates record the rows before and after the statement.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audited_patients" WHERE "audited_patients"."id" = $1`)).
+		WithArgs(uint(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "audited_patients" SET "name"=$1 WHERE "id" = $2`)).
+		WithArgs("John Doe", uint(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audited_patients" WHERE "audited_patients"."id" = $1`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John Doe"))
+	mock.ExpectQuery(regexp.QuoteMeta(insertAuditLog)).
+		WithArgs("audited_patients", "1", gh.AuditUpdate, "dr.smith",
+			`{"id":1,"name":"John"}`, `{"id":1,"name":"John Doe"}`, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	require.NoError(t, db.WithContext(ctx).Model(&patient).Update("name", "John Doe").Error)
+
+	// Deletes by conditions record the matching rows.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audited_patients" WHERE name = $1`)).
+		WithArgs("John Doe").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John Doe"))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "audited_patients" WHERE name = $1`)).
+		WithArgs("John Doe").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(insertAuditLog)).
+		WithArgs("audited_patients", "1", gh.AuditDelete, "dr.smith", `{"id":1,"name":"John Doe"}`, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	require.NoError(t, db.WithContext(ctx).Where("name = ?", "John Doe").Delete(&auditedPatient{}).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditHistory(t *testing.T) {
+	db, mock := mockDB(t)
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_logs" WHERE entity_table = $1 AND entity_id = $2 ORDER BY created_at, id`)).
+		WithArgs("audited_patients", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "entity_table", "entity_id", "action", "created_at"}).
+			AddRow(1, "audited_patients", "1", gh.AuditCreate, now.Add(-time.Hour)).
+			AddRow(2, "audited_patients", "1", gh.AuditUpdate, now))
+
+	logs, err := gh.WrapDB(db).AuditHistory(&auditedPatient{}, 1)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, gh.AuditCreate, logs[0].Action)
+	assert.Equal(t, gh.AuditUpdate, logs[1].Action)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
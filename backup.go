@@ -0,0 +1,383 @@
+package gh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// BackupFormat is the format of a backup, see BackupOptions.
+type BackupFormat string
+
+const (
+	BackupCustom    BackupFormat = "custom"    // pg_dump archive, restored with pg_restore
+	BackupPlain     BackupFormat = "plain"     // SQL script, restored with psql
+	BackupDirectory BackupFormat = "directory" // directory of files, allows Jobs
+	BackupTar       BackupFormat = "tar"       // tar archive, restored with pg_restore
+
+	// BackupCopy is a logical dump of the data of Tables with COPY through DB, without the postgres
+	// client tools, e.g to snapshot a few tables from a container that does not have them.
+	// The file can also be restored with psql.
+	BackupCopy BackupFormat = "copy"
+)
+
+// BackupOptions are the options of Backup and Restore.
+type BackupOptions struct {
+	// Config has the database and the credentials, e.g parsed with ParseDSN.
+	// They are passed to the tools in PG* environment variables, never in their arguments.
+	Config PgConfig
+
+	// File is the file (or directory for BackupDirectory) written by Backup and read by Restore.
+	File string
+
+	// Format is the format of File. Default: BackupCustom.
+	Format BackupFormat
+
+	// Tables restricts the backup or restore to these tables, e.g "public.users". Required for BackupCopy.
+	Tables []string
+
+	// ExcludeTables are tables not backed up. Not supported by BackupCopy.
+	ExcludeTables []string
+
+	// Clean drops (or truncates, for BackupCopy) the objects before restoring them.
+	Clean bool
+
+	// Jobs is the number of parallel jobs, for BackupDirectory backups and non plain restores.
+	Jobs int
+
+	// Args are extra arguments of pg_dump, pg_restore or psql, e.g "--no-owner".
+	Args []string
+
+	// BinDir is the directory of pg_dump, pg_restore and psql. Default: found in PATH.
+	BinDir string
+
+	// DB is the database of BackupCopy backups and restores.
+	DB *gorm.DB
+
+	// Progress is called as each table is dumped or restored.
+	Progress func(BackupProgress)
+}
+
+// BackupProgress reports the progress of a Backup or Restore.
+type BackupProgress struct {
+	Table   string // table, if Message is about one
+	Rows    int64  // rows copied, for BackupCopy
+	Message string // verbose message of the tool, or e.g "copied 10 rows of public.users"
+}
+
+// progressTable matches the verbose messages of pg_dump and pg_restore about the data of a table.
+var progressTable = regexp.MustCompile(`(?:dumping contents of|processing data for) table "([^"]+)"`)
+
+// Backup backs up the database of opts.Config to opts.File with pg_dump, or with COPY for BackupCopy.
+// pg_dump runs with --verbose, and its messages are reported to opts.Progress.
+//
+//	err := gh.Backup(ctx, gh.BackupOptions{
+//		Config: cfg,
+//		File:   "/backups/clinic.dump",
+//		Progress: func(p gh.BackupProgress) {
+//			log.Println(p.Message)
+//		},
+//	})
+func Backup(ctx context.Context, opts BackupOptions) error {
+	format := opts.Format
+	if format == "" {
+		format = BackupCustom
+	}
+
+	switch format {
+	case BackupCopy:
+		return backupCopy(ctx, opts)
+	case BackupCustom, BackupPlain, BackupDirectory, BackupTar:
+	default:
+		return fmt.Errorf("unknown backup format %q", format)
+	}
+
+	args := []string{"--verbose", "--format=" + string(format), "--file=" + opts.File}
+	if opts.Jobs > 0 {
+		args = append(args, "--jobs="+strconv.Itoa(opts.Jobs))
+	}
+	for _, table := range opts.Tables {
+		args = append(args, "--table="+table)
+	}
+	for _, table := range opts.ExcludeTables {
+		args = append(args, "--exclude-table="+table)
+	}
+
+	if err := runPgTool(ctx, opts, "pg_dump", append(args, opts.Args...)); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", opts.Config.Database, err)
+	}
+	return nil
+}
+
+// Restore restores opts.File to the database of opts.Config, with pg_restore, psql for BackupPlain
+// backups, or COPY for BackupCopy. opts.Tables restricts the restore to these tables, except for plain
+// backups. BackupCopy restores run in a transaction, in the order of the file.
+//
+//	err := gh.Restore(ctx, gh.BackupOptions{Config: cfg, File: "/backups/clinic.dump", Clean: true, Jobs: 4})
+func Restore(ctx context.Context, opts BackupOptions) error {
+	format := opts.Format
+	if format == "" {
+		format = BackupCustom
+	}
+
+	var err error
+	switch format {
+	case BackupCopy:
+		err = restoreCopy(ctx, opts)
+	case BackupPlain:
+		if opts.Clean {
+			return errors.New("clean is not supported by plain restores, back up with pg_dump --clean instead")
+		}
+		args := []string{"--echo-errors", "--set=ON_ERROR_STOP=1", "--file=" + opts.File}
+		err = runPgTool(ctx, opts, "psql", append(args, opts.Args...))
+	case BackupCustom, BackupDirectory, BackupTar:
+		args := []string{"--verbose", "--dbname=" + opts.Config.Database}
+		if opts.Clean {
+			args = append(args, "--clean", "--if-exists")
+		}
+		if opts.Jobs > 0 {
+			args = append(args, "--jobs="+strconv.Itoa(opts.Jobs))
+		}
+		for _, table := range opts.Tables {
+			args = append(args, "--table="+table)
+		}
+		err = runPgTool(ctx, opts, "pg_restore", append(append(args, opts.Args...), opts.File))
+	default:
+		return fmt.Errorf("unknown backup format %q", format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", opts.File, err)
+	}
+	return nil
+}
+
+// runPgTool runs a postgres client tool with the credentials of opts.Config, reporting its
+// stderr lines to opts.Progress. The error has the last lines of stderr.
+func runPgTool(ctx context.Context, opts BackupOptions, name string, args []string) error {
+	if opts.BinDir != "" {
+		name = filepath.Join(opts.BinDir, name)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), pgEnv(opts.Config)...)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var tail []string
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		tail = append(tail, line)
+		if len(tail) > 5 {
+			tail = tail[1:]
+		}
+
+		if opts.Progress != nil {
+			progress := BackupProgress{Message: line}
+			if m := progressTable.FindStringSubmatch(line); m != nil {
+				progress.Table = m[1]
+			}
+			opts.Progress(progress)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if len(tail) > 0 {
+			return fmt.Errorf("%s: %w: %s", filepath.Base(name), err, strings.Join(tail, "\n"))
+		}
+		return fmt.Errorf("%s: %w", filepath.Base(name), err)
+	}
+	return nil
+}
+
+// pgEnv returns the libpq environment variables of config.
+func pgEnv(config PgConfig) []string {
+	env := []string{}
+	vars := []struct{ name, value string }{
+		{"PGHOST", config.Host},
+		{"PGPORT", config.Port},
+		{"PGUSER", config.User},
+		{"PGPASSWORD", config.Password},
+		{"PGDATABASE", config.Database},
+		{"PGTZ", config.Timezone},
+	}
+	for _, v := range vars {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+
+	// ParseDSN defaults to "disabled", which libpq does not know.
+	if config.SSLMode == "disabled" {
+		env = append(env, "PGSSLMODE=disable")
+	} else if config.SSLMode != "" {
+		env = append(env, "PGSSLMODE="+config.SSLMode)
+	}
+	return env
+}
+
+// copyStatement matches the COPY statements of BackupCopy files.
+var copyStatement = regexp.MustCompile(`^COPY (.+) FROM stdin;$`)
+
+// backupCopy dumps opts.Tables with COPY TO STDOUT, in the format of plain pg_dump data sections.
+func backupCopy(ctx context.Context, opts BackupOptions) error {
+	if opts.DB == nil || len(opts.Tables) == 0 {
+		return errors.New("copy backups require DB and Tables")
+	}
+
+	f, err := os.Create(opts.File)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	err = withPgConn(ctx, opts.DB, func(pc *stdlib.Conn) error {
+		for _, table := range opts.Tables {
+			fmt.Fprintf(w, "COPY %s FROM stdin;\n", quoteIdent(table))
+			tag, err := pc.Conn().PgConn().CopyTo(ctx, w, "COPY "+quoteIdent(table)+" TO STDOUT")
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table, err)
+			}
+			fmt.Fprint(w, "\\.\n\n")
+
+			if opts.Progress != nil {
+				rows := tag.RowsAffected()
+				opts.Progress(BackupProgress{Table: table, Rows: rows, Message: fmt.Sprintf("copied %d rows of %s", rows, table)})
+			}
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", strings.Join(opts.Tables, ", "), err)
+	}
+	return f.Close()
+}
+
+// restoreCopy restores a BackupCopy file with COPY FROM STDIN, in a transaction.
+func restoreCopy(ctx context.Context, opts BackupOptions) error {
+	if opts.DB == nil {
+		return errors.New("copy restores require DB")
+	}
+
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return withPgConn(ctx, opts.DB, func(pc *stdlib.Conn) error {
+		tx, err := pc.Conn().Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadString('\n')
+			if err == io.EOF && line == "" {
+				break
+			}
+			if err != nil && err != io.EOF {
+				return err
+			}
+
+			m := copyStatement.FindStringSubmatch(strings.TrimSuffix(line, "\n"))
+			if m == nil {
+				continue
+			}
+
+			table := m[1]
+			if opts.Clean {
+				if _, err := tx.Exec(ctx, "TRUNCATE "+table+" CASCADE"); err != nil {
+					return fmt.Errorf("failed to truncate %s: %w", table, err)
+				}
+			}
+
+			tag, err := tx.Conn().PgConn().CopyFrom(ctx, &copyDataReader{r: r}, "COPY "+table+" FROM STDIN")
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table, err)
+			}
+
+			if opts.Progress != nil {
+				rows := tag.RowsAffected()
+				opts.Progress(BackupProgress{Table: table, Rows: rows, Message: fmt.Sprintf("copied %d rows of %s", rows, table)})
+			}
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+// copyDataReader reads the data of a COPY statement, up to its \. terminator line.
+type copyDataReader struct {
+	r    *bufio.Reader
+	line []byte
+	done bool
+}
+
+func (c *copyDataReader) Read(p []byte) (int, error) {
+	for len(c.line) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		line, err := c.r.ReadBytes('\n')
+		if bytes.Equal(bytes.TrimSuffix(line, []byte("\n")), []byte(`\.`)) {
+			c.done = true
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		c.line = line
+	}
+
+	n := copy(p, c.line)
+	c.line = c.line[n:]
+	return n, nil
+}
+
+// withPgConn calls fn with a pgx connection of db.
+func withPgConn(ctx context.Context, db *gorm.DB, fn func(pc *stdlib.Conn) error) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("%w: got %T", ErrUnsupportedDriver, driverConn)
+		}
+		return fn(pc)
+	})
+}
@@ -0,0 +1,105 @@
+package gh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePgTool writes a shell script named name to dir, recording its arguments and credentials
+// to name.log and printing stderr to its stderr.
+func fakePgTool(t *testing.T, dir, name, stderr string, code int) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := "#!/bin/sh\n" +
+		`echo "$@" > "$0.log"` + "\n" +
+		`echo "$PGHOST $PGPORT $PGUSER $PGPASSWORD $PGDATABASE $PGSSLMODE" >> "$0.log"` + "\n" +
+		"printf '" + stderr + "' >&2\n" +
+		"exit " + string(rune('0'+code)) + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755))
+}
+
+func readToolLog(t *testing.T, dir, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name+".log"))
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	fakePgTool(t, dir, "pg_dump", `pg_dump: reading schemas\npg_dump: dumping contents of table "public.patients"\n`, 0)
+
+	config := gh.PgConfig{Host: "db", Port: "5432", User: "clinic", Password: "s3cret", Database: "clinic", SSLMode: "disabled"}
+	var progress []gh.BackupProgress
+	err := gh.Backup(context.Background(), gh.BackupOptions{
+		Config:        config,
+		File:          "/backups/clinic.dump",
+		Tables:        []string{"public.patients"},
+		ExcludeTables: []string{"audit_logs"},
+		Args:          []string{"--no-owner"},
+		BinDir:        dir,
+		Progress:      func(p gh.BackupProgress) { progress = append(progress, p) },
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"--verbose --format=custom --file=/backups/clinic.dump --table=public.patients --exclude-table=audit_logs --no-owner",
+		"db 5432 clinic s3cret clinic disable",
+	}, readToolLog(t, dir, "pg_dump"))
+	assert.Equal(t, []gh.BackupProgress{
+		{Message: "pg_dump: reading schemas"},
+		{Table: "public.patients", Message: `pg_dump: dumping contents of table "public.patients"`},
+	}, progress)
+
+	fakePgTool(t, dir, "pg_dump", `pg_dump: error: connection refused\n`, 1)
+	err = gh.Backup(context.Background(), gh.BackupOptions{Config: config, File: "clinic.dump", BinDir: dir})
+	assert.EqualError(t, err, "failed to back up clinic: pg_dump: exit status 1: pg_dump: error: connection refused")
+
+	err = gh.Backup(context.Background(), gh.BackupOptions{Format: "zip"})
+	assert.EqualError(t, err, `unknown backup format "zip"`)
+
+	err = gh.Backup(context.Background(), gh.BackupOptions{Format: gh.BackupCopy, Tables: []string{"patients"}})
+	assert.EqualError(t, err, "copy backups require DB and Tables")
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	fakePgTool(t, dir, "pg_restore", `pg_restore: processing data for table "public.visits"\n`, 0)
+	fakePgTool(t, dir, "psql", "", 0)
+
+	config := gh.PgConfig{Host: "localhost", Port: "5432", User: "postgres", Database: "clinic", SSLMode: "require"}
+	var tables []string
+	err := gh.Restore(context.Background(), gh.BackupOptions{
+		Config:   config,
+		File:     "clinic.dump",
+		Clean:    true,
+		Jobs:     4,
+		BinDir:   dir,
+		Progress: func(p gh.BackupProgress) { tables = append(tables, p.Table) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--verbose --dbname=clinic --clean --if-exists --jobs=4 clinic.dump",
+		"localhost 5432 postgres  clinic require",
+	}, readToolLog(t, dir, "pg_restore"))
+	assert.Equal(t, []string{"public.visits"}, tables)
+
+	require.NoError(t, gh.Restore(context.Background(), gh.BackupOptions{
+		Config: config,
+		File:   "clinic.sql",
+		Format: gh.BackupPlain,
+		BinDir: dir,
+	}))
+	assert.Equal(t, "--echo-errors --set=ON_ERROR_STOP=1 --file=clinic.sql", readToolLog(t, dir, "psql")[0])
+}
@@ -0,0 +1,375 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// Cache stores the encoded results of Cached queries. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of key, reporting whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes keys. Missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+
+	// AddToSet adds members to the set stored under key, atomically, and sets its ttl.
+	AddToSet(ctx context.Context, key string, ttl time.Duration, members ...string) error
+
+	// PopSet removes the set stored under key and returns its members, atomically.
+	PopSet(ctx context.Context, key string) ([]string, error)
+}
+
+// MemoryCache is an in-process Cache. Expired entries are removed when read.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	sets    map[string]cacheSet
+}
+
+type cacheEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+type cacheSet struct {
+	members   map[string]struct{}
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]cacheEntry{}, sets: map[string]cacheSet{}}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.data, ok, nil
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{data: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Delete implements Cache.
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+		delete(c.sets, key)
+	}
+	return nil
+}
+
+// AddToSet implements Cache.
+func (c *MemoryCache) AddToSet(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set, ok := c.sets[key]
+	if !ok || time.Now().After(set.expiresAt) {
+		set = cacheSet{members: map[string]struct{}{}}
+	}
+	for _, member := range members {
+		set.members[member] = struct{}{}
+	}
+	set.expiresAt = time.Now().Add(ttl)
+	c.sets[key] = set
+	return nil
+}
+
+// PopSet implements Cache.
+func (c *MemoryCache) PopSet(ctx context.Context, key string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set, ok := c.sets[key]
+	delete(c.sets, key)
+	if !ok || time.Now().After(set.expiresAt) {
+		return nil, nil
+	}
+	return slices.Sorted(maps.Keys(set.members)), nil
+}
+
+const cacheKey = "gh:cache"
+
+// tagTTL is the minimum lifetime of the tags of cached entries, refreshed when an entry is added.
+const tagTTL = 24 * time.Hour
+
+type cacheSettings struct {
+	key string
+	ttl time.Duration
+}
+
+var (
+	defaultCache = NewMemoryCache()
+	cacheGroup   singleflight.Group
+)
+
+// CachePlugin is a gorm plugin setting the Cache of Cached queries, and invalidating the cached
+// results of a table when it is written: creates, and updates or deletes without primary keys,
+// invalidate all the results of the table; updates and deletes of models with primary keys
+// invalidate the results containing those rows. A row updated into the conditions of a cached
+// query it was not part of is only seen once the entry expires.
+//
+// Results are invalidated once the default transaction of the statement commits, so concurrent
+// queries can not cache the rows it is replacing. Statements of transactions started with
+// Transaction or Begin are invalidated when they run, before the transaction commits: call
+// InvalidateTables after the commit to drop results cached in between.
+//
+//	db.Use(&gh.CachePlugin{Cache: rediscache.New(client, "myapp:")})
+type CachePlugin struct {
+	// Cache stores the results. Default: an in-process MemoryCache, not shared with BustCache.
+	Cache Cache
+}
+
+// Name implements gorm.Plugin.
+func (p *CachePlugin) Name() string {
+	return cacheKey
+}
+
+// Initialize implements gorm.Plugin, registering the invalidation callbacks.
+func (p *CachePlugin) Initialize(db *gorm.DB) error {
+	if p.Cache == nil {
+		p.Cache = NewMemoryCache()
+	}
+
+	const commit = "gorm:commit_or_rollback_transaction"
+	cb := db.Callback()
+	if err := cb.Create().After(commit).Register("gh:cache_invalidate", p.invalidateTable); err != nil {
+		return err
+	}
+	if err := cb.Update().After(commit).Register("gh:cache_invalidate", p.invalidateRows); err != nil {
+		return err
+	}
+	return cb.Delete().After(commit).Register("gh:cache_invalidate", p.invalidateRows)
+}
+
+// InvalidateTables deletes all the cached results of tables, e.g after committing a transaction
+// writing them.
+//
+//	err := db.Transaction(func(tx *gorm.DB) error { ... })
+//	plugin.InvalidateTables(ctx, "departments")
+func (p *CachePlugin) InvalidateTables(ctx context.Context, tables ...string) error {
+	tags := make([]string, len(tables))
+	for i, table := range tables {
+		tags[i] = tableTag(table)
+	}
+	return deleteTagged(ctx, p.Cache, tags)
+}
+
+// invalidateTable deletes the cached results of the table written by db.
+func (p *CachePlugin) invalidateTable(db *gorm.DB) {
+	if db.Error == nil && db.Statement.Table != "" {
+		p.invalidate(db, []string{tableTag(db.Statement.Table)})
+	}
+}
+
+// invalidateRows deletes the cached results containing the models written by db,
+// or all the results of the table if their primary keys are unknown.
+func (p *CachePlugin) invalidateRows(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Table == "" {
+		return
+	}
+
+	keys := primaryKeys(db.Statement)
+	if len(keys) == 0 {
+		p.invalidateTable(db)
+		return
+	}
+
+	tags := make([]string, len(keys))
+	for i, key := range keys {
+		tags[i] = rowTag(db.Statement.Table, key)
+	}
+	p.invalidate(db, tags)
+}
+
+func (p *CachePlugin) invalidate(db *gorm.DB, tags []string) {
+	if err := deleteTagged(db.Statement.Context, p.Cache, tags); err != nil {
+		db.Logger.Error(db.Statement.Context, "failed to invalidate cached %s: %v", db.Statement.Table, err)
+	}
+}
+
+// Cached serves the results of First and Find from the cache under key for ttl, e.g for reference
+// data read on every request. On a miss, concurrent callers with the same key share a single query.
+// Errors, including gorm.ErrRecordNotFound, are not cached. Results are copied with encoding/gob,
+// so only exported fields are cached. Cache errors are logged, and the query is run.
+//
+// The cache is in-process, unless set with CachePlugin.
+//
+//	departments := []Department{}
+//	err := gh.WrapDB(db).Cached("departments", 5*time.Minute).Order("name").Find(&departments)
+func (gdb *GormDB) Cached(key string, ttl time.Duration) *GormDB {
+	gdb.db = gdb.db.Set(cacheKey, cacheSettings{key: key, ttl: ttl})
+	return gdb
+}
+
+// BustCache removes the results cached under keys in the in-process cache, e.g after updating
+// reference data. For a Cache set with CachePlugin, use its Delete method.
+func BustCache(keys ...string) {
+	defaultCache.Delete(context.Background(), keys...)
+}
+
+// cacheOf returns the Cache of db's CachePlugin, or the in-process cache.
+func cacheOf(db *gorm.DB) Cache {
+	if plugin, ok := db.Config.Plugins[cacheKey].(*CachePlugin); ok {
+		return plugin.Cache
+	}
+	return defaultCache
+}
+
+// cached runs query, filling dest, unless the chain is Cached and its results are in the cache.
+func (gdb *GormDB) cached(dest any, query func() *gorm.DB) error {
+	v, ok := gdb.db.Get(cacheKey)
+	if !ok {
+		return query().Error
+	}
+
+	settings := v.(cacheSettings)
+	ctx := gdb.db.Statement.Context
+	cache := cacheOf(gdb.db)
+
+	data, found, err := cache.Get(ctx, settings.key)
+	if err != nil {
+		gdb.db.Logger.Error(ctx, "failed to read cached %s: %v", settings.key, err)
+	}
+	if found {
+		return decodeCached(settings.key, data, dest)
+	}
+
+	leader := false
+	result, err, _ := cacheGroup.Do(settings.key, func() (any, error) {
+		leader = true
+		tx := query()
+		if tx.Error != nil {
+			return nil, tx.Error
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(dest); err != nil {
+			return nil, fmt.Errorf("failed to cache %s: %w", settings.key, err)
+		}
+
+		if err := cache.Set(ctx, settings.key, buf.Bytes(), settings.ttl); err != nil {
+			gdb.db.Logger.Error(ctx, "failed to cache %s: %v", settings.key, err)
+		} else if err := tagCached(ctx, cache, tx.Statement, settings); err != nil {
+			gdb.db.Logger.Error(ctx, "failed to tag cached %s: %v", settings.key, err)
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil || leader {
+		return err
+	}
+	return decodeCached(settings.key, result.([]byte), dest)
+}
+
+// decodeCached decodes cached results into dest, zeroed first since gob omits zero values.
+func decodeCached(key string, data []byte, dest any) error {
+	reflect.ValueOf(dest).Elem().SetZero()
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(dest); err != nil {
+		return fmt.Errorf("failed to decode cached %s: %w", key, err)
+	}
+	return nil
+}
+
+// Tags are cache sets of the keys of the results of a table (or row), deleted when it is written.
+func tableTag(table string) string {
+	return "gh:cache:tag:" + table
+}
+
+func rowTag(table, primaryKey string) string {
+	return "gh:cache:tag:" + table + ":" + primaryKey
+}
+
+// tagCached adds the key of the cached results of stmt to the tags of its table and rows.
+func tagCached(ctx context.Context, cache Cache, stmt *gorm.Statement, settings cacheSettings) error {
+	if stmt.Table == "" {
+		return nil
+	}
+
+	tags := []string{tableTag(stmt.Table)}
+	for _, key := range primaryKeys(stmt) {
+		tags = append(tags, rowTag(stmt.Table, key))
+	}
+
+	for _, tag := range tags {
+		if err := cache.AddToSet(ctx, tag, max(settings.ttl, tagTTL), settings.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTagged deletes the keys listed in tags, and the tags.
+func deleteTagged(ctx context.Context, cache Cache, tags []string) error {
+	keys := []string{}
+	for _, tag := range tags {
+		members, err := cache.PopSet(ctx, tag)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, members...)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return cache.Delete(ctx, keys...)
+}
+
+// primaryKeys returns the non-zero primary keys of the models of stmt, formatted with %v.
+func primaryKeys(stmt *gorm.Statement) []string {
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil || !stmt.ReflectValue.IsValid() {
+		return nil
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	keys := []string{}
+	add := func(rv reflect.Value) {
+		if rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+			return
+		}
+		if value, zero := field.ValueOf(stmt.Context, rv); !zero {
+			keys = append(keys, fmt.Sprint(value))
+		}
+	}
+
+	switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(rv.Index(i))
+		}
+	default:
+		add(rv)
+	}
+	return keys
+}
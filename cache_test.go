@@ -0,0 +1,163 @@
+package gh_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Department struct {
+	ID   uint
+	Name string
+}
+
+func TestCached(t *testing.T) {
+	db, mock := mockDB(t)
+	t.Cleanup(func() { gh.BustCache("test:departments") })
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Surgery").AddRow(2, "Pediatrics")
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "departments" ORDER BY name`).WillReturnRows(rows())
+
+	for range 2 {
+		departments := []Department{}
+		require.NoError(t, gh.WrapDB(db).Cached("test:departments", time.Minute).Order("name").Find(&departments))
+		assert.Equal(t, []Department{{1, "Surgery"}, {2, "Pediatrics"}}, departments)
+	}
+
+	// Not found is not cached.
+	mock.ExpectQuery(`SELECT \* FROM "departments" WHERE "departments"."id" = \$1`).WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	var department Department
+	assert.ErrorIs(t, gh.WrapDB(db).Cached("test:department:9", time.Minute).First(&department, 9), gorm.ErrRecordNotFound)
+
+	gh.BustCache("test:departments")
+	mock.ExpectQuery(`SELECT \* FROM "departments"`).WillReturnRows(rows())
+	departments := []Department{}
+	require.NoError(t, gh.WrapDB(db).Cached("test:departments", time.Minute).Find(&departments))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCachedExpiryAndSingleflight(t *testing.T) {
+	db, mock := mockDB(t)
+	t.Cleanup(func() { gh.BustCache("test:singleflight") })
+
+	mock.ExpectQuery(`SELECT \* FROM "departments"`).WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Surgery"))
+
+	var wg sync.WaitGroup
+	results := make([][]Department, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, gh.WrapDB(db).Cached("test:singleflight", 200*time.Millisecond).Find(&results[i]))
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		assert.Equal(t, []Department{{1, "Surgery"}}, result)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	mock.ExpectQuery(`SELECT \* FROM "departments"`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "General Surgery"))
+
+	var department Department
+	require.NoError(t, gh.WrapDB(db).Cached("test:singleflight", time.Minute).First(&department))
+	assert.Equal(t, "General Surgery", department.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCachePluginInvalidation(t *testing.T) {
+	db, mock := mockDB(t)
+	plugin := &gh.CachePlugin{}
+	require.NoError(t, db.Use(plugin))
+
+	ctx := context.Background()
+	cached := func(key string) bool {
+		_, found, err := plugin.Cache.Get(ctx, key)
+		require.NoError(t, err)
+		return found
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "departments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Surgery").AddRow(2, "Pediatrics"))
+	mock.ExpectQuery(`SELECT \* FROM "departments" WHERE "departments"."id" = \$1`).WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Pediatrics"))
+
+	departments, department := []Department{}, Department{}
+	require.NoError(t, gh.WrapDB(db).Cached("departments", time.Minute).Find(&departments))
+	require.NoError(t, gh.WrapDB(db).Cached("department:2", time.Minute).First(&department, 2))
+
+	// Updating department 1 invalidates the results containing it.
+	mock.ExpectExec(`UPDATE "departments" SET "name"=\$1 WHERE "id" = \$2`).WithArgs("General Surgery", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, db.Model(&departments[0]).Update("name", "General Surgery").Error)
+	assert.False(t, cached("departments"))
+	assert.True(t, cached("department:2"))
+
+	// Creating a department invalidates all the results of the table.
+	mock.ExpectQuery(`INSERT INTO "departments"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	require.NoError(t, db.Create(&Department{Name: "Radiology"}).Error)
+	assert.False(t, cached("department:2"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCachePluginInvalidatesAfterCommit(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	plugin := &gh.CachePlugin{}
+	require.NoError(t, db.Use(plugin))
+	ctx := context.Background()
+	require.NoError(t, plugin.Cache.Set(ctx, "departments", []byte("cached"), time.Minute))
+	require.NoError(t, plugin.Cache.AddToSet(ctx, "gh:cache:tag:departments", time.Minute, "departments"))
+
+	// A query caching the results before the commit is invalidated by it.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "departments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, db.Callback().Update().Before("gorm:commit_or_rollback_transaction").
+		Register("test:before_commit", func(*gorm.DB) {
+			_, found, _ := plugin.Cache.Get(ctx, "departments")
+			assert.True(t, found, "invalidated before commit")
+		}))
+	require.NoError(t, db.Model(&Department{}).Where("name = ?", "Surgery").Update("name", "General Surgery").Error)
+
+	_, found, err := plugin.Cache.Get(ctx, "departments")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// Tags are shared sets: concurrent callers do not drop each other's keys.
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, plugin.Cache.AddToSet(ctx, "tag", time.Minute, fmt.Sprint(i)))
+		}()
+	}
+	wg.Wait()
+	members, err := plugin.Cache.PopSet(ctx, "tag")
+	require.NoError(t, err)
+	assert.Len(t, members, 20)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
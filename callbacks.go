@@ -0,0 +1,25 @@
+package gh
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// forEachModelValue calls fn with each addressable struct value of the statement,
+// i.e the struct itself or each element of a slice of structs.
+func forEachModelValue(stmt *gorm.Statement, fn func(rv reflect.Value)) {
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			rv := reflect.Indirect(stmt.ReflectValue.Index(i))
+			if rv.Kind() == reflect.Struct && rv.CanAddr() {
+				fn(rv)
+			}
+		}
+	case reflect.Struct:
+		if stmt.ReflectValue.CanAddr() {
+			fn(stmt.ReflectValue)
+		}
+	}
+}
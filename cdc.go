@@ -0,0 +1,456 @@
+package gh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ChangeOp is the operation of a Change.
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "insert"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete"
+)
+
+// Change is a row change of T decoded from the write-ahead log.
+type Change[T any] struct {
+	Op    ChangeOp
+	Table string // schema qualified, e.g public.users
+	LSN   string // log sequence number of the change, e.g 0/16B3748
+
+	// Old is the row before an update or delete. Only its primary key is set, unless the table has
+	// REPLICA IDENTITY FULL. It is nil for updates that do not change the primary key.
+	Old *T
+
+	// New is the row after an insert or update. Unchanged TOASTed values, e.g large text,
+	// are not sent and have their zero value.
+	New *T
+}
+
+// ErrInvalidWAL is returned when a logical replication message can not be decoded.
+var ErrInvalidWAL = errors.New("invalid logical replication message")
+
+// ChangeCapture streams the row changes of registered tables from a logical replication slot
+// (change-data-capture), e.g to sync caches or search indexes without triggers. Changes are read
+// with the pgoutput plugin through the SQL interface of the slot, so the connections of db are used
+// and no replication connection is needed. The server must run with wal_level = logical.
+//
+// Delivery is at-least-once and in commit order: the slot is advanced past a transaction once
+// all its changes are handled, so a transaction is delivered again if a handler fails.
+//
+//	capture := gh.NewChangeCapture(db)
+//	gh.Capture(capture, func(ctx context.Context, change gh.Change[Patient]) error {
+//		return index.Sync(ctx, change.New)
+//	})
+//	if err := capture.Setup(ctx); err != nil {
+//		return err
+//	}
+//	go capture.Run(ctx)
+type ChangeCapture struct {
+	// Slot is the name of the replication slot. Default: gh_cdc.
+	Slot string
+
+	// Publication is the name of the publication of the tables. Default: gh_cdc.
+	Publication string
+
+	// BatchSize is the number of changes read per poll, rounded up to whole transactions. Default: 1000.
+	BatchSize int
+
+	// PollInterval is the interval between polls when there are no changes. Default: 1s.
+	PollInterval time.Duration
+
+	db        *gorm.DB
+	types     *pgtype.Map
+	mu        sync.Mutex
+	tables    []string
+	handlers  map[string]changeHandler // by table name of the model
+	relations map[uint32]*walRelation
+}
+
+// changeHandler decodes a change of a table and calls the handler of its model.
+type changeHandler func(ctx context.Context, op ChangeOp, rel *walRelation, old, new walTuple, lsn string) error
+
+// NewChangeCapture creates a ChangeCapture of the changes of db.
+func NewChangeCapture(db *gorm.DB) *ChangeCapture {
+	return &ChangeCapture{
+		db:        db,
+		types:     pgtype.NewMap(),
+		handlers:  map[string]changeHandler{},
+		relations: map[uint32]*walRelation{},
+	}
+}
+
+// Capture subscribes fn to the changes of the table of T. Call it before Setup,
+// so the table is added to the publication.
+func Capture[T any](c *ChangeCapture, fn func(ctx context.Context, change Change[T]) error) error {
+	stmt := &gorm.Statement{DB: c.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return fmt.Errorf("failed to parse %T: %w", *new(T), err)
+	}
+
+	decode := func(rel *walRelation, tuple walTuple) (*T, error) {
+		if tuple == nil {
+			return nil, nil
+		}
+
+		row := new(T)
+		rv := reflect.ValueOf(row).Elem()
+		for i, column := range rel.columns {
+			field := stmt.Schema.LookUpField(column.name)
+			if field == nil || i >= len(tuple) || tuple[i].kind != 't' {
+				continue
+			}
+			if err := c.scanColumn(field, rv, column.typeOID, tuple[i].data); err != nil {
+				return nil, fmt.Errorf("failed to decode %s.%s: %w", rel.name, column.name, err)
+			}
+		}
+		return row, nil
+	}
+
+	handler := func(ctx context.Context, op ChangeOp, rel *walRelation, old, new walTuple, lsn string) error {
+		change := Change[T]{Op: op, Table: rel.name, LSN: lsn}
+		var err error
+		if change.Old, err = decode(rel, old); err != nil {
+			return err
+		}
+		if change.New, err = decode(rel, new); err != nil {
+			return err
+		}
+		return fn(ctx, change)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables = append(c.tables, stmt.Table)
+	c.handlers[stmt.Table] = handler
+	return nil
+}
+
+// scanColumn sets field of rv to the text value of a column, falling back to gorm's conversions
+// for types pgx can not scan into, e.g fields with a serializer.
+func (c *ChangeCapture) scanColumn(field *schema.Field, rv reflect.Value, oid uint32, data []byte) error {
+	dst := field.ReflectValueOf(context.Background(), rv).Addr().Interface()
+	if err := c.types.Scan(oid, pgtype.TextFormatCode, data, dst); err == nil {
+		return nil
+	}
+	return field.Set(context.Background(), rv, string(data))
+}
+
+func (c *ChangeCapture) slot() string {
+	if c.Slot == "" {
+		return "gh_cdc"
+	}
+	return c.Slot
+}
+
+func (c *ChangeCapture) publication() string {
+	if c.Publication == "" {
+		return "gh_cdc"
+	}
+	return c.Publication
+}
+
+// Setup creates (or updates) the publication of the captured tables, and creates the replication
+// slot if it does not exist. Changes are retained from the creation of the slot, until they are read:
+// Drop the slot when it is no longer used, or the server keeps the write-ahead log forever.
+func (c *ChangeCapture) Setup(ctx context.Context) error {
+	c.mu.Lock()
+	tables := make([]string, len(c.tables))
+	for i, table := range c.tables {
+		tables[i] = quoteIdent(table)
+	}
+	c.mu.Unlock()
+	if len(tables) == 0 {
+		return errors.New("no table captured")
+	}
+
+	db := c.db.WithContext(ctx)
+	var exists bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = ?)", c.publication()).Scan(&exists).Error; err != nil {
+		return fmt.Errorf("failed to check publication %s: %w", c.publication(), err)
+	}
+
+	sql := "CREATE PUBLICATION " + quoteName(c.publication()) + " FOR TABLE "
+	if exists {
+		sql = "ALTER PUBLICATION " + quoteName(c.publication()) + " SET TABLE "
+	}
+	if err := db.Exec(sql + strings.Join(tables, ", ")).Error; err != nil {
+		return fmt.Errorf("failed to set up publication %s: %w", c.publication(), err)
+	}
+
+	err := db.Exec("SELECT pg_create_logical_replication_slot(?, 'pgoutput') "+
+		"WHERE NOT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = ?)", c.slot(), c.slot()).Error
+	if err != nil {
+		return fmt.Errorf("failed to create replication slot %s: %w", c.slot(), err)
+	}
+	return nil
+}
+
+// Drop drops the replication slot and the publication.
+func (c *ChangeCapture) Drop(ctx context.Context) error {
+	db := c.db.WithContext(ctx)
+	err := db.Exec("SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = ?", c.slot()).Error
+	if err != nil {
+		return fmt.Errorf("failed to drop replication slot %s: %w", c.slot(), err)
+	}
+	if err := db.Exec("DROP PUBLICATION IF EXISTS " + quoteName(c.publication())).Error; err != nil {
+		return fmt.Errorf("failed to drop publication %s: %w", c.publication(), err)
+	}
+	return nil
+}
+
+// Run captures changes until ctx is canceled and returns ctx.Err().
+// Errors are logged with db's logger and the failed transaction is retried on the next poll.
+func (c *ChangeCapture) Run(ctx context.Context) error {
+	poll := durationOr(c.PollInterval, time.Second)
+	for {
+		n, err := c.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			c.db.Logger.Error(ctx, "change capture: %v", err)
+		}
+
+		// Keep going while full batches are read.
+		if err == nil && n >= c.batchSize() {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+func (c *ChangeCapture) batchSize() int {
+	if c.BatchSize <= 0 {
+		return 1000
+	}
+	return c.BatchSize
+}
+
+// walMessage is a pgoutput message read from the slot.
+type walMessage struct {
+	LSN  string
+	Data []byte
+}
+
+// Poll handles a batch of changes and returns the number of changes handled. It stops at the first
+// handler error, advancing the slot past the transactions handled so far.
+func (c *ChangeCapture) Poll(ctx context.Context) (int, error) {
+	db := c.db.WithContext(ctx)
+	messages := []walMessage{}
+	err := db.Raw("SELECT lsn::text AS lsn, data FROM pg_logical_slot_peek_binary_changes(?, NULL, ?, "+
+		"'proto_version', '1', 'publication_names', ?)", c.slot(), c.batchSize(), c.publication()).
+		Scan(&messages).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replication slot %s: %w", c.slot(), err)
+	}
+
+	handled, pending := 0, 0
+	committed := ""
+	var handleErr error
+	for _, msg := range messages {
+		n, commit, err := c.handle(ctx, msg)
+		if err != nil {
+			handleErr = err
+			break
+		}
+
+		pending += n
+		if commit {
+			committed = msg.LSN
+			handled += pending
+			pending = 0
+		}
+	}
+
+	if committed != "" {
+		if err := db.Exec("SELECT pg_replication_slot_advance(?, ?::pg_lsn)", c.slot(), committed).Error; err != nil {
+			return 0, fmt.Errorf("failed to advance replication slot %s: %w", c.slot(), err)
+		}
+	}
+	return handled, handleErr
+}
+
+// handle decodes a pgoutput message and calls the handler of its table. It returns the number of
+// changes handled and whether the message is the commit of a transaction.
+func (c *ChangeCapture) handle(ctx context.Context, msg walMessage) (int, bool, error) {
+	if len(msg.Data) == 0 {
+		return 0, false, ErrInvalidWAL
+	}
+
+	r := &walReader{data: msg.Data[1:]}
+	switch msg.Data[0] {
+	case 'C': // commit
+		return 0, true, nil
+	case 'R': // relation
+		rel, err := r.relation()
+		if err != nil {
+			return 0, false, err
+		}
+		c.mu.Lock()
+		c.relations[rel.id] = rel
+		c.mu.Unlock()
+		return 0, false, nil
+	case 'I', 'U', 'D':
+	default: // begin, origin, type and truncate
+		return 0, false, nil
+	}
+
+	c.mu.Lock()
+	rel := c.relations[r.uint32()]
+	c.mu.Unlock()
+	if rel == nil {
+		return 0, false, fmt.Errorf("%w: change before its relation", ErrInvalidWAL)
+	}
+
+	var op ChangeOp
+	var old, new walTuple
+	var err error
+	switch msg.Data[0] {
+	case 'I':
+		op = ChangeInsert
+		if r.byte() != 'N' {
+			return 0, false, ErrInvalidWAL
+		}
+		new, err = r.tuple()
+	case 'U':
+		op = ChangeUpdate
+		kind := r.byte()
+		if kind == 'K' || kind == 'O' {
+			if old, err = r.tuple(); err != nil {
+				return 0, false, err
+			}
+			kind = r.byte()
+		}
+		if kind != 'N' {
+			return 0, false, ErrInvalidWAL
+		}
+		new, err = r.tuple()
+	case 'D':
+		op = ChangeDelete
+		if kind := r.byte(); kind != 'K' && kind != 'O' {
+			return 0, false, ErrInvalidWAL
+		}
+		old, err = r.tuple()
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	c.mu.Lock()
+	handler, ok := c.handlers[rel.name]
+	if !ok {
+		handler, ok = c.handlers[rel.table]
+	}
+	c.mu.Unlock()
+	if !ok {
+		return 0, false, nil
+	}
+
+	if err := handler(ctx, op, rel, old, new, msg.LSN); err != nil {
+		return 0, false, fmt.Errorf("%s %s handler failed: %w", rel.name, op, err)
+	}
+	return 1, false, nil
+}
+
+// walRelation describes the columns of a table, sent before its first change.
+type walRelation struct {
+	id      uint32
+	name    string // schema qualified
+	table   string
+	columns []walColumn
+}
+
+type walColumn struct {
+	name    string
+	typeOID uint32
+}
+
+// walTuple are the column values of a row.
+type walTuple []walValue
+
+// walValue is a column value. kind is 'n' for NULL, 'u' for an unchanged TOASTed value and
+// 't' for a text value.
+type walValue struct {
+	kind byte
+	data []byte
+}
+
+// walReader reads the fields of a pgoutput message. Reading past the end sets err.
+type walReader struct {
+	data []byte
+	err  error
+}
+
+func (r *walReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.data) < n {
+		r.err = ErrInvalidWAL
+		return make([]byte, max(n, 0))
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *walReader) byte() byte     { return r.next(1)[0] }
+func (r *walReader) uint16() uint16 { return binary.BigEndian.Uint16(r.next(2)) }
+func (r *walReader) uint32() uint32 { return binary.BigEndian.Uint32(r.next(4)) }
+
+func (r *walReader) string() string {
+	i := -1
+	if r.err == nil {
+		i = strings.IndexByte(string(r.data), 0)
+	}
+	if i < 0 {
+		r.err = ErrInvalidWAL
+		return ""
+	}
+	s := string(r.data[:i])
+	r.data = r.data[i+1:]
+	return s
+}
+
+func (r *walReader) relation() (*walRelation, error) {
+	rel := &walRelation{id: r.uint32()}
+	namespace := r.string()
+	rel.table = r.string()
+	rel.name = namespace + "." + rel.table
+	r.byte() // replica identity
+
+	n := int(r.uint16())
+	for i := 0; i < n && r.err == nil; i++ {
+		r.byte() // flags
+		column := walColumn{name: r.string(), typeOID: r.uint32()}
+		r.uint32() // type modifier
+		rel.columns = append(rel.columns, column)
+	}
+	return rel, r.err
+}
+
+func (r *walReader) tuple() (walTuple, error) {
+	n := int(r.uint16())
+	tuple := make(walTuple, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		kind := r.byte()
+		var data []byte
+		if kind == 't' {
+			data = r.next(int(r.uint32()))
+		}
+		tuple = append(tuple, walValue{kind: kind, data: data})
+	}
+	return tuple, r.err
+}
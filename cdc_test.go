@@ -0,0 +1,143 @@
+package gh_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cdcPatient struct {
+	ID        uint
+	Name      string
+	Phone     *string
+	CreatedAt time.Time
+}
+
+// walMessage builds a pgoutput message of the given type from its fields:
+// uint32 and uint16 are written big-endian, strings are null terminated and []byte as is.
+func walMessage(kind byte, fields ...any) []byte {
+	msg := []byte{kind}
+	for _, field := range fields {
+		switch f := field.(type) {
+		case uint32:
+			msg = binary.BigEndian.AppendUint32(msg, f)
+		case uint16:
+			msg = binary.BigEndian.AppendUint16(msg, f)
+		case byte:
+			msg = append(msg, f)
+		case string:
+			msg = append(append(msg, f...), 0)
+		case []byte:
+			msg = append(msg, f...)
+		}
+	}
+	return msg
+}
+
+// walTuple encodes column values, nil being NULL.
+func walTuple(values ...any) []byte {
+	tuple := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			tuple = append(tuple, 'n')
+			continue
+		}
+		s := value.(string)
+		tuple = binary.BigEndian.AppendUint32(append(tuple, 't'), uint32(len(s)))
+		tuple = append(tuple, s...)
+	}
+	return tuple
+}
+
+func TestChangeCapture(t *testing.T) {
+	db, mock := mockDB(t)
+	capture := gh.NewChangeCapture(db)
+	ctx := context.Background()
+
+	changes := []gh.Change[cdcPatient]{}
+	var failure error
+	require.NoError(t, gh.Capture(capture, func(ctx context.Context, change gh.Change[cdcPatient]) error {
+		if failure != nil && change.Op == gh.ChangeUpdate {
+			return failure
+		}
+		changes = append(changes, change)
+		return nil
+	}))
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM pg_publication WHERE pubname = \$1\)`).
+		WithArgs("gh_cdc").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`CREATE PUBLICATION "gh_cdc" FOR TABLE "cdc_patients"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_create_logical_replication_slot\(\$1, 'pgoutput'\) WHERE NOT EXISTS`).
+		WithArgs("gh_cdc", "gh_cdc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, capture.Setup(ctx))
+
+	relation := walMessage('R', uint32(16384), "public", "cdc_patients", byte('d'), uint16(4),
+		byte(1), "id", uint32(23), uint32(0xffffffff),
+		byte(0), "name", uint32(25), uint32(0xffffffff),
+		byte(0), "phone", uint32(25), uint32(0xffffffff),
+		byte(0), "created_at", uint32(1184), uint32(0xffffffff),
+	)
+	second := func(rows *sqlmock.Rows) *sqlmock.Rows {
+		return rows.
+			AddRow("0/4", walMessage('B', make([]byte, 20))).
+			AddRow("0/5", walMessage('U', uint32(16384), byte('N'), walTuple("1", "John Doe", "0700", "2024-05-01 10:00:00+00"))).
+			AddRow("0/6", walMessage('D', uint32(16384), byte('K'), walTuple("1", nil, nil, nil))).
+			AddRow("0/7", walMessage('C', make([]byte, 25)))
+	}
+
+	// The second transaction fails: the slot is advanced past the first one only.
+	failure = errors.New("index unavailable")
+	mock.ExpectQuery(`SELECT lsn::text AS lsn, data FROM pg_logical_slot_peek_binary_changes\(\$1, NULL, \$2, 'proto_version', '1', 'publication_names', \$3\)`).
+		WithArgs("gh_cdc", 1000, "gh_cdc").
+		WillReturnRows(second(sqlmock.NewRows([]string{"lsn", "data"}).
+			AddRow("0/1", walMessage('B', make([]byte, 20))).
+			AddRow("0/1", relation).
+			AddRow("0/2", walMessage('I', uint32(16384), byte('N'), walTuple("1", "John", nil, "2024-05-01 10:00:00+00"))).
+			AddRow("0/3", walMessage('C', make([]byte, 25)))))
+	mock.ExpectExec(`SELECT pg_replication_slot_advance\(\$1, \$2::pg_lsn\)`).
+		WithArgs("gh_cdc", "0/3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := capture.Poll(ctx)
+	assert.EqualError(t, err, "public.cdc_patients update handler failed: index unavailable")
+	assert.Equal(t, 1, n)
+
+	// The second transaction is delivered again, with its relation.
+	failure = nil
+	mock.ExpectQuery(`pg_logical_slot_peek_binary_changes`).
+		WillReturnRows(second(sqlmock.NewRows([]string{"lsn", "data"}).AddRow("0/4", relation)))
+	mock.ExpectExec(`SELECT pg_replication_slot_advance\(\$1, \$2::pg_lsn\)`).
+		WithArgs("gh_cdc", "0/7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err = capture.Poll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	phone := "0700"
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.Len(t, changes, 3)
+	assert.Equal(t, gh.ChangeInsert, changes[0].Op)
+	assert.Equal(t, "public.cdc_patients", changes[0].Table)
+	assert.Nil(t, changes[0].Old)
+	assert.Equal(t, uint(1), changes[0].New.ID)
+	assert.Equal(t, "John", changes[0].New.Name)
+	assert.Nil(t, changes[0].New.Phone)
+	assert.Equal(t, gh.ChangeUpdate, changes[1].Op)
+	assert.Equal(t, "John Doe", changes[1].New.Name)
+	assert.Equal(t, &phone, changes[1].New.Phone)
+	assert.True(t, created.Equal(changes[1].New.CreatedAt))
+	assert.Equal(t, gh.ChangeDelete, changes[2].Op)
+	assert.Equal(t, &cdcPatient{ID: 1}, changes[2].Old)
+	assert.Nil(t, changes[2].New)
+}
@@ -0,0 +1,219 @@
+package gh
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen is returned for statements rejected by an open CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // statements run
+	CircuitOpen                         // statements fail fast with ErrCircuitOpen
+	CircuitHalfOpen                     // a few probe statements run to test the database
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+const breakerAdmittedKey = "gh:circuit_breaker_admitted"
+
+// CircuitBreaker is a gorm plugin failing statements fast with ErrCircuitOpen while the database
+// is down, instead of piling up goroutines waiting on the connection pool. It opens after
+// FailureThreshold consecutive failures, and after OpenTimeout lets HalfOpenProbes statements
+// through: it closes if they succeed, and opens again if one fails.
+//
+// Statements that reach the database and fail, e.g constraint violations or ErrRecordNotFound,
+// are not failures, unless IsFailure says otherwise. Begin and Commit of transactions are not guarded.
+//
+//	db.Use(&gh.CircuitBreaker{FailureThreshold: 5, OpenTimeout: 10 * time.Second})
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit. Default: 5.
+	FailureThreshold int
+
+	// OpenTimeout is the time the circuit stays open before probing the database. Default: 30s.
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is the number of statements let through, one at a time, to close the circuit. Default: 1.
+	HalfOpenProbes int
+
+	// IsFailure reports whether err counts as a failure. Default: connection errors, timeouts and
+	// server unavailability errors (SQLSTATE classes 08, 53 and 57).
+	IsFailure func(err error) bool
+
+	// OnStateChange is called when the state changes, e.g to log or export it.
+	OnStateChange func(from, to CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	probes   int
+}
+
+// Name implements gorm.Plugin.
+func (b *CircuitBreaker) Name() string {
+	return "gh:circuit_breaker"
+}
+
+// Initialize implements gorm.Plugin, guarding the statements of every processor.
+func (b *CircuitBreaker) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().Before("*").Register, cb.Create().After("*").Register,
+		cb.Query().Before("*").Register, cb.Query().After("*").Register,
+		cb.Update().Before("*").Register, cb.Update().After("*").Register,
+		cb.Delete().Before("*").Register, cb.Delete().After("*").Register,
+		cb.Row().Before("*").Register, cb.Row().After("*").Register,
+		cb.Raw().Before("*").Register, cb.Raw().After("*").Register,
+	}
+
+	for i, register := range registrations {
+		name, fn := "gh:circuit_breaker_before", b.before
+		if i%2 == 1 {
+			name, fn = "gh:circuit_breaker_after", b.after
+		}
+		if err := register(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *CircuitBreaker) before(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		db.InstanceSet(breakerAdmittedKey, false)
+		return
+	}
+
+	admitted := b.allow()
+	db.InstanceSet(breakerAdmittedKey, admitted)
+	if !admitted {
+		db.AddError(ErrCircuitOpen)
+	}
+}
+
+func (b *CircuitBreaker) after(db *gorm.DB) {
+	if admitted, _ := db.InstanceGet(breakerAdmittedKey); admitted == true {
+		b.record(db.Error)
+	}
+}
+
+// allow reports whether a statement may run, moving an open circuit to half-open after OpenTimeout.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < durationOr(b.OpenTimeout, 30*time.Second) {
+			return false
+		}
+		b.setState(CircuitHalfOpen)
+		b.probes = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record records the outcome of an admitted statement.
+func (b *CircuitBreaker) record(err error) {
+	failed := err != nil && b.isFailure(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+		if failed {
+			b.open()
+			return
+		}
+		b.probes++
+		if b.probes >= max(b.HalfOpenProbes, 1) {
+			b.failures = 0
+			b.setState(CircuitClosed)
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= cmp.Or(b.FailureThreshold, 5) {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.probing = false
+	b.setState(CircuitOpen)
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	from := b.state
+	b.state = state
+	if from != state && b.OnStateChange != nil {
+		b.OnStateChange(from, state)
+	}
+}
+
+func (b *CircuitBreaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	return isUnavailable(err)
+}
+
+// isUnavailable reports whether err means the database is unreachable or overloaded.
+func isUnavailable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") || strings.HasPrefix(pgErr.Code, "57")
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
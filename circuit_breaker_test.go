@@ -0,0 +1,72 @@
+package gh_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type breakerWard struct {
+	ID   uint
+	Name string
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	db, mock := mockDB(t)
+	transitions := []string{}
+	breaker := &gh.CircuitBreaker{
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		OnStateChange: func(from, to gh.CircuitState) {
+			transitions = append(transitions, from.String()+" -> "+to.String())
+		},
+	}
+	require.NoError(t, db.Use(breaker))
+
+	down := &pgconn.PgError{Code: "57P03", Message: "the database system is starting up"}
+	query := `SELECT \* FROM "breaker_wards"`
+
+	// Application errors do not count.
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	assert.ErrorIs(t, db.First(&breakerWard{}).Error, gorm.ErrRecordNotFound)
+	mock.ExpectQuery(query).WillReturnError(&pgconn.PgError{Code: "23505"})
+	assert.Error(t, db.Find(&[]breakerWard{}).Error)
+	assert.Equal(t, gh.CircuitClosed, breaker.State())
+
+	mock.ExpectQuery(query).WillReturnError(down)
+	mock.ExpectQuery(query).WillReturnError(errors.Join(errors.New("dial"), &pgconn.ConnectError{}))
+	assert.ErrorAs(t, db.Find(&[]breakerWard{}).Error, new(*pgconn.PgError))
+	assert.Error(t, db.Find(&[]breakerWard{}).Error)
+	assert.Equal(t, gh.CircuitOpen, breaker.State())
+
+	// Open: statements fail fast without reaching the database.
+	assert.ErrorIs(t, db.Find(&[]breakerWard{}).Error, gh.ErrCircuitOpen)
+	assert.ErrorIs(t, db.Exec("SELECT 1").Error, gh.ErrCircuitOpen)
+	assert.ErrorIs(t, db.Create(&breakerWard{Name: "Maternity"}).Error, gh.ErrCircuitOpen)
+
+	// Half-open: a failed probe opens the circuit again.
+	time.Sleep(30 * time.Millisecond)
+	mock.ExpectQuery(query).WillReturnError(down)
+	assert.ErrorAs(t, db.Find(&[]breakerWard{}).Error, new(*pgconn.PgError))
+	assert.Equal(t, gh.CircuitOpen, breaker.State())
+
+	// A successful probe closes it.
+	time.Sleep(30 * time.Millisecond)
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Maternity"))
+	require.NoError(t, db.Find(&[]breakerWard{}).Error)
+	assert.Equal(t, gh.CircuitClosed, breaker.State())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, []string{
+		"closed -> open",
+		"open -> half-open", "half-open -> open",
+		"open -> half-open", "half-open -> closed",
+	}, transitions)
+}
@@ -0,0 +1,266 @@
+package gh
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Composite is a value of a user-defined composite type (CREATE TYPE ... AS (...)),
+// mapped to the exported fields of the struct T in declaration order.
+// Like sql.Null, Valid is false for NULL. Fields can be strings, numbers, bools, time.Time,
+// sql.Scanner / driver.Valuer implementations, or pointers to those for NULL attributes.
+//
+//	CREATE TYPE address AS (street text, city text, zip text);
+//
+//	type Address struct{ Street, City string; Zip *string }
+//
+//	type Patient struct {
+//		Address gh.Composite[Address] `gorm:"type:address"`
+//	}
+type Composite[T any] struct {
+	V     T
+	Valid bool
+}
+
+// NewComposite returns a valid Composite holding v.
+func NewComposite[T any](v T) Composite[T] {
+	return Composite[T]{V: v, Valid: true}
+}
+
+// Value implements driver.Valuer, encoding the value as a row literal, e.g ("1 Main St","Kampala",).
+func (c Composite[T]) Value() (driver.Value, error) {
+	if !c.Valid {
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(c.V)
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("gh: Composite requires a struct, got %T", c.V)
+	}
+
+	attrs := []*string{}
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+
+		text, err := compositeText(rv.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("gh: field %s: %w", rv.Type().Field(i).Name, err)
+		}
+		attrs = append(attrs, text)
+	}
+	return FormatComposite(attrs), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *Composite[T]) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*c = Composite[T]{}
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Composite", src)
+	}
+
+	attrs, err := ParseComposite(text)
+	if err != nil {
+		return err
+	}
+
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("gh: Composite requires a struct, got %T", v)
+	}
+
+	n := 0
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+
+		if n >= len(attrs) {
+			return fmt.Errorf("gh: composite %q has fewer attributes than %T", text, v)
+		}
+
+		if err := setFromText(rv.Field(i), attrs[n]); err != nil {
+			return fmt.Errorf("gh: field %s: %w", rv.Type().Field(i).Name, err)
+		}
+		n++
+	}
+
+	*c = Composite[T]{V: v, Valid: true}
+	return nil
+}
+
+// ParseComposite parses the text form of a composite value (a row literal),
+// e.g (1,"a b",) into its attributes. NULL attributes are nil.
+func ParseComposite(text string) ([]*string, error) {
+	if len(text) < 2 || text[0] != '(' || text[len(text)-1] != ')' {
+		return nil, fmt.Errorf("gh: invalid composite %q", text)
+	}
+
+	p := &textParser{s: text[1 : len(text)-1]}
+	attrs := []*string{}
+	for {
+		if !p.done() && p.s[p.i] == '"' {
+			s, err := p.quoted(true)
+			if err != nil {
+				return nil, fmt.Errorf("gh: invalid composite %q", text)
+			}
+			attrs = append(attrs, &s)
+		} else {
+			start := p.i
+			for !p.done() && p.s[p.i] != ',' {
+				p.i++
+			}
+
+			if p.i == start {
+				attrs = append(attrs, nil)
+			} else {
+				s := p.s[start:p.i]
+				attrs = append(attrs, &s)
+			}
+		}
+
+		if p.done() {
+			return attrs, nil
+		}
+
+		if !p.consume(",") {
+			return nil, fmt.Errorf("gh: invalid composite %q", text)
+		}
+	}
+}
+
+// FormatComposite formats attributes as a row literal. nil attributes are NULL.
+func FormatComposite(attrs []*string) string {
+	parts := make([]string, len(attrs))
+	for i, attr := range attrs {
+		if attr != nil {
+			parts[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(*attr) + `"`
+		}
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// compositeText returns the text form of a field value, nil for NULL.
+func compositeText(v reflect.Value) (*string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		if _, ok := v.Interface().(driver.Valuer); !ok {
+			v = v.Elem()
+		}
+	}
+
+	var value any = v.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+
+	var s string
+	switch x := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	case bool:
+		s = strconv.FormatBool(x)
+	case time.Time:
+		s = x.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(x)
+	}
+	return &s, nil
+}
+
+// setFromText sets the field v from the text form of an attribute, nil for NULL.
+func setFromText(v reflect.Value, text *string) error {
+	if scanner, ok := v.Addr().Interface().(sql.Scanner); ok {
+		if text == nil {
+			return scanner.Scan(nil)
+		}
+		return scanner.Scan(*text)
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if text == nil {
+			v.SetZero()
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return setFromText(v.Elem(), text)
+	}
+
+	if text == nil {
+		v.SetZero()
+		return nil
+	}
+
+	s := *text
+	if _, ok := v.Interface().(time.Time); ok {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999", time.DateOnly} {
+			if t, err := time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q", s)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// CompositeEq filters the rows whose composite column has its attribute field equal to value.
+func (gdb *GormDB) CompositeEq(column, field string, value any) *GormDB {
+	gdb.db = gdb.db.Where("("+column+")."+quoteName(field)+" = ?", value)
+	return gdb
+}
@@ -0,0 +1,68 @@
+package gh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type address struct {
+	Street  string
+	City    string
+	Zip     *string
+	Floor   int
+	Checked time.Time
+	private string
+}
+
+type legacyPatient struct {
+	ID      uint
+	Address gh.Composite[address]
+}
+
+func TestComposite(t *testing.T) {
+	var c gh.Composite[address]
+	assert.NoError(t, c.Scan(`("1 ""Main"" St",Kampala,,3,"2024-05-01 10:00:00+00")`))
+	assert.True(t, c.Valid)
+	assert.Equal(t, `1 "Main" St`, c.V.Street)
+	assert.Equal(t, "Kampala", c.V.City)
+	assert.Nil(t, c.V.Zip)
+	assert.Equal(t, 3, c.V.Floor)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), c.V.Checked.UTC())
+
+	zip := "256"
+	v, err := gh.NewComposite(address{Street: `a\b`, Zip: &zip, Checked: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `("a\\b","","256","0","2024-05-01T00:00:00Z")`, v)
+
+	assert.NoError(t, c.Scan(nil))
+	assert.False(t, c.Valid)
+
+	v, err = c.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.Error(t, c.Scan(`(a,b)`))
+	assert.Error(t, c.Scan(`a,b`))
+}
+
+func TestParseComposite(t *testing.T) {
+	attrs, err := gh.ParseComposite(`(,"",x,"a,b")`)
+	assert.NoError(t, err)
+	assert.Len(t, attrs, 4)
+	assert.Nil(t, attrs[0])
+	assert.Equal(t, "", *attrs[1])
+	assert.Equal(t, "x", *attrs[2])
+	assert.Equal(t, "a,b", *attrs[3])
+
+	assert.Equal(t, `(,"","x","a,b")`, gh.FormatComposite(attrs))
+}
+
+func TestCompositeEq(t *testing.T) {
+	sql := gh.WrapDB(dryRunDB(t)).CompositeEq("address", "city", "Kampala").
+		ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]legacyPatient{}) })
+	assert.Equal(t, `SELECT * FROM "legacy_patients" WHERE (address)."city" = 'Kampala'`, sql)
+}
@@ -0,0 +1,120 @@
+package gh
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// ConnectionArgs are the Relay connection arguments.
+// First and Last can not be used together. If both are 0, First defaults to 10.
+type ConnectionArgs struct {
+	First  int    // number of edges after After
+	After  string // cursor of the edge to start after
+	Last   int    // number of edges before Before
+	Before string // cursor of the edge to end before
+}
+
+// Edge is a node of a Relay connection with its cursor.
+type Edge[T any] struct {
+	Cursor string `json:"cursor"`
+	Node   T      `json:"node"`
+}
+
+// PageInfo is the pagination information of a Relay connection.
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+// Connection is a Relay-style (GraphQL cursor connections specification) page of T.
+type Connection[T any] struct {
+	Edges    []Edge[T] `json:"edges"`
+	PageInfo PageInfo  `json:"pageInfo"`
+}
+
+// GetConnection retrieves a Relay connection of T using keyset pagination.
+// orderColumns are the same as for GetKeysetPaginated and cursors are interchangeable.
+// HasPreviousPage (when paginating forward) and HasNextPage (when paginating backward)
+// are true whenever a cursor was given, as the specification allows.
+// db is the *gorm.DB object with the query options already applied.
+func GetConnection[T any](db *gorm.DB, args ConnectionArgs, orderColumns ...string) (*Connection[T], error) {
+	if args.First < 0 || args.Last < 0 {
+		return nil, errors.New("first and last must not be negative")
+	}
+
+	if args.First > 0 && args.Last > 0 {
+		return nil, errors.New("first and last can not be used together")
+	}
+
+	backward := args.Last > 0
+	limit := args.First
+	if backward {
+		limit = args.Last
+	} else if limit == 0 {
+		limit = 10
+	}
+
+	ks, err := newKeyset(db, new(T), orderColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Model(new(T))
+	if args.After != "" {
+		values, err := ks.decode(args.After)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(ks.after(values, false))
+	}
+
+	if args.Before != "" {
+		values, err := ks.decode(args.Before)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(ks.after(values, true))
+	}
+
+	results := []T{}
+	if err := query.Order(ks.order(backward)).Limit(limit + 1).Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	if backward {
+		slices.Reverse(results)
+	}
+
+	conn := &Connection[T]{Edges: make([]Edge[T], len(results))}
+	for i := range results {
+		cursor, err := ks.encode(reflect.ValueOf(&results[i]).Elem())
+		if err != nil {
+			return nil, err
+		}
+		conn.Edges[i] = Edge[T]{Cursor: cursor, Node: results[i]}
+	}
+
+	if backward {
+		conn.PageInfo.HasPreviousPage = hasMore
+		conn.PageInfo.HasNextPage = args.Before != ""
+	} else {
+		conn.PageInfo.HasNextPage = hasMore
+		conn.PageInfo.HasPreviousPage = args.After != ""
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
@@ -0,0 +1,37 @@
+package gh_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestGetConnection(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sql string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	}))
+
+	conn, err := gh.GetConnection[keysetVisit](db, gh.ConnectionArgs{First: 5}, "doctor")
+	assert.NoError(t, err)
+	assert.Empty(t, conn.Edges)
+	assert.False(t, conn.PageInfo.HasNextPage)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" ORDER BY "keyset_visits"."doctor","keyset_visits"."id" LIMIT $1`, sql)
+
+	before := base64.RawURLEncoding.EncodeToString([]byte(`["Dr. Smith",42]`))
+	conn, err = gh.GetConnection[keysetVisit](db, gh.ConnectionArgs{Last: 5, Before: before}, "doctor")
+	assert.NoError(t, err)
+	assert.True(t, conn.PageInfo.HasNextPage)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" WHERE ("keyset_visits"."doctor" < $1 OR ("keyset_visits"."doctor" = $2 AND "keyset_visits"."id" < $3)) ORDER BY "keyset_visits"."doctor" DESC,"keyset_visits"."id" DESC LIMIT $4`, sql)
+
+	_, err = gh.GetConnection[keysetVisit](db, gh.ConnectionArgs{First: 5, Last: 5})
+	assert.Error(t, err)
+
+	_, err = gh.GetConnection[keysetVisit](db, gh.ConnectionArgs{After: "bad"})
+	assert.ErrorIs(t, err, gh.ErrInvalidCursor)
+}
@@ -0,0 +1,135 @@
+package gh
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// EstimatedCount returns an estimate of the number of rows of model's table from the statistics of
+// postgres, without scanning the table, e.g for dashboards where COUNT(*) over tens of millions of
+// rows times out.
+//
+// The row density recorded by the last VACUUM or ANALYZE (pg_class.reltuples / relpages) is scaled
+// by the current size of the table, as the planner does, so rows added since then are counted.
+// Tables never analyzed fall back to the live rows tracked by the statistics collector.
+// Partitioned tables are the sum of their partitions.
+//
+// If db has conditions, the planner's estimate of the filtered query (EXPLAIN) is returned instead,
+// which is only as good as the column statistics.
+//
+//	patients, err := gh.EstimatedCount(ctx, db, &Patient{})
+//	admitted, err := gh.EstimatedCount(ctx, db.Where("status = ?", "admitted"), &Visit{})
+func EstimatedCount(ctx context.Context, db *gorm.DB, model any) (int64, error) {
+	db = db.WithContext(ctx)
+	if _, filtered := db.Statement.Clauses["WHERE"]; filtered {
+		return estimateCount(db, model)
+	}
+
+	table, err := tableOfModel(db, model)
+	if err != nil {
+		return 0, err
+	}
+
+	var count sql.NullInt64
+	err = db.Session(&gorm.Session{NewDB: true}).Raw(`SELECT SUM(CASE
+			WHEN c.reltuples >= 0 AND c.relpages > 0
+				THEN c.reltuples / c.relpages * (pg_relation_size(c.oid) / current_setting('block_size')::int)
+			ELSE COALESCE(s.n_live_tup, 0)
+		END)::bigint
+		FROM pg_partition_tree(to_regclass(?)) t
+		JOIN pg_class c ON c.oid = t.relid
+		LEFT JOIN pg_stat_all_tables s ON s.relid = c.oid
+		WHERE t.isleaf`, table).Row().Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate count of %s: %w", table, err)
+	}
+	if !count.Valid {
+		return 0, fmt.Errorf("failed to estimate count of %s: table does not exist", table)
+	}
+	return count.Int64, nil
+}
+
+// ApproxCountDistinct returns an approximation of the number of distinct values of column in the
+// rows of model's table matching the conditions of db, e.g the unique patients of the month.
+//
+// If the hll (postgresql-hll) extension is installed, values are counted with a HyperLogLog
+// sketch: the rows are still scanned, but without the sort or hash of COUNT(DISTINCT), with an
+// error of about 1%. Otherwise, for a query without conditions, the n_distinct statistic of the
+// column recorded by ANALYZE is returned, without scanning the table. Queries with conditions
+// need the extension and fail with ErrExtensionUnavailable without it.
+//
+//	err := gh.EnsureExtensions(db, "hll")
+//	patients, err := gh.ApproxCountDistinct(ctx, db.Where("created_at >= ?", monthStart), &Visit{}, "patient_id")
+func ApproxCountDistinct(ctx context.Context, db *gorm.DB, model any, column string) (int64, error) {
+	db = db.WithContext(ctx)
+	table, err := tableOfModel(db, model)
+	if err != nil {
+		return 0, err
+	}
+
+	field := db.Statement.Schema.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return 0, fmt.Errorf("unknown column %q for %s", column, table)
+	}
+
+	var hll bool
+	err = db.Session(&gorm.Session{NewDB: true}).
+		Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'hll')").Scan(&hll).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to list extensions: %w", err)
+	}
+
+	var count int64
+	if hll {
+		err := db.Model(model).
+			Select(fmt.Sprintf("COALESCE(hll_cardinality(hll_add_agg(hll_hash_any(%s))), 0)::bigint", quoteName(field.DBName))).
+			Scan(&count).Error
+		if err != nil {
+			return 0, fmt.Errorf("failed to count distinct %s: %w", field.DBName, err)
+		}
+		return count, nil
+	}
+
+	if _, filtered := db.Statement.Clauses["WHERE"]; filtered {
+		return 0, fmt.Errorf("failed to count distinct %s with conditions, run CREATE EXTENSION hll: %w",
+			field.DBName, ErrExtensionUnavailable)
+	}
+
+	// n_distinct is negative if it is a fraction of the rows, e.g -1 for a unique column.
+	var distinct sql.NullFloat64
+	err = db.Session(&gorm.Session{NewDB: true}).Raw(`SELECT s.n_distinct FROM pg_stats s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = s.tablename
+		WHERE c.oid = to_regclass(?) AND s.attname = ?
+		ORDER BY s.inherited DESC LIMIT 1`, table, field.DBName).Row().Scan(&distinct)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to count distinct %s: %w", field.DBName, err)
+	}
+	if !distinct.Valid {
+		return 0, fmt.Errorf("failed to count distinct %s: %s is not analyzed", field.DBName, table)
+	}
+	if distinct.Float64 >= 0 {
+		return int64(distinct.Float64), nil
+	}
+
+	rows, err := EstimatedCount(ctx, db.Session(&gorm.Session{NewDB: true}), model)
+	if err != nil {
+		return 0, err
+	}
+	return int64(-distinct.Float64 * float64(rows)), nil
+}
+
+// tableOfModel parses model into the statement of db and returns its table, or the table of db
+// if set with Table.
+func tableOfModel(db *gorm.DB, model any) (string, error) {
+	table := db.Statement.Table
+	if err := db.Statement.Parse(model); err != nil {
+		return "", err
+	}
+	return cmp.Or(table, db.Statement.Schema.Table), nil
+}
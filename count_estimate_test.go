@@ -0,0 +1,83 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type estimatedVisit struct {
+	ID        uint
+	PatientID uint
+	Status    string
+}
+
+func TestEstimatedCount(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT SUM\(CASE .*reltuples / c.relpages .* FROM pg_partition_tree\(to_regclass\(\$1\)\) t`).
+		WithArgs("estimated_visits").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(25000000))
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM "estimated_visits" WHERE status = $1`)).
+		WithArgs("admitted").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Plan Rows": 1200}}]`))
+	mock.ExpectQuery(`FROM pg_partition_tree`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(nil))
+
+	count, err := gh.EstimatedCount(ctx, db, &estimatedVisit{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(25000000), count)
+
+	count, err = gh.EstimatedCount(ctx, db.Where("status = ?", "admitted"), &estimatedVisit{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1200), count)
+
+	_, err = gh.EstimatedCount(ctx, db.Table("missing"), &estimatedVisit{})
+	assert.ErrorContains(t, err, "missing: table does not exist")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproxCountDistinct(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+	extension := regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'hll')")
+
+	// With hll, the conditions are applied.
+	mock.ExpectQuery(extension).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(hll_cardinality(hll_add_agg(hll_hash_any("patient_id"))), 0)::bigint FROM "estimated_visits" WHERE status = $1`)).
+		WithArgs("admitted").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4210))
+
+	count, err := gh.ApproxCountDistinct(ctx, db.Where("status = ?", "admitted"), &estimatedVisit{}, "PatientID")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4210), count)
+
+	// Without hll, n_distinct is a fraction of the estimated rows.
+	mock.ExpectQuery(extension).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT s.n_distinct FROM pg_stats s .* WHERE c.oid = to_regclass\(\$1\) AND s.attname = \$2`).
+		WithArgs("estimated_visits", "patient_id").
+		WillReturnRows(sqlmock.NewRows([]string{"n_distinct"}).AddRow(-0.25))
+	mock.ExpectQuery(`FROM pg_partition_tree`).
+		WithArgs("estimated_visits").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(1000000))
+
+	count, err = gh.ApproxCountDistinct(ctx, db, &estimatedVisit{}, "patient_id")
+	require.NoError(t, err)
+	assert.Equal(t, int64(250000), count)
+
+	// Without hll, conditions can not be estimated.
+	mock.ExpectQuery(extension).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	_, err = gh.ApproxCountDistinct(ctx, db.Where("status = ?", "admitted"), &estimatedVisit{}, "patient_id")
+	assert.ErrorIs(t, err, gh.ErrExtensionUnavailable)
+
+	_, err = gh.ApproxCountDistinct(ctx, db, &estimatedVisit{}, "ward")
+	assert.ErrorContains(t, err, `unknown column "ward"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,164 @@
+package gh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronNext returns the first activation of schedule strictly after t, in t's location.
+// schedule has the syntax accepted by Scheduler.Register.
+func CronNext(schedule string, t time.Time) (time.Time, error) {
+	s, err := parseCron(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := s.next(t)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never runs", schedule)
+	}
+	return next, nil
+}
+
+// cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bitsets of the allowed values
+	domAny, dowAny                bool   // the field was *
+	every                         time.Duration
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a standard 5-field cron expression (minute hour day-of-month month day-of-week)
+// with lists, ranges and steps (e.g "*/15 9-17 * * 1-5"), a descriptor like @daily,
+// or "@every <duration>" (e.g "@every 90m").
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: invalid duration", spec)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+
+	s := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		*sets[i] = set
+	}
+
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma separated list of *, values, ranges and steps into a bitset.
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+
+			var err error
+			if start, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, lo, hi)
+		}
+
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// next returns the first activation time strictly after t, in t's location,
+// or the zero time if there is none within 5 years.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether t's day matches. As in cron, if both the day of month and
+// the day of week are restricted, either one matching is enough.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if !s.domAny && !s.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
@@ -0,0 +1,48 @@
+package gh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday.
+	from := time.Date(2024, 5, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 5, 16, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC)},
+		{"30 8 * * 0", time.Date(2024, 5, 19, 8, 30, 0, 0, time.UTC)},
+		{"30 8 * * 7", time.Date(2024, 5, 19, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1,20 * *", time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)}, // day of month OR day of week
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			got, err := gh.CronNext(tt.schedule, from)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCronNextInvalid(t *testing.T) {
+	for _, schedule := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "@every x", "0 0 31 2 *"} {
+		_, err := gh.CronNext(schedule, time.Now())
+		assert.Error(t, err, schedule)
+	}
+}
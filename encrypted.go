@@ -0,0 +1,422 @@
+package gh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrNoKeyProvider is returned when encrypting or decrypting before SetKeyProvider is called.
+	ErrNoKeyProvider = errors.New("encryption key provider is not configured")
+
+	// ErrInvalidCiphertext is returned when an encrypted column value is malformed or has been tampered with.
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// encryptedPrefix identifies the format of encrypted values: gh1:<key id>:<base64(nonce + ciphertext)>
+const encryptedPrefix = "gh1"
+
+// KeyProvider supplies AES keys (16, 24 or 32 bytes) for encrypted columns.
+// Values are encrypted with the current key and the key id is stored alongside
+// the ciphertext, so old keys remain usable for decryption after rotation.
+type KeyProvider interface {
+	// CurrentKey returns the id and key used to encrypt new values.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given id.
+	Key(id string) ([]byte, error)
+
+	// BlindIndexKey returns the HMAC key used to compute blind indexes.
+	BlindIndexKey() ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider backed by keys held in memory.
+type StaticKeyProvider struct {
+	CurrentID string            // id of the key used for encryption
+	Keys      map[string][]byte // keys by id
+	IndexKey  []byte            // HMAC key for blind indexes
+}
+
+// CurrentKey implements KeyProvider.
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.CurrentID)
+	return p.CurrentID, key, err
+}
+
+// Key implements KeyProvider.
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found", id)
+	}
+	return key, nil
+}
+
+// BlindIndexKey implements KeyProvider.
+func (p *StaticKeyProvider) BlindIndexKey() ([]byte, error) {
+	if len(p.IndexKey) == 0 {
+		return nil, errors.New("blind index key is not configured")
+	}
+	return p.IndexKey, nil
+}
+
+var (
+	keyProviderMu sync.RWMutex
+	keyProvider   KeyProvider
+)
+
+// SetKeyProvider sets the KeyProvider used by all Encrypted columns.
+// It must be called before any encrypted column is read or written.
+func SetKeyProvider(p KeyProvider) {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+	keyProvider = p
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderMu.RLock()
+	defer keyProviderMu.RUnlock()
+	if keyProvider == nil {
+		return nil, ErrNoKeyProvider
+	}
+	return keyProvider, nil
+}
+
+// Encrypted is a column that is transparently encrypted with AES-GCM on write
+// and decrypted on read. The plaintext is available in V.
+// An empty value is stored as NULL.
+//
+// Encrypted values can not be searched. Use a blind index column for equality searches:
+//
+//	type Patient struct {
+//		ID       uint
+//		NIN      gh.Encrypted[string] `gh:"blind_index:nin_index"`
+//		NINIndex string               `gorm:"index"`
+//	}
+//
+//	db.Use(&gh.BlindIndexes{})
+//	gh.WrapDB(db).BlindEq("nin_index", "CM1234").First(&patient)
+type Encrypted[T string | []byte] struct {
+	V T
+}
+
+// NewEncrypted returns an Encrypted value holding v.
+func NewEncrypted[T string | []byte](v T) Encrypted[T] {
+	return Encrypted[T]{V: v}
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (Encrypted[T]) GormDataType() string {
+	return "text"
+}
+
+// Value implements driver.Valuer by encrypting the plaintext.
+func (e Encrypted[T]) Value() (driver.Value, error) {
+	if len(e.V) == 0 {
+		return nil, nil
+	}
+	return encrypt([]byte(e.V))
+}
+
+// Scan implements sql.Scanner by decrypting the stored value.
+func (e *Encrypted[T]) Scan(src any) error {
+	var ciphertext string
+	switch v := src.(type) {
+	case nil:
+		var zero T
+		e.V = zero
+		return nil
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Encrypted", src)
+	}
+
+	plaintext, err := decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+	e.V = T(plaintext)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler by marshaling the plaintext.
+func (e Encrypted[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.V)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Encrypted[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &e.V)
+}
+
+func (e Encrypted[T]) plaintext() []byte {
+	return []byte(e.V)
+}
+
+func encrypt(plaintext []byte) (string, error) {
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+
+	id, key, err := p.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(id))
+	return encryptedPrefix + ":" + id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(ciphertext string) ([]byte, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != encryptedPrefix {
+		return nil, ErrInvalidCiphertext
+	}
+
+	p, err := getKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := p.Key(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, []byte(parts[1]))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// BlindIndex returns the keyed hash (HMAC-SHA256, hex encoded) of value used to
+// search encrypted columns by equality.
+func BlindIndex(value string) (string, error) {
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := p.BlindIndexKey()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BlindEq applies an equality filter on a blind index column.
+// If a value is empty, it does nothing.
+func (gdb *GormDB) BlindEq(column string, value string) *GormDB {
+	if value == "" {
+		return gdb
+	}
+
+	index, err := BlindIndex(value)
+	if err != nil {
+		gdb.db = gdb.db.Session(&gorm.Session{})
+		gdb.db.AddError(err)
+		return gdb
+	}
+
+	gdb.db = gdb.db.Where(column+" = ?", index)
+	return gdb
+}
+
+// BlindIndexes is a gorm plugin that computes blind index columns for Encrypted fields
+// tagged with `gh:"blind_index:<column>"` on create and update. Updating a field to an empty
+// value clears its index. Plain string or []byte values of map updates are encrypted.
+type BlindIndexes struct{}
+
+// Name implements gorm.Plugin.
+func (BlindIndexes) Name() string {
+	return "gh:blind_indexes"
+}
+
+// Initialize implements gorm.Plugin.
+func (p BlindIndexes) Initialize(db *gorm.DB) error {
+	err := db.Callback().Create().Before("gorm:create").Register("gh:blind_indexes_create", p.beforeCreate)
+	if err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("gh:blind_indexes_update", p.beforeUpdate)
+}
+
+func (BlindIndexes) beforeCreate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+
+	for field, indexField := range blindIndexFields(stmt.Schema) {
+		forEachModelValue(stmt, func(rv reflect.Value) {
+			value, _ := field.ValueOf(stmt.Context, rv)
+			index, ok, err := blindIndexOf(value)
+			if err != nil {
+				db.AddError(err)
+			} else if ok && index != nil {
+				db.AddError(indexField.Set(stmt.Context, rv, index))
+			}
+		})
+	}
+}
+
+func (BlindIndexes) beforeUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+
+	for field, indexField := range blindIndexFields(stmt.Schema) {
+		var value any
+		switch dest := stmt.Dest.(type) {
+		case map[string]any:
+			key := field.DBName
+			if _, ok := dest[key]; !ok {
+				key = field.Name
+			}
+			v, ok := dest[key]
+			if !ok {
+				continue
+			}
+			if v == nil {
+				stmt.SetColumn(indexField.DBName, nil, true)
+				continue
+			}
+			// Plain values are encrypted, e.g Updates(map[string]any{"nin": "CM1234"}).
+			value = encryptPlain(field, v)
+			dest[key] = value
+		default:
+			rv := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+			if rv.Kind() != reflect.Struct {
+				continue
+			}
+			value, _ = field.ValueOf(stmt.Context, rv)
+		}
+
+		// A cleared value clears its index, so lookups by the old value no longer match.
+		index, ok, err := blindIndexOf(value)
+		if err != nil {
+			db.AddError(err)
+		} else if ok {
+			stmt.SetColumn(indexField.DBName, index, true)
+		}
+	}
+}
+
+// blindIndexFields maps Encrypted fields tagged with blind_index to their index fields.
+func blindIndexFields(s *schema.Schema) map[*schema.Field]*schema.Field {
+	fields := map[*schema.Field]*schema.Field{}
+	for _, field := range s.Fields {
+		if column := ghTag(field)["BLIND_INDEX"]; column != "" {
+			if indexField := s.LookUpField(column); indexField != nil {
+				fields[field] = indexField
+			}
+		}
+	}
+	return fields
+}
+
+// blindIndexOf computes the blind index of an Encrypted, string or []byte value, nil if the value
+// is empty. ok is false if value is not one of those types.
+func blindIndexOf(value any) (index any, ok bool, err error) {
+	var plaintext []byte
+	switch v := value.(type) {
+	case interface{ plaintext() []byte }:
+		plaintext = v.plaintext()
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	default:
+		return nil, false, nil
+	}
+
+	if len(plaintext) == 0 {
+		return nil, true, nil
+	}
+	hash, err := BlindIndex(string(plaintext))
+	if err != nil {
+		return nil, false, err
+	}
+	return hash, true, nil
+}
+
+// encryptPlain returns a string or []byte value as the Encrypted type of field.
+// Other values are returned unchanged.
+func encryptPlain(field *schema.Field, value any) any {
+	var plaintext []byte
+	switch v := value.(type) {
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	default:
+		return value
+	}
+
+	switch field.FieldType {
+	case reflect.TypeFor[Encrypted[string]]():
+		return NewEncrypted(string(plaintext))
+	case reflect.TypeFor[Encrypted[[]byte]]():
+		return NewEncrypted(plaintext)
+	}
+	return value
+}
+
+// ghTag returns the settings of the `gh` struct tag of field, keyed by upper case name.
+// e.g `gh:"sensitive;blind_index:nin_index"`
+func ghTag(field *schema.Field) map[string]string {
+	return schema.ParseTagSetting(field.Tag.Get("gh"), ";")
+}
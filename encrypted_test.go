@@ -0,0 +1,92 @@
+package gh_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type encryptedPatient struct {
+	ID       uint
+	NIN      gh.Encrypted[string] `gh:"blind_index:nin_index"`
+	NINIndex string
+	Notes    gh.Encrypted[[]byte]
+}
+
+func setTestKeys(t *testing.T) *gh.StaticKeyProvider {
+	t.Helper()
+
+	provider := &gh.StaticKeyProvider{
+		CurrentID: "k1",
+		Keys:      map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")},
+		IndexKey:  []byte("blind-index-key"),
+	}
+	gh.SetKeyProvider(provider)
+	t.Cleanup(func() { gh.SetKeyProvider(nil) })
+	return provider
+}
+
+func TestEncrypted(t *testing.T) {
+	provider := setTestKeys(t)
+
+	value, err := gh.NewEncrypted("CM1234").Value()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value.(string), "gh1:k1:"))
+	assert.NotContains(t, value, "CM1234")
+
+	var decrypted gh.Encrypted[string]
+	assert.NoError(t, decrypted.Scan(value))
+	assert.Equal(t, "CM1234", decrypted.V)
+
+	// Old keys remain usable after rotation.
+	provider.Keys["k2"] = []byte("fedcba9876543210")
+	provider.CurrentID = "k2"
+	assert.NoError(t, decrypted.Scan(value))
+	assert.Equal(t, "CM1234", decrypted.V)
+
+	// Tampered values are rejected.
+	tampered := value.(string)[:len(value.(string))-2] + "AA"
+	assert.ErrorIs(t, decrypted.Scan(tampered), gh.ErrInvalidCiphertext)
+	assert.ErrorIs(t, decrypted.Scan("plain text"), gh.ErrInvalidCiphertext)
+
+	empty, err := gh.Encrypted[[]byte]{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, empty)
+}
+
+func TestBlindIndexes(t *testing.T) {
+	setTestKeys(t)
+
+	index1, err := gh.BlindIndex("CM1234")
+	assert.NoError(t, err)
+	index2, err := gh.BlindIndex("CM1234")
+	assert.NoError(t, err)
+	assert.Equal(t, index1, index2)
+
+	db := dryRunDB(t)
+	assert.NoError(t, db.Use(&gh.BlindIndexes{}))
+
+	patient := encryptedPatient{NIN: gh.NewEncrypted("CM1234")}
+	db.WithContext(context.Background()).Create(&patient)
+	assert.Equal(t, index1, patient.NINIndex)
+
+	// Plain values of map updates are encrypted and indexed.
+	stmt := db.Model(&encryptedPatient{ID: 1}).Updates(map[string]any{"nin": "CM1234"}).Statement
+	assert.Equal(t, `UPDATE "encrypted_patients" SET "nin"=$1,"nin_index"=$2 WHERE "id" = $3`, stmt.SQL.String())
+	assert.Equal(t, []any{gh.NewEncrypted("CM1234"), index1, uint(1)}, stmt.Vars)
+
+	// Cleared values clear their index.
+	stmt = db.Model(&encryptedPatient{ID: 1}).Updates(map[string]any{"nin": ""}).Statement
+	assert.Equal(t, []any{gh.NewEncrypted(""), nil, uint(1)}, stmt.Vars)
+
+	patient.ID, patient.NIN = 1, gh.Encrypted[string]{}
+	db.Save(&patient)
+	assert.Empty(t, patient.NINIndex)
+
+	gh.SetKeyProvider(nil)
+	_, err = gh.BlindIndex("CM1234")
+	assert.ErrorIs(t, err, gh.ErrNoKeyProvider)
+}
@@ -0,0 +1,178 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidEnumValue is returned when a value is not one of the values of an enum.
+var ErrInvalidEnumValue = errors.New("invalid enum value")
+
+// Enum is implemented by string types with a fixed set of values, e.g
+//
+//	type VisitStatus string
+//
+//	const (
+//		VisitOpen   VisitStatus = "open"
+//		VisitClosed VisitStatus = "closed"
+//	)
+//
+//	func (VisitStatus) EnumValues() []string { return []string{"open", "closed"} }
+type Enum interface {
+	~string
+	EnumValues() []string // the allowed values, in order
+}
+
+// EnsureEnum creates the postgres ENUM type name with values, or adds the missing values
+// to an existing type, each after its predecessor in values so the order is preserved.
+// Values are never removed since postgres can not drop enum values, see RenameEnumValue to rename one.
+//
+// Before postgres 12, ALTER TYPE ... ADD VALUE can not run inside a transaction
+// and a new value can not be used in the transaction that added it.
+func EnsureEnum(db *gorm.DB, name string, values ...string) error {
+	var exists bool
+	if err := db.Raw("SELECT to_regtype(?) IS NOT NULL", quoteIdent(name)).Scan(&exists).Error; err != nil {
+		return fmt.Errorf("failed to look up type %s: %w", name, err)
+	}
+
+	if !exists {
+		literals := make([]string, len(values))
+		for i, v := range values {
+			literals[i] = quoteLiteral(v)
+		}
+
+		sql := "CREATE TYPE " + quoteIdent(name) + " AS ENUM (" + strings.Join(literals, ", ") + ")"
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to create enum %s: %w", name, err)
+		}
+		return nil
+	}
+
+	existing, err := EnumValues(db, name)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range values {
+		if slices.Contains(existing, v) {
+			continue
+		}
+
+		sql := "ALTER TYPE " + quoteIdent(name) + " ADD VALUE IF NOT EXISTS " + quoteLiteral(v)
+		if i > 0 {
+			sql += " AFTER " + quoteLiteral(values[i-1])
+		} else if len(existing) > 0 {
+			sql += " BEFORE " + quoteLiteral(existing[0])
+		}
+
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to add value %s to enum %s: %w", v, name, err)
+		}
+		existing = append(existing, v)
+	}
+	return nil
+}
+
+// EnsureEnumFor creates or updates the postgres ENUM type name from the values of T.
+//
+//	err := gh.EnsureEnumFor[VisitStatus](db, "visit_status")
+func EnsureEnumFor[T Enum](db *gorm.DB, name string) error {
+	var zero T
+	return EnsureEnum(db, name, zero.EnumValues()...)
+}
+
+// EnumValues returns the values of the postgres ENUM type name, in order.
+func EnumValues(db *gorm.DB, name string) ([]string, error) {
+	values := []string{}
+	err := db.Raw(`SELECT enumlabel FROM pg_enum WHERE enumtypid = ?::regtype ORDER BY enumsortorder`,
+		quoteIdent(name)).Scan(&values).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values of enum %s: %w", name, err)
+	}
+	return values, nil
+}
+
+// RenameEnumValue renames the value from of the postgres ENUM type name to to.
+func RenameEnumValue(db *gorm.DB, name, from, to string) error {
+	sql := "ALTER TYPE " + quoteIdent(name) + " RENAME VALUE " + quoteLiteral(from) + " TO " + quoteLiteral(to)
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to rename value %s of enum %s: %w", from, name, err)
+	}
+	return nil
+}
+
+// EnumValue is a value of the Enum T that is validated when scanned, stored and decoded from JSON.
+// An empty EnumValue is stored as NULL. Use the gorm type tag to map it to a postgres ENUM:
+//
+//	type Visit struct {
+//		Status gh.EnumValue[VisitStatus] `gorm:"type:visit_status"`
+//	}
+//
+//	visit.Status = gh.EnumValue[VisitStatus](VisitOpen)
+type EnumValue[T Enum] string
+
+// Get returns the value as T.
+func (e EnumValue[T]) Get() T {
+	return T(e)
+}
+
+// Valid reports whether e is one of the values of T.
+func (e EnumValue[T]) Valid() bool {
+	var zero T
+	return slices.Contains(zero.EnumValues(), string(e))
+}
+
+func (e EnumValue[T]) validate() error {
+	if e != "" && !e.Valid() {
+		var zero T
+		return fmt.Errorf("%w %q for %T", ErrInvalidEnumValue, string(e), zero)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (e EnumValue[T]) Value() (driver.Value, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	if e == "" {
+		return nil, nil
+	}
+	return string(e), nil
+}
+
+// Scan implements sql.Scanner.
+func (e *EnumValue[T]) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*e = ""
+	case []byte:
+		*e = EnumValue[T](v)
+	case string:
+		*e = EnumValue[T](v)
+	default:
+		return fmt.Errorf("gh: cannot scan %T into EnumValue", src)
+	}
+	return e.validate()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *EnumValue[T]) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*e = ""
+	if s != nil {
+		*e = EnumValue[T](*s)
+	}
+	return e.validate()
+}
@@ -0,0 +1,70 @@
+package gh_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type visitStatus string
+
+func (visitStatus) EnumValues() []string { return []string{"open", "billed", "closed"} }
+
+func TestEnsureEnumCreate(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regtype($1) IS NOT NULL")).WithArgs(`"visit_status"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TYPE "visit_status" AS ENUM ('open', 'billed', 'closed')`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.EnsureEnumFor[visitStatus](db, "visit_status"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureEnumAddValues(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regtype($1) IS NOT NULL")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT enumlabel FROM pg_enum WHERE enumtypid = $1::regtype ORDER BY enumsortorder")).
+		WithArgs(`"visit_status"`).
+		WillReturnRows(sqlmock.NewRows([]string{"enumlabel"}).AddRow("closed"))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TYPE "visit_status" ADD VALUE IF NOT EXISTS 'open' BEFORE 'closed'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TYPE "visit_status" ADD VALUE IF NOT EXISTS 'billed' AFTER 'open'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.EnsureEnum(db, "visit_status", "open", "billed", "closed"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnumValue(t *testing.T) {
+	status := gh.EnumValue[visitStatus]("open")
+	assert.True(t, status.Valid())
+	assert.Equal(t, visitStatus("open"), status.Get())
+
+	v, err := status.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "open", v)
+
+	v, err = gh.EnumValue[visitStatus]("").Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = gh.EnumValue[visitStatus]("lost").Value()
+	assert.ErrorIs(t, err, gh.ErrInvalidEnumValue)
+
+	assert.NoError(t, status.Scan([]byte("closed")))
+	assert.Equal(t, gh.EnumValue[visitStatus]("closed"), status)
+	assert.ErrorIs(t, status.Scan("lost"), gh.ErrInvalidEnumValue)
+
+	var decoded struct{ Status gh.EnumValue[visitStatus] }
+	assert.NoError(t, json.Unmarshal([]byte(`{"Status":"billed"}`), &decoded))
+	assert.Equal(t, visitStatus("billed"), decoded.Status.Get())
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"Status":"lost"}`), &decoded), gh.ErrInvalidEnumValue)
+}
@@ -0,0 +1,122 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ModelEvent is a lifecycle event of models, see On.
+type ModelEvent string
+
+const (
+	BeforeCreate ModelEvent = "before_create"
+	AfterCreate  ModelEvent = "after_create"
+	BeforeUpdate ModelEvent = "before_update"
+	AfterUpdate  ModelEvent = "after_update"
+	BeforeDelete ModelEvent = "before_delete"
+	AfterDelete  ModelEvent = "after_delete"
+)
+
+const eventsKey = "gh:events"
+
+// eventBus is the gorm plugin dispatching model events to the handlers registered with On.
+type eventBus struct {
+	mu       sync.RWMutex
+	handlers map[ModelEvent]map[reflect.Type][]func(ctx context.Context, model any) error
+}
+
+// Name implements gorm.Plugin.
+func (b *eventBus) Name() string {
+	return eventsKey
+}
+
+// Initialize implements gorm.Plugin, registering a callback per event.
+func (b *eventBus) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []struct {
+		event    ModelEvent
+		register func(name string, fn func(*gorm.DB)) error
+	}{
+		{BeforeCreate, cb.Create().Before("gorm:create").Register},
+		{AfterCreate, cb.Create().After("gorm:create").Register},
+		{BeforeUpdate, cb.Update().Before("gorm:update").Register},
+		{AfterUpdate, cb.Update().After("gorm:update").Register},
+		{BeforeDelete, cb.Delete().Before("gorm:delete").Register},
+		{AfterDelete, cb.Delete().After("gorm:delete").Register},
+	}
+
+	for _, r := range registrations {
+		event := r.event
+		if err := r.register("gh:events_"+string(event), func(db *gorm.DB) { b.dispatch(db, event) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch calls the handlers of event with each model of the statement.
+func (b *eventBus) dispatch(db *gorm.DB, event ModelEvent) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers[event][db.Statement.Schema.ModelType]
+	b.mu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+
+	forEachModelValue(db.Statement, func(rv reflect.Value) {
+		for _, handler := range handlers {
+			if db.Error != nil {
+				return
+			}
+			if err := handler(db.Statement.Context, rv.Addr().Interface()); err != nil {
+				db.AddError(fmt.Errorf("%s %s handler failed: %w", db.Statement.Schema.Name, event, err))
+			}
+		}
+	})
+}
+
+// On subscribes fn to the event of the model T on db, e.g to send an email or enqueue a job after
+// a user is created, without editing the gorm hooks of T. Handlers are called in the order they
+// are registered, with each model of the statement and its context. An error aborts the statement:
+// the changes are rolled back if it runs in a transaction, the default unless SkipDefaultTransaction
+// is set. For updates of columns and deletes by conditions, the model only has the values it was given.
+//
+//	err := gh.On(db, gh.AfterCreate, func(ctx context.Context, user *User) error {
+//		return mailer.SendWelcome(ctx, user.Email)
+//	})
+func On[T any](db *gorm.DB, event ModelEvent, fn func(ctx context.Context, model *T) error) error {
+	switch event {
+	case BeforeCreate, AfterCreate, BeforeUpdate, AfterUpdate, BeforeDelete, AfterDelete:
+	default:
+		return fmt.Errorf("unknown model event %q", event)
+	}
+
+	bus, ok := db.Config.Plugins[eventsKey].(*eventBus)
+	if !ok {
+		bus = &eventBus{handlers: map[ModelEvent]map[reflect.Type][]func(context.Context, any) error{}}
+		if err := db.Use(bus); err != nil {
+			return fmt.Errorf("failed to register model events: %w", err)
+		}
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.handlers[event] == nil {
+		bus.handlers[event] = map[reflect.Type][]func(context.Context, any) error{}
+	}
+
+	t := reflect.TypeFor[T]()
+	bus.handlers[event][t] = append(bus.handlers[event][t], func(ctx context.Context, model any) error {
+		return fn(ctx, model.(*T))
+	})
+	return nil
+}
@@ -0,0 +1,68 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eventUser struct {
+	ID    uint
+	Email string
+}
+
+type eventKey struct{}
+
+func TestOn(t *testing.T) {
+	db, mock := mockDB(t)
+
+	var events []string
+	require.NoError(t, gh.On(db, gh.BeforeCreate, func(ctx context.Context, user *eventUser) error {
+		if user.Email == "" {
+			return errors.New("missing email")
+		}
+		events = append(events, "before "+user.Email)
+		return nil
+	}))
+	require.NoError(t, gh.On(db, gh.AfterCreate, func(ctx context.Context, user *eventUser) error {
+		events = append(events, "welcome "+user.Email+" "+ctx.Value(eventKey{}).(string))
+		return nil
+	}))
+	require.NoError(t, gh.On(db, gh.AfterDelete, func(ctx context.Context, user *eventUser) error {
+		events = append(events, "deleted")
+		return nil
+	}))
+	assert.ErrorContains(t, gh.On(db, "after_find", func(ctx context.Context, user *eventUser) error { return nil }),
+		`unknown model event "after_find"`)
+
+	mock.ExpectQuery(`INSERT INTO "event_users"`).
+		WithArgs("a@example.com", "b@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	ctx := context.WithValue(context.Background(), eventKey{}, "ctx")
+	users := []eventUser{{Email: "a@example.com"}, {Email: "b@example.com"}}
+	require.NoError(t, gh.WrapDB(db).WithContext(ctx).Create(&users))
+	assert.Equal(t, []string{
+		"before a@example.com", "before b@example.com",
+		"welcome a@example.com ctx", "welcome b@example.com ctx",
+	}, events)
+
+	// A failed handler aborts the statement.
+	err := gh.WrapDB(db).Create(&eventUser{})
+	assert.EqualError(t, err, "eventUser before_create handler failed: missing email")
+
+	// Other models are not dispatched.
+	mock.ExpectExec(`DELETE FROM "departments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gh.WrapDB(db).Delete(&Department{ID: 1}))
+
+	mock.ExpectExec(`DELETE FROM "event_users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gh.WrapDB(db).Delete(&eventUser{ID: 1}))
+	assert.Equal(t, "deleted", events[len(events)-1])
+	assert.Len(t, events, 5)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,135 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Plan is a query plan parsed from EXPLAIN (FORMAT JSON).
+// Times are in milliseconds and only set with ANALYZE.
+type Plan struct {
+	Root          *PlanNode       `json:"Plan"`
+	PlanningTime  float64         `json:"Planning Time"`
+	ExecutionTime float64         `json:"Execution Time"`
+	Raw           json.RawMessage `json:"-"` // the EXPLAIN output
+}
+
+// PlanNode is a node of a query plan. Actual* and buffer fields are only set with ANALYZE.
+type PlanNode struct {
+	NodeType            string      `json:"Node Type"` // e.g Seq Scan, Index Scan, Hash Join
+	RelationName        string      `json:"Relation Name"`
+	Alias               string      `json:"Alias"`
+	IndexName           string      `json:"Index Name"`
+	Filter              string      `json:"Filter"`
+	StartupCost         float64     `json:"Startup Cost"`
+	TotalCost           float64     `json:"Total Cost"`
+	PlanRows            float64     `json:"Plan Rows"`
+	PlanWidth           int         `json:"Plan Width"`
+	ActualStartupTime   float64     `json:"Actual Startup Time"`
+	ActualTotalTime     float64     `json:"Actual Total Time"`
+	ActualRows          float64     `json:"Actual Rows"` // per loop
+	ActualLoops         float64     `json:"Actual Loops"`
+	RowsRemovedByFilter float64     `json:"Rows Removed by Filter"`
+	SharedHitBlocks     int64       `json:"Shared Hit Blocks"`
+	SharedReadBlocks    int64       `json:"Shared Read Blocks"`
+	Plans               []*PlanNode `json:"Plans"`
+}
+
+// Rows returns the number of rows the node processed: the actual rows of all loops
+// (plus those removed by a filter) with ANALYZE, the planner estimate otherwise.
+func (n *PlanNode) Rows() float64 {
+	if n.ActualLoops > 0 {
+		return (n.ActualRows + n.RowsRemovedByFilter) * n.ActualLoops
+	}
+	return n.PlanRows
+}
+
+// Walk calls fn for the root node and its descendants, depth first.
+func (p *Plan) Walk(fn func(n *PlanNode)) {
+	var walk func(n *PlanNode)
+	walk = func(n *PlanNode) {
+		if n == nil {
+			return
+		}
+		fn(n)
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+}
+
+// SeqScans returns the sequential scan nodes that processed at least minRows rows,
+// the usual sign of a missing index.
+func (p *Plan) SeqScans(minRows float64) []*PlanNode {
+	nodes := []*PlanNode{}
+	p.Walk(func(n *PlanNode) {
+		if n.NodeType == "Seq Scan" && n.Rows() >= minRows {
+			nodes = append(nodes, n)
+		}
+	})
+	return nodes
+}
+
+// Explain runs EXPLAIN on the statement fn would execute on the chain and parses the plan.
+// With analyze, the statement is executed (EXPLAIN ANALYZE, BUFFERS): run inserts, updates
+// and deletes in a transaction that is rolled back.
+//
+//	plan, err := gdb.Eq("doctor", "Dr. Smith").Explain(ctx, true, func(tx *gorm.DB) *gorm.DB {
+//		return tx.Find(&[]Visit{})
+//	})
+func (gdb *GormDB) Explain(ctx context.Context, analyze bool, fn func(tx *gorm.DB) *gorm.DB) (*Plan, error) {
+	tx := fn(gdb.db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true}))
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// The dry run SQL has the dialect's placeholders, so it is run on the connection directly.
+	stmt := tx.Statement
+	var raw []byte
+	err := gdb.db.Statement.ConnPool.QueryRowContext(ctx, explainPrefix(analyze)+stmt.SQL.String(), stmt.Vars...).Scan(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	return parsePlan(raw)
+}
+
+// Explain runs EXPLAIN on the query and parses the plan. With analyze,
+// the query is executed (EXPLAIN ANALYZE, BUFFERS).
+func (qb *QueryBuilder) Explain(ctx context.Context, db *gorm.DB, analyze bool) (*Plan, error) {
+	query, args, err := qb.buildGorm()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	if err := db.WithContext(ctx).Raw(explainPrefix(analyze)+query, args...).Row().Scan(&raw); err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	return parsePlan(raw)
+}
+
+func explainPrefix(analyze bool) string {
+	if analyze {
+		return "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "
+	}
+	return "EXPLAIN (FORMAT JSON) "
+}
+
+// parsePlan parses the output of EXPLAIN (FORMAT JSON), a single element array.
+func parsePlan(raw []byte) (*Plan, error) {
+	plans := []*Plan{}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	if len(plans) == 0 || plans[0].Root == nil {
+		return nil, fmt.Errorf("failed to parse plan: no plan in %s", raw)
+	}
+
+	plans[0].Raw = raw
+	return plans[0], nil
+}
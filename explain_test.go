@@ -0,0 +1,76 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type explainVisit struct {
+	ID     uint
+	Doctor string
+}
+
+const analyzedPlan = `[{"Plan": {"Node Type": "Hash Join", "Total Cost": 250.5, "Plan Rows": 10,
+	"Actual Rows": 8, "Actual Loops": 1, "Plans": [
+		{"Node Type": "Seq Scan", "Relation Name": "explain_visits", "Plan Rows": 100, "Actual Rows": 4,
+			"Actual Loops": 1, "Rows Removed by Filter": 49996, "Shared Read Blocks": 420},
+		{"Node Type": "Index Scan", "Relation Name": "patients", "Index Name": "patients_pkey", "Actual Rows": 1, "Actual Loops": 4}
+	]}, "Planning Time": 0.2, "Execution Time": 31.5}]`
+
+func TestGormDBExplain(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) SELECT * FROM "explain_visits" WHERE doctor = $1`)).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(analyzedPlan))
+
+	plan, err := gh.WrapDB(db).Eq("doctor", "Dr. Smith").Explain(context.Background(), true, func(tx *gorm.DB) *gorm.DB {
+		return tx.Find(&[]explainVisit{})
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "Hash Join", plan.Root.NodeType)
+	assert.Equal(t, 31.5, plan.ExecutionTime)
+	require.Len(t, plan.Root.Plans, 2)
+	assert.Equal(t, int64(420), plan.Root.Plans[0].SharedReadBlocks)
+
+	scans := plan.SeqScans(10000)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "explain_visits", scans[0].RelationName)
+	assert.Empty(t, plan.SeqScans(100000))
+}
+
+func TestQueryBuilderExplain(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM visits WHERE doctor = $1`)).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).
+			AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "visits", "Plan Rows": 500}}]`))
+
+	plan, err := gh.NewQueryBuilder("SELECT * FROM visits").Where("doctor = ?", "Dr. Smith").
+		Explain(context.Background(), db, false)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, float64(500), plan.Root.Rows())
+	assert.Len(t, plan.SeqScans(500), 1)
+	assert.Contains(t, string(plan.Raw), "Seq Scan")
+}
+
+func TestExplainInvalidPlan(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("EXPLAIN").WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[]`))
+
+	_, err := gh.NewQueryBuilder("SELECT 1").Explain(context.Background(), db, false)
+	assert.ErrorContains(t, err, "no plan")
+}
@@ -0,0 +1,189 @@
+package gh
+
+import (
+	"cmp"
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ExportOptions are the options shared by the exports of query results, e.g ExportCSV.
+type ExportOptions struct {
+	// Columns are the columns (or field names) of the model exported, in order.
+	// Default: all the columns of the model, except fields tagged `csv:"-"`.
+	Columns []string
+
+	// BatchSize is the number of rows fetched per query. Default: 1000.
+	BatchSize int
+
+	// OrderBy orders the rows, see GetKeysetPaginated. Default: the primary key.
+	OrderBy []string
+}
+
+// CSVOptions are options for ExportCSV.
+type CSVOptions struct {
+	ExportOptions
+
+	// Comma is the field delimiter. Default: ','.
+	Comma rune
+
+	// NoHeader omits the header row.
+	NoHeader bool
+}
+
+// exportColumn is a column of an export, with its header.
+type exportColumn struct {
+	field  *schema.Field
+	header string
+}
+
+// exportColumns returns the columns of T to export. Headers are the values of the `csv` struct
+// tag, or the column names.
+func exportColumns[T any](db *gorm.DB, columns []string) ([]exportColumn, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("failed to parse %T: %w", *new(T), err)
+	}
+
+	header := func(field *schema.Field) string {
+		name, _, _ := strings.Cut(field.Tag.Get("csv"), ",")
+		return cmp.Or(name, field.DBName)
+	}
+
+	result := []exportColumn{}
+	if len(columns) == 0 {
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && field.Readable && field.Tag.Get("csv") != "-" {
+				result = append(result, exportColumn{field: field, header: header(field)})
+			}
+		}
+		return result, nil
+	}
+
+	for _, column := range columns {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("unknown export column %q for %s", column, stmt.Schema.Table)
+		}
+		result = append(result, exportColumn{field: field, header: header(field)})
+	}
+	return result, nil
+}
+
+// exportRows calls fn with each row of T matching the chain, fetched in batches with keyset pagination.
+// The ordering of the chain is replaced by opts.OrderBy.
+func exportRows[T any](gdb *GormDB, opts ExportOptions, fn func(rv reflect.Value) error) error {
+	for row, err := range Iter[T](gdb.db.Statement.Context, gdb.db, cmp.Or(opts.BatchSize, 1000), opts.OrderBy...) {
+		if err != nil {
+			return err
+		}
+		if err := fn(reflect.ValueOf(&row).Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportValue returns the value of the column of the row rv, dereferenced and with
+// driver.Valuer types (except time.Time) converted to their database value. NULL is nil.
+func exportValue(column exportColumn, rv reflect.Value) (any, error) {
+	value, _ := column.field.ValueOf(context.Background(), rv)
+	for {
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		value = v.Elem().Interface()
+	}
+
+	if _, ok := value.(time.Time); ok {
+		return value, nil
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", column.field.DBName, err)
+		}
+		return dv, nil
+	}
+	return value, nil
+}
+
+// ExportCSV streams the rows of T matching the chain to w as CSV, fetching them in batches
+// with keyset pagination, so large exports are not loaded in memory. The ordering of the chain
+// is replaced by opts.OrderBy. Times are formatted as RFC 3339 and NULLs are empty.
+//
+//	w.Header().Set("Content-Type", "text/csv")
+//	err := gh.ExportCSV[Visit](w, gdb.WithContext(r.Context()).Eq("doctor", doctor), gh.CSVOptions{
+//		ExportOptions: gh.ExportOptions{Columns: []string{"id", "patient_name", "created_at"}},
+//	})
+func ExportCSV[T any](w io.Writer, gdb *GormDB, opts CSVOptions) error {
+	columns, err := exportColumns[T](gdb.db, opts.Columns)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+
+	if !opts.NoHeader {
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.header
+		}
+		if err := cw.Write(headers); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	record := make([]string, len(columns))
+	err = exportRows[T](gdb, opts.ExportOptions, func(rv reflect.Value) error {
+		for i, column := range columns {
+			value, err := exportValue(column, rv)
+			if err != nil {
+				return err
+			}
+			record[i] = formatCSV(value)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export CSV: %w", err)
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// formatCSV formats a value returned by exportValue.
+func formatCSV(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
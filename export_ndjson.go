@@ -0,0 +1,77 @@
+package gh
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// NDJSONOptions are options for ExportNDJSON.
+type NDJSONOptions struct {
+	ExportOptions
+
+	// Gzip compresses the output.
+	Gzip bool
+}
+
+// ExportNDJSON streams the rows of T matching the chain to w as newline delimited JSON (JSON Lines),
+// one object per row, iterating over them with Iter. Rows are encoded with encoding/json, so the json
+// struct tags apply, unless opts.Columns are set: objects then have these columns, keyed by name.
+//
+//	w.Header().Set("Content-Type", "application/x-ndjson")
+//	w.Header().Set("Content-Encoding", "gzip")
+//	err := gh.ExportNDJSON[AuditLog](w, gdb.WithContext(r.Context()), gh.NDJSONOptions{Gzip: true})
+func ExportNDJSON[T any](w io.Writer, gdb *GormDB, opts NDJSONOptions) error {
+	var columns []exportColumn
+	if len(opts.Columns) > 0 {
+		var err error
+		if columns, err = exportColumns[T](gdb.db, opts.Columns); err != nil {
+			return err
+		}
+	}
+
+	var gz *gzip.Writer
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+
+	ctx := gdb.db.Statement.Context
+	for row, err := range Iter[T](ctx, gdb.db, cmp.Or(opts.BatchSize, 1000), opts.OrderBy...) {
+		if err != nil {
+			return fmt.Errorf("failed to export NDJSON: %w", err)
+		}
+
+		var value any = row
+		if columns != nil {
+			object := make(map[string]any, len(columns))
+			rv := reflect.ValueOf(&row).Elem()
+			for _, column := range columns {
+				object[column.field.DBName], _ = column.field.ValueOf(ctx, rv)
+			}
+			value = object
+		}
+
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("failed to write NDJSON: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write NDJSON: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write NDJSON: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package gh_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportVisit struct {
+	ID        uint   `csv:"Visit"`
+	Patient   string `csv:"Patient Name"`
+	Fee       *float64
+	Notes     string    `csv:"-"`
+	CreatedAt time.Time `csv:"Date"`
+}
+
+func TestExportCSV(t *testing.T) {
+	db, mock := mockDB(t)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	columns := []string{"id", "patient", "fee", "notes", "created_at"}
+
+	mock.ExpectQuery(`SELECT \* FROM "export_visits" WHERE doctor = \$1 ORDER BY "export_visits"."id" LIMIT \$2`).
+		WithArgs("Dr. Okello", 3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "John, Jr.", 15000.5, "x", created).
+			AddRow(2, "Jane", nil, "y", created).
+			AddRow(3, "Amina", 100, "z", created))
+	mock.ExpectQuery(`SELECT \* FROM "export_visits" WHERE doctor = \$1 AND "export_visits"."id" > \$2 ORDER BY "export_visits"."id" LIMIT \$3`).
+		WithArgs("Dr. Okello", 2, 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "Amina", 100, "z", created))
+
+	var buf bytes.Buffer
+	err := gh.ExportCSV[exportVisit](&buf, gh.WrapDB(db).Where("doctor = ?", "Dr. Okello"), gh.CSVOptions{
+		ExportOptions: gh.ExportOptions{BatchSize: 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Visit,Patient Name,fee,Date\n"+
+		"1,\"John, Jr.\",15000.5,2024-05-01T10:00:00Z\n"+
+		"2,Jane,,2024-05-01T10:00:00Z\n"+
+		"3,Amina,100,2024-05-01T10:00:00Z\n", buf.String())
+
+	mock.ExpectQuery(`SELECT \* FROM "export_visits" ORDER BY "export_visits"."id" LIMIT \$1`).
+		WithArgs(1001).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Jane", nil, "x", created))
+
+	buf.Reset()
+	err = gh.ExportCSV[exportVisit](&buf, gh.WrapDB(db), gh.CSVOptions{
+		ExportOptions: gh.ExportOptions{Columns: []string{"Notes", "patient"}},
+		Comma:         ';',
+		NoHeader:      true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "x;Jane\n", buf.String())
+
+	err = gh.ExportCSV[exportVisit](&buf, gh.WrapDB(db), gh.CSVOptions{ExportOptions: gh.ExportOptions{Columns: []string{"doctor"}}})
+	assert.ErrorContains(t, err, `unknown export column "doctor"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportXLSX(t *testing.T) {
+	db, mock := mockDB(t)
+	created := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT \* FROM "export_visits" ORDER BY "export_visits"."id" LIMIT \$1`).
+		WithArgs(1001).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient", "fee", "notes", "created_at"}).
+			AddRow(1, "John & Sons", 15000.5, "", created).
+			AddRow(2, "Jane", 100, "", created.Truncate(24*time.Hour)).
+			AddRow(3, "Amina", nil, "", created))
+
+	var buf bytes.Buffer
+	err := gh.ExportXLSX[exportVisit](&buf, gh.WrapDB(db), gh.XLSXOptions{SheetName: "Visits", Totals: []string{"fee"}})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+
+		// Every part is well-formed XML.
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for err == nil {
+			_, err = decoder.Token()
+		}
+		require.ErrorIs(t, err, io.EOF, f.Name)
+		parts[f.Name] = string(data)
+	}
+
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Visits"`)
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="B1" s="1" t="inlineStr"><is><t xml:space="preserve">Patient Name</t></is></c>`,
+		`<c r="B2" s="0" t="inlineStr"><is><t xml:space="preserve">John &amp; Sons</t></is></c>`,
+		`<c r="C2" s="0"><v>15000.5</v></c>`,
+		`<c r="D2" s="3"><v>45413.4375</v></c>`, // 2024-05-01 10:30
+		`<c r="D3" s="2"><v>45413</v></c>`,
+		`<row r="4"><c r="A4" s="0"><v>3</v></c><c r="B4" s="0" t="inlineStr"><is><t xml:space="preserve">Amina</t></is></c><c r="D4"`,
+		`<c r="A5" s="1" t="inlineStr"><is><t>Total</t></is></c><c r="C5" s="1"><f>SUM(C2:C4)</f><v>15100.5</v></c>`,
+		`<col min="2" max="2" width="14" customWidth="1"/>`,
+	} {
+		assert.Contains(t, sheet, cell)
+	}
+
+	err = gh.ExportXLSX[exportVisit](&buf, gh.WrapDB(db), gh.XLSXOptions{Totals: []string{"doctor"}})
+	assert.ErrorContains(t, err, `unknown totals column "doctor"`)
+}
+
+func TestExportNDJSON(t *testing.T) {
+	db, mock := mockDB(t)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "patient", "fee", "notes", "created_at"}).
+			AddRow(1, "John <Jr>", 15000.5, "", created).
+			AddRow(2, "Jane", nil, "", created)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "export_visits"`).WithArgs(1001).WillReturnRows(rows())
+
+	var buf bytes.Buffer
+	require.NoError(t, gh.ExportNDJSON[exportVisit](&buf, gh.WrapDB(db), gh.NDJSONOptions{}))
+	assert.Equal(t, `{"ID":1,"Patient":"John <Jr>","Fee":15000.5,"Notes":"","CreatedAt":"2024-05-01T10:00:00Z"}`+"\n"+
+		`{"ID":2,"Patient":"Jane","Fee":null,"Notes":"","CreatedAt":"2024-05-01T10:00:00Z"}`+"\n", buf.String())
+
+	mock.ExpectQuery(`SELECT \* FROM "export_visits"`).WithArgs(1001).WillReturnRows(rows())
+
+	buf.Reset()
+	require.NoError(t, gh.ExportNDJSON[exportVisit](&buf, gh.WrapDB(db), gh.NDJSONOptions{
+		ExportOptions: gh.ExportOptions{Columns: []string{"id", "Patient"}},
+		Gzip:          true,
+	}))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"patient":"John <Jr>"}`+"\n"+`{"id":2,"patient":"Jane"}`+"\n", string(data))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
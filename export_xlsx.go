@@ -0,0 +1,328 @@
+package gh
+
+import (
+	"archive/zip"
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm/schema"
+)
+
+// XLSXOptions are options for ExportXLSX.
+type XLSXOptions struct {
+	ExportOptions
+
+	// SheetName is the name of the worksheet, at most 31 characters without []:*?/\. Default: Sheet1.
+	SheetName string
+
+	// Totals are the columns summed in a totals row after the rows.
+	Totals []string
+}
+
+// Cell styles, indexes of cellXfs in xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleBold
+	xlsxStyleDate
+	xlsxStyleDateTime
+)
+
+// ExportXLSX streams the rows of T matching the chain to w as an Excel workbook with one sheet,
+// fetching them in batches with keyset pagination like ExportCSV. Numbers and booleans are typed cells,
+// times are formatted dates, the header row is bold and frozen, and the columns are sized to fit their
+// content. The sheet is spooled to a temporary file until the widths are known.
+//
+//	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+//	w.Header().Set("Content-Disposition", `attachment; filename="invoices.xlsx"`)
+//	err := gh.ExportXLSX[Invoice](w, gdb.WithContext(r.Context()), gh.XLSXOptions{Totals: []string{"amount"}})
+func ExportXLSX[T any](w io.Writer, gdb *GormDB, opts XLSXOptions) error {
+	columns, err := exportColumns[T](gdb.db, opts.Columns)
+	if err != nil {
+		return err
+	}
+
+	name := cmp.Or(opts.SheetName, "Sheet1")
+	if utf8.RuneCountInString(name) > 31 || strings.ContainsAny(name, `[]:*?/\`) {
+		return fmt.Errorf("invalid sheet name %q", name)
+	}
+
+	totals := make([]bool, len(columns))
+	for _, total := range opts.Totals {
+		i := -1
+		for j, column := range columns {
+			if column.field.DBName == total || column.field.Name == total {
+				i = j
+			}
+		}
+		if i < 0 {
+			return fmt.Errorf("unknown totals column %q", total)
+		}
+		totals[i] = true
+	}
+
+	spool, err := os.CreateTemp("", "gh-xlsx-*")
+	if err != nil {
+		return fmt.Errorf("failed to create XLSX spool: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	sheet := &xlsxSheet{w: bufio.NewWriter(spool), widths: make([]int, len(columns)), sums: make([]float64, len(columns))}
+	headers := make([]any, len(columns))
+	for i, column := range columns {
+		headers[i] = column.header
+	}
+	sheet.writeRow(headers, xlsxStyleBold)
+
+	row := make([]any, len(columns))
+	err = exportRows[T](gdb, opts.ExportOptions, func(rv reflect.Value) error {
+		for i, column := range columns {
+			value, err := exportValue(column, rv)
+			if err != nil {
+				return err
+			}
+			row[i] = xlsxValue(column.field, value)
+			if totals[i] {
+				if n, ok := row[i].(json.Number); ok {
+					f, _ := n.Float64()
+					sheet.sums[i] += f
+				}
+			}
+		}
+		sheet.writeRow(row, xlsxStyleDefault)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export XLSX: %w", err)
+	}
+
+	if len(opts.Totals) > 0 {
+		sheet.writeTotals(totals)
+	}
+
+	if err := sheet.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write XLSX spool: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read XLSX spool: %w", err)
+	}
+
+	if err := writeXLSX(w, name, sheet, spool); err != nil {
+		return fmt.Errorf("failed to write XLSX: %w", err)
+	}
+	return nil
+}
+
+// xlsxSheet writes the rows of a worksheet, tracking the column widths and totals.
+type xlsxSheet struct {
+	w      *bufio.Writer
+	rows   int
+	widths []int     // in characters
+	sums   []float64 // of the totals columns
+}
+
+// xlsxValue converts a value returned by exportValue for a cell: numbers (and numeric strings of
+// numeric columns, e.g decimals) become json.Number, times and booleans are kept, others are text.
+func xlsxValue(field *schema.Field, value any) any {
+	switch v := value.(type) {
+	case nil, time.Time, bool:
+		return v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return json.Number(fmt.Sprint(v))
+	case json.Number:
+		return v
+	case string:
+		if isNumericField(field) {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return json.Number(v)
+			}
+		}
+	}
+	return formatCSV(value)
+}
+
+// isNumericField reports whether field is stored in a numeric column.
+func isNumericField(field *schema.Field) bool {
+	switch field.DataType {
+	case schema.Int, schema.Uint, schema.Float:
+		return true
+	}
+	dataType := strings.ToLower(string(field.DataType))
+	return strings.HasPrefix(dataType, "numeric") || strings.HasPrefix(dataType, "decimal")
+}
+
+func (s *xlsxSheet) writeRow(values []any, style int) {
+	s.rows++
+	fmt.Fprintf(s.w, `<row r="%d">`, s.rows)
+	for i, value := range values {
+		s.writeCell(i, value, style)
+	}
+	s.w.WriteString("</row>")
+}
+
+func (s *xlsxSheet) writeCell(i int, value any, style int) {
+	ref := xlsxColumn(i) + strconv.Itoa(s.rows)
+	width := 0
+
+	switch v := value.(type) {
+	case nil:
+		return
+	case json.Number:
+		fmt.Fprintf(s.w, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, v)
+		width = len(v)
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		fmt.Fprintf(s.w, `<c r="%s" s="%d" t="b"><v>%d</v></c>`, ref, style, b)
+		width = 5
+	case time.Time:
+		style, width = xlsxStyleDateTime, 16
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			style, width = xlsxStyleDate, 10
+		}
+		fmt.Fprintf(s.w, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(xlsxSerial(v), 'f', -1, 64))
+	default:
+		text := fmt.Sprint(v)
+		fmt.Fprintf(s.w, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+		xml.EscapeText(s.w, []byte(text))
+		s.w.WriteString("</t></is></c>")
+		width = utf8.RuneCountInString(text)
+	}
+	s.widths[i] = max(s.widths[i], width)
+}
+
+// writeTotals writes a bold row summing the totals columns of the rows.
+func (s *xlsxSheet) writeTotals(totals []bool) {
+	first, last := 2, s.rows
+	s.rows++
+	fmt.Fprintf(s.w, `<row r="%d">`, s.rows)
+	for i, total := range totals {
+		ref := xlsxColumn(i) + strconv.Itoa(s.rows)
+		switch {
+		case total:
+			sum := strconv.FormatFloat(s.sums[i], 'f', -1, 64)
+			fmt.Fprintf(s.w, `<c r="%s" s="%d"><f>SUM(%s%d:%s%d)</f><v>%s</v></c>`,
+				ref, xlsxStyleBold, xlsxColumn(i), first, xlsxColumn(i), last, sum)
+			s.widths[i] = max(s.widths[i], len(sum))
+		case i == 0:
+			fmt.Fprintf(s.w, `<c r="%s" s="%d" t="inlineStr"><is><t>Total</t></is></c>`, ref, xlsxStyleBold)
+		}
+	}
+	s.w.WriteString("</row>")
+}
+
+// xlsxColumn returns the name of the column i, e.g A for 0 and AA for 26.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSerial returns the Excel serial date of the wall clock of t: days since 1899-12-30.
+func xlsxSerial(t time.Time) float64 {
+	_, offset := t.Zone()
+	return float64(t.Unix()+int64(offset))/86400 + 25569
+}
+
+// writeXLSX writes the workbook of the sheet, whose rows are read from sheetData.
+func writeXLSX(w io.Writer, name string, sheet *xlsxSheet, sheetData io.Reader) error {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlAttr(name))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	b.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	b.WriteString(`</sheetView></sheetViews><cols>`)
+	for i, width := range sheet.widths {
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, min(max(width, 6), 60)+2)
+	}
+	b.WriteString("</cols><sheetData>")
+
+	if _, err := io.WriteString(f, b.String()); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, sheetData); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func xmlAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines the cell styles: default, bold, date and date time.
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs></styleSheet>`
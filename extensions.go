@@ -0,0 +1,59 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInsufficientPrivilege is returned when the database user lacks the privilege for an operation.
+	ErrInsufficientPrivilege = errors.New("insufficient privilege")
+
+	// ErrExtensionUnavailable is returned when an extension is not installed on the database server.
+	ErrExtensionUnavailable = errors.New("extension not available on the server")
+)
+
+// EnsureExtensions creates the given extensions if they are not installed,
+// so features like trigram search or UUID defaults can declare their prerequisites.
+//
+//	err := gh.EnsureExtensions(db, "pg_trgm", "unaccent", "pgcrypto")
+//
+// Creating most extensions requires superuser (or, for trusted extensions, CREATE on the database).
+// Errors wrap ErrInsufficientPrivilege or ErrExtensionUnavailable when applicable.
+func EnsureExtensions(db *gorm.DB, names ...string) error {
+	installed := []string{}
+	if err := db.Raw("SELECT extname FROM pg_extension").Scan(&installed).Error; err != nil {
+		return fmt.Errorf("failed to list extensions: %w", err)
+	}
+
+	for _, name := range names {
+		if slices.Contains(installed, name) {
+			continue
+		}
+
+		if err := db.Exec("CREATE EXTENSION IF NOT EXISTS " + quoteName(name)).Error; err != nil {
+			return extensionError(name, err)
+		}
+	}
+	return nil
+}
+
+// extensionError describes a failure to create the extension name.
+func extensionError(name string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "42501": // insufficient_privilege
+			return fmt.Errorf("failed to create extension %s, run CREATE EXTENSION %s as a superuser: %w: %w",
+				name, quoteName(name), ErrInsufficientPrivilege, err)
+		case "58P01", "0A000": // undefined_file, feature_not_supported
+			return fmt.Errorf("failed to create extension %s, install its package on the server: %w: %w",
+				name, ErrExtensionUnavailable, err)
+		}
+	}
+	return fmt.Errorf("failed to create extension %s: %w", name, err)
+}
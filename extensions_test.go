@@ -0,0 +1,46 @@
+package gh_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureExtensions(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("SELECT extname FROM pg_extension").
+		WillReturnRows(sqlmock.NewRows([]string{"extname"}).AddRow("plpgsql").AddRow("pg_trgm"))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.EnsureExtensions(db, "pg_trgm", "uuid-ossp"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureExtensionsErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"42501", gh.ErrInsufficientPrivilege},
+		{"0A000", gh.ErrExtensionUnavailable},
+	}
+
+	for _, tt := range tests {
+		db, mock := mockDB(t)
+		mock.ExpectQuery("SELECT extname FROM pg_extension").WillReturnRows(sqlmock.NewRows([]string{"extname"}))
+		mock.ExpectExec(regexp.QuoteMeta(`CREATE EXTENSION IF NOT EXISTS "postgis"`)).
+			WillReturnError(&pgconn.PgError{Code: tt.code, Message: "failed"})
+
+		err := gh.EnsureExtensions(db, "postgis")
+		assert.ErrorIs(t, err, tt.want)
+
+		var pgErr *pgconn.PgError
+		assert.True(t, errors.As(err, &pgErr))
+	}
+}
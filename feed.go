@@ -0,0 +1,88 @@
+package gh
+
+import (
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FeedPage is a page of new records for feed-style endpoints.
+type FeedPage[T any] struct {
+	Results []T    `json:"results"`  // newest first
+	HasMore bool   `json:"has_more"` // more new records exist, fetch again with Cursor
+	Cursor  string `json:"cursor"`   // position of the newest record returned, or the given cursor if none
+}
+
+// SincePaginate retrieves up to limit records of T whose time column is after the given time,
+// for feed-style endpoints (e.g fetch new notifications since the last poll).
+//
+// The oldest new records are returned first so that no record is skipped when more than
+// limit records are new: if HasMore is true, call SinceCursor with the returned Cursor to
+// fetch the next batch. Within a page results are ordered newest first.
+// Ordering is deterministic (time column, then primary key), so records sharing a timestamp
+// are neither duplicated nor lost across pages.
+// If limit is less than 1, it defaults to 10.
+func SincePaginate[T any](db *gorm.DB, column string, after time.Time, limit int) (*FeedPage[T], error) {
+	ks, err := newKeyset(db, new(T), []string{column})
+	if err != nil {
+		return nil, err
+	}
+	return fetchFeed[T](db, ks, clause.Gt{Column: ks.column(0), Value: after}, limit, "")
+}
+
+// SinceCursor retrieves up to limit records of T newer than the record identified by cursor,
+// as returned by SincePaginate or a previous SinceCursor call. See SincePaginate.
+// An empty cursor starts from the oldest record, so that a poller can keep polling with the
+// Cursor of a page, even if it was empty.
+func SinceCursor[T any](db *gorm.DB, column string, cursor string, limit int) (*FeedPage[T], error) {
+	ks, err := newKeyset(db, new(T), []string{column})
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor == "" {
+		return fetchFeed[T](db, ks, nil, limit, cursor)
+	}
+
+	values, err := ks.decode(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return fetchFeed[T](db, ks, ks.after(values, false), limit, cursor)
+}
+
+func fetchFeed[T any](db *gorm.DB, ks *keyset, condition clause.Expression, limit int, cursor string) (*FeedPage[T], error) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	query := db.Model(new(T))
+	if condition != nil {
+		query = query.Where(condition)
+	}
+
+	results := []T{}
+	err := query.Order(ks.order(false)).Limit(limit + 1).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &FeedPage[T]{Results: results, Cursor: cursor}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		page.HasMore = true
+	}
+
+	if len(page.Results) > 0 {
+		page.Cursor, err = ks.encode(reflect.ValueOf(&page.Results[len(page.Results)-1]).Elem())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Reverse(page.Results)
+	return page, nil
+}
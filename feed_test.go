@@ -0,0 +1,37 @@
+package gh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSincePaginate(t *testing.T) {
+	db := dryRunDB(t)
+
+	var stmt *gorm.Statement
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		stmt = tx.Statement
+	}))
+
+	since := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	page, err := gh.SincePaginate[keysetVisit](db, "created_at", since, 50)
+	assert.NoError(t, err)
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.Cursor)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" WHERE "keyset_visits"."created_at" > $1 ORDER BY "keyset_visits"."created_at","keyset_visits"."id" LIMIT $2`, stmt.SQL.String())
+	assert.Equal(t, []any{since, 51}, stmt.Vars)
+
+	// The empty cursor of an empty page starts from the oldest record.
+	page, err = gh.SinceCursor[keysetVisit](db, "created_at", page.Cursor, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, page.Cursor)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" ORDER BY "keyset_visits"."created_at","keyset_visits"."id" LIMIT $1`, stmt.SQL.String())
+	assert.Equal(t, []any{11}, stmt.Vars)
+
+	_, err = gh.SinceCursor[keysetVisit](db, "created_at", "invalid", 50)
+	assert.ErrorIs(t, err, gh.ErrInvalidCursor)
+}
@@ -0,0 +1,243 @@
+package gh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Fixtures are the rows inserted by LoadFixtures, with their generated columns, by "table.label".
+type Fixtures map[string]map[string]any
+
+// ID returns the id column of the fixture name ("table.label"), or nil if there is no such fixture.
+func (f Fixtures) ID(name string) any {
+	return f[name]["id"]
+}
+
+// LoadFixtures inserts the fixtures of the files of fsys matching the glob patterns, in a transaction,
+// after truncating their tables (restarting identities and cascading to referencing tables).
+//
+// Each YAML or JSON file is named after its table, e.g fixtures/patients.yml, and maps fixture labels
+// to the column values of a row. Maps and lists are stored as JSON. Files are text/template templates
+// with the functions:
+//
+//	now             the current time
+//	uuid            a new UUIDv7
+//	ref NAME [COL]  the id (or column COL) of the fixture NAME, "table.label", loading its file first
+//
+// For example, fixtures/visits.yml:
+//
+//	checkup:
+//	  patient_id: {{ ref "patients.john" }}
+//	  reference: "{{ uuid }}"
+//	  created_at: "{{ now }}"
+//	  vitals: {pulse: 72}
+func LoadFixtures(db *gorm.DB, fsys fs.FS, patterns ...string) (Fixtures, error) {
+	files := map[string]string{} // by table
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixtures pattern %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			ext := path.Ext(match)
+			if ext != ".yml" && ext != ".yaml" && ext != ".json" {
+				continue
+			}
+
+			table := strings.TrimSuffix(path.Base(match), ext)
+			if other, ok := files[table]; ok && other != match {
+				return nil, fmt.Errorf("duplicate fixtures for table %s: %s and %s", table, other, match)
+			}
+			files[table] = match
+		}
+	}
+
+	tables := make([]string, 0, len(files))
+	for table := range files {
+		tables = append(tables, table)
+	}
+	slices.Sort(tables)
+
+	fixtures := Fixtures{}
+	if len(tables) == 0 {
+		return fixtures, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		quoted := make([]string, len(tables))
+		for i, table := range tables {
+			quoted[i] = quoteIdent(table)
+		}
+
+		if err := tx.Exec("TRUNCATE " + strings.Join(quoted, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			return fmt.Errorf("failed to truncate fixture tables: %w", err)
+		}
+
+		loader := &fixtureLoader{tx: tx, fsys: fsys, files: files, fixtures: fixtures, state: map[string]int{}}
+		for _, table := range tables {
+			if err := loader.load(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+const (
+	fixtureLoading = 1
+	fixtureLoaded  = 2
+)
+
+// fixtureLoader loads fixture files, loading the files referenced with ref first.
+type fixtureLoader struct {
+	tx       *gorm.DB
+	fsys     fs.FS
+	files    map[string]string
+	fixtures Fixtures
+	state    map[string]int // by table
+}
+
+func (l *fixtureLoader) load(table string) error {
+	switch l.state[table] {
+	case fixtureLoaded:
+		return nil
+	case fixtureLoading:
+		return fmt.Errorf("circular reference to the fixtures of table %s", table)
+	}
+	l.state[table] = fixtureLoading
+
+	file := l.files[table]
+	data, err := fs.ReadFile(l.fsys, file)
+	if err != nil {
+		return fmt.Errorf("failed to read fixtures %s: %w", file, err)
+	}
+
+	tmpl, err := template.New(file).Funcs(template.FuncMap{
+		"now":  func() string { return time.Now().Format(time.RFC3339Nano) },
+		"uuid": NewUUIDv7,
+		"ref":  l.ref,
+	}).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid fixtures %s: %w", file, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return fmt.Errorf("invalid fixtures %s: %w", file, err)
+	}
+
+	// JSON is YAML, and yaml.Node keeps the order of the fixtures.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		return fmt.Errorf("invalid fixtures %s: %w", file, err)
+	}
+
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("invalid fixtures %s: expected a mapping of labels to rows", file)
+		}
+
+		for i := 0; i < len(root.Content); i += 2 {
+			label := root.Content[i].Value
+			row := map[string]any{}
+			if err := root.Content[i+1].Decode(&row); err != nil {
+				return fmt.Errorf("invalid fixture %s.%s: %w", table, label, err)
+			}
+
+			inserted, err := l.insert(table, row)
+			if err != nil {
+				return fmt.Errorf("failed to insert fixture %s.%s: %w", table, label, err)
+			}
+			l.fixtures[table+"."+label] = inserted
+		}
+	}
+
+	l.state[table] = fixtureLoaded
+	return nil
+}
+
+// insert inserts row into table, returning the inserted row.
+func (l *fixtureLoader) insert(table string, row map[string]any) (map[string]any, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		quoted[i], placeholders[i] = quoteName(column), "?"
+
+		values[i] = row[column]
+		switch row[column].(type) {
+		case map[string]any, []any:
+			encoded, err := json.Marshal(row[column])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = string(encoded)
+		}
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING *",
+		quoteIdent(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	if len(columns) == 0 {
+		sql = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING *", quoteIdent(table))
+	}
+
+	inserted := map[string]any{}
+	if err := l.tx.Raw(sql, values...).Scan(&inserted).Error; err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// ref returns the id (or column) of the fixture name, "table.label".
+func (l *fixtureLoader) ref(name string, column ...string) (any, error) {
+	idx := strings.LastIndexByte(name, '.')
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid fixture reference %q, expected table.label", name)
+	}
+
+	table := name[:idx]
+	if _, ok := l.files[table]; !ok {
+		return nil, fmt.Errorf("no fixtures for table %s", table)
+	}
+	if err := l.load(table); err != nil {
+		return nil, err
+	}
+
+	row, ok := l.fixtures[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fixture %s", name)
+	}
+
+	col := "id"
+	if len(column) > 0 {
+		col = column[0]
+	}
+
+	value, ok := row[col]
+	if !ok {
+		return nil, fmt.Errorf("fixture %s has no column %s", name, col)
+	}
+	return value, nil
+}
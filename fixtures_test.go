@@ -0,0 +1,69 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	db, mock := mockDB(t)
+
+	fsys := fstest.MapFS{
+		"fixtures/patients.yml": {Data: []byte(`
+john:
+  name: John
+  tags: [vip]
+jane:
+  name: Jane
+`)},
+		// Loaded first, alphabetically: ref loads patients.yml.
+		"fixtures/appointments.json": {Data: []byte(`{
+  "checkup": {"patient_id": {{ ref "patients.jane" }}, "reference": "{{ uuid }}", "created_at": "{{ now }}"}
+}`)},
+		"fixtures/README.md": {Data: []byte("fixtures")},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "appointments", "patients" RESTART IDENTITY CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "patients" ("name", "tags") VALUES ($1, $2) RETURNING *`)).
+		WithArgs("John", `["vip"]`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John"))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "patients" ("name") VALUES ($1) RETURNING *`)).
+		WithArgs("Jane").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Jane"))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "appointments" ("created_at", "patient_id", "reference") VALUES ($1, $2, $3) RETURNING *`)).
+		WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id"}).AddRow(7, 2))
+	mock.ExpectCommit()
+
+	fixtures, err := gh.LoadFixtures(db, fsys, "fixtures/*")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualValues(t, 2, fixtures.ID("patients.jane"))
+	assert.EqualValues(t, 7, fixtures.ID("appointments.checkup"))
+	assert.Nil(t, fixtures.ID("patients.nobody"))
+}
+
+func TestLoadFixturesErrors(t *testing.T) {
+	db, mock := mockDB(t)
+
+	fsys := fstest.MapFS{
+		"fixtures/visits.yml": {Data: []byte(`first: {doctor_id: {{ ref "doctors.house" }}}`)},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("TRUNCATE").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := gh.LoadFixtures(db, fsys, "fixtures/*.yml")
+	assert.ErrorContains(t, err, "no fixtures for table doctors")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
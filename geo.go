@@ -0,0 +1,191 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// SRID of WGS 84 (GPS latitude and longitude), used by Point and the geospatial filters.
+const SRIDWGS84 = 4326
+
+// errInvalidEWKB is returned when a geometry can not be decoded.
+var errInvalidEWKB = errors.New("gh: invalid EWKB geometry")
+
+// Point is a WGS 84 location stored in a PostGIS geometry(Point, 4326) column.
+// The postgis extension must be installed, see EnsureExtensions(db, "postgis").
+// Use *Point for nullable columns.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (Point) GormDataType() string {
+	return "geometry(Point,4326)"
+}
+
+// Value implements driver.Valuer, encoding the point as EWKT.
+func (p Point) Value() (driver.Value, error) {
+	return fmt.Sprintf("SRID=%d;POINT(%s %s)", SRIDWGS84,
+		strconv.FormatFloat(p.Lng, 'f', -1, 64), strconv.FormatFloat(p.Lat, 'f', -1, 64)), nil
+}
+
+// Scan implements sql.Scanner. It accepts hex encoded (E)WKB, as returned by PostGIS, and (E)WKT.
+func (p *Point) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*p = Point{}
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Point", src)
+	}
+
+	if wkt, ok := strings.CutPrefix(strings.ToUpper(text[strings.Index(text, ";")+1:]), "POINT("); ok {
+		var x, y float64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(wkt, ")"), "%g %g", &x, &y); err != nil {
+			return fmt.Errorf("gh: invalid point %q", text)
+		}
+		p.Lng, p.Lat = x, y
+		return nil
+	}
+
+	g, err := hex.DecodeString(text)
+	if err != nil {
+		return errInvalidEWKB
+	}
+
+	geomType, _, body, err := Geometry(g).header()
+	if err != nil {
+		return err
+	}
+
+	if geomType != 1 || len(body) < 16 {
+		return fmt.Errorf("gh: geometry type %d is not a point", geomType)
+	}
+
+	order := Geometry(g).byteOrder()
+	p.Lng = math.Float64frombits(order.Uint64(body[0:8]))
+	p.Lat = math.Float64frombits(order.Uint64(body[8:16]))
+	return nil
+}
+
+// Geometry is any PostGIS geometry in EWKB (extended well-known binary) form.
+// Insert geometries from text with an expression, e.g
+// gorm.Expr("ST_GeomFromText(?, 4326)", "POLYGON((...))").
+type Geometry []byte
+
+// GormDataType returns the column type used by gorm migrations.
+func (Geometry) GormDataType() string {
+	return "geometry"
+}
+
+// Type returns the OGC geometry type code, e.g 1 for Point and 3 for Polygon.
+func (g Geometry) Type() (int, error) {
+	geomType, _, _, err := g.header()
+	return int(geomType), err
+}
+
+// SRID returns the spatial reference identifier, 0 if the geometry has none.
+func (g Geometry) SRID() (int, error) {
+	_, srid, _, err := g.header()
+	return int(srid), err
+}
+
+func (g Geometry) byteOrder() binary.ByteOrder {
+	if g[0] == 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// header decodes the EWKB header into the geometry type, SRID and the remaining bytes.
+func (g Geometry) header() (geomType uint32, srid uint32, body []byte, err error) {
+	if len(g) < 5 {
+		return 0, 0, nil, errInvalidEWKB
+	}
+
+	order := g.byteOrder()
+	geomType = order.Uint32(g[1:5])
+	body = g[5:]
+	if geomType&0x20000000 != 0 {
+		if len(body) < 4 {
+			return 0, 0, nil, errInvalidEWKB
+		}
+		srid = order.Uint32(body[:4])
+		body = body[4:]
+	}
+	return geomType & 0x0fffffff, srid, body, nil
+}
+
+// Value implements driver.Valuer, encoding the geometry as hex EWKB. An empty Geometry is stored as NULL.
+func (g Geometry) Value() (driver.Value, error) {
+	if len(g) == 0 {
+		return nil, nil
+	}
+	return hex.EncodeToString(g), nil
+}
+
+// Scan implements sql.Scanner. It accepts hex encoded EWKB, as returned by PostGIS.
+func (g *Geometry) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*g = nil
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Geometry", src)
+	}
+
+	data, err := hex.DecodeString(text)
+	if err != nil {
+		return errInvalidEWKB
+	}
+	*g = data
+	return nil
+}
+
+// pointExpr is the WGS 84 point at lat, lng.
+func pointExpr(lat, lng float64) clause.Expr {
+	return clause.Expr{SQL: "ST_SetSRID(ST_MakePoint(?, ?), 4326)", Vars: []any{lng, lat}}
+}
+
+// WithinRadius filters the rows whose geometry column is within meters of the location lat, lng.
+// Distances are computed on the spheroid (geography), which uses a geography GiST index if any.
+func (gdb *GormDB) WithinRadius(column string, lat, lng, meters float64) *GormDB {
+	gdb.db = gdb.db.Where("ST_DWithin("+column+"::geography, ?::geography, ?)", pointExpr(lat, lng), meters)
+	return gdb
+}
+
+// InBoundingBox filters the rows whose geometry column intersects the box between
+// the south-west (minLat, minLng) and north-east (maxLat, maxLng) corners, e.g the visible map area.
+// The column must have SRID 4326.
+func (gdb *GormDB) InBoundingBox(column string, minLat, minLng, maxLat, maxLng float64) *GormDB {
+	gdb.db = gdb.db.Where(column+" && ST_MakeEnvelope(?, ?, ?, ?, 4326)", minLng, minLat, maxLng, maxLat)
+	return gdb
+}
+
+// NearestN orders the rows by distance of their geometry column from lat, lng and keeps the n nearest,
+// using the index-assisted <-> operator.
+func (gdb *GormDB) NearestN(column string, lat, lng float64, n int) *GormDB {
+	gdb.db = gdb.db.Clauses(clause.OrderBy{
+		Expression: clause.Expr{SQL: column + " <-> ?", Vars: []any{pointExpr(lat, lng)}},
+	}).Limit(n)
+	return gdb
+}
@@ -0,0 +1,67 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type branch struct {
+	ID       uint
+	Location gh.Point
+}
+
+func TestPoint(t *testing.T) {
+	v, err := gh.Point{Lat: 0.3476, Lng: 32.5825}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "SRID=4326;POINT(32.5825 0.3476)", v)
+
+	// SELECT 'SRID=4326;POINT(32.5825 0.3476)'::geometry
+	var p gh.Point
+	assert.NoError(t, p.Scan("0101000020E6100000C3F5285C8F4A404002BC0512143FD63F"))
+	assert.InDelta(t, 32.5825, p.Lng, 1e-9)
+	assert.InDelta(t, 0.3476, p.Lat, 1e-9)
+
+	// WKB without SRID.
+	assert.NoError(t, p.Scan([]byte("0101000000C3F5285C8F4A404002BC0512143FD63F")))
+	assert.InDelta(t, 32.5825, p.Lng, 1e-9)
+
+	assert.NoError(t, p.Scan("POINT(1.5 -2)"))
+	assert.Equal(t, gh.Point{Lat: -2, Lng: 1.5}, p)
+
+	// A polygon is not a point.
+	assert.Error(t, p.Scan("0103000020E6100000010000000400000000000000000000000000000000000000000000000000F03F0000000000000000000000000000F03F000000000000F03F00000000000000000000000000000000"))
+	assert.Error(t, p.Scan("zz"))
+}
+
+func TestGeometry(t *testing.T) {
+	var g gh.Geometry
+	assert.NoError(t, g.Scan("0101000020E6100000C3F5285C8F4A404002BC0512143FD63F"))
+
+	geomType, err := g.Type()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, geomType)
+
+	srid, err := g.SRID()
+	assert.NoError(t, err)
+	assert.Equal(t, gh.SRIDWGS84, srid)
+
+	v, err := g.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "0101000020e6100000c3f5285c8f4a404002bc0512143fd63f", v)
+}
+
+func TestGeoFilters(t *testing.T) {
+	find := func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]branch{}) }
+
+	sql := gh.WrapDB(dryRunDB(t)).WithinRadius("location", 0.3476, 32.5825, 5000).ToSQL(find)
+	assert.Equal(t, `SELECT * FROM "branches" WHERE ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint(32.5825, 0.3476), 4326)::geography, 5000)`, sql)
+
+	sql = gh.WrapDB(dryRunDB(t)).InBoundingBox("location", 0, 32, 1, 33).ToSQL(find)
+	assert.Equal(t, `SELECT * FROM "branches" WHERE location && ST_MakeEnvelope(32, 0, 33, 1, 4326)`, sql)
+
+	sql = gh.WrapDB(dryRunDB(t)).NearestN("location", 0.3476, 32.5825, 3).ToSQL(find)
+	assert.Equal(t, `SELECT * FROM "branches" ORDER BY location <-> ST_SetSRID(ST_MakePoint(32.5825, 0.3476), 4326) LIMIT 3`, sql)
+}
@@ -0,0 +1,249 @@
+package ghtest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/abiiranathan/gh"
+)
+
+// sequence numbers the instances built by all factories, making generated values unique.
+var sequence atomic.Int64
+
+var (
+	firstNames = []string{"John", "Jane", "Amina", "Peter", "Grace", "Moses", "Sarah", "David", "Esther", "Joseph"}
+	lastNames  = []string{"Okello", "Namubiru", "Smith", "Mugisha", "Achieng", "Brown", "Kato", "Nakato", "Otieno", "Garcia"}
+	cities     = []string{"Kampala", "Nairobi", "Kigali", "London", "Lagos", "Accra", "Dar es Salaam", "Toronto"}
+	countries  = []string{"Uganda", "Kenya", "Rwanda", "United Kingdom", "Nigeria", "Ghana", "Tanzania", "Canada"}
+	words      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do"}
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(gh.UUID{})
+)
+
+// ModelFactory builds and persists instances of the model T with fake values. See Factory.
+type ModelFactory[T any] struct {
+	attrs map[string]reflect.Value
+}
+
+// Factory returns a factory of the model T, a struct. Built instances have fake values derived
+// from the names and types of their fields, e.g a unique address for Email, a person's name for
+// FirstName, a past date for DateOfBirth. Primary keys (except UUIDs), foreign keys (fields ending
+// in ID), CreatedAt, UpdatedAt, DeletedAt and fields of other struct types, e.g associations, are left zero.
+//
+//	users, err := ghtest.Factory[User]().With("Role", "admin").CreateN(db, 50)
+func Factory[T any]() *ModelFactory[T] {
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("ghtest: factory model %s is not a struct", reflect.TypeFor[T]()))
+	}
+	return &ModelFactory[T]{attrs: map[string]reflect.Value{}}
+}
+
+// With returns a copy of the factory setting the field to value. value may also be a func(n int) V,
+// called with the sequence number of every instance, e.g for unique values.
+// It panics if T has no such field or value cannot be assigned to it.
+func (f *ModelFactory[T]) With(field string, value any) *ModelFactory[T] {
+	sf, ok := reflect.TypeFor[T]().FieldByName(field)
+	if !ok {
+		panic(fmt.Sprintf("ghtest: %s has no field %s", reflect.TypeFor[T](), field))
+	}
+
+	v := reflect.ValueOf(value)
+	if value == nil {
+		v = reflect.Zero(sf.Type)
+	}
+
+	t := v.Type()
+	if isGenerator(t) {
+		t = t.Out(0)
+	}
+
+	if !t.AssignableTo(sf.Type) {
+		panic(fmt.Sprintf("ghtest: cannot assign %s to %s.%s of type %s", t, reflect.TypeFor[T](), field, sf.Type))
+	}
+
+	attrs := make(map[string]reflect.Value, len(f.attrs)+1)
+	for k, v := range f.attrs {
+		attrs[k] = v
+	}
+	attrs[field] = v
+	return &ModelFactory[T]{attrs: attrs}
+}
+
+// isGenerator reports whether t is a func(n int) V.
+func isGenerator(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.NumIn() == 1 && t.In(0).Kind() == reflect.Int && t.NumOut() == 1
+}
+
+// Build returns a new instance, not persisted.
+func (f *ModelFactory[T]) Build() T {
+	var model T
+	n := int(sequence.Add(1))
+
+	v := reflect.ValueOf(&model).Elem()
+	fillStruct(v, n)
+
+	for field, value := range f.attrs {
+		if isGenerator(value.Type()) {
+			value = value.Call([]reflect.Value{reflect.ValueOf(n)})[0]
+		}
+		v.FieldByName(field).Set(value)
+	}
+	return model
+}
+
+// BuildN returns n new instances, not persisted.
+func (f *ModelFactory[T]) BuildN(n int) []T {
+	models := make([]T, n)
+	for i := range models {
+		models[i] = f.Build()
+	}
+	return models
+}
+
+// Create builds and inserts an instance.
+func (f *ModelFactory[T]) Create(db *gh.GormDB) (T, error) {
+	model := f.Build()
+	if err := db.DB().Create(&model).Error; err != nil {
+		return model, fmt.Errorf("failed to create %T: %w", model, err)
+	}
+	return model, nil
+}
+
+// CreateN builds and inserts n instances, in a single statement.
+func (f *ModelFactory[T]) CreateN(db *gh.GormDB, n int) ([]T, error) {
+	models := f.BuildN(n)
+	if n == 0 {
+		return models, nil
+	}
+
+	if err := db.DB().Create(&models).Error; err != nil {
+		return models, fmt.Errorf("failed to create %d %T: %w", n, models[0], err)
+	}
+	return models, nil
+}
+
+// fillStruct sets the fields of the struct v to fake values.
+func fillStruct(v reflect.Value, n int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Tag.Get("gorm") == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			fillStruct(fv, n) // e.g gorm.Model
+			continue
+		}
+
+		// Foreign keys are left zero: a random one would reference no row.
+		primaryKey := sf.Name == "ID" || strings.Contains(strings.ToLower(sf.Tag.Get("gorm")), "primarykey")
+		if primaryKey && sf.Type != uuidType || !primaryKey && strings.HasSuffix(sf.Name, "ID") {
+			continue
+		}
+
+		switch sf.Name {
+		case "CreatedAt", "UpdatedAt", "DeletedAt":
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Pointer {
+			elem := reflect.New(sf.Type.Elem())
+			if fakeValue(elem.Elem(), sf.Name, n) {
+				fv.Set(elem)
+			}
+			continue
+		}
+		fakeValue(fv, sf.Name, n)
+	}
+}
+
+// fakeValue sets v, the field name, to a fake value and reports whether it has one for its type.
+func fakeValue(v reflect.Value, name string, n int) bool {
+	lower := strings.ToLower(name)
+
+	switch v.Type() {
+	case timeType:
+		if strings.Contains(lower, "birth") || lower == "dob" {
+			v.Set(reflect.ValueOf(time.Now().AddDate(-18-rand.IntN(60), 0, -rand.IntN(365)).Truncate(24 * time.Hour)))
+		} else {
+			v.Set(reflect.ValueOf(time.Now().Add(-time.Duration(rand.IntN(365*24)) * time.Hour).Truncate(time.Second)))
+		}
+		return true
+	case uuidType:
+		u, err := gh.NewUUIDv7()
+		if err != nil {
+			return false
+		}
+		v.Set(reflect.ValueOf(u))
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fakeString(lower, name, n))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(1 + rand.IntN(100)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(1 + rand.IntN(100)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(rand.IntN(100_000)) / 100)
+	case reflect.Bool:
+		v.SetBool(rand.IntN(2) == 1)
+	default:
+		return false // structs, slices and maps, e.g associations
+	}
+	return true
+}
+
+// fakeString returns a fake value for the string field name.
+func fakeString(lower, name string, n int) string {
+	first, last := firstNames[n%len(firstNames)], lastNames[(n/len(firstNames))%len(lastNames)]
+
+	switch {
+	case strings.Contains(lower, "email"):
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), n)
+	case strings.Contains(lower, "username") || strings.Contains(lower, "login"):
+		return fmt.Sprintf("%s%d", strings.ToLower(first), n)
+	case strings.Contains(lower, "firstname") || strings.Contains(lower, "givenname"):
+		return first
+	case strings.Contains(lower, "lastname") || strings.Contains(lower, "surname") || strings.Contains(lower, "familyname"):
+		return last
+	case strings.Contains(lower, "phone") || strings.Contains(lower, "mobile"):
+		return fmt.Sprintf("+256700%06d", n%1_000_000)
+	case strings.Contains(lower, "password"):
+		return "password"
+	case strings.Contains(lower, "url") || strings.Contains(lower, "website"):
+		return fmt.Sprintf("https://example.com/%d", n)
+	case strings.Contains(lower, "address") || strings.Contains(lower, "street"):
+		return fmt.Sprintf("%d Main Street", n)
+	case strings.Contains(lower, "city"):
+		return cities[n%len(cities)]
+	case strings.Contains(lower, "country"):
+		return countries[n%len(countries)]
+	case strings.Contains(lower, "description") || strings.Contains(lower, "notes") ||
+		strings.Contains(lower, "comment") || strings.Contains(lower, "bio"):
+		return sentence(8)
+	case strings.Contains(lower, "name"):
+		return first + " " + last
+	case strings.Contains(lower, "code") || strings.Contains(lower, "number"):
+		return fmt.Sprintf("%s-%06d", strings.ToUpper(name[:min(3, len(name))]), n)
+	}
+	return fmt.Sprintf("%s %d", name, n)
+}
+
+func sentence(length int) string {
+	s := make([]string, length)
+	for i := range s {
+		s[i] = words[rand.IntN(len(words))]
+	}
+	return strings.ToUpper(s[0][:1]) + strings.Join(s, " ")[1:] + "."
+}
@@ -0,0 +1,69 @@
+package ghtest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type Doctor struct {
+	gorm.Model
+	Reference    gh.UUID
+	FirstName    string
+	LastName     string
+	Email        string
+	Phone        *string
+	DateOfBirth  time.Time
+	Fee          float64
+	Active       bool
+	DepartmentID uint
+	Patients     []Patient `gorm:"-"`
+}
+
+func TestFactoryBuild(t *testing.T) {
+	doctor := ghtest.Factory[Doctor]().Build()
+
+	assert.Zero(t, doctor.ID)
+	assert.True(t, doctor.CreatedAt.IsZero())
+	assert.Zero(t, doctor.DepartmentID)
+	assert.False(t, doctor.Reference.IsZero())
+	assert.NotEmpty(t, doctor.FirstName)
+	assert.NotEmpty(t, doctor.LastName)
+	assert.True(t, strings.HasSuffix(doctor.Email, "@example.com"))
+	require.NotNil(t, doctor.Phone)
+	assert.True(t, doctor.DateOfBirth.Before(time.Now().AddDate(-18, 0, 0)))
+
+	doctors := ghtest.Factory[Doctor]().
+		With("DepartmentID", uint(3)).
+		With("Email", func(n int) string { return fmt.Sprintf("doctor%d@hospital.org", n) }).
+		BuildN(2)
+	assert.Equal(t, uint(3), doctors[1].DepartmentID)
+	assert.NotEqual(t, doctors[0].Email, doctors[1].Email)
+	assert.True(t, strings.HasSuffix(doctors[0].Email, "@hospital.org"))
+
+	assert.PanicsWithValue(t, "ghtest: ghtest_test.Doctor has no field Mail", func() {
+		ghtest.Factory[Doctor]().With("Mail", "x")
+	})
+	assert.Panics(t, func() { ghtest.Factory[Doctor]().With("Fee", "free") })
+}
+
+func TestFactoryCreateN(t *testing.T) {
+	db, mock := ghtest.NewMockDB(t)
+
+	mock.ExpectQuery(`INSERT INTO "patients" \("name"\) VALUES \(\$1\),\(\$2\),\(\$3\) RETURNING "id"`).
+		WithArgs("Jane", "Jane", "Jane").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+
+	patients, err := ghtest.Factory[Patient]().With("Name", "Jane").CreateN(db, 3)
+	require.NoError(t, err)
+	require.Len(t, patients, 3)
+	assert.EqualValues(t, 3, patients[2].ID)
+}
@@ -0,0 +1,205 @@
+// Package ghtest provides helpers for tests against a real Postgres database.
+//
+// NewPostgres connects to the server of the TEST_DATABASE_URL environment variable or,
+// if it is not set, to a disposable Postgres container started with docker. Every call
+// creates a new database, dropped when the test ends, so tests can run in parallel.
+//
+//	func TestCreatePatient(t *testing.T) {
+//		db := ghtest.NewPostgresOpts(t, ghtest.Options{Models: []any{&Patient{}}})
+//		...
+//	}
+package ghtest
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/migrate"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultImage is the docker image of the Postgres container.
+const DefaultImage = "postgres:16-alpine"
+
+// Options are options for NewPostgresOpts.
+type Options struct {
+	// Image is the docker image of the container. Default: DefaultImage.
+	Image string
+
+	// Migrations and MigrationsDir are the SQL migrations applied to the database, see migrate.NewMigrator.
+	Migrations    fs.FS
+	MigrationsDir string
+
+	// Models are auto migrated after the migrations.
+	Models []any
+
+	// LogLevel is the level of the queries logged with t.Log. Default: silent.
+	LogLevel logger.LogLevel
+}
+
+// NewPostgres returns a connection to a new, empty database. See NewPostgresOpts.
+func NewPostgres(t testing.TB) *gh.GormDB {
+	t.Helper()
+	return NewPostgresOpts(t, Options{})
+}
+
+// NewPostgresOpts returns a connection to a new database, migrated as set in opts.
+// The database is dropped and the container removed when the test ends. The test is
+// skipped if TEST_DATABASE_URL is not set and docker is not installed.
+func NewPostgresOpts(t testing.TB, opts Options) *gh.GormDB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dsn = startContainer(t, cmp.Or(opts.Image, DefaultImage))
+	}
+
+	db := createDatabase(t, dsn, opts.LogLevel)
+	ctx := context.Background()
+
+	if opts.Migrations != nil {
+		migrator, err := migrate.NewMigrator(db, opts.Migrations, opts.MigrationsDir)
+		if err != nil {
+			t.Fatalf("failed to load migrations: %v", err)
+		}
+
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatalf("failed to migrate test database: %v", err)
+		}
+	}
+
+	if len(opts.Models) > 0 {
+		if err := db.AutoMigrate(opts.Models...); err != nil {
+			t.Fatalf("failed to migrate models: %v", err)
+		}
+	}
+	return gh.WrapDB(db)
+}
+
+// startContainer starts a Postgres container, removed when the test ends, and returns its DSN.
+func startContainer(t testing.TB, image string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("TEST_DATABASE_URL is not set and docker is not installed")
+	}
+
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "POSTGRES_PASSWORD=postgres", "--publish", "127.0.0.1::5432", image).Output()
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %v", commandError(err))
+	}
+
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "--force", id).Run(); err != nil {
+			t.Errorf("failed to remove postgres container %s: %v", id, commandError(err))
+		}
+	})
+
+	out, err = exec.Command("docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		t.Fatalf("failed to read postgres container port: %v", commandError(err))
+	}
+
+	address, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatalf("invalid postgres container address %q: %v", address, err)
+	}
+	return fmt.Sprintf("host=%s port=%s user=postgres password=postgres dbname=postgres sslmode=disable", host, port)
+}
+
+// createDatabase creates a database on the server of dsn, dropped when the test ends, and connects to it.
+func createDatabase(t testing.TB, dsn string, logLevel logger.LogLevel) *gorm.DB {
+	t.Helper()
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("invalid test database DSN: %v", err)
+	}
+
+	admin := stdlib.OpenDB(*config)
+	t.Cleanup(func() { admin.Close() })
+
+	if err := waitReady(admin, 30*time.Second); err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("failed to generate database name: %v", err)
+	}
+
+	name := "ghtest_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	config.Database = name
+	sqlDB := stdlib.OpenDB(*config)
+	t.Cleanup(func() {
+		sqlDB.Close()
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)"); err != nil {
+			t.Errorf("failed to drop test database %s: %v", name, err)
+		}
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.New(testWriter{t}, logger.Config{
+			SlowThreshold: time.Second,
+			LogLevel:      cmp.Or(logLevel, logger.Silent),
+		}),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	return db
+}
+
+// waitReady pings db until it answers, e.g while the container starts, or the timeout elapses.
+func waitReady(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := db.Ping()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// commandError adds the standard error of a failed command to err.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// testWriter writes the logs of gorm's logger with t.Logf.
+type testWriter struct {
+	t testing.TB
+}
+
+func (w testWriter) Printf(format string, args ...any) {
+	w.t.Logf(format, args...)
+}
@@ -0,0 +1,34 @@
+package ghtest_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Patient struct {
+	ID   uint
+	Name string
+}
+
+func TestNewPostgres(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/0001_create_wards.up.sql": {Data: []byte("CREATE TABLE wards (id bigserial PRIMARY KEY, name text NOT NULL)")},
+	}
+
+	db := ghtest.NewPostgresOpts(t, ghtest.Options{
+		Migrations:    migrations,
+		MigrationsDir: "migrations",
+		Models:        []any{&Patient{}},
+	})
+
+	require.NoError(t, db.DB().Create(&Patient{Name: "John"}).Error)
+	require.NoError(t, db.DB().Exec("INSERT INTO wards (name) VALUES ('Maternity')").Error)
+
+	var count int64
+	require.NoError(t, db.DB().Model(&Patient{}).Count(&count).Error)
+	assert.EqualValues(t, 1, count)
+}
@@ -0,0 +1,47 @@
+package ghtest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewMockDB returns a GormDB backed by go-sqlmock, configured like gh.PgConnectWithConn,
+// to unit test code without a database. Expected queries are regular expressions, and
+// statements are not prepared so no ExpectPrepare is needed. The test fails if expectations
+// are not met when it ends.
+//
+//	db, mock := ghtest.NewMockDB(t)
+//	mock.ExpectQuery(`SELECT \* FROM "patients"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+func NewMockDB(t testing.TB) (*gh.GormDB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	db, err := gh.PgConnectWithConn(sqlDB, io.Discard, logger.Silent, nil)
+	if err != nil {
+		t.Fatalf("failed to open mock database: %v", err)
+	}
+
+	// Prepared statements would need an ExpectPrepare for every statement.
+	if prepared, ok := db.ConnPool.(*gorm.PreparedStmtDB); ok {
+		db.ConnPool, db.Statement.ConnPool = prepared.ConnPool, prepared.ConnPool
+		db.PrepareStmt = false
+	}
+	db.SkipDefaultTransaction = true
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet sqlmock expectations: %v", err)
+		}
+		sqlDB.Close()
+	})
+	return gh.WrapDB(db), mock
+}
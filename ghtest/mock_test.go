@@ -0,0 +1,25 @@
+package ghtest_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMockDB(t *testing.T) {
+	db, mock := ghtest.NewMockDB(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "patients" WHERE name = \$1`).WithArgs("John").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John"))
+	mock.ExpectExec(`UPDATE "patients"`).WithArgs("Jane", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	patients := []Patient{}
+	require.NoError(t, db.DB().Where("name = ?", "John").Find(&patients).Error)
+	assert.Equal(t, []Patient{{ID: 1, Name: "John"}}, patients)
+
+	// No implicit transaction around writes.
+	require.NoError(t, db.DB().Model(&patients[0]).Update("name", "Jane").Error)
+}
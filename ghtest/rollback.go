@@ -0,0 +1,31 @@
+package ghtest
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/gh"
+)
+
+// WithRollback runs fn in a transaction that is always rolled back, even if fn fails the test,
+// giving isolated tests against a shared database. Transaction calls made by fn use savepoints,
+// but the code under test must not call Begin or Commit itself.
+//
+//	ghtest.WithRollback(t, db, func(tx *gh.GormDB) {
+//		err := service.CreatePatient(tx, patient)
+//		...
+//	})
+func WithRollback(t testing.TB, db *gh.GormDB, fn func(tx *gh.GormDB)) {
+	t.Helper()
+
+	tx := db.DB().Begin()
+	if tx.Error != nil {
+		t.Fatalf("failed to begin test transaction: %v", tx.Error)
+	}
+
+	defer func() {
+		if err := tx.Rollback().Error; err != nil {
+			t.Errorf("failed to roll back test transaction: %v", err)
+		}
+	}()
+	fn(gh.WrapDB(tx))
+}
@@ -0,0 +1,32 @@
+package ghtest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRollback(t *testing.T) {
+	db, mock := ghtest.NewMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO patients").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO visits").WillReturnError(errors.New("duplicate key"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	ghtest.WithRollback(t, db, func(tx *gh.GormDB) {
+		assert.NoError(t, tx.DB().Exec("INSERT INTO patients (name) VALUES ('John')").Error)
+
+		err := tx.Transaction(func(nested *gh.GormDB) error {
+			return nested.DB().Exec("INSERT INTO visits (patient_id) VALUES (1)").Error
+		})
+		assert.EqualError(t, err, "duplicate key")
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,9 +3,10 @@ package gh
 
 import (
 	"context"
-	"math"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GormDB is a wrapper around the *gorm.DB object that provides helper functions.
@@ -33,7 +34,7 @@ func (gdb *GormDB) WithContext(ctx context.Context) *GormDB {
 func (gdb *GormDB) Transaction(fn func(*GormDB) error) error {
 	return gdb.db.Transaction(func(tx *gorm.DB) error {
 		return fn(&GormDB{db: tx})
-	})
+	}, txOptions(gdb.db)...)
 }
 
 func (gdb *GormDB) BeforeQuery(callback func(*gorm.DB)) error {
@@ -44,6 +45,21 @@ func (gdb *GormDB) AfterQuery(callback func(*gorm.DB)) error {
 	return gdb.db.Callback().Query().After("gorm:query").Register("after_query", callback)
 }
 
+// ToSQL renders the statement fn would execute on the chain, with interpolated arguments,
+// using a gorm DryRun session so nothing is executed. For debugging and test snapshots only.
+//
+//	sql := gdb.Eq("doctor", "Dr. Smith").ToSQL(func(tx *gorm.DB) *gorm.DB {
+//		return tx.Find(&[]Visit{})
+//	})
+func (gdb *GormDB) ToSQL(fn func(tx *gorm.DB) *gorm.DB) string {
+	return gdb.db.ToSQL(fn)
+}
+
+// Use registers a gorm plugin (e.g AuditColumns) on the underlying database.
+func (gdb *GormDB) Use(plugin gorm.Plugin) error {
+	return gdb.db.Use(plugin)
+}
+
 func (gdb *GormDB) JSONFilter(column, key string, value interface{}) *GormDB {
 	gdb.db = gdb.db.Where(column+"->>? = ?", key, value)
 	return gdb
@@ -281,6 +297,24 @@ func (gdb *GormDB) Preload(options ...PreloadOptions) *GormDB {
 	return gdb
 }
 
+// Preloads preloads the associations of paths, nested with dots.
+// e.g Preloads("Orders.Items", "Profile") preloads the orders, their items and the profile.
+func (gdb *GormDB) Preloads(paths ...string) *GormDB {
+	for _, path := range paths {
+		gdb.db = gdb.db.Preload(path)
+	}
+	return gdb
+}
+
+// PreloadAll preloads all the associations of the model, and theirs, up to depth levels.
+// If depth is 0, it does nothing. Mind the queries: every association preloaded is one.
+func (gdb *GormDB) PreloadAll(depth int) *GormDB {
+	if depth > 0 {
+		gdb.db = gdb.db.Preload(strings.Repeat(clause.Associations+".", depth-1) + clause.Associations)
+	}
+	return gdb
+}
+
 // Joins joins the associations.
 func (gdb *GormDB) Joins(query string, args ...any) *GormDB {
 	gdb.db = gdb.db.Joins(query, args...)
@@ -289,12 +323,16 @@ func (gdb *GormDB) Joins(query string, args ...any) *GormDB {
 
 // First retrieves the first record.
 func (gdb *GormDB) First(dest any, conds ...any) error {
-	return gdb.db.First(dest, conds...).Error
+	return gdb.cached(dest, func() *gorm.DB {
+		return gdb.db.First(dest, conds...)
+	})
 }
 
 // Find finds all records matching given conditions conds
 func (gdb *GormDB) Find(dest any, conds ...any) error {
-	return gdb.db.Find(dest, conds...).Error
+	return gdb.cached(dest, func() *gorm.DB {
+		return gdb.db.Find(dest, conds...)
+	})
 }
 
 // Create inserts value, returning the inserted data's primary key in value's id.
@@ -335,42 +373,21 @@ type PagedResponse[T any] struct {
 	HasNext    bool  `json:"has_next"`
 	HasPrev    bool  `json:"has_prev"`
 	Results    []T   `json:"results"`
+
+	// CountEstimated is true if Count and TotalPages are planner estimates (see CountEstimate).
+	CountEstimated bool `json:"count_estimated,omitempty"`
+
+	// Extra holds endpoint specific metadata, e.g facets or totals.
+	Extra map[string]any `json:"extra,omitempty"`
 }
 
 // GetPaginated retrieves a paginated list of results.
 // The page and pageSize are used to calculate the offset and limit.
 // If the page is less than 1, it defaults to 1.
 // db is the *gorm.DB object with the model and query options already applied.
+// The count and page queries run concurrently on separate connections,
+// unless db is inside a transaction.
 // It returns the PaginatedResults and an error if any.
 func GetPaginated[T any](db *gorm.DB, model *T, page int, pageSize int) (*PagedResponse[T], error) {
-	results := []T{}
-
-	// Page must be >= 1
-	if page < 1 {
-		page = 1
-	}
-
-	// Calculate offset and limit
-	offset := (page - 1) * pageSize
-
-	// Retrieve total count of records after applying options
-	var totalCount int64
-	if err := db.Model(model).Count(&totalCount).Error; err != nil {
-		return nil, err
-	}
-
-	if err := db.Model(model).Offset(offset).Limit(pageSize).Find(&results).Error; err != nil {
-		return nil, err
-	}
-
-	paginatedResponse := &PagedResponse[T]{
-		Page:       page,
-		PageSize:   pageSize,
-		HasNext:    int64(page*pageSize) < totalCount,
-		HasPrev:    page > 1,
-		Results:    results,
-		Count:      totalCount,
-		TotalPages: int64(math.Ceil(float64(totalCount) / float64(pageSize))),
-	}
-	return paginatedResponse, nil
+	return GetPaginatedOpts(db, model, page, pageSize, PageOptions{})
 }
@@ -0,0 +1,268 @@
+package gh_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type paginatedVisit struct {
+	ID     uint
+	Doctor string
+}
+
+func TestGetPaginated(t *testing.T) {
+	db := dryRunDB(t)
+
+	var (
+		sqls []string
+		mu   = make(chan struct{}, 1)
+	)
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		mu <- struct{}{}
+		sqls = append(sqls, tx.Statement.SQL.String())
+		<-mu
+	}))
+
+	res, err := gh.GetPaginated(db.Where("doctor = ?", "Dr. Smith"), &paginatedVisit{}, 3, 25)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res.Page)
+	assert.Equal(t, 25, res.PageSize)
+	assert.True(t, res.HasPrev)
+	assert.ElementsMatch(t, []string{
+		`SELECT count(*) FROM "paginated_visits" WHERE doctor = $1`,
+		`SELECT * FROM "paginated_visits" WHERE doctor = $1 LIMIT $2 OFFSET $3`,
+	}, sqls)
+}
+
+func TestGetPaginatedCountNone(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sqls []string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sqls = append(sqls, tx.Statement.SQL.String())
+	}))
+
+	res, err := gh.GetPaginatedOpts(db, &paginatedVisit{}, 1, 25, gh.PageOptions{CountMode: gh.CountNone})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), res.Count)
+	assert.Equal(t, int64(-1), res.TotalPages)
+	assert.False(t, res.HasNext)
+	assert.Equal(t, []string{`SELECT * FROM "paginated_visits" LIMIT $1`}, sqls)
+}
+
+func TestPaginationConfig(t *testing.T) {
+	config := gh.DefaultPaginationConfig()
+
+	tests := []struct {
+		page, pageSize         int
+		wantPage, wantPageSize int
+	}{
+		{0, 0, 1, 20},
+		{2, 50, 2, 50},
+		{3, 100000, 3, 100},
+		{-1, -5, 1, 20},
+	}
+
+	for _, tt := range tests {
+		page, pageSize := config.Clamp(tt.page, tt.pageSize)
+		assert.Equal(t, tt.wantPage, page)
+		assert.Equal(t, tt.wantPageSize, pageSize)
+	}
+
+	db := dryRunDB(t)
+	var sql string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	}))
+
+	config.DefaultSort = "id DESC"
+	res, err := gh.GetPaginatedOpts(db, &paginatedVisit{}, 1, 5000, gh.PageOptions{CountMode: gh.CountNone, Config: &config})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, res.PageSize)
+	assert.Equal(t, `SELECT * FROM "paginated_visits" ORDER BY id DESC LIMIT $1`, sql)
+}
+
+func TestPaginate(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sql string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		if tx.Statement.Dest != nil {
+			if _, ok := tx.Statement.Dest.(*[]paginatedVisit); ok {
+				sql = tx.Statement.SQL.String()
+			}
+		}
+	}))
+
+	res, err := gh.Paginate[paginatedVisit](gh.WrapDB(db).ILIKE("doctor", "smith").Order("id DESC"), 2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.Page)
+	assert.Equal(t, `SELECT * FROM "paginated_visits" WHERE doctor ILIKE $1 ORDER BY id DESC LIMIT $2 OFFSET $3`, sql)
+}
+
+func TestPaginateQuery(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sqls []string
+	var mu sync.Mutex
+	assert.NoError(t, db.Callback().Row().After("gorm:row").Register("test:capture", func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		sqls = append(sqls, tx.Statement.SQL.String())
+	}))
+
+	qb := gh.NewQueryBuilder("SELECT doctor, SUM(total_amount) AS total_amount FROM income_per_billable").
+		Where("doctor=?", "Dr. Smith").
+		GroupBy("doctor")
+
+	type income struct {
+		Doctor      string
+		TotalAmount float64
+	}
+
+	// Scanning is not supported in dry run mode, only the generated SQL is checked.
+	_, err := gh.PaginateQuery[income](db, qb, 2, 10)
+	assert.Error(t, err)
+	assert.ElementsMatch(t, []string{
+		`SELECT COUNT(*) FROM (SELECT doctor, SUM(total_amount) AS total_amount FROM income_per_billable WHERE doctor=$1 GROUP BY doctor) AS paginated`,
+		`SELECT doctor, SUM(total_amount) AS total_amount FROM income_per_billable WHERE doctor=$1 GROUP BY doctor LIMIT $2 OFFSET $3`,
+	}, sqls)
+}
+
+func TestGetPaginatedInto(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sql string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		if tx.Statement.Dest != nil {
+			if _, ok := tx.Statement.Dest.(*[]paginatedVisitDTO); ok {
+				sql = tx.Statement.SQL.String()
+			}
+		}
+	}))
+
+	res, err := gh.GetPaginatedInto[paginatedVisit, paginatedVisitDTO](db, 1, 10, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Results)
+	assert.Equal(t, `SELECT "paginated_visits"."doctor" FROM "paginated_visits" LIMIT $1`, sql)
+
+	_, err = gh.GetPaginatedInto(db, 1, 10, func(v paginatedVisit) paginatedVisitDTO {
+		return paginatedVisitDTO{Doctor: v.Doctor}
+	})
+	assert.NoError(t, err)
+}
+
+type paginatedVisitDTO struct {
+	Doctor string
+}
+
+func TestMapPagedResponse(t *testing.T) {
+	resp := &gh.PagedResponse[paginatedVisit]{
+		Page:     2,
+		PageSize: 2,
+		Count:    5,
+		HasNext:  true,
+		HasPrev:  true,
+		Results:  []paginatedVisit{{ID: 3, Doctor: "A"}, {ID: 4, Doctor: "B"}},
+	}
+	resp.SetExtra("total_amount", 1500)
+
+	mapped := gh.Map(resp, func(v paginatedVisit) string { return v.Doctor })
+	assert.Equal(t, []string{"A", "B"}, mapped.Results)
+	assert.Equal(t, 2, mapped.Page)
+	assert.Equal(t, int64(5), mapped.Count)
+	assert.True(t, mapped.HasNext)
+	assert.Equal(t, map[string]any{"total_amount": 1500}, mapped.Extra)
+}
+
+func TestGetPaginatedFacets(t *testing.T) {
+	db := dryRunDB(t)
+
+	var sqls []string
+	var mu sync.Mutex
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		sqls = append(sqls, tx.Statement.SQL.String())
+	}))
+
+	res, err := gh.GetPaginatedOpts(db.Where("id > ?", 10).Order("id DESC"), &paginatedVisit{}, 1, 10, gh.PageOptions{
+		CountMode: gh.CountNone,
+		Facets:    []string{"doctor", "id"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]gh.FacetCount{"doctor": {}, "id": {}}, res.Extra["facets"])
+	assert.Contains(t, sqls, `SELECT "doctor" AS f0, GROUPING("doctor") AS g0, "id" AS f1, GROUPING("id") AS g1, COUNT(*) AS count FROM "paginated_visits" WHERE id > $1 GROUP BY GROUPING SETS (("doctor"), ("id")) ORDER BY count DESC`)
+
+	_, err = gh.GetPaginatedOpts(db, &paginatedVisit{}, 1, 10, gh.PageOptions{Facets: []string{"1; DROP TABLE x"}})
+	assert.Error(t, err)
+}
+
+func TestGormDBToSQL(t *testing.T) {
+	db := dryRunDB(t)
+
+	sql := gh.WrapDB(db).Eq("doctor", "O'Brien").ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Find(&[]paginatedVisit{})
+	})
+	assert.Equal(t, `SELECT * FROM "paginated_visits" WHERE doctor = 'O''Brien'`, sql)
+}
+
+type preloadCustomer struct {
+	ID      uint
+	Profile preloadProfile `gorm:"foreignKey:CustomerID"`
+	Orders  []preloadOrder `gorm:"foreignKey:CustomerID"`
+}
+
+type preloadProfile struct {
+	ID         uint
+	CustomerID uint
+}
+
+type preloadOrder struct {
+	ID         uint
+	CustomerID uint
+	Items      []preloadItem `gorm:"foreignKey:OrderID"`
+}
+
+type preloadItem struct {
+	ID      uint
+	OrderID uint
+}
+
+func TestPreloads(t *testing.T) {
+	for name, preload := range map[string]func(*gh.GormDB) *gh.GormDB{
+		"Preloads":   func(gdb *gh.GormDB) *gh.GormDB { return gdb.Preloads("Orders.Items", "Profile") },
+		"PreloadAll": func(gdb *gh.GormDB) *gh.GormDB { return gdb.PreloadAll(2) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, mock := mockDB(t)
+			mock.MatchExpectationsInOrder(false)
+
+			mock.ExpectQuery(`SELECT \* FROM "preload_customers"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`SELECT \* FROM "preload_orders" WHERE "preload_orders"."customer_id" = \$1`).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id"}).AddRow(10, 1))
+			mock.ExpectQuery(`SELECT \* FROM "preload_items" WHERE "preload_items"."order_id" = \$1`).
+				WithArgs(10).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "order_id"}).AddRow(100, 10))
+			mock.ExpectQuery(`SELECT \* FROM "preload_profiles" WHERE "preload_profiles"."customer_id" = \$1`).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id"}).AddRow(5, 1))
+
+			customers := []preloadCustomer{}
+			require.NoError(t, preload(gh.WrapDB(db)).Find(&customers))
+			require.Len(t, customers, 1)
+			assert.Equal(t, uint(5), customers[0].Profile.ID)
+			require.Len(t, customers[0].Orders, 1)
+			assert.Equal(t, []preloadItem{{ID: 100, OrderID: 10}}, customers[0].Orders[0].Items)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
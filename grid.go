@@ -0,0 +1,320 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GridConfig configures the server-side data grid adapters (DataTables and AGGrid).
+type GridConfig struct {
+	// Columns maps the column names used by the client to SQL columns.
+	// Only these columns can be searched, filtered and sorted.
+	Columns map[string]string
+
+	// MaxRows is the maximum number of rows returned per request. Default: 100.
+	MaxRows int
+}
+
+func (cfg GridConfig) column(name string) (string, bool) {
+	column, ok := cfg.Columns[name]
+	return column, ok && column != ""
+}
+
+// rows clamps the number of rows requested by the client, defaulting to MaxRows if none is given.
+func (cfg GridConfig) rows(n int) int {
+	maxRows := cfg.MaxRows
+	if maxRows <= 0 {
+		maxRows = 100
+	}
+
+	if n <= 0 || n > maxRows {
+		return maxRows
+	}
+	return n
+}
+
+// DataTablesOrder is an ordering of a DataTables server-side request.
+type DataTablesOrder struct {
+	Column int    // index in Columns
+	Dir    string // asc or desc
+}
+
+// DataTablesColumn is a column of a DataTables server-side request.
+type DataTablesColumn struct {
+	Data       string
+	Searchable bool
+	Orderable  bool
+	Search     string
+}
+
+// DataTablesRequest is the payload sent by DataTables (https://datatables.net) in server-side processing mode.
+type DataTablesRequest struct {
+	Draw    int
+	Start   int
+	Length  int // -1 (all rows) and 0 return up to MaxRows
+	Search  string
+	Order   []DataTablesOrder
+	Columns []DataTablesColumn
+}
+
+// DataTablesResponse is the response expected by DataTables in server-side processing mode.
+type DataTablesResponse[T any] struct {
+	Draw            int    `json:"draw"`
+	RecordsTotal    int64  `json:"recordsTotal"`
+	RecordsFiltered int64  `json:"recordsFiltered"`
+	Data            []T    `json:"data"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ParseDataTablesRequest parses the query string or form values sent by DataTables.
+func ParseDataTablesRequest(values url.Values) (*DataTablesRequest, error) {
+	atoi := func(key string) (int, error) {
+		v := values.Get(key)
+		if v == "" {
+			return 0, nil
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return n, nil
+	}
+
+	req := &DataTablesRequest{Search: values.Get("search[value]")}
+	var err error
+	if req.Draw, err = atoi("draw"); err != nil {
+		return nil, err
+	}
+	if req.Start, err = atoi("start"); err != nil {
+		return nil, err
+	}
+	if req.Length, err = atoi("length"); err != nil {
+		return nil, err
+	}
+
+	for i := 0; values.Has(fmt.Sprintf("columns[%d][data]", i)); i++ {
+		prefix := fmt.Sprintf("columns[%d]", i)
+		req.Columns = append(req.Columns, DataTablesColumn{
+			Data:       values.Get(prefix + "[data]"),
+			Searchable: values.Get(prefix+"[searchable]") != "false",
+			Orderable:  values.Get(prefix+"[orderable]") != "false",
+			Search:     values.Get(prefix + "[search][value]"),
+		})
+	}
+
+	for i := 0; values.Has(fmt.Sprintf("order[%d][column]", i)); i++ {
+		column, err := atoi(fmt.Sprintf("order[%d][column]", i))
+		if err != nil {
+			return nil, err
+		}
+		req.Order = append(req.Order, DataTablesOrder{Column: column, Dir: values.Get(fmt.Sprintf("order[%d][dir]", i))})
+	}
+	return req, nil
+}
+
+// DataTables answers a DataTables server-side request with the records of T.
+// The global search matches any searchable allowed column (ILIKE), column searches
+// match their own column and ordering is restricted to allowed orderable columns.
+// db is the *gorm.DB object with the query options already applied.
+func DataTables[T any](db *gorm.DB, req *DataTablesRequest, cfg GridConfig) (*DataTablesResponse[T], error) {
+	base := db.Session(&gorm.Session{})
+	filtered := base.Model(new(T))
+
+	if req.Search != "" {
+		var conditions []string
+		var args []any
+		for _, c := range req.Columns {
+			if column, ok := cfg.column(c.Data); ok && c.Searchable {
+				conditions = append(conditions, column+"::text ILIKE ? ESCAPE '\\'")
+				args = append(args, "%"+escapeLike(req.Search)+"%")
+			}
+		}
+
+		if len(conditions) > 0 {
+			filtered = filtered.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		}
+	}
+
+	for _, c := range req.Columns {
+		if column, ok := cfg.column(c.Data); ok && c.Searchable && c.Search != "" {
+			filtered = filtered.Where(column+"::text ILIKE ? ESCAPE '\\'", "%"+escapeLike(c.Search)+"%")
+		}
+	}
+
+	page := filtered.Session(&gorm.Session{})
+	for _, o := range req.Order {
+		if o.Column < 0 || o.Column >= len(req.Columns) || !req.Columns[o.Column].Orderable {
+			continue
+		}
+
+		if column, ok := cfg.column(req.Columns[o.Column].Data); ok {
+			page = page.Order(column + orderDirection(o.Dir))
+		}
+	}
+
+	resp := &DataTablesResponse[T]{Draw: req.Draw, Data: []T{}}
+	err := runPageQueries(db,
+		func(ctx context.Context) error {
+			return base.WithContext(ctx).Model(new(T)).Count(&resp.RecordsTotal).Error
+		},
+		func(ctx context.Context) error {
+			return filtered.WithContext(ctx).Count(&resp.RecordsFiltered).Error
+		},
+		func(ctx context.Context) error {
+			return page.WithContext(ctx).Offset(max(req.Start, 0)).Limit(cfg.rows(req.Length)).Find(&resp.Data).Error
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AGGridSort is a sort model entry of an AG Grid server-side request.
+type AGGridSort struct {
+	ColID string `json:"colId"`
+	Sort  string `json:"sort"` // asc or desc
+}
+
+// AGGridFilter is a column filter of an AG Grid server-side request.
+// Text, number, date and set filters are supported.
+type AGGridFilter struct {
+	FilterType string `json:"filterType"` // text, number, date or set
+	Type       string `json:"type"`       // equals, contains, inRange, ...
+	Filter     any    `json:"filter"`
+	FilterTo   any    `json:"filterTo"`
+	DateFrom   string `json:"dateFrom"`
+	DateTo     string `json:"dateTo"`
+	Values     []any  `json:"values"`
+}
+
+// AGGridRequest is the payload of the AG Grid (https://www.ag-grid.com) server-side row model.
+type AGGridRequest struct {
+	StartRow    int                     `json:"startRow"`
+	EndRow      int                     `json:"endRow"`
+	SortModel   []AGGridSort            `json:"sortModel"`
+	FilterModel map[string]AGGridFilter `json:"filterModel"`
+}
+
+// AGGridResponse is the response of the AG Grid server-side row model.
+type AGGridResponse[T any] struct {
+	RowData  []T   `json:"rowData"`
+	RowCount int64 `json:"rowCount"`
+}
+
+// AGGrid answers an AG Grid server-side row model request with the records of T.
+// Filters and sorting are restricted to the allowed columns.
+// db is the *gorm.DB object with the query options already applied.
+func AGGrid[T any](db *gorm.DB, req *AGGridRequest, cfg GridConfig) (*AGGridResponse[T], error) {
+	filtered := db.Session(&gorm.Session{}).Model(new(T))
+
+	for name, filter := range req.FilterModel {
+		column, ok := cfg.column(name)
+		if !ok {
+			return nil, fmt.Errorf("column %q can not be filtered", name)
+		}
+
+		condition, args, err := agGridCondition(column, filter)
+		if err != nil {
+			return nil, err
+		}
+		filtered = filtered.Where(condition, args...)
+	}
+
+	page := filtered.Session(&gorm.Session{})
+	for _, s := range req.SortModel {
+		column, ok := cfg.column(s.ColID)
+		if !ok {
+			return nil, fmt.Errorf("column %q can not be sorted", s.ColID)
+		}
+		page = page.Order(column + orderDirection(s.Sort))
+	}
+
+	start := max(req.StartRow, 0)
+	resp := &AGGridResponse[T]{RowData: []T{}}
+	err := runPageQueries(db,
+		func(ctx context.Context) error {
+			return filtered.WithContext(ctx).Count(&resp.RowCount).Error
+		},
+		func(ctx context.Context) error {
+			return page.WithContext(ctx).Offset(start).Limit(cfg.rows(req.EndRow - start)).Find(&resp.RowData).Error
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// agGridCondition converts an AG Grid filter on column to a WHERE condition.
+func agGridCondition(column string, f AGGridFilter) (string, []any, error) {
+	value := f.Filter
+	valueTo := f.FilterTo
+	if f.FilterType == "date" {
+		value, valueTo = f.DateFrom, f.DateTo
+	}
+
+	if f.FilterType == "set" {
+		if len(f.Values) == 0 {
+			return "1 = 0", nil, nil
+		}
+		return column + " IN ?", []any{f.Values}, nil
+	}
+
+	text := column
+	if f.FilterType == "text" {
+		text = column + "::text"
+	}
+
+	switch f.Type {
+	case "equals":
+		return column + " = ?", []any{value}, nil
+	case "notEqual":
+		return column + " <> ?", []any{value}, nil
+	case "lessThan":
+		return column + " < ?", []any{value}, nil
+	case "lessThanOrEqual":
+		return column + " <= ?", []any{value}, nil
+	case "greaterThan":
+		return column + " > ?", []any{value}, nil
+	case "greaterThanOrEqual":
+		return column + " >= ?", []any{value}, nil
+	case "inRange":
+		return column + " BETWEEN ? AND ?", []any{value, valueTo}, nil
+	case "contains":
+		return text + " ILIKE ? ESCAPE '\\'", []any{"%" + escapeLike(fmt.Sprint(value)) + "%"}, nil
+	case "notContains":
+		return text + " NOT ILIKE ? ESCAPE '\\'", []any{"%" + escapeLike(fmt.Sprint(value)) + "%"}, nil
+	case "startsWith":
+		return text + " ILIKE ? ESCAPE '\\'", []any{escapeLike(fmt.Sprint(value)) + "%"}, nil
+	case "endsWith":
+		return text + " ILIKE ? ESCAPE '\\'", []any{"%" + escapeLike(fmt.Sprint(value))}, nil
+	case "blank":
+		return column + " IS NULL", nil, nil
+	case "notBlank":
+		return column + " IS NOT NULL", nil, nil
+	}
+	return "", nil, fmt.Errorf("unsupported filter %s %s", f.FilterType, f.Type)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with \ as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes s to match itself in a LIKE pattern with ESCAPE '\'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// orderDirection returns the SQL direction suffix, defaulting to ascending.
+func orderDirection(dir string) string {
+	if strings.EqualFold(dir, "desc") {
+		return " DESC"
+	}
+	return " ASC"
+}
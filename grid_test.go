@@ -0,0 +1,152 @@
+package gh_test
+
+import (
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var gridConfig = gh.GridConfig{Columns: map[string]string{"doctor": "doctor", "id": "id"}}
+
+// captureQueries records the SQL of every query executed on db.
+func captureQueries(t *testing.T, db *gorm.DB) func() []string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var queries []string
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture_all", func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, tx.Statement.SQL.String())
+	}))
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return queries
+	}
+}
+
+func TestParseDataTablesRequest(t *testing.T) {
+	values, err := url.ParseQuery("draw=3&start=20&length=10&search[value]=john" +
+		"&columns[0][data]=id&columns[0][searchable]=false" +
+		"&columns[1][data]=doctor&columns[1][search][value]=smith" +
+		"&order[0][column]=1&order[0][dir]=desc")
+	assert.NoError(t, err)
+
+	req, err := gh.ParseDataTablesRequest(values)
+	assert.NoError(t, err)
+	assert.Equal(t, &gh.DataTablesRequest{
+		Draw:   3,
+		Start:  20,
+		Length: 10,
+		Search: "john",
+		Order:  []gh.DataTablesOrder{{Column: 1, Dir: "desc"}},
+		Columns: []gh.DataTablesColumn{
+			{Data: "id", Searchable: false, Orderable: true},
+			{Data: "doctor", Searchable: true, Orderable: true, Search: "smith"},
+		},
+	}, req)
+
+	_, err = gh.ParseDataTablesRequest(url.Values{"draw": {"x"}})
+	assert.Error(t, err)
+}
+
+func TestDataTables(t *testing.T) {
+	db := dryRunDB(t)
+	queries := captureQueries(t, db)
+
+	resp, err := gh.DataTables[paginatedVisit](db, &gh.DataTablesRequest{
+		Draw:   3,
+		Start:  20,
+		Length: 1000,
+		Search: "john",
+		Order:  []gh.DataTablesOrder{{Column: 1, Dir: "desc"}, {Column: 2, Dir: "asc"}},
+		Columns: []gh.DataTablesColumn{
+			{Data: "id", Orderable: true},
+			{Data: "doctor", Searchable: true, Orderable: true, Search: "smith"},
+			{Data: "password", Searchable: true, Orderable: true},
+		},
+	}, gridConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, resp.Draw)
+
+	assert.ElementsMatch(t, []string{
+		`SELECT count(*) FROM "paginated_visits"`,
+		`SELECT count(*) FROM "paginated_visits" WHERE (doctor::text ILIKE $1 ESCAPE '\') AND doctor::text ILIKE $2 ESCAPE '\'`,
+		`SELECT * FROM "paginated_visits" WHERE (doctor::text ILIKE $1 ESCAPE '\') AND doctor::text ILIKE $2 ESCAPE '\' ORDER BY doctor DESC LIMIT $3 OFFSET $4`,
+	}, queries())
+}
+
+func TestAGGrid(t *testing.T) {
+	db := dryRunDB(t)
+	queries := captureQueries(t, db)
+
+	_, err := gh.AGGrid[paginatedVisit](db, &gh.AGGridRequest{
+		StartRow:  100,
+		EndRow:    150,
+		SortModel: []gh.AGGridSort{{ColID: "id", Sort: "desc"}},
+		FilterModel: map[string]gh.AGGridFilter{
+			"doctor": {FilterType: "text", Type: "startsWith", Filter: "Dr"},
+		},
+	}, gridConfig)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		`SELECT count(*) FROM "paginated_visits" WHERE doctor::text ILIKE $1 ESCAPE '\'`,
+		`SELECT * FROM "paginated_visits" WHERE doctor::text ILIKE $1 ESCAPE '\' ORDER BY id DESC LIMIT $2 OFFSET $3`,
+	}, queries())
+
+	_, err = gh.AGGrid[paginatedVisit](db, &gh.AGGridRequest{
+		FilterModel: map[string]gh.AGGridFilter{"password": {FilterType: "text", Type: "equals", Filter: "x"}},
+	}, gridConfig)
+	assert.Error(t, err)
+
+	_, err = gh.AGGrid[paginatedVisit](db, &gh.AGGridRequest{
+		FilterModel: map[string]gh.AGGridFilter{"doctor": {FilterType: "text", Type: "regex", Filter: "x"}},
+	}, gridConfig)
+	assert.Error(t, err)
+}
+
+func TestGridEscapesSearch(t *testing.T) {
+	db, mock := mockDB(t)
+	mock.MatchExpectationsInOrder(false)
+
+	// Wildcards in the search match themselves and a zero length defaults to MaxRows.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "paginated_visits"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "paginated_visits" WHERE (doctor::text ILIKE $1 ESCAPE '\')`)).
+		WithArgs(`%50\%\_off\\%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "paginated_visits" WHERE (doctor::text ILIKE $1 ESCAPE '\') LIMIT $2`)).
+		WithArgs(`%50\%\_off\\%`, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doctor"}).AddRow(1, `50%_off\`))
+
+	resp, err := gh.DataTables[paginatedVisit](db, &gh.DataTablesRequest{
+		Search:  `50%_off\`,
+		Columns: []gh.DataTablesColumn{{Data: "doctor", Searchable: true}},
+	}, gridConfig)
+	require.NoError(t, err)
+	assert.Len(t, resp.Data, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "paginated_visits" WHERE doctor::text ILIKE $1 ESCAPE '\'`)).
+		WithArgs(`Dr\_%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "paginated_visits" WHERE doctor::text ILIKE $1 ESCAPE '\' LIMIT $2`)).
+		WithArgs(`Dr\_%`, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doctor"}))
+
+	_, err = gh.AGGrid[paginatedVisit](db, &gh.AGGridRequest{
+		FilterModel: map[string]gh.AGGridFilter{"doctor": {FilterType: "text", Type: "startsWith", Filter: "Dr_"}},
+	}, gridConfig)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
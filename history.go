@@ -0,0 +1,177 @@
+package gh
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Columns added to history tables in addition to the columns of the source table.
+const (
+	HistoryValidFrom = "history_valid_from" // when the version became current
+	HistoryValidTo   = "history_valid_to"   // when the version was replaced or deleted, NULL if current
+	HistoryOperation = "history_operation"  // INSERT, UPDATE or BACKFILL
+)
+
+// historyTable returns the history table name for table.
+func historyTable(table string) string {
+	return table + "_history"
+}
+
+// EnableHistory creates a <table>_history table for each model and installs a trigger
+// that records every version of a row along with the period in which it was current.
+// Rows existing before history was enabled are backfilled with an unbounded start.
+//
+// EnableHistory is idempotent and should be called after migrations, since columns
+// added to the source table are added to the history table on each call.
+func EnableHistory(db *gorm.DB, models ...any) error {
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+
+		if len(stmt.Schema.PrimaryFieldDBNames) == 0 {
+			return fmt.Errorf("history requires a primary key on table %s", stmt.Table)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return enableHistory(tx, stmt.Table, stmt.Schema.PrimaryFieldDBNames)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enable history on %s: %w", stmt.Table, err)
+		}
+	}
+	return nil
+}
+
+func enableHistory(tx *gorm.DB, table string, primaryKeys []string) error {
+	history := historyTable(table)
+
+	type column struct {
+		Name string
+		Type string
+	}
+
+	columns := []column{}
+	err := tx.Raw(`SELECT a.attname AS name, format_type(a.atttypid, a.atttypmod) AS type
+		FROM pg_attribute a
+		WHERE a.attrelid = ?::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, table).Scan(&columns).Error
+	if err != nil {
+		return err
+	}
+
+	var missing bool
+	err = tx.Raw("SELECT to_regclass(?) IS NULL", history).Scan(&missing).Error
+	if err != nil {
+		return err
+	}
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s TIMESTAMPTZ NOT NULL, %s TIMESTAMPTZ, %s TEXT NOT NULL)`,
+			quoteIdent(history), HistoryValidFrom, HistoryValidTo, HistoryOperation),
+	}
+
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quoteIdent(c.Name)
+		values[i] = "NEW." + quoteIdent(c.Name)
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s",
+			quoteIdent(history), quoteIdent(c.Name), c.Type))
+	}
+
+	matchOld := make([]string, len(primaryKeys))
+	for i, pk := range primaryKeys {
+		matchOld[i] = fmt.Sprintf("%s = OLD.%s", quoteIdent(pk), quoteIdent(pk))
+	}
+
+	fn := quoteIdent(history + "_fn")
+	stmts = append(stmts,
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s, %s)",
+			quoteIdent(strings.ReplaceAll(history, ".", "_")+"_idx"), quoteIdent(history),
+			strings.Join(quoteAll(primaryKeys), ", "), HistoryValidFrom),
+
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE %s SET %s = now() WHERE %s AND %s IS NULL;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		INSERT INTO %s (%s, %s, %s) VALUES (%s, now(), TG_OP);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`,
+			fn,
+			quoteIdent(history), HistoryValidTo, strings.Join(matchOld, " AND "), HistoryValidTo,
+			quoteIdent(history), strings.Join(names, ", "), HistoryValidFrom, HistoryOperation, strings.Join(values, ", ")),
+
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", quoteIdent(historyTrigger(table)), quoteIdent(table)),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+			quoteIdent(historyTrigger(table)), quoteIdent(table), fn),
+	)
+
+	if missing {
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s, %s, %s) SELECT %s, '-infinity', 'BACKFILL' FROM %s",
+			quoteIdent(history), strings.Join(names, ", "), HistoryValidFrom, HistoryOperation,
+			strings.Join(names, ", "), quoteIdent(table)))
+	}
+
+	for _, sql := range stmts {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisableHistory removes the history triggers of the models.
+// The history tables are kept.
+func DisableHistory(db *gorm.DB, models ...any) error {
+	for _, model := range models {
+		table, err := tableName(db, model)
+		if err != nil {
+			return err
+		}
+
+		err = db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s",
+			quoteIdent(historyTrigger(table)), quoteIdent(table))).Error
+		if err != nil {
+			return fmt.Errorf("failed to disable history on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func historyTrigger(table string) string {
+	return strings.ReplaceAll(table, ".", "_") + "_history_trigger"
+}
+
+// AsOf queries the history table of model, returning rows in the state they were at the given time.
+// History must have been enabled for the model with EnableHistory.
+//
+// e.g WrapDB(db).AsOf(&Patient{}, lastWeek).Where("id = ?", 5).First(&patient)
+func (gdb *GormDB) AsOf(model any, at time.Time) *GormDB {
+	gdb.db = gdb.db.Model(model)
+	table, err := tableName(gdb.db, model)
+	if err != nil {
+		gdb.db.AddError(err)
+		return gdb
+	}
+
+	gdb.db = gdb.db.Table(historyTable(table)).
+		Where(HistoryValidFrom+" <= ? AND ("+HistoryValidTo+" IS NULL OR "+HistoryValidTo+" > ?)", at, at)
+	return gdb
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return quoted
+}
@@ -0,0 +1,97 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type historyPatient struct {
+	ID   uint
+	Name string
+}
+
+func TestAsOf(t *testing.T) {
+	db := dryRunDB(t)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var patient historyPatient
+		return gh.WrapDB(tx).AsOf(&historyPatient{}, at).Where("id = ?", 5).DB().Find(&patient)
+	})
+
+	assert.Equal(t, `SELECT * FROM "history_patients_history" WHERE (history_valid_from <= '2024-01-01 00:00:00' AND (history_valid_to IS NULL OR history_valid_to > '2024-01-01 00:00:00')) AND id = 5`, sql)
+}
+
+// expectHistoryDDL expects the statements of EnableHistory on history_patients, with a backfill if
+// the history table is missing.
+func expectHistoryDDL(mock sqlmock.Sqlmock, missing bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.attname AS name, format_type(a.atttypid, a.atttypmod) AS type`)).
+		WithArgs("history_patients").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "type"}).AddRow("id", "bigint").AddRow("name", "text"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NULL`)).
+		WithArgs("history_patients_history").
+		WillReturnRows(sqlmock.NewRows([]string{"missing"}).AddRow(missing))
+
+	// The history table has the period columns and every column of the source table.
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "history_patients_history" (history_valid_from TIMESTAMPTZ NOT NULL, history_valid_to TIMESTAMPTZ, history_operation TEXT NOT NULL)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "history_patients_history" ADD COLUMN IF NOT EXISTS "id" bigint`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "history_patients_history" ADD COLUMN IF NOT EXISTS "name" text`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "history_patients_history_idx" ON "history_patients_history" ("id", history_valid_from)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Updates and deletes close the current version, inserts and updates add a new one.
+	mock.ExpectExec(`(?s)^CREATE OR REPLACE FUNCTION "history_patients_history_fn"\(\) RETURNS TRIGGER.*` +
+		regexp.QuoteMeta(`IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE "history_patients_history" SET history_valid_to = now() WHERE "id" = OLD."id" AND history_valid_to IS NULL;`) + `.*` +
+		regexp.QuoteMeta(`IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		INSERT INTO "history_patients_history" ("id", "name", history_valid_from, history_operation) VALUES (NEW."id", NEW."name", now(), TG_OP);`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TRIGGER IF EXISTS "history_patients_history_trigger" ON "history_patients"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TRIGGER "history_patients_history_trigger" AFTER INSERT OR UPDATE OR DELETE ON "history_patients" FOR EACH ROW EXECUTE FUNCTION "history_patients_history_fn"()`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if missing {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "history_patients_history" ("id", "name", history_valid_from, history_operation) SELECT "id", "name", '-infinity', 'BACKFILL' FROM "history_patients"`)).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+	}
+	mock.ExpectCommit()
+}
+
+func TestEnableHistory(t *testing.T) {
+	db, mock := mockDB(t)
+
+	// The first call creates the history table and backfills the existing rows.
+	expectHistoryDDL(mock, true)
+	require.NoError(t, gh.EnableHistory(db, &historyPatient{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Later calls add new columns and replace the trigger without a second backfill.
+	expectHistoryDDL(mock, false)
+	require.NoError(t, gh.EnableHistory(db, &historyPatient{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	type noKey struct{ Name string }
+	assert.ErrorContains(t, gh.EnableHistory(db, &noKey{}), "history requires a primary key on table no_keys")
+}
+
+func TestDisableHistory(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TRIGGER IF EXISTS "history_patients_history_trigger" ON "history_patients"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, gh.DisableHistory(db, &historyPatient{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Hstore is a set of key/value pairs stored in a column of the hstore extension type.
+// A nil value is NULL. A nil Hstore is stored as NULL.
+// The extension must be installed, see EnsureExtensions(db, "hstore").
+type Hstore map[string]*string
+
+// Get returns the value of key, and false if it is missing or NULL.
+func (h Hstore) Get(key string) (string, bool) {
+	v, ok := h[key]
+	if !ok || v == nil {
+		return "", false
+	}
+	return *v, true
+}
+
+// Set sets key to value.
+func (h Hstore) Set(key, value string) {
+	h[key] = &value
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (Hstore) GormDataType() string {
+	return "hstore"
+}
+
+// Value implements driver.Valuer.
+func (h Hstore) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		value := "NULL"
+		if h[k] != nil {
+			value = quoteHstore(*h[k])
+		}
+		pairs[i] = quoteHstore(k) + "=>" + value
+	}
+	return strings.Join(pairs, ", "), nil
+}
+
+func quoteHstore(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Scan implements sql.Scanner.
+func (h *Hstore) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Hstore", src)
+	}
+
+	parsed, err := parseHstore(text)
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// parseHstore parses the text form of an hstore, e.g "a"=>"1", "b"=>NULL.
+func parseHstore(text string) (Hstore, error) {
+	h := Hstore{}
+	p := &textParser{s: text}
+	for {
+		p.skipSpaces()
+		if p.done() {
+			return h, nil
+		}
+
+		key, err := p.hstoreToken()
+		if err != nil || key == nil {
+			return nil, fmt.Errorf("gh: invalid hstore %q", text)
+		}
+
+		p.skipSpaces()
+		if !p.consume("=>") {
+			return nil, fmt.Errorf("gh: invalid hstore %q", text)
+		}
+
+		p.skipSpaces()
+		value, err := p.hstoreToken()
+		if err != nil {
+			return nil, fmt.Errorf("gh: invalid hstore %q", text)
+		}
+		h[*key] = value
+
+		p.skipSpaces()
+		if !p.done() && !p.consume(",") {
+			return nil, fmt.Errorf("gh: invalid hstore %q", text)
+		}
+	}
+}
+
+// textParser is a cursor over the text form of a postgres value.
+type textParser struct {
+	s string
+	i int
+}
+
+func (p *textParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *textParser) skipSpaces() {
+	for !p.done() && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *textParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.i:], prefix) {
+		p.i += len(prefix)
+		return true
+	}
+	return false
+}
+
+// quoted reads a double quoted string with backslash escapes. If doubled is true,
+// a doubled quote is a literal quote too (record syntax).
+func (p *textParser) quoted(doubled bool) (string, error) {
+	var b strings.Builder
+	p.i++ // opening quote
+	for !p.done() {
+		c := p.s[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.s):
+			b.WriteByte(p.s[p.i+1])
+			p.i += 2
+		case c == '"' && doubled && p.i+1 < len(p.s) && p.s[p.i+1] == '"':
+			b.WriteByte('"')
+			p.i += 2
+		case c == '"':
+			p.i++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted string")
+}
+
+// hstoreToken reads a quoted string, or an unquoted one ending at a separator. NULL is nil.
+func (p *textParser) hstoreToken() (*string, error) {
+	if !p.done() && p.s[p.i] == '"' {
+		s, err := p.quoted(false)
+		return &s, err
+	}
+
+	start := p.i
+	for !p.done() && !strings.ContainsRune(" ,=", rune(p.s[p.i])) {
+		p.i++
+	}
+
+	s := p.s[start:p.i]
+	if s == "" {
+		return nil, fmt.Errorf("empty token")
+	}
+	if strings.EqualFold(s, "NULL") {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// HstoreHasKey filters the rows whose hstore column contains key.
+func (gdb *GormDB) HstoreHasKey(column, key string) *GormDB {
+	gdb.db = gdb.db.Where("exist("+column+", ?)", key)
+	return gdb
+}
+
+// HstoreEq filters the rows whose hstore column has key set to value.
+func (gdb *GormDB) HstoreEq(column, key, value string) *GormDB {
+	gdb.db = gdb.db.Where(column+" -> ? = ?", key, value)
+	return gdb
+}
+
+// HstoreContains filters the rows whose hstore column contains all the pairs of h.
+// If h is empty, it does nothing.
+func (gdb *GormDB) HstoreContains(column string, h Hstore) *GormDB {
+	if len(h) > 0 {
+		value, _ := h.Value()
+		gdb.db = gdb.db.Where(column+" @> ?::hstore", value)
+	}
+	return gdb
+}
@@ -0,0 +1,58 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type legacyItem struct {
+	ID    uint
+	Attrs gh.Hstore
+}
+
+func TestHstore(t *testing.T) {
+	var h gh.Hstore
+	assert.NoError(t, h.Scan(`"color"=>"red", "size"=>NULL, "quote"=>"say \"hi\"", "a=>b"=>"c, d"`))
+
+	color, ok := h.Get("color")
+	assert.True(t, ok)
+	assert.Equal(t, "red", color)
+
+	_, ok = h.Get("size")
+	assert.False(t, ok)
+	assert.Contains(t, h, "size")
+
+	quote, _ := h.Get("quote")
+	assert.Equal(t, `say "hi"`, quote)
+
+	c, _ := h.Get("a=>b")
+	assert.Equal(t, "c, d", c)
+
+	h = gh.Hstore{"size": nil}
+	h.Set("color", `re"d`)
+	v, err := h.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `"color"=>"re\"d", "size"=>NULL`, v)
+
+	assert.NoError(t, h.Scan(""))
+	assert.Empty(t, h)
+	assert.Error(t, h.Scan(`"a"=`))
+	assert.Error(t, h.Scan(`"a"=>"b" "c"=>"d"`))
+
+	v, err = gh.Hstore(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestHstoreFilters(t *testing.T) {
+	sql := gh.WrapDB(dryRunDB(t)).
+		HstoreHasKey("attrs", "color").
+		HstoreEq("attrs", "size", "XL").
+		HstoreContains("attrs", gh.Hstore{"brand": nil}).
+		ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]legacyItem{}) })
+
+	assert.Equal(t, `SELECT * FROM "legacy_items" WHERE exist(attrs, 'color') AND attrs -> 'size' = 'XL' AND attrs @> '"brand"=>NULL'::hstore`, sql)
+}
@@ -0,0 +1,86 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrEmptyIdempotencyKey is returned by Idempotent for an empty key.
+var ErrEmptyIdempotencyKey = errors.New("idempotency key is empty")
+
+// IdempotencyKey is a key recorded in the idempotency_keys table by Idempotent,
+// with the result of the operation it identifies.
+type IdempotencyKey struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Result    JSONB     `json:"result"`
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// TableName implements gorm's Tabler interface.
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Idempotent runs fn once per key, e.g the Idempotency-Key header of a payment or booking request,
+// and returns its result, encoded as JSON, to the later calls with the same key.
+//
+// fn runs in a transaction recording the key, so its writes and the key are committed together:
+// if fn fails, nothing is recorded and the key can be retried. Concurrent calls with the same key
+// wait for the first one to commit or roll back. Side effects outside the database, e.g calling a
+// payment provider, should be idempotent on their own, or use the key too.
+//
+// The idempotency_keys table must be migrated before use, and old keys deleted once clients
+// stop retrying:
+//
+//	db.AutoMigrate(&gh.IdempotencyKey{})
+//	db.Where("created_at < ?", time.Now().AddDate(0, 0, -7)).Delete(&gh.IdempotencyKey{})
+//
+//	payment, err := gh.Idempotent(ctx, db, r.Header.Get("Idempotency-Key"), func(tx *gorm.DB) (Payment, error) {
+//		payment := Payment{InvoiceID: invoice.ID, Amount: invoice.Balance}
+//		return payment, tx.Create(&payment).Error
+//	})
+func Idempotent[T any](ctx context.Context, db *gorm.DB, key string, fn func(tx *gorm.DB) (T, error)) (T, error) {
+	var result T
+	if key == "" {
+		return result, ErrEmptyIdempotencyKey
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := IdempotencyKey{Key: key}
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if created.Error != nil {
+			return fmt.Errorf("failed to record idempotency key: %w", created.Error)
+		}
+
+		if created.RowsAffected == 0 {
+			if err := tx.First(&record).Error; err != nil {
+				return fmt.Errorf("failed to get idempotency key: %w", err)
+			}
+			if err := record.Result.Unmarshal(&result); err != nil {
+				return fmt.Errorf("failed to decode result of idempotency key %s: %w", key, err)
+			}
+			return nil
+		}
+
+		var err error
+		if result, err = fn(tx); err != nil {
+			return err
+		}
+
+		data, err := NewJSONB(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode result of idempotency key %s: %w", key, err)
+		}
+		return tx.Model(&record).Update("result", data).Error
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
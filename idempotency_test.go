@@ -0,0 +1,75 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type idempotentPayment struct {
+	ID     uint
+	Amount float64
+}
+
+func TestIdempotent(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+	calls := 0
+	pay := func(tx *gorm.DB) (idempotentPayment, error) {
+		calls++
+		payment := idempotentPayment{Amount: 5000}
+		return payment, tx.Create(&payment).Error
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys" \("key","result","created_at"\) VALUES \(\$1,\$2,\$3\) ON CONFLICT DO NOTHING`).
+		WithArgs("req-1", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "idempotent_payments"`).
+		WithArgs(5000.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "result"=\$1 WHERE "key" = \$2`).
+		WithArgs(`{"ID":7,"Amount":5000}`, "req-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	payment, err := gh.Idempotent(ctx, db, "req-1", pay)
+	require.NoError(t, err)
+	assert.Equal(t, idempotentPayment{ID: 7, Amount: 5000}, payment)
+
+	// A retry returns the stored result without running fn.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_keys" WHERE "idempotency_keys"."key" = \$1 ORDER BY "idempotency_keys"."key" LIMIT \$2`).
+		WithArgs("req-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "result"}).AddRow("req-1", `{"ID":7,"Amount":5000}`))
+	mock.ExpectCommit()
+
+	payment, err = gh.Idempotent(ctx, db, "req-1", pay)
+	require.NoError(t, err)
+	assert.Equal(t, idempotentPayment{ID: 7, Amount: 5000}, payment)
+	assert.Equal(t, 1, calls)
+
+	// Failures are rolled back with the key.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	declined := errors.New("card declined")
+	_, err = gh.Idempotent(ctx, db, "req-2", func(tx *gorm.DB) (idempotentPayment, error) {
+		return idempotentPayment{}, declined
+	})
+	assert.ErrorIs(t, err, declined)
+
+	_, err = gh.Idempotent(ctx, db, "", pay)
+	assert.ErrorIs(t, err, gh.ErrEmptyIdempotencyKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
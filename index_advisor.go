@@ -0,0 +1,115 @@
+package gh
+
+import (
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// IndexSuggestionKind is the kind of an IndexSuggestion.
+type IndexSuggestionKind string
+
+const (
+	SuggestDropIndex IndexSuggestionKind = "drop_index" // the index is never used
+	SuggestAddIndex  IndexSuggestionKind = "add_index"  // the table is mostly read with sequential scans
+)
+
+// IndexSuggestion is a finding of AdviseIndexes.
+type IndexSuggestion struct {
+	Kind       IndexSuggestionKind `json:"kind"`
+	Schema     string              `json:"schema"`
+	Table      string              `json:"table"`
+	Index      string              `json:"index,omitempty"` // the unused index
+	Bytes      int64               `json:"bytes"`           // size of the unused index or of the table
+	Rows       int64               `json:"rows"`
+	SeqScans   int64               `json:"seq_scans"`
+	IndexScans int64               `json:"index_scans"`
+	Queries    []string            `json:"queries,omitempty"` // slowest statements on the table, from pg_stat_statements
+	Reason     string              `json:"reason"`
+	SQL        string              `json:"sql,omitempty"` // statement applying the suggestion, if known
+}
+
+// AdvisorOptions are options for AdviseIndexes.
+type AdvisorOptions struct {
+	// Tables with fewer live rows are ignored. Default: 10000.
+	MinRows int64
+
+	// Tables with fewer sequential scans are ignored. Default: 100.
+	MinSeqScans int64
+
+	// Number of pg_stat_statements queries attached to a SuggestAddIndex suggestion. Default: 3.
+	MaxQueries int
+}
+
+// AdviseIndexes reports the indexes that were never scanned (excluding those enforcing a primary key,
+// unique or exclusion constraint) and the large tables read mostly with sequential scans.
+// If the pg_stat_statements extension is installed, the slowest statements on these tables are attached.
+//
+// Statistics are cumulative since the last reset and per server: check a replica's statistics too
+// before dropping an index that may be used there.
+func AdviseIndexes(db *gorm.DB, opts AdvisorOptions) ([]IndexSuggestion, error) {
+	if opts.MinRows <= 0 {
+		opts.MinRows = 10000
+	}
+	if opts.MinSeqScans <= 0 {
+		opts.MinSeqScans = 100
+	}
+	if opts.MaxQueries <= 0 {
+		opts.MaxQueries = 3
+	}
+
+	suggestions := []IndexSuggestion{}
+	err := db.Raw(`SELECT 'drop_index' AS kind, s.schemaname AS schema, s.relname AS table, s.indexrelname AS index,
+			pg_relation_size(s.indexrelid) AS bytes, t.n_live_tup AS rows, t.seq_scan AS seq_scans, s.idx_scan AS index_scans
+		FROM pg_stat_user_indexes s
+		JOIN pg_stat_user_tables t ON t.relid = s.relid
+		JOIN pg_index i ON i.indexrelid = s.indexrelid
+		WHERE s.idx_scan = 0 AND NOT i.indisunique AND NOT i.indisprimary
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = s.indexrelid)
+		ORDER BY bytes DESC, 2, 3, 4`).Scan(&suggestions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index usage: %w", err)
+	}
+
+	for i := range suggestions {
+		s := &suggestions[i]
+		s.Reason = fmt.Sprintf("index %s on %s was never scanned", s.Index, s.Table)
+		s.SQL = "DROP INDEX CONCURRENTLY " + quoteName(s.Schema) + "." + quoteName(s.Index)
+	}
+
+	tables := []IndexSuggestion{}
+	err = db.Raw(`SELECT 'add_index' AS kind, schemaname AS schema, relname AS table,
+			pg_relation_size(relid) AS bytes, n_live_tup AS rows, seq_scan AS seq_scans, COALESCE(idx_scan, 0) AS index_scans
+		FROM pg_stat_user_tables
+		WHERE n_live_tup >= ? AND seq_scan >= ? AND seq_scan > COALESCE(idx_scan, 0)
+		ORDER BY seq_tup_read DESC, 2, 3`, opts.MinRows, opts.MinSeqScans).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table scans: %w", err)
+	}
+
+	var hasStatements bool
+	if len(tables) > 0 {
+		err = db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')").
+			Scan(&hasStatements).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up pg_stat_statements: %w", err)
+		}
+	}
+
+	for i := range tables {
+		s := &tables[i]
+		s.Reason = fmt.Sprintf("%s has %d rows and was read with %d sequential scans and %d index scans",
+			s.Table, s.Rows, s.SeqScans, s.IndexScans)
+
+		if hasStatements {
+			err = db.Raw(`SELECT query FROM pg_stat_statements
+				WHERE query ~* ? ORDER BY mean_exec_time DESC LIMIT ?`,
+				`\m`+regexp.QuoteMeta(s.Table)+`\M`, opts.MaxQueries).Scan(&s.Queries).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pg_stat_statements: %w", err)
+			}
+		}
+	}
+	return append(suggestions, tables...), nil
+}
@@ -0,0 +1,60 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdviseIndexes(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("FROM pg_stat_user_indexes s").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "schema", "table", "index", "bytes", "rows", "seq_scans", "index_scans"}).
+			AddRow("drop_index", "public", "visits", "idx_visits_notes", 8192, 50000, 10, 900))
+	mock.ExpectQuery("FROM pg_stat_user_tables").WithArgs(int64(10000), int64(100)).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "schema", "table", "bytes", "rows", "seq_scans", "index_scans"}).
+			AddRow("add_index", "public", "patients", 1<<20, 20000, 500, 10))
+	mock.ExpectQuery("pg_extension WHERE extname = 'pg_stat_statements'").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM pg_stat_statements").WithArgs(`\mpatients\M`, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"query"}).AddRow("SELECT * FROM patients WHERE phone = $1"))
+
+	suggestions, err := gh.AdviseIndexes(db, gh.AdvisorOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, suggestions, 2)
+
+	drop := suggestions[0]
+	assert.Equal(t, gh.SuggestDropIndex, drop.Kind)
+	assert.Equal(t, "idx_visits_notes", drop.Index)
+	assert.Equal(t, `DROP INDEX CONCURRENTLY "public"."idx_visits_notes"`, drop.SQL)
+
+	add := suggestions[1]
+	assert.Equal(t, gh.SuggestAddIndex, add.Kind)
+	assert.Equal(t, "patients", add.Table)
+	assert.Equal(t, int64(500), add.SeqScans)
+	assert.Equal(t, []string{"SELECT * FROM patients WHERE phone = $1"}, add.Queries)
+	assert.Contains(t, add.Reason, "500 sequential scans")
+}
+
+func TestAdviseIndexesWithoutStatements(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("FROM pg_stat_user_indexes s").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "schema", "table", "index"}))
+	mock.ExpectQuery("FROM pg_stat_user_tables").WithArgs(int64(10), int64(100)).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "schema", "table", "rows", "seq_scans"}).
+			AddRow("add_index", "public", "patients", 20, 500))
+	mock.ExpectQuery("pg_extension WHERE extname = 'pg_stat_statements'").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	suggestions, err := gh.AdviseIndexes(db, gh.AdvisorOptions{MinRows: 10})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, suggestions, 1)
+	assert.Empty(t, suggestions[0].Queries)
+}
@@ -0,0 +1,302 @@
+package gh
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DatabaseSchema describes the tables of a database, as returned by Introspect.
+type DatabaseSchema struct {
+	Schemas []SchemaInfo `json:"schemas"`
+}
+
+// SchemaInfo describes a schema and its tables.
+type SchemaInfo struct {
+	Name   string      `json:"name"`
+	Tables []TableInfo `json:"tables"`
+}
+
+// TableInfo describes a table (or partitioned table).
+type TableInfo struct {
+	Schema      string           `json:"schema"`
+	Name        string           `json:"name"`
+	PrimaryKey  []string         `json:"primary_key"`
+	Columns     []ColumnInfo     `json:"columns"`
+	Indexes     []IndexInfo      `json:"indexes"`
+	ForeignKeys []ForeignKeyInfo `json:"foreign_keys"`
+}
+
+// ColumnInfo describes a column.
+type ColumnInfo struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"` // e.g character varying(255), timestamp with time zone
+	Nullable bool    `json:"nullable"`
+	Default  *string `json:"default"` // default expression, nil if none
+	Position int     `json:"position"`
+}
+
+// IndexInfo describes an index.
+type IndexInfo struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"` // empty for expression indexes
+	Unique     bool     `json:"unique"`
+	Primary    bool     `json:"primary"`
+	Definition string   `json:"definition"` // CREATE INDEX statement
+}
+
+// ForeignKeyInfo describes a foreign key constraint.
+type ForeignKeyInfo struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	RefSchema  string   `json:"ref_schema"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	OnUpdate   string   `json:"on_update"` // NO ACTION, RESTRICT, CASCADE, SET NULL or SET DEFAULT
+	OnDelete   string   `json:"on_delete"`
+}
+
+// Table returns the table name, optionally schema qualified (public by default), or nil if it does not exist.
+func (s *DatabaseSchema) Table(name string) *TableInfo {
+	schema, table, ok := strings.Cut(name, ".")
+	if !ok {
+		schema, table = "public", name
+	}
+
+	for i := range s.Schemas {
+		if s.Schemas[i].Name != schema {
+			continue
+		}
+		for j := range s.Schemas[i].Tables {
+			if s.Schemas[i].Tables[j].Name == table {
+				return &s.Schemas[i].Tables[j]
+			}
+		}
+	}
+	return nil
+}
+
+// Column returns the column name, or nil if the table has no such column.
+func (t *TableInfo) Column(name string) *ColumnInfo {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Introspect reads the tables, columns, indexes and foreign keys of the given schemas
+// (all schemas except the system ones if none is given) from the postgres catalog,
+// e.g to build admin UIs, migration diffs or filter allowlists.
+func Introspect(db *gorm.DB, schemas ...string) (*DatabaseSchema, error) {
+	filter := "n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'"
+	args := []any{}
+	if len(schemas) > 0 {
+		filter = "n.nspname IN ?"
+		args = append(args, schemas)
+	}
+
+	type tableRow struct {
+		Schema string
+		Name   string
+	}
+
+	tables := []tableRow{}
+	err := db.Raw(`SELECT n.nspname AS schema, c.relname AS name
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+filter+`
+		ORDER BY 1, 2`, args...).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	result := &DatabaseSchema{Schemas: []SchemaInfo{}}
+	byName := map[string]*TableInfo{}
+	for _, t := range tables {
+		if n := len(result.Schemas); n == 0 || result.Schemas[n-1].Name != t.Schema {
+			result.Schemas = append(result.Schemas, SchemaInfo{Name: t.Schema, Tables: []TableInfo{}})
+		}
+
+		s := &result.Schemas[len(result.Schemas)-1]
+		s.Tables = append(s.Tables, TableInfo{
+			Schema:      t.Schema,
+			Name:        t.Name,
+			PrimaryKey:  []string{},
+			Columns:     []ColumnInfo{},
+			Indexes:     []IndexInfo{},
+			ForeignKeys: []ForeignKeyInfo{},
+		})
+	}
+
+	// Pointers are taken once the slices stop growing.
+	for i := range result.Schemas {
+		for j := range result.Schemas[i].Tables {
+			t := &result.Schemas[i].Tables[j]
+			byName[t.Schema+"."+t.Name] = t
+		}
+	}
+
+	if err := introspectColumns(db, filter, args, byName); err != nil {
+		return nil, err
+	}
+	if err := introspectIndexes(db, filter, args, byName); err != nil {
+		return nil, err
+	}
+	if err := introspectForeignKeys(db, filter, args, byName); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func introspectColumns(db *gorm.DB, filter string, args []any, tables map[string]*TableInfo) error {
+	type columnRow struct {
+		Schema string
+		Table  string
+		ColumnInfo
+	}
+
+	rows := []columnRow{}
+	err := db.Raw(`SELECT n.nspname AS schema, c.relname AS table, a.attname AS name,
+			format_type(a.atttypid, a.atttypmod) AS type, NOT a.attnotnull AS nullable,
+			pg_get_expr(d.adbin, d.adrelid) AS default, a.attnum AS position
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attnum > 0 AND NOT a.attisdropped AND c.relkind IN ('r', 'p') AND `+filter+`
+		ORDER BY 1, 2, a.attnum`, args...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	for _, row := range rows {
+		if t, ok := tables[row.Schema+"."+row.Table]; ok {
+			t.Columns = append(t.Columns, row.ColumnInfo)
+		}
+	}
+	return nil
+}
+
+func introspectIndexes(db *gorm.DB, filter string, args []any, tables map[string]*TableInfo) error {
+	type indexRow struct {
+		Schema     string
+		Table      string
+		Name       string
+		Columns    string
+		Unique     bool
+		Primary    bool
+		Definition string
+	}
+
+	rows := []indexRow{}
+	err := db.Raw(`SELECT n.nspname AS schema, t.relname AS table, i.relname AS name,
+			ARRAY(SELECT a.attname FROM unnest(ix.indkey) WITH ORDINALITY k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum ORDER BY k.ord)::text AS columns,
+			ix.indisunique AS unique, ix.indisprimary AS primary, pg_get_indexdef(ix.indexrelid) AS definition
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE `+filter+`
+		ORDER BY 1, 2, 3`, args...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	for _, row := range rows {
+		t, ok := tables[row.Schema+"."+row.Table]
+		if !ok {
+			continue
+		}
+
+		index := IndexInfo{
+			Name:       row.Name,
+			Columns:    textArray(row.Columns),
+			Unique:     row.Unique,
+			Primary:    row.Primary,
+			Definition: row.Definition,
+		}
+		t.Indexes = append(t.Indexes, index)
+		if index.Primary {
+			t.PrimaryKey = index.Columns
+		}
+	}
+	return nil
+}
+
+func introspectForeignKeys(db *gorm.DB, filter string, args []any, tables map[string]*TableInfo) error {
+	type foreignKeyRow struct {
+		Schema     string
+		Table      string
+		Name       string
+		Columns    string
+		RefSchema  string
+		RefTable   string
+		RefColumns string
+		OnUpdate   string
+		OnDelete   string
+	}
+
+	rows := []foreignKeyRow{}
+	err := db.Raw(`SELECT n.nspname AS schema, t.relname AS table, con.conname AS name,
+			ARRAY(SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum ORDER BY k.ord)::text AS columns,
+			rn.nspname AS ref_schema, r.relname AS ref_table,
+			ARRAY(SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum ORDER BY k.ord)::text AS ref_columns,
+			con.confupdtype AS on_update, con.confdeltype AS on_delete
+		FROM pg_constraint con
+		JOIN pg_class t ON t.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_class r ON r.oid = con.confrelid
+		JOIN pg_namespace rn ON rn.oid = r.relnamespace
+		WHERE con.contype = 'f' AND `+filter+`
+		ORDER BY 1, 2, 3`, args...).Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+
+	for _, row := range rows {
+		if t, ok := tables[row.Schema+"."+row.Table]; ok {
+			t.ForeignKeys = append(t.ForeignKeys, ForeignKeyInfo{
+				Name:       row.Name,
+				Columns:    textArray(row.Columns),
+				RefSchema:  row.RefSchema,
+				RefTable:   row.RefTable,
+				RefColumns: textArray(row.RefColumns),
+				OnUpdate:   foreignKeyAction(row.OnUpdate),
+				OnDelete:   foreignKeyAction(row.OnDelete),
+			})
+		}
+	}
+	return nil
+}
+
+// textArray converts a text array in postgres text format to a slice, ignoring NULL elements.
+func textArray(text string) []string {
+	values := []string{}
+	elems, _ := parsePgArray(text)
+	for _, elem := range elems {
+		if elem != nil {
+			values = append(values, *elem)
+		}
+	}
+	return values
+}
+
+// foreignKeyAction converts a pg_constraint action code to its SQL name.
+func foreignKeyAction(code string) string {
+	switch code {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return "NO ACTION"
+}
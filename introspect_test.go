@@ -0,0 +1,64 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospect(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("FROM pg_class c JOIN pg_namespace n").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name"}).
+			AddRow("public", "patients").AddRow("public", "visits"))
+	mock.ExpectQuery("FROM pg_attribute a").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "table", "name", "type", "nullable", "default", "position"}).
+			AddRow("public", "patients", "id", "bigint", false, "nextval('patients_id_seq'::regclass)", 1).
+			AddRow("public", "patients", "name", "text", true, nil, 2).
+			AddRow("public", "visits", "id", "bigint", false, nil, 1).
+			AddRow("public", "visits", "patient_id", "bigint", false, nil, 2))
+	mock.ExpectQuery("FROM pg_index ix").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "table", "name", "columns", "unique", "primary", "definition"}).
+			AddRow("public", "patients", "patients_pkey", "{id}", true, true, "CREATE UNIQUE INDEX patients_pkey ON public.patients USING btree (id)").
+			AddRow("public", "visits", "idx_visits_patient", "{patient_id}", false, false, "CREATE INDEX idx_visits_patient ON public.visits USING btree (patient_id)"))
+	mock.ExpectQuery("FROM pg_constraint con").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "table", "name", "columns", "ref_schema", "ref_table", "ref_columns", "on_update", "on_delete"}).
+			AddRow("public", "visits", "fk_visits_patient", "{patient_id}", "public", "patients", "{id}", "a", "c"))
+
+	schema, err := gh.Introspect(db, "public")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, schema.Schemas, 1)
+	require.Len(t, schema.Schemas[0].Tables, 2)
+
+	patients := schema.Table("patients")
+	require.NotNil(t, patients)
+	assert.Equal(t, []string{"id"}, patients.PrimaryKey)
+	require.Len(t, patients.Columns, 2)
+	assert.Equal(t, "nextval('patients_id_seq'::regclass)", *patients.Column("id").Default)
+	assert.True(t, patients.Column("name").Nullable)
+	assert.Nil(t, patients.Column("name").Default)
+	assert.Nil(t, patients.Column("missing"))
+
+	visits := schema.Table("public.visits")
+	require.NotNil(t, visits)
+	assert.Empty(t, visits.PrimaryKey)
+	require.Len(t, visits.Indexes, 1)
+	assert.Equal(t, []string{"patient_id"}, visits.Indexes[0].Columns)
+	assert.Equal(t, []gh.ForeignKeyInfo{{
+		Name:       "fk_visits_patient",
+		Columns:    []string{"patient_id"},
+		RefSchema:  "public",
+		RefTable:   "patients",
+		RefColumns: []string{"id"},
+		OnUpdate:   "NO ACTION",
+		OnDelete:   "CASCADE",
+	}}, visits.ForeignKeys)
+
+	assert.Nil(t, schema.Table("audit.visits"))
+}
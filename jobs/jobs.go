@@ -0,0 +1,138 @@
+// Package jobs implements a Postgres-backed job queue.
+//
+// Jobs are rows of the gh_jobs table. Workers claim them with FOR UPDATE SKIP LOCKED,
+// so any number of workers can poll the same queue without claiming a job twice.
+// Failed jobs are retried with an exponential backoff and dead-lettered
+// (status dead) once they exhaust their attempts.
+//
+//	if err := jobs.Migrate(db); err != nil { ... }
+//
+//	_, err := jobs.Enqueue(ctx, db, "emails", Email{To: "jane@example.com"}, time.Time{})
+//
+//	worker := jobs.NewWorker(db, "emails", func(ctx context.Context, job *jobs.Job) error {
+//		var email Email
+//		if err := job.Decode(&email); err != nil {
+//			return err
+//		}
+//		return send(ctx, email)
+//	})
+//	err := worker.Run(ctx) // blocks until ctx is canceled
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusPending Status = "pending" // waiting to run at RunAt
+	StatusRunning Status = "running" // claimed by a worker
+	StatusDone    Status = "done"    // completed successfully
+	StatusDead    Status = "dead"    // failed MaxAttempts times
+)
+
+// Job is a unit of work of a queue.
+type Job struct {
+	ID          int64           `gorm:"primaryKey" json:"id"`
+	Queue       string          `gorm:"not null;index:idx_gh_jobs_fetch,priority:1" json:"queue"`
+	Status      Status          `gorm:"not null;default:pending;index:idx_gh_jobs_fetch,priority:2" json:"status"`
+	RunAt       time.Time       `gorm:"not null;index:idx_gh_jobs_fetch,priority:3" json:"run_at"`
+	Payload     json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts    int             `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int             `gorm:"not null" json:"max_attempts"`
+	LastError   string          `json:"last_error"`
+	LockedAt    *time.Time      `json:"locked_at"` // when the job was last claimed
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// TableName implements the gorm.Tabler interface.
+func (Job) TableName() string {
+	return "gh_jobs"
+}
+
+// Decode unmarshals the JSON payload of the job into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// DefaultMaxAttempts is the number of attempts of a job enqueued with Enqueue.
+const DefaultMaxAttempts = 5
+
+// Migrate creates or updates the gh_jobs table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Job{})
+}
+
+// Enqueue adds a job with payload to queue, to run at runAt (now if zero).
+// payload is stored as is if it is a []byte or json.RawMessage, and encoded as JSON otherwise.
+// Within a transaction, the job becomes visible to workers when the transaction commits.
+func Enqueue(ctx context.Context, db *gorm.DB, queue string, payload any, runAt time.Time) (*Job, error) {
+	var data json.RawMessage
+	switch p := payload.(type) {
+	case json.RawMessage:
+		data = p
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("failed to encode payload: %w", err)
+		}
+	}
+
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	job := &Job{
+		Queue:       queue,
+		Status:      StatusPending,
+		RunAt:       runAt,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+
+	if err := db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue job on %s: %w", queue, err)
+	}
+	return job, nil
+}
+
+// DeadJobs returns the dead-lettered jobs of queue, most recent first.
+func DeadJobs(ctx context.Context, db *gorm.DB, queue string) ([]Job, error) {
+	jobs := []Job{}
+	err := db.WithContext(ctx).
+		Where("queue = ? AND status = ?", queue, StatusDead).
+		Order("updated_at DESC").
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// Retry reschedules the dead job id to run now with a fresh set of attempts.
+func Retry(ctx context.Context, db *gorm.DB, id int64) error {
+	result := db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusDead).
+		Updates(map[string]any{
+			"status":    StatusPending,
+			"attempts":  0,
+			"run_at":    time.Now(),
+			"locked_at": nil,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("dead job %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/abiiranathan/gh/jobs"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var jobColumns = []string{"id", "queue", "status", "run_at", "payload", "attempts", "max_attempts"}
+
+func TestEnqueue(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+
+	mock.ExpectQuery(`INSERT INTO "gh_jobs" .* RETURNING "id"`).
+		WithArgs("emails", jobs.StatusPending, sqlmock.AnyArg(), []byte(`{"to":"jane@example.com"}`),
+			0, jobs.DefaultMaxAttempts, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	job, err := jobs.Enqueue(context.Background(), db, "emails", map[string]string{"to": "jane@example.com"}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), job.ID)
+	assert.False(t, job.RunAt.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// runOnce runs worker until handled is closed, then stops it.
+func runOnce(t *testing.T, worker *jobs.Worker, handled chan struct{}) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("job not handled")
+	}
+
+	// Let the worker record the outcome before stopping it.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func expectClaim(mock sqlmock.Sqlmock, attempts, maxAttempts int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "gh_jobs" WHERE queue = \$1 AND .* ORDER BY run_at, id LIMIT \$\d+ FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow(7, "emails", jobs.StatusPending, time.Now(), []byte(`{"to":"jane@example.com"}`), attempts, maxAttempts))
+	mock.ExpectExec(`UPDATE "gh_jobs" SET "attempts"=\$1,"locked_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE "id" = \$5`).
+		WithArgs(attempts+1, sqlmock.AnyArg(), jobs.StatusRunning, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestWorkerSuccess(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	expectClaim(mock, 0, 5)
+	mock.ExpectExec(`UPDATE "gh_jobs" SET "last_error"=\$1,"locked_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE \(status = \$5 AND locked_at = \$6\) AND "id" = \$7`).
+		WithArgs("", nil, jobs.StatusDone, sqlmock.AnyArg(), jobs.StatusRunning, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handled := make(chan struct{})
+	worker := jobs.NewWorker(db, "emails", func(ctx context.Context, job *jobs.Job) error {
+		defer close(handled)
+
+		var email struct{ To string }
+		assert.NoError(t, job.Decode(&email))
+		assert.Equal(t, "jane@example.com", email.To)
+		assert.Equal(t, 1, job.Attempts)
+		return nil
+	})
+	worker.PollInterval = time.Hour
+
+	runOnce(t, worker, handled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkerRetry(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	expectClaim(mock, 1, 5)
+	mock.ExpectExec(`UPDATE "gh_jobs" SET "last_error"=\$1,"locked_at"=\$2,"run_at"=\$3,"status"=\$4`).
+		WithArgs("smtp unavailable", nil, sqlmock.AnyArg(), jobs.StatusPending, sqlmock.AnyArg(), jobs.StatusRunning, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handled := make(chan struct{})
+	worker := jobs.NewWorker(db, "emails", func(ctx context.Context, job *jobs.Job) error {
+		close(handled)
+		return errors.New("smtp unavailable")
+	})
+	worker.PollInterval = time.Hour
+
+	runOnce(t, worker, handled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkerDeadLetter(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	expectClaim(mock, 4, 5)
+	mock.ExpectExec(`UPDATE "gh_jobs" SET "last_error"=\$1,"locked_at"=\$2,"status"=\$3`).
+		WithArgs("panic: boom", nil, jobs.StatusDead, sqlmock.AnyArg(), jobs.StatusRunning, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	handled := make(chan struct{})
+	worker := jobs.NewWorker(db, "emails", func(ctx context.Context, job *jobs.Job) error {
+		close(handled)
+		panic("boom")
+	})
+	worker.PollInterval = time.Hour
+
+	runOnce(t, worker, handled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkerShutdownWaitsForJobs(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	expectClaim(mock, 0, 5)
+	mock.ExpectExec(`UPDATE "gh_jobs"`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	started := make(chan struct{})
+	finished := false
+	worker := jobs.NewWorker(db, "emails", func(ctx context.Context, job *jobs.Job) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		finished = ctx.Err() == nil
+		return nil
+	})
+	worker.PollInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.True(t, finished)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	mock.ExpectExec(`UPDATE "gh_jobs" SET .* WHERE id = \$\d+ AND status = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := jobs.Retry(context.Background(), db, 7)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Handler processes a job. Returning an error (or panicking) fails the attempt.
+// ctx is only canceled if the job is still running after Worker.ShutdownTimeout.
+type Handler func(ctx context.Context, job *Job) error
+
+// Worker polls a queue and runs its jobs with a Handler.
+// The exported fields must be set before Run is called.
+type Worker struct {
+	// Concurrency is the maximum number of jobs run at the same time. Default: 1.
+	Concurrency int
+
+	// PollInterval is the interval between polls when the queue is empty. Default: 1s.
+	PollInterval time.Duration
+
+	// Backoff returns the delay before retrying a job that failed its attempt-th attempt.
+	// Default: 2^attempt seconds, up to 1h.
+	Backoff func(attempt int) time.Duration
+
+	// LockTimeout is the time after which a running job is considered abandoned
+	// (e.g its worker crashed) and claimed again. It must be longer than any job runs. Default: 5m.
+	LockTimeout time.Duration
+
+	// ShutdownTimeout is how long Run waits for running jobs once its context is canceled,
+	// before canceling their context. Default: 30s.
+	ShutdownTimeout time.Duration
+
+	db      *gorm.DB
+	queue   string
+	handler Handler
+}
+
+// NewWorker creates a Worker running the jobs of queue with handler.
+func NewWorker(db *gorm.DB, queue string, handler Handler) *Worker {
+	return &Worker{db: db, queue: queue, handler: handler}
+}
+
+// Run claims and runs jobs until ctx is canceled. It then stops claiming jobs,
+// waits for the running jobs (see ShutdownTimeout) and returns ctx.Err().
+// Errors while polling are logged with db's logger.
+func (w *Worker) Run(ctx context.Context) error {
+	slots := make(chan struct{}, max(w.Concurrency, 1))
+	poll := durationOr(w.PollInterval, time.Second)
+
+	// Running jobs outlive ctx so they can finish during shutdown.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	defer w.drain(&wg, cancelJobs)
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		job, err := w.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			w.db.Logger.Error(ctx, "jobs: failed to poll %s: %v", w.queue, err)
+		}
+
+		if job == nil {
+			<-slots
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(poll):
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			w.process(jobCtx, job)
+		}()
+	}
+}
+
+// drain waits for the running jobs, canceling their context after ShutdownTimeout.
+func (w *Worker) drain(wg *sync.WaitGroup, cancelJobs context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(durationOr(w.ShutdownTimeout, 30*time.Second)):
+		cancelJobs()
+		<-done
+	}
+}
+
+// claim locks the next due job of the queue, skipping the jobs locked by other workers,
+// and marks it running. It returns nil if there is no job to run.
+func (w *Worker) claim(ctx context.Context) (*Job, error) {
+	var claimed *Job
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Postgres stores microseconds, truncate so locked_at can be compared later.
+		now := time.Now().Truncate(time.Microsecond)
+		abandoned := now.Add(-durationOr(w.LockTimeout, 5*time.Minute))
+
+		jobs := []Job{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ?", w.queue).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				StatusPending, now, StatusRunning, abandoned).
+			Order("run_at, id").
+			Limit(1).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		job := &jobs[0]
+		if job.Attempts >= job.MaxAttempts {
+			// Abandoned during its last attempt.
+			return tx.Model(job).Updates(map[string]any{
+				"status":     StatusDead,
+				"last_error": "lock timeout exceeded",
+				"locked_at":  nil,
+			}).Error
+		}
+
+		job.Status = StatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		err = tx.Model(job).Updates(map[string]any{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		claimed = job
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// process runs the handler on job and records the outcome.
+func (w *Worker) process(ctx context.Context, job *Job) {
+	err := w.run(ctx, job)
+
+	updates := map[string]any{"locked_at": nil}
+	switch {
+	case err == nil:
+		updates["status"] = StatusDone
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = StatusDead
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = StatusPending
+		updates["last_error"] = err.Error()
+		updates["run_at"] = time.Now().Add(w.backoff(job.Attempts))
+	}
+
+	// Only update the job if it was not reclaimed after LockTimeout.
+	ctx = context.WithoutCancel(ctx)
+	result := w.db.WithContext(ctx).Model(job).
+		Where("status = ? AND locked_at = ?", StatusRunning, job.LockedAt).
+		Updates(updates)
+	if result.Error != nil {
+		w.db.Logger.Error(ctx, "jobs: failed to update job %d: %v", job.ID, result.Error)
+	}
+}
+
+// run calls the handler, converting a panic to an error.
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.handler(ctx, job)
+}
+
+func (w *Worker) backoff(attempt int) time.Duration {
+	if w.Backoff != nil {
+		return w.Backoff(attempt)
+	}
+	return min(time.Second<<min(attempt, 12), time.Hour)
+}
+
+// durationOr returns d, or def if d is not positive.
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
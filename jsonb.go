@@ -0,0 +1,70 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONB is a raw JSON value stored in a postgres jsonb column.
+// An empty JSONB is stored as NULL.
+type JSONB []byte
+
+// NewJSONB marshals v into a JSONB value.
+func NewJSONB(v any) (JSONB, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSONB(data), nil
+}
+
+// Unmarshal decodes the JSON value into v.
+func (j JSONB) Unmarshal(v any) error {
+	if len(j) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j, v)
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (JSONB) GormDataType() string {
+	return "jsonb"
+}
+
+// Value implements driver.Valuer.
+func (j JSONB) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner.
+func (j *JSONB) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSONB(v)
+	default:
+		return fmt.Errorf("gh: cannot scan %T into JSONB", src)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j JSONB) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *JSONB) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
@@ -0,0 +1,39 @@
+package gh_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONB(t *testing.T) {
+	j, err := gh.NewJSONB(map[string]any{"name": "John"})
+	assert.NoError(t, err)
+
+	value, err := j.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"John"}`, value)
+
+	var scanned gh.JSONB
+	assert.NoError(t, scanned.Scan([]byte(`{"age":30}`)))
+
+	var m map[string]int
+	assert.NoError(t, scanned.Unmarshal(&m))
+	assert.Equal(t, 30, m["age"])
+
+	assert.NoError(t, scanned.Scan(nil))
+	value, err = scanned.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	data, err := json.Marshal(struct {
+		Before gh.JSONB `json:"before"`
+		After  gh.JSONB `json:"after"`
+	}{After: j})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"before":null,"after":{"name":"John"}}`, string(data))
+
+	assert.Error(t, scanned.Scan(42))
+}
@@ -0,0 +1,284 @@
+package gh
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can not be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// KeysetResponse is a page of results retrieved with keyset (cursor-based) pagination.
+type KeysetResponse[T any] struct {
+	Limit      int    `json:"limit"`
+	HasNext    bool   `json:"has_next"`
+	NextCursor string `json:"next_cursor"` // cursor of the next page, empty on the last page
+	Results    []T    `json:"results"`
+}
+
+// GetKeysetPaginated retrieves a page of results after the given cursor using keyset pagination,
+// which unlike GetPaginated does not slow down on deep pages.
+// orderColumns are column names of T with an optional direction, e.g "created_at DESC".
+// The primary key is appended as a tie-breaker so the ordering is stable.
+// Ordered columns should be NOT NULL.
+//
+// Pass an empty cursor for the first page and the returned NextCursor for the following pages.
+// If limit is less than 1, it defaults to 10.
+// db is the *gorm.DB object with the query options already applied.
+func GetKeysetPaginated[T any](db *gorm.DB, cursor string, limit int, orderColumns ...string) (*KeysetResponse[T], error) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	ks, err := newKeyset(db, new(T), orderColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Model(new(T))
+	if cursor != "" {
+		values, err := ks.decode(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(ks.after(values, false))
+	}
+
+	results := []T{}
+	if err := query.Order(ks.order(false)).Limit(limit + 1).Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	response := &KeysetResponse[T]{Limit: limit, Results: results}
+	if len(results) > limit {
+		response.Results = results[:limit]
+		response.HasNext = true
+		response.NextCursor, err = ks.encode(reflect.ValueOf(&response.Results[limit-1]).Elem())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// keyset holds the ordering used for keyset pagination of a model.
+type keyset struct {
+	table   string
+	columns []keysetColumn
+}
+
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// newKeyset parses orderColumns (e.g "created_at DESC") of model's table and appends the
+// primary key as a tie-breaker, using the direction of the last column.
+func newKeyset(db *gorm.DB, model any, orderColumns []string) (*keyset, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+
+	ks := &keyset{table: stmt.Table}
+	seen := map[string]bool{}
+	for _, orderColumn := range orderColumns {
+		parts := strings.Fields(orderColumn)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid order column %q", orderColumn)
+		}
+
+		field := stmt.Schema.LookUpField(parts[0])
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("unknown order column %q for %s", parts[0], stmt.Table)
+		}
+
+		var desc bool
+		if len(parts) == 2 {
+			switch strings.ToUpper(parts[1]) {
+			case "ASC":
+			case "DESC":
+				desc = true
+			default:
+				return nil, fmt.Errorf("invalid order direction %q", parts[1])
+			}
+		}
+
+		if !seen[field.DBName] {
+			seen[field.DBName] = true
+			ks.columns = append(ks.columns, keysetColumn{field: field, desc: desc})
+		}
+	}
+
+	if len(stmt.Schema.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("keyset pagination requires a primary key on %s", stmt.Table)
+	}
+
+	var desc bool
+	if len(ks.columns) > 0 {
+		desc = ks.columns[len(ks.columns)-1].desc
+	}
+
+	for _, field := range stmt.Schema.PrimaryFields {
+		if !seen[field.DBName] {
+			ks.columns = append(ks.columns, keysetColumn{field: field, desc: desc})
+		}
+	}
+	return ks, nil
+}
+
+// order returns the ORDER BY clause. If reverse is true, all directions are inverted.
+func (ks *keyset) order(reverse bool) clause.OrderBy {
+	columns := make([]clause.OrderByColumn, len(ks.columns))
+	for i, c := range ks.columns {
+		columns[i] = clause.OrderByColumn{
+			Column: clause.Column{Table: ks.table, Name: c.field.DBName},
+			Desc:   c.desc != reverse,
+		}
+	}
+	return clause.OrderBy{Columns: columns}
+}
+
+// after returns the condition selecting rows after the row with the given key values,
+// e.g (a > ?) OR (a = ? AND b > ?). If reverse is true, it selects the rows before.
+func (ks *keyset) after(values []any, reverse bool) clause.Expression {
+	var or []clause.Expression
+	for i, c := range ks.columns {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: ks.column(j), Value: values[j]})
+		}
+
+		if c.desc != reverse {
+			and = append(and, clause.Lt{Column: ks.column(i), Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: ks.column(i), Value: values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	return clause.Or(or...)
+}
+
+func (ks *keyset) column(i int) clause.Column {
+	return clause.Column{Table: ks.table, Name: ks.columns[i].field.DBName}
+}
+
+// encode returns the cursor of the row rv (a struct value of the model).
+func (ks *keyset) encode(rv reflect.Value) (string, error) {
+	values := make([]any, len(ks.columns))
+	for i, c := range ks.columns {
+		values[i] = c.field.ReflectValueOf(context.Background(), rv).Interface()
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode parses a cursor into key values typed like the model's fields.
+func (ks *keyset) decode(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	raw := []json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) != len(ks.columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(raw))
+	for i, c := range ks.columns {
+		v := reflect.New(c.field.FieldType)
+		if err := json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
+
+// ForEachPage walks every page of T with keyset pagination and calls fn with each page,
+// for batch processing and export jobs. Pages are ordered by orderColumns (see GetKeysetPaginated),
+// or by primary key if none is given. Count and TotalPages are -1 since they are not computed.
+//
+// Iteration stops at the first error returned by fn or when ctx is canceled.
+// db is the *gorm.DB object with the query options already applied.
+func ForEachPage[T any](ctx context.Context, db *gorm.DB, pageSize int, fn func(page *PagedResponse[T]) error, orderColumns ...string) error {
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	db = db.WithContext(ctx)
+	cursor := ""
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		res, err := GetKeysetPaginated[T](db, cursor, pageSize, orderColumns...)
+		if err != nil {
+			return err
+		}
+
+		if len(res.Results) == 0 {
+			return nil
+		}
+
+		err = fn(&PagedResponse[T]{
+			Page:       page,
+			PageSize:   pageSize,
+			TotalPages: -1,
+			Count:      -1,
+			HasNext:    res.HasNext,
+			HasPrev:    page > 1,
+			Results:    res.Results,
+		})
+		if err != nil || !res.HasNext {
+			return err
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// errStopIteration stops ForEachPage when the loop over Iter is broken.
+var errStopIteration = errors.New("stop iteration")
+
+// Iter returns an iterator over the rows of T, fetched in pages of batchSize rows with ForEachPage,
+// for streaming large tables. If a query fails, the error is yielded with a zero T and the iteration stops.
+//
+//	for visit, err := range gh.Iter[Visit](ctx, db.Where("year = ?", 2024), 500) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Iter[T any](ctx context.Context, db *gorm.DB, batchSize int, orderColumns ...string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		err := ForEachPage(ctx, db, batchSize, func(page *PagedResponse[T]) error {
+			for _, row := range page.Results {
+				if !yield(row, nil) {
+					return errStopIteration
+				}
+			}
+			return nil
+		}, orderColumns...)
+
+		if err != nil && !errors.Is(err, errStopIteration) {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package gh_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type keysetVisit struct {
+	ID        uint
+	Doctor    string
+	CreatedAt time.Time
+}
+
+func TestGetKeysetPaginated(t *testing.T) {
+	db := dryRunDB(t)
+
+	var stmt *gorm.Statement
+	assert.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		stmt = tx.Statement
+	}))
+
+	res, err := gh.GetKeysetPaginated[keysetVisit](db.Where("doctor = ?", "Dr. Smith"), "", 20, "created_at DESC")
+	assert.NoError(t, err)
+	assert.False(t, res.HasNext)
+	assert.Empty(t, res.NextCursor)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" WHERE doctor = $1 ORDER BY "keyset_visits"."created_at" DESC,"keyset_visits"."id" DESC LIMIT $2`, stmt.SQL.String())
+
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`["2024-01-02T10:00:00Z",42]`))
+	_, err = gh.GetKeysetPaginated[keysetVisit](db, cursor, 10, "created_at DESC")
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "keyset_visits" WHERE ("keyset_visits"."created_at" < $1 OR ("keyset_visits"."created_at" = $2 AND "keyset_visits"."id" < $3)) ORDER BY "keyset_visits"."created_at" DESC,"keyset_visits"."id" DESC LIMIT $4`, stmt.SQL.String())
+	assert.Equal(t, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), stmt.Vars[0])
+	assert.Equal(t, uint(42), stmt.Vars[2])
+
+	_, err = gh.GetKeysetPaginated[keysetVisit](db, "not a cursor", 10, "created_at DESC")
+	assert.ErrorIs(t, err, gh.ErrInvalidCursor)
+
+	_, err = gh.GetKeysetPaginated[keysetVisit](db, "", 10, "password")
+	assert.Error(t, err)
+}
+
+func TestForEachPage(t *testing.T) {
+	db := dryRunDB(t)
+
+	// Dry run returns no rows, so fn is never called.
+	called := false
+	err := gh.ForEachPage(context.Background(), db, 100, func(page *gh.PagedResponse[keysetVisit]) error {
+		called = true
+		return nil
+	}, "created_at")
+	assert.NoError(t, err)
+	assert.False(t, called)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = gh.ForEachPage(ctx, db, 100, func(page *gh.PagedResponse[keysetVisit]) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIter(t *testing.T) {
+	db, mock := mockDB(t)
+	columns := []string{"id", "doctor", "created_at"}
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT \* FROM "keyset_visits" ORDER BY "keyset_visits"."id" LIMIT \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "A", created).AddRow(2, "B", created).AddRow(3, "C", created))
+	mock.ExpectQuery(`SELECT \* FROM "keyset_visits" WHERE "keyset_visits"."id" > \$1 ORDER BY "keyset_visits"."id" LIMIT \$2`).
+		WithArgs(2, 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "C", created).AddRow(4, "D", created))
+
+	// Breaking the loop stops fetching pages.
+	doctors := ""
+	for visit, err := range gh.Iter[keysetVisit](context.Background(), db, 2) {
+		require.NoError(t, err)
+		doctors += visit.Doctor
+		if visit.ID == 3 {
+			break
+		}
+	}
+	assert.Equal(t, "ABC", doctors)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range gh.Iter[keysetVisit](ctx, db, 2) {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
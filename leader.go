@@ -0,0 +1,113 @@
+package gh
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LeaderElector elects a single leader among the processes campaigning on the same name,
+// using a session-level advisory lock: the process holding the lock is the leader.
+// If the connection holding the lock is lost, leadership is revoked and the lock is
+// released by Postgres, so another process can be elected.
+//
+//	elector := gh.NewLeaderElector(db, "scheduler")
+//	elector.OnElected = func(ctx context.Context) { scheduler.Run(ctx) } // ctx is canceled on loss
+//	go elector.Run(ctx)
+type LeaderElector struct {
+	// OnElected is called in its own goroutine when leadership is gained.
+	// Its context is canceled when leadership is lost.
+	OnElected func(ctx context.Context)
+
+	// OnRevoked is called when leadership is lost or given up.
+	OnRevoked func()
+
+	// RetryInterval is the interval between campaigns while not leader. Default: 5s.
+	RetryInterval time.Duration
+
+	// CheckInterval is the interval between health checks of the lock connection
+	// while leader. Default: 5s.
+	CheckInterval time.Duration
+
+	db     *gorm.DB
+	key    int64
+	leader atomic.Bool
+}
+
+// NewLeaderElector creates a LeaderElector campaigning on the advisory lock derived from name.
+func NewLeaderElector(db *gorm.DB, name string) *LeaderElector {
+	return &LeaderElector{db: db, key: AdvisoryKey(name)}
+}
+
+// IsLeader reports whether this process is currently the leader.
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for leadership until ctx is canceled, then gives up leadership
+// if held and returns ctx.Err(). Errors while campaigning are logged with db's logger.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	retry := durationOr(e.RetryInterval, 5*time.Second)
+	for {
+		lock, err := TryAdvisoryLock(ctx, e.db, e.key)
+		if err != nil && ctx.Err() == nil {
+			e.db.Logger.Error(ctx, "leader election: %v", err)
+		}
+
+		if lock != nil {
+			e.lead(ctx, lock)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// lead holds leadership until ctx is canceled or the lock connection is lost.
+func (e *LeaderElector) lead(ctx context.Context, lock *SessionLock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.leader.Store(true)
+	if e.OnElected != nil {
+		go e.OnElected(leaderCtx)
+	}
+
+	defer func() {
+		e.leader.Store(false)
+		cancel()
+		if e.OnRevoked != nil {
+			e.OnRevoked()
+		}
+	}()
+
+	ticker := time.NewTicker(durationOr(e.CheckInterval, 5*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			unlockCtx, cancelUnlock := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelUnlock()
+			lock.Unlock(unlockCtx)
+			return
+		case <-ticker.C:
+			if err := lock.conn.PingContext(ctx); err != nil && ctx.Err() == nil {
+				e.db.Logger.Error(ctx, "leader election: lost lock connection: %v", err)
+				discardConn(lock.conn)
+				return
+			}
+		}
+	}
+}
+
+// durationOr returns d, or def if d is not positive.
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,48 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderElector(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(gh.AdvisoryKey("scheduler")).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	elected := make(chan struct{})
+	revoked := make(chan struct{})
+
+	elector := gh.NewLeaderElector(db, "scheduler")
+	elector.OnElected = func(ctx context.Context) {
+		close(elected)
+		<-ctx.Done()
+	}
+	elector.OnRevoked = func() { close(revoked) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- elector.Run(ctx) }()
+
+	select {
+	case <-elected:
+	case <-time.After(time.Second):
+		t.Fatal("not elected")
+	}
+	assert.True(t, elector.IsLeader())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	<-revoked
+	assert.False(t, elector.IsLeader())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
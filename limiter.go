@@ -0,0 +1,275 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRateLimited is returned when a Limiter rejects a query, or its wait is canceled.
+var ErrRateLimited = errors.New("query rate limit exceeded")
+
+const (
+	limiterKey        = "gh:limiter"
+	limiterReleaseKey = "gh:limiter_release"
+)
+
+// Limiter bounds the queries of a caller, e.g the exports and reports of a tenant, so a single
+// consumer can not exhaust the connection pool. It caps the queries running at once (a semaphore)
+// and the rate they start at (a token bucket), per key of their context.
+//
+//	reports := &gh.Limiter{
+//		MaxConcurrent: 2,
+//		Rate:          5,
+//		Wait:          true,
+//		Key: func(ctx context.Context) string {
+//			tenantID, _ := gh.TenantIDFromContext(ctx)
+//			return fmt.Sprint(tenantID)
+//		},
+//	}
+//	err := gh.ExportCSV[Visit](w, gdb.WithContext(r.Context()).Limited(reports), gh.CSVOptions{})
+type Limiter struct {
+	// MaxConcurrent is the maximum number of queries running at once per key. Default: unlimited.
+	MaxConcurrent int
+
+	// Rate is the number of queries per second started per key. Default: unlimited.
+	Rate float64
+
+	// Burst is the number of queries started at once above Rate. Default: Rate, at least 1.
+	Burst int
+
+	// Wait makes queries over the limits wait for their turn, until their context is done.
+	// Otherwise they fail with ErrRateLimited.
+	Wait bool
+
+	// Key returns the key of the caller from the context of the query, e.g the tenant.
+	// Default: the same key for all callers.
+	Key func(ctx context.Context) string
+
+	// IdleTimeout is the time after which the state of an idle key, with no query running and a
+	// full token bucket, is evicted, e.g for keys per tenant. Default: 1 minute.
+	IdleTimeout time.Duration
+
+	mu     sync.Mutex
+	states map[string]*limiterState
+	swept  time.Time
+}
+
+// limiterState is the semaphore and token bucket of a key.
+type limiterState struct {
+	slots  chan struct{}
+	tokens float64
+	last   time.Time
+	active int       // Acquire calls waiting or holding a slot
+	used   time.Time // last Acquire or release
+}
+
+// Acquire waits for (or, if Wait is false, checks) the limits of the caller of ctx, e.g to bound
+// a whole export instead of its queries. release must be called when the work is done.
+//
+//	release, err := reports.Acquire(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer release()
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	key := ""
+	if l.Key != nil {
+		key = l.Key(ctx)
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.states == nil {
+		l.states = map[string]*limiterState{}
+	}
+	if now.Sub(l.swept) >= l.idleTimeout() {
+		l.sweep(now)
+	}
+	state, ok := l.states[key]
+	if !ok {
+		state = &limiterState{tokens: float64(l.burst()), last: now}
+		if l.MaxConcurrent > 0 {
+			state.slots = make(chan struct{}, l.MaxConcurrent)
+		}
+		l.states[key] = state
+	}
+	state.active++
+	state.used = now
+	l.mu.Unlock()
+
+	if err := l.take(ctx, state); err != nil {
+		l.done(state)
+		return nil, err
+	}
+
+	if state.slots == nil {
+		l.done(state)
+		return func() {}, nil
+	}
+
+	select {
+	case state.slots <- struct{}{}:
+	default:
+		if !l.Wait {
+			l.done(state)
+			return nil, fmt.Errorf("%w: %d concurrent queries", ErrRateLimited, l.MaxConcurrent)
+		}
+		select {
+		case state.slots <- struct{}{}:
+		case <-ctx.Done():
+			l.done(state)
+			return nil, fmt.Errorf("%w: %w", ErrRateLimited, ctx.Err())
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-state.slots
+			l.done(state)
+		})
+	}, nil
+}
+
+// done records the end of an Acquire call of state, or the release of its slot.
+func (l *Limiter) done(state *limiterState) {
+	l.mu.Lock()
+	state.active--
+	state.used = time.Now()
+	l.mu.Unlock()
+}
+
+// sweep evicts the states of keys idle for IdleTimeout whose token bucket is full, so evicting
+// them loses nothing. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	l.swept = now
+	for key, state := range l.states {
+		full := l.Rate <= 0 || state.tokens+now.Sub(state.last).Seconds()*l.Rate >= float64(l.burst())
+		if state.active == 0 && full && now.Sub(state.used) >= l.idleTimeout() {
+			delete(l.states, key)
+		}
+	}
+}
+
+func (l *Limiter) idleTimeout() time.Duration {
+	return durationOr(l.IdleTimeout, time.Minute)
+}
+
+func (l *Limiter) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(int(l.Rate), 1)
+}
+
+// take takes a token of the bucket of state, waiting for it if Wait is set.
+func (l *Limiter) take(ctx context.Context, state *limiterState) error {
+	if l.Rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	state.tokens = min(state.tokens+now.Sub(state.last).Seconds()*l.Rate, float64(l.burst()))
+	state.last = now
+	if state.tokens >= 1 {
+		state.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	if !l.Wait {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %g queries per second", ErrRateLimited, l.Rate)
+	}
+
+	// Reserve the next token and wait until it is added.
+	delay := time.Duration((1 - state.tokens) / l.Rate * float64(time.Second))
+	state.tokens--
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		state.tokens++
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %w", ErrRateLimited, ctx.Err())
+	}
+}
+
+// LimiterPlugin is a gorm plugin registering the callbacks acquiring the Limiter of Limited chains.
+//
+//	db.Use(&gh.LimiterPlugin{})
+type LimiterPlugin struct{}
+
+// Name implements gorm.Plugin.
+func (p *LimiterPlugin) Name() string {
+	return limiterKey
+}
+
+// Initialize implements gorm.Plugin, registering the callbacks of every processor.
+func (p *LimiterPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().Before("*").Register, cb.Create().After("*").Register,
+		cb.Query().Before("*").Register, cb.Query().After("*").Register,
+		cb.Update().Before("*").Register, cb.Update().After("*").Register,
+		cb.Delete().Before("*").Register, cb.Delete().After("*").Register,
+		cb.Row().Before("*").Register, cb.Row().After("*").Register,
+		cb.Raw().Before("*").Register, cb.Raw().After("*").Register,
+	}
+	for i, register := range registrations {
+		name, fn := "gh:limiter_before", acquireLimiter
+		if i%2 == 1 {
+			name, fn = "gh:limiter_after", releaseLimiter
+		}
+		if err := register(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Limited bounds the statements of the chain with l: each statement acquires l before it runs
+// and releases it when it is done, or for Rows once the query returns. See Limiter.
+// The LimiterPlugin must be registered, otherwise the chain fails with ErrPluginNotRegistered.
+func (gdb *GormDB) Limited(l *Limiter) *GormDB {
+	if err := requirePlugin(gdb.db, limiterKey, "db.Use(&gh.LimiterPlugin{})"); err != nil {
+		gdb.db = gdb.db.Session(&gorm.Session{})
+		gdb.db.AddError(err)
+		return gdb
+	}
+
+	gdb.db = gdb.db.Set(limiterKey, l).Session(&gorm.Session{})
+	return gdb
+}
+
+func acquireLimiter(db *gorm.DB) {
+	l, ok := db.Get(limiterKey)
+	if !ok || db.Error != nil || db.DryRun {
+		return
+	}
+
+	release, err := l.(*Limiter).Acquire(db.Statement.Context)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(limiterReleaseKey, release)
+}
+
+func releaseLimiter(db *gorm.DB) {
+	value, _ := db.InstanceGet(limiterReleaseKey)
+	if release, ok := value.(func()); ok {
+		release()
+		db.InstanceSet(limiterReleaseKey, nil)
+	}
+}
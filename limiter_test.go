@@ -0,0 +1,125 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantKey struct{}
+
+func TestLimiter(t *testing.T) {
+	limiter := &gh.Limiter{
+		MaxConcurrent: 1,
+		Key:           func(ctx context.Context) string { return ctx.Value(tenantKey{}).(string) },
+	}
+	acme := context.WithValue(context.Background(), tenantKey{}, "acme")
+	globex := context.WithValue(context.Background(), tenantKey{}, "globex")
+
+	release, err := limiter.Acquire(acme)
+	require.NoError(t, err)
+
+	_, err = limiter.Acquire(acme)
+	assert.ErrorIs(t, err, gh.ErrRateLimited)
+
+	// Keys are limited separately.
+	releaseGlobex, err := limiter.Acquire(globex)
+	require.NoError(t, err)
+	releaseGlobex()
+
+	// Waiting callers get the slot when it is released, or fail when their context is done.
+	limiter.Wait = true
+	ctx, cancel := context.WithTimeout(acme, 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx)
+	assert.ErrorIs(t, err, gh.ErrRateLimited)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, err = limiter.Acquire(acme)
+	require.NoError(t, err)
+	release()
+	release() // releasing twice is a no-op
+}
+
+func TestLimiterRate(t *testing.T) {
+	limiter := &gh.Limiter{Rate: 50, Burst: 2}
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := limiter.Acquire(ctx)
+		require.NoError(t, err)
+	}
+	_, err := limiter.Acquire(ctx)
+	assert.ErrorIs(t, err, gh.ErrRateLimited)
+
+	limiter.Wait = true
+	start := time.Now()
+	_, err = limiter.Acquire(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestLimited(t *testing.T) {
+	db, mock := mockDB(t)
+	limiter := &gh.Limiter{MaxConcurrent: 1}
+	assert.ErrorIs(t, gh.WrapDB(db).Limited(limiter).DB().Find(&[]breakerWard{}).Error, gh.ErrPluginNotRegistered)
+
+	require.NoError(t, db.Use(&gh.LimiterPlugin{}))
+	gdb := gh.WrapDB(db).Limited(limiter)
+
+	// The slot is released after each statement.
+	for range 2 {
+		mock.ExpectQuery(`SELECT \* FROM "breaker_wards"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Maternity"))
+		require.NoError(t, gdb.DB().Find(&[]breakerWard{}).Error)
+	}
+
+	release, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.ErrorIs(t, gdb.DB().Find(&[]breakerWard{}).Error, gh.ErrRateLimited)
+	release()
+
+	// Chains without the limiter are not limited.
+	mock.ExpectQuery(`SELECT \* FROM "breaker_wards"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	release, _ = limiter.Acquire(context.Background())
+	defer release()
+	require.NoError(t, db.Find(&[]breakerWard{}).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLimiterEvictsIdleKeys(t *testing.T) {
+	limiter := &gh.Limiter{
+		MaxConcurrent: 1,
+		IdleTimeout:   time.Millisecond,
+		Key:           func(ctx context.Context) string { return ctx.Value(tenantKey{}).(string) },
+	}
+	tenant := func(id string) context.Context { return context.WithValue(context.Background(), tenantKey{}, id) }
+
+	release, err := limiter.Acquire(tenant("a"))
+	require.NoError(t, err)
+	idle, err := limiter.Acquire(tenant("b"))
+	require.NoError(t, err)
+	idle()
+
+	// Acquiring c sweeps the idle keys: b is evicted, a is kept while its slot is held.
+	time.Sleep(5 * time.Millisecond)
+	_, err = limiter.Acquire(tenant("c"))
+	require.NoError(t, err)
+
+	_, err = limiter.Acquire(tenant("a"))
+	assert.ErrorIs(t, err, gh.ErrRateLimited)
+	release()
+
+	release, err = limiter.Acquire(tenant("b"))
+	require.NoError(t, err)
+	release()
+}
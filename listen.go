@@ -0,0 +1,152 @@
+package gh
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// ErrUnsupportedDriver is returned when a feature requires the pgx driver used by PgConnect.
+var ErrUnsupportedDriver = errors.New("unsupported database driver, pgx is required")
+
+// Notification is a message received on a channel with Listen.
+type Notification struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+	PID     uint32 `json:"pid"` // process ID of the notifying backend
+}
+
+// Decode unmarshals the JSON payload of the notification into v.
+func (n Notification) Decode(v any) error {
+	return json.Unmarshal([]byte(n.Payload), v)
+}
+
+// Listen subscribes to a Postgres notification channel on a dedicated connection of db's pool
+// and delivers the notifications on the returned Go channel until ctx is canceled, when it is closed.
+//
+// If the connection is lost, Listen reconnects with an exponential backoff (up to 30s)
+// and logs the failure with db's logger. Notifications sent while disconnected are lost.
+// The connection is discarded rather than returned to the pool when Listen stops.
+//
+//	notifications, err := gh.Listen(ctx, db, "invalidate")
+//	for n := range notifications {
+//		var key CacheKey
+//		if err := n.Decode(&key); err == nil { ... }
+//	}
+func Listen(ctx context.Context, db *gorm.DB, channel string) (<-chan Notification, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := listenConn(ctx, sqlDB, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	notifications := make(chan Notification)
+	go func() {
+		defer close(notifications)
+
+		backoff := time.Second
+		for {
+			err := conn.Raw(func(driverConn any) error {
+				pc := driverConn.(*stdlib.Conn).Conn()
+				for {
+					n, err := pc.WaitForNotification(ctx)
+					if err != nil {
+						return err
+					}
+
+					backoff = time.Second
+					select {
+					case notifications <- Notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID}:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			})
+
+			discardConn(conn)
+
+			if ctx.Err() != nil {
+				return
+			}
+			db.Logger.Error(ctx, "listen on %s: %v, reconnecting in %s", channel, err, backoff)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+
+				backoff = min(2*backoff, 30*time.Second)
+				conn, err = listenConn(ctx, sqlDB, channel)
+				if err == nil {
+					break
+				}
+				db.Logger.Error(ctx, "listen on %s: %v, reconnecting in %s", channel, err, backoff)
+			}
+		}
+	}()
+	return notifications, nil
+}
+
+// listenConn acquires a connection from the pool and listens on channel.
+func listenConn(ctx context.Context, sqlDB *sql.DB, channel string) (*sql.Conn, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		pc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("%w: got %T", ErrUnsupportedDriver, driverConn)
+		}
+
+		_, err := pc.Conn().Exec(ctx, "LISTEN "+quoteName(channel))
+		return err
+	})
+	if err != nil {
+		discardConn(conn)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// discardConn closes conn without returning it to the pool,
+// so session state such as LISTEN does not leak to other users.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+// Notify sends a notification on channel with pg_notify. payload is sent as is if it is
+// a string or []byte, and encoded as JSON otherwise. Within a transaction, the notification
+// is delivered when the transaction commits.
+func Notify(db *gorm.DB, channel string, payload any) error {
+	var text string
+	switch p := payload.(type) {
+	case string:
+		text = p
+	case []byte:
+		text = string(p)
+	default:
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification payload: %w", err)
+		}
+		text = string(data)
+	}
+
+	return db.Exec("SELECT pg_notify(?, ?)", channel, text).Error
+}
@@ -0,0 +1,36 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenRequiresPgx(t *testing.T) {
+	db, _ := mockDB(t)
+
+	_, err := gh.Listen(context.Background(), db, "invalidate")
+	assert.ErrorIs(t, err, gh.ErrUnsupportedDriver)
+}
+
+func TestNotify(t *testing.T) {
+	db, mock := mockDB(t)
+
+	notify := regexp.QuoteMeta("SELECT pg_notify($1, $2)")
+	mock.ExpectExec(notify).WithArgs("invalidate", "users:1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(notify).WithArgs("invalidate", `{"id":1,"table":"users"}`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.Notify(db, "invalidate", "users:1"))
+	assert.NoError(t, gh.Notify(db, "invalidate", map[string]any{"table": "users", "id": 1}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationDecode(t *testing.T) {
+	var payload struct{ ID int }
+	assert.NoError(t, gh.Notification{Payload: `{"id": 7}`}.Decode(&payload))
+	assert.Equal(t, 7, payload.ID)
+}
@@ -0,0 +1,245 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrMissingLoader is returned by Load, LoadMany and LoadBy when the context carries no Loader.
+var ErrMissingLoader = errors.New("missing loader in context")
+
+type loaderKey struct{}
+
+// WithLoader returns a copy of ctx carrying loader, used by Load, LoadMany and LoadBy.
+func WithLoader(ctx context.Context, loader *Loader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+// LoaderFromContext returns the Loader stored in ctx by WithLoader.
+func LoaderFromContext(ctx context.Context) (*Loader, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	loader, ok := ctx.Value(loaderKey{}).(*Loader)
+	return loader, ok
+}
+
+// Loader coalesces the loads of rows by key made during a short window, e.g by concurrent GraphQL
+// resolvers, into one IN query per model and column, and memoizes the results.
+// A Loader is meant to live for a single request: rows written afterwards are not seen.
+//
+//	loader := gh.NewLoader(db.WithContext(r.Context()))
+//	ctx := gh.WithLoader(r.Context(), loader)
+//
+//	// In each resolver:
+//	department, err := gh.Load[Department](ctx, doctor.DepartmentID)
+//	visits, err := gh.LoadBy[Visit](ctx, "patient_id", patient.ID)
+type Loader struct {
+	// Wait is how long a batch collects keys before it is queried. Default: 2ms.
+	Wait time.Duration
+
+	// MaxBatch is the number of keys querying a batch immediately. Default: 1000.
+	MaxBatch int
+
+	db      *gorm.DB
+	mu      sync.Mutex
+	batches map[loadGroup]*loadBatch // pending batches
+	results map[loadKey]*loadResult  // memoized results
+}
+
+type loadGroup struct {
+	typ    reflect.Type
+	column string
+}
+
+type loadKey struct {
+	group loadGroup
+	key   string // formatted with %v, so 5 and uint(5) are the same key
+}
+
+type loadResult struct {
+	done chan struct{}
+	rows []any
+	err  error
+}
+
+type loadBatch struct {
+	once    sync.Once
+	keys    []any
+	results map[string]*loadResult
+	query   func(keys []any) (map[string][]any, error)
+}
+
+// NewLoader creates a Loader querying db.
+func NewLoader(db *gorm.DB) *Loader {
+	return &Loader{db: db, batches: map[loadGroup]*loadBatch{}, results: map[loadKey]*loadResult{}}
+}
+
+// Load returns the row of T with the primary key id, or gorm.ErrRecordNotFound.
+func Load[T any, K comparable](ctx context.Context, id K) (*T, error) {
+	rows, err := LoadMany[T](ctx, []K{id})
+	if err != nil {
+		return nil, err
+	}
+	if rows[0] == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+// LoadMany returns the rows of T with the primary keys ids, in the same order, nil if not found.
+func LoadMany[T any, K comparable](ctx context.Context, ids []K) ([]*T, error) {
+	rows, err := load[T](ctx, "", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*T, len(ids))
+	for i := range rows {
+		if len(rows[i]) > 0 {
+			result[i] = &rows[i][0]
+		}
+	}
+	return result, nil
+}
+
+// LoadBy returns the rows of T whose column equals key, e.g the visits of a patient
+// with LoadBy[Visit](ctx, "patient_id", patient.ID).
+func LoadBy[T any, K comparable](ctx context.Context, column string, key K) ([]T, error) {
+	rows, err := load[T](ctx, column, []K{key})
+	if err != nil {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// load returns the rows of T whose column (the primary key if empty) equals each key.
+func load[T any, K comparable](ctx context.Context, column string, keys []K) ([][]T, error) {
+	loader, ok := LoaderFromContext(ctx)
+	if !ok {
+		return nil, ErrMissingLoader
+	}
+
+	var model T
+	stmt := &gorm.Statement{DB: loader.db}
+	if err := stmt.Parse(&model); err != nil {
+		return nil, fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	if column != "" {
+		field = stmt.Schema.LookUpField(column)
+	}
+	if field == nil {
+		return nil, fmt.Errorf("%T has no column %q", model, column)
+	}
+
+	group := loadGroup{typ: reflect.TypeFor[T](), column: field.DBName}
+	query := func(keys []any) (map[string][]any, error) {
+		rows := []T{}
+		err := loader.db.Table(stmt.Schema.Table).Where(quoteName(field.DBName)+" IN ?", keys).Find(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", stmt.Schema.Table, err)
+		}
+
+		byKey := map[string][]any{}
+		for _, row := range rows {
+			value, _ := field.ValueOf(loader.db.Statement.Context, reflect.ValueOf(row))
+			key := fmt.Sprint(value)
+			byKey[key] = append(byKey[key], row)
+		}
+		return byKey, nil
+	}
+
+	results := make([]*loadResult, len(keys))
+	loader.mu.Lock()
+	for i, key := range keys {
+		results[i] = loader.enqueue(group, key, query)
+	}
+	loader.mu.Unlock()
+
+	rows := make([][]T, len(keys))
+	for i, result := range results {
+		select {
+		case <-result.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		rows[i] = make([]T, len(result.rows))
+		for j, row := range result.rows {
+			rows[i][j] = row.(T)
+		}
+	}
+	return rows, nil
+}
+
+// enqueue returns the memoized result of key, adding it to the pending batch of group if new.
+// l.mu must be held.
+func (l *Loader) enqueue(group loadGroup, key any, query func(keys []any) (map[string][]any, error)) *loadResult {
+	k := loadKey{group: group, key: fmt.Sprint(key)}
+	if result, ok := l.results[k]; ok {
+		return result
+	}
+
+	result := &loadResult{done: make(chan struct{})}
+	l.results[k] = result
+
+	batch, ok := l.batches[group]
+	if !ok {
+		batch = &loadBatch{results: map[string]*loadResult{}, query: query}
+		l.batches[group] = batch
+		time.AfterFunc(durationOr(l.Wait, 2*time.Millisecond), func() { l.dispatch(group, batch) })
+	}
+
+	batch.keys = append(batch.keys, key)
+	batch.results[k.key] = result
+
+	maxBatch := l.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = 1000
+	}
+
+	if len(batch.keys) >= maxBatch {
+		delete(l.batches, group)
+		go l.dispatch(group, batch)
+	}
+	return result
+}
+
+// dispatch queries the keys of batch once and resolves their results.
+// Failed results are forgotten, so they are retried by the next load.
+func (l *Loader) dispatch(group loadGroup, batch *loadBatch) {
+	batch.once.Do(func() {
+		l.mu.Lock()
+		if l.batches[group] == batch {
+			delete(l.batches, group)
+		}
+		l.mu.Unlock()
+
+		rows, err := batch.query(batch.keys)
+
+		if err != nil {
+			l.mu.Lock()
+			for key := range batch.results {
+				delete(l.results, loadKey{group: group, key: key})
+			}
+			l.mu.Unlock()
+		}
+
+		for key, result := range batch.results {
+			result.rows, result.err = rows[key], err
+			close(result.done)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package gh_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type Visit struct {
+	ID        uint
+	PatientID uint
+}
+
+func TestLoad(t *testing.T) {
+	db, mock := mockDB(t)
+	loader := gh.NewLoader(db)
+	loader.Wait = 50 * time.Millisecond
+	ctx := gh.WithLoader(context.Background(), loader)
+
+	mock.ExpectQuery(`SELECT \* FROM "departments" WHERE "id" IN \(\$1,\$2,\$3\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Surgery").AddRow(2, "Pediatrics"))
+
+	// Concurrent loads are coalesced into one query, duplicate keys included.
+	var wg sync.WaitGroup
+	names := make([]string, 4)
+	for i, id := range []uint{1, 2, 1, 2} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			department, err := gh.Load[Department](ctx, id)
+			if assert.NoError(t, err) {
+				names[i] = department.Name
+			}
+		}()
+	}
+
+	wg.Add(1)
+	var missing error
+	go func() {
+		defer wg.Done()
+		_, missing = gh.Load[Department](ctx, 3)
+	}()
+	wg.Wait()
+
+	assert.Equal(t, []string{"Surgery", "Pediatrics", "Surgery", "Pediatrics"}, names)
+	assert.ErrorIs(t, missing, gorm.ErrRecordNotFound)
+
+	// Loaded rows are memoized, whatever the key type.
+	departments, err := gh.LoadMany[Department](ctx, []int{2, 3, 1})
+	require.NoError(t, err)
+	require.Len(t, departments, 3)
+	assert.Equal(t, "Pediatrics", departments[0].Name)
+	assert.Nil(t, departments[1])
+	assert.Equal(t, "Surgery", departments[2].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadBy(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := gh.WithLoader(context.Background(), gh.NewLoader(db))
+
+	mock.ExpectQuery(`SELECT \* FROM "visits" WHERE "patient_id" IN \(\$1\)`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id"}).AddRow(1, 7).AddRow(2, 7))
+
+	visits, err := gh.LoadBy[Visit](ctx, "patient_id", uint(7))
+	require.NoError(t, err)
+	assert.Equal(t, []Visit{{1, 7}, {2, 7}}, visits)
+
+	_, err = gh.LoadBy[Visit](ctx, "doctor_id", 1)
+	assert.ErrorContains(t, err, `has no column "doctor_id"`)
+
+	_, err = gh.Load[Visit](context.Background(), 1)
+	assert.ErrorIs(t, err, gh.ErrMissingLoader)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,117 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Ltree is a label path stored in a column of the ltree extension type, e.g "clinic.surgery.theatre".
+// Labels are separated by dots. An empty Ltree is stored as NULL.
+// The extension must be installed, see EnsureExtensions(db, "ltree").
+type Ltree string
+
+// NewLtree joins labels into a path.
+func NewLtree(labels ...string) Ltree {
+	return Ltree(strings.Join(labels, "."))
+}
+
+// Labels returns the labels of the path.
+func (l Ltree) Labels() []string {
+	if l == "" {
+		return []string{}
+	}
+	return strings.Split(string(l), ".")
+}
+
+// Depth returns the number of labels of the path.
+func (l Ltree) Depth() int {
+	return len(l.Labels())
+}
+
+// Parent returns the path without its last label, empty for a root path.
+func (l Ltree) Parent() Ltree {
+	i := strings.LastIndex(string(l), ".")
+	if i < 0 {
+		return ""
+	}
+	return l[:i]
+}
+
+// Child returns the path extended with label.
+func (l Ltree) Child(label string) Ltree {
+	if l == "" {
+		return Ltree(label)
+	}
+	return l + "." + Ltree(label)
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (Ltree) GormDataType() string {
+	return "ltree"
+}
+
+// Value implements driver.Valuer.
+func (l Ltree) Value() (driver.Value, error) {
+	if l == "" {
+		return nil, nil
+	}
+	return string(l), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *Ltree) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*l = ""
+	case []byte:
+		*l = Ltree(v)
+	case string:
+		*l = Ltree(v)
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Ltree", src)
+	}
+	return nil
+}
+
+// AncestorsOf filters the rows whose ltree column is an ancestor of path (or path itself).
+// If path is empty, it does nothing.
+func (gdb *GormDB) AncestorsOf(column string, path Ltree) *GormDB {
+	if path != "" {
+		gdb.db = gdb.db.Where(column+" @> ?::ltree", string(path))
+	}
+	return gdb
+}
+
+// DescendantsOf filters the rows whose ltree column is a descendant of path (or path itself).
+// If path is empty, it does nothing.
+func (gdb *GormDB) DescendantsOf(column string, path Ltree) *GormDB {
+	if path != "" {
+		gdb.db = gdb.db.Where(column+" <@ ?::ltree", string(path))
+	}
+	return gdb
+}
+
+// Matches filters the rows whose ltree column matches the lquery pattern, e.g "clinic.*{1}.theatre".
+// If lquery is empty, it does nothing.
+func (gdb *GormDB) Matches(column, lquery string) *GormDB {
+	if lquery != "" {
+		gdb.db = gdb.db.Where(column+" ~ ?::lquery", lquery)
+	}
+	return gdb
+}
+
+// CreateLtreeIndex creates a GiST index on the ltree column of table,
+// which speeds up the ancestor, descendant and lquery operators.
+func CreateLtreeIndex(db *gorm.DB, table, column string) error {
+	index := "idx_" + strings.ReplaceAll(table, ".", "_") + "_" + column + "_gist"
+	sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIST (%s)",
+		quoteName(index), quoteIdent(table), quoteName(column))
+
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to create ltree index on %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type category struct {
+	ID   uint
+	Path gh.Ltree
+}
+
+func TestLtree(t *testing.T) {
+	path := gh.NewLtree("clinic", "surgery", "theatre")
+	assert.Equal(t, gh.Ltree("clinic.surgery.theatre"), path)
+	assert.Equal(t, []string{"clinic", "surgery", "theatre"}, path.Labels())
+	assert.Equal(t, 3, path.Depth())
+	assert.Equal(t, gh.Ltree("clinic.surgery"), path.Parent())
+	assert.Equal(t, gh.Ltree(""), gh.Ltree("clinic").Parent())
+	assert.Equal(t, gh.Ltree("clinic.lab"), gh.Ltree("clinic").Child("lab"))
+	assert.Equal(t, 0, gh.Ltree("").Depth())
+
+	v, err := gh.Ltree("").Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	var scanned gh.Ltree
+	assert.NoError(t, scanned.Scan([]byte("a.b")))
+	assert.Equal(t, gh.Ltree("a.b"), scanned)
+}
+
+func TestLtreeFilters(t *testing.T) {
+	gdb := gh.WrapDB(dryRunDB(t))
+
+	sql := gdb.DescendantsOf("path", "clinic.surgery").Matches("path", "*.theatre").AncestorsOf("path", "").
+		ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]category{}) })
+	assert.Equal(t, `SELECT * FROM "categories" WHERE path <@ 'clinic.surgery'::ltree AND path ~ '*.theatre'::lquery`, sql)
+
+	sql = gh.WrapDB(dryRunDB(t)).AncestorsOf("path", "clinic.surgery").
+		ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]category{}) })
+	assert.Equal(t, `SELECT * FROM "categories" WHERE path @> 'clinic.surgery'::ltree`, sql)
+}
+
+func TestCreateLtreeIndex(t *testing.T) {
+	db, mock := mockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "idx_public_categories_path_gist" ON "public"."categories" USING GIST ("path")`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.CreateLtreeIndex(db, "public.categories", "path"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
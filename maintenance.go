@@ -0,0 +1,119 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// VacuumOptions are the options of VacuumTable.
+type VacuumOptions struct {
+	// Full rewrites the table to return its space to the operating system.
+	// It takes an ACCESS EXCLUSIVE lock, blocking reads and writes for the whole run.
+	Full bool
+
+	// Freeze freezes all tuples, e.g before a bulk-loaded table becomes read-mostly.
+	Freeze bool
+
+	// Analyze updates the planner statistics too.
+	Analyze bool
+}
+
+// AnalyzeTable updates the planner statistics of table.
+func AnalyzeTable(ctx context.Context, db *gorm.DB, table string) error {
+	if err := db.WithContext(ctx).Exec("ANALYZE " + quoteIdent(table)).Error; err != nil {
+		return fmt.Errorf("failed to analyze %s: %w", table, err)
+	}
+	return nil
+}
+
+// VacuumTable vacuums table. VACUUM can not run in a transaction, so db must not be one.
+func VacuumTable(ctx context.Context, db *gorm.DB, table string, opts VacuumOptions) error {
+	options := []string{}
+	if opts.Full {
+		options = append(options, "FULL")
+	}
+	if opts.Freeze {
+		options = append(options, "FREEZE")
+	}
+	if opts.Analyze {
+		options = append(options, "ANALYZE")
+	}
+
+	sql := "VACUUM "
+	if len(options) > 0 {
+		sql += "(" + strings.Join(options, ", ") + ") "
+	}
+
+	if err := db.WithContext(ctx).Exec(sql + quoteIdent(table)).Error; err != nil {
+		return fmt.Errorf("failed to vacuum %s: %w", table, err)
+	}
+	return nil
+}
+
+// ReindexConcurrently rebuilds the indexes of table without blocking writes (postgres 12+),
+// e.g to remove index bloat. It can not run in a transaction, so db must not be one.
+func ReindexConcurrently(ctx context.Context, db *gorm.DB, table string) error {
+	if err := db.WithContext(ctx).Exec("REINDEX TABLE CONCURRENTLY " + quoteIdent(table)).Error; err != nil {
+		return fmt.Errorf("failed to reindex %s: %w", table, err)
+	}
+	return nil
+}
+
+// MaintenanceOptions are the options of MaintainTables.
+type MaintenanceOptions struct {
+	// Vacuum options. The tables are always analyzed.
+	Vacuum VacuumOptions
+
+	// Reindex rebuilds the indexes of the tables after vacuuming them.
+	Reindex bool
+}
+
+// MaintainTables returns a task vacuuming, analyzing and optionally reindexing tables, to register
+// with a Scheduler:
+//
+//	scheduler.Register("nightly-maintenance", "0 3 * * *", gh.MaintainTables(db, gh.MaintenanceOptions{}, "visits", "invoices"))
+//
+// Each table is guarded by a session advisory lock, so a table being maintained by another instance
+// is skipped. A failure does not stop the maintenance of the other tables; the errors are joined.
+func MaintainTables(db *gorm.DB, opts MaintenanceOptions, tables ...string) TaskFunc {
+	opts.Vacuum.Analyze = true
+
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, table := range tables {
+			if ctx.Err() != nil {
+				return errors.Join(append(errs, ctx.Err())...)
+			}
+
+			if err := maintainTable(ctx, db, table, opts); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+func maintainTable(ctx context.Context, db *gorm.DB, table string, opts MaintenanceOptions) error {
+	lock, err := TryAdvisoryLock(ctx, db, AdvisoryKey("gh:maintenance:"+table))
+	if err != nil {
+		return err
+	}
+
+	if lock == nil {
+		return nil
+	}
+	defer lock.Unlock(context.WithoutCancel(ctx))
+
+	if err := VacuumTable(ctx, db, table, opts.Vacuum); err != nil {
+		return err
+	}
+
+	if opts.Reindex {
+		return ReindexConcurrently(ctx, db, table)
+	}
+	return nil
+}
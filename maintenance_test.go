@@ -0,0 +1,49 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceStatements(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta(`ANALYZE "public"."visits"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`VACUUM "visits"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`VACUUM (FULL, FREEZE, ANALYZE) "visits"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`REINDEX TABLE CONCURRENTLY "visits"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.AnalyzeTable(ctx, db, "public.visits"))
+	assert.NoError(t, gh.VacuumTable(ctx, db, "visits", gh.VacuumOptions{}))
+	assert.NoError(t, gh.VacuumTable(ctx, db, "visits", gh.VacuumOptions{Full: true, Freeze: true, Analyze: true}))
+	assert.NoError(t, gh.ReindexConcurrently(ctx, db, "visits"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMaintainTables(t *testing.T) {
+	db, mock := mockDB(t)
+
+	visitsKey := gh.AdvisoryKey("gh:maintenance:visits")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(visitsKey).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(`VACUUM (ANALYZE) "visits"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`REINDEX TABLE CONCURRENTLY "visits"`)).WillReturnError(errors.New("deadlock detected"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(visitsKey).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	// invoices is being maintained by another instance.
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(gh.AdvisoryKey("gh:maintenance:invoices")).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(false))
+
+	task := gh.MaintainTables(db, gh.MaintenanceOptions{Reindex: true}, "visits", "invoices")
+	err := task(context.Background())
+	assert.ErrorContains(t, err, "failed to reindex visits")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
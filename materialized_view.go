@@ -0,0 +1,93 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaterializedView describes an existing materialized view.
+type MaterializedView struct {
+	Schema     string `json:"schema"`
+	Name       string `json:"name"`
+	Populated  bool   `json:"populated"`  // false if created WITH NO DATA and never refreshed
+	Definition string `json:"definition"` // the SELECT query of the view
+}
+
+// CreateMaterializedView creates the materialized view name (optionally schema qualified)
+// from query if it does not exist. If withData is false, the view is created WITH NO DATA
+// and must be refreshed before it can be queried.
+//
+//	err := gh.CreateMaterializedView(db, "income_per_billable", `SELECT date, billable_type, doctor,
+//		SUM(total_amount) AS total_amount FROM invoices GROUP BY 1, 2, 3`, true)
+func CreateMaterializedView(db *gorm.DB, name, query string, withData bool) error {
+	sql := "CREATE MATERIALIZED VIEW IF NOT EXISTS " + quoteIdent(name) + " AS " + query
+	if !withData {
+		sql += " WITH NO DATA"
+	}
+
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to create materialized view %s: %w", name, err)
+	}
+	return nil
+}
+
+// DropMaterializedView drops the materialized view name and, if cascade is true, the objects that depend on it.
+func DropMaterializedView(db *gorm.DB, name string, cascade bool) error {
+	sql := "DROP MATERIALIZED VIEW IF EXISTS " + quoteIdent(name)
+	if cascade {
+		sql += " CASCADE"
+	}
+
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to drop materialized view %s: %w", name, err)
+	}
+	return nil
+}
+
+// RefreshMaterializedView recomputes the materialized view name.
+// With concurrently, readers are not blocked during the refresh, which requires
+// a unique index on the view and a view that is already populated.
+func RefreshMaterializedView(ctx context.Context, db *gorm.DB, name string, concurrently bool) error {
+	sql := "REFRESH MATERIALIZED VIEW "
+	if concurrently {
+		sql += "CONCURRENTLY "
+	}
+
+	if err := db.WithContext(ctx).Exec(sql + quoteIdent(name)).Error; err != nil {
+		return fmt.Errorf("failed to refresh materialized view %s: %w", name, err)
+	}
+	return nil
+}
+
+// RefreshEvery refreshes the materialized view name every interval until ctx is canceled,
+// and returns ctx.Err(). Failed refreshes are logged with db's logger and retried at the next tick.
+// It blocks, so run it in its own goroutine:
+//
+//	go gh.RefreshEvery(ctx, db, "income_per_billable", time.Hour, true)
+func RefreshEvery(ctx context.Context, db *gorm.DB, name string, interval time.Duration, concurrently bool) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := RefreshMaterializedView(ctx, db, name, concurrently); err != nil && ctx.Err() == nil {
+				db.Logger.Error(ctx, "%v", err)
+			}
+		}
+	}
+}
+
+// MaterializedViews returns the materialized views of the database, excluding system schemas.
+func MaterializedViews(db *gorm.DB) ([]MaterializedView, error) {
+	views := []MaterializedView{}
+	err := db.Raw(`SELECT schemaname AS schema, matviewname AS name, ispopulated AS populated, definition
+		FROM pg_matviews WHERE schemaname NOT IN ('pg_catalog', 'information_schema') ORDER BY schemaname, matviewname`).
+		Scan(&views).Error
+	return views, err
+}
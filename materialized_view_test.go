@@ -0,0 +1,44 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaterializedViews(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE MATERIALIZED VIEW IF NOT EXISTS "reports"."income" AS SELECT 1 WITH NO DATA`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`REFRESH MATERIALIZED VIEW CONCURRENTLY "reports"."income"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP MATERIALIZED VIEW IF EXISTS "reports"."income" CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.CreateMaterializedView(db, "reports.income", "SELECT 1", false))
+	assert.NoError(t, gh.RefreshMaterializedView(context.Background(), db, "reports.income", true))
+	assert.NoError(t, gh.DropMaterializedView(db, "reports.income", true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshEvery(t *testing.T) {
+	db, mock := mockDB(t)
+
+	refresh := regexp.QuoteMeta(`REFRESH MATERIALIZED VIEW "income"`)
+	mock.ExpectExec(refresh).WillReturnError(assert.AnError)
+	mock.ExpectExec(refresh).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- gh.RefreshEvery(ctx, db, "income", 5*time.Millisecond, false) }()
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
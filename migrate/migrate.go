@@ -0,0 +1,437 @@
+// Package migrate applies versioned SQL migrations, e.g embedded in the binary with embed.FS.
+//
+// Migrations are files named <version>_<name>.up.sql, with an optional <version>_<name>.down.sql
+// to roll them back, e.g 20240501120000_create_visits.up.sql. Applied versions are recorded in the
+// schema_migrations table. Each migration runs in its own transaction, and a session advisory lock
+// ensures a single instance migrates the database at a time.
+//
+// A migration starting with the line "-- gh:no-transaction" runs outside a transaction,
+// for statements like CREATE INDEX CONCURRENTLY. It is marked dirty while it runs, and a
+// failure leaves it dirty: Up and Down return ErrDirty until it is resolved manually.
+//
+// Go migrations, e.g data backfills that are impossible in pure SQL, are registered with
+// Migrator.Register and interleaved with the SQL migrations by version.
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+//	if err != nil { ... }
+//	applied, err := migrator.Up(ctx)
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrOutOfOrder is returned by Up when a pending migration is older than the latest applied one,
+	// e.g after merging a branch, unless Migrator.AllowOutOfOrder is set.
+	ErrOutOfOrder = errors.New("migration is older than the latest applied migration")
+
+	// ErrIrreversible is returned by Down when a migration has no down migration.
+	ErrIrreversible = errors.New("migration has no down migration")
+
+	// ErrDirty is returned by Up and Down when a migration run outside a transaction failed,
+	// leaving the database in an unknown state. Once resolved, clear it with
+	// UPDATE schema_migrations SET dirty = false (or delete the row to rerun the migration).
+	ErrDirty = errors.New("migration is dirty")
+
+	// ErrChecksumMismatch is returned by Up when an applied migration was edited,
+	// if Migrator.VerifyChecksums is set.
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+)
+
+// noTransaction is the directive of migrations that must run outside a transaction.
+const noTransaction = "-- gh:no-transaction"
+
+// MigrationFunc is a Go migration step, run in the migration's transaction.
+type MigrationFunc func(tx *gh.GormDB) error
+
+// Migration is a versioned schema change, written in SQL or in Go.
+type Migration struct {
+	Version       int64         `json:"version"`
+	Name          string        `json:"name"`
+	Up            string        `json:"-"`              // SQL applying the migration
+	Down          string        `json:"-"`              // SQL rolling the migration back
+	UpFunc        MigrationFunc `json:"-"`              // Go function applying the migration, instead of Up
+	DownFunc      MigrationFunc `json:"-"`              // Go function rolling the migration back, instead of Down
+	NoTransaction bool          `json:"no_transaction"` // run outside a transaction
+}
+
+// Checksum returns the hex SHA-256 of the up SQL, or "" for a Go migration.
+func (m *Migration) Checksum() string {
+	if m.Up == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reversible reports whether the migration can be rolled back.
+func (m *Migration) Reversible() bool {
+	return m.Down != "" || m.DownFunc != nil
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// SchemaMigration is a row of the schema_migrations table, recording an applied migration.
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+	Checksum  string    `gorm:"not null" json:"checksum"` // checksum of the up SQL when applied
+	Dirty     bool      `gorm:"not null" json:"dirty"`    // a migration run outside a transaction failed
+}
+
+// TableName implements the gorm.Tabler interface.
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies and rolls back migrations.
+type Migrator struct {
+	// AllowOutOfOrder applies pending migrations older than the latest applied one
+	// instead of returning ErrOutOfOrder.
+	AllowOutOfOrder bool
+
+	// VerifyChecksums makes Up return ErrChecksumMismatch if an applied migration was edited.
+	VerifyChecksums bool
+
+	db         *gorm.DB
+	migrations []*Migration // sorted by version
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.sql$`)
+
+// NewMigrator returns a Migrator for the SQL files of the directory dir of fsys.
+// Files with other extensions are ignored. fsys is nil for Go migrations only.
+func NewMigrator(db *gorm.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	if fsys == nil {
+		return &Migrator{db: db}, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, match[2])
+		}
+
+		sql := string(data)
+		if match[3] == ".down" {
+			m.Down = sql
+		} else {
+			if m.Up != "" {
+				return nil, fmt.Errorf("duplicate migration %s", m)
+			}
+			m.Up = sql
+			m.NoTransaction = strings.HasPrefix(strings.TrimSpace(sql), noTransaction)
+		}
+	}
+
+	migrator := &Migrator{db: db}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up migration", m)
+		}
+		migrator.migrations = append(migrator.migrations, m)
+	}
+
+	slices.SortFunc(migrator.migrations, func(a, b *Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrator, nil
+}
+
+// Register adds a Go migration. down is nil if the migration is irreversible.
+// It returns an error if a migration with the same version exists.
+//
+//	err := migrator.Register(20240502090000, "backfill_full_names", func(tx *gh.GormDB) error {
+//		return tx.DB().Exec("UPDATE patients SET full_name = first_name || ' ' || last_name").Error
+//	}, nil)
+func (m *Migrator) Register(version int64, name string, up, down MigrationFunc) error {
+	idx, found := slices.BinarySearchFunc(m.migrations, version, func(migration *Migration, v int64) int {
+		return cmp.Compare(migration.Version, v)
+	})
+	if found {
+		return fmt.Errorf("duplicate migration version %d: %s and %s", version, m.migrations[idx].Name, name)
+	}
+
+	migration := &Migration{Version: version, Name: name, UpFunc: up, DownFunc: down}
+	m.migrations = slices.Insert(m.migrations, idx, migration)
+	return nil
+}
+
+// Migrations returns the migrations, sorted by version.
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Applied returns the applied migrations, sorted by version.
+func (m *Migrator) Applied(ctx context.Context) ([]SchemaMigration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied := []SchemaMigration{}
+	if err := m.db.WithContext(ctx).Order("version").Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// Pending returns the migrations that are not applied, sorted by version.
+func (m *Migrator) Pending(ctx context.Context) ([]*Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(applied), nil
+}
+
+func (m *Migrator) pending(applied []SchemaMigration) []*Migration {
+	done := map[int64]bool{}
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+
+	pending := []*Migration{}
+	for _, migration := range m.migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// Up applies the pending migrations in version order and returns them.
+// It stops at the first failure, returning the migrations applied so far.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	lock, err := gh.AdvisoryLock(ctx, m.db, gh.AdvisoryKey("gh:migrate"))
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock(context.WithoutCancel(ctx))
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkDirty(applied); err != nil {
+		return nil, err
+	}
+
+	if m.VerifyChecksums {
+		if modified := m.modified(applied); len(modified) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, modified[0])
+		}
+	}
+
+	pending := m.pending(applied)
+	if len(applied) > 0 && !m.AllowOutOfOrder {
+		latest := applied[len(applied)-1].Version
+		for _, migration := range pending {
+			if migration.Version < latest {
+				return nil, fmt.Errorf("%w: %s, latest is %d", ErrOutOfOrder, migration, latest)
+			}
+		}
+	}
+
+	done := []*Migration{}
+	for _, migration := range pending {
+		err := m.run(ctx, migration, migration.Up, migration.UpFunc, func(tx *gorm.DB) error {
+			// Clears the dirty row of a migration run outside a transaction.
+			return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+				Checksum:  migration.Checksum(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %s: %w", migration, err)
+		}
+
+		m.db.Logger.Info(ctx, "applied migration %s", migration)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and returns them.
+// It stops at the first failure, returning the migrations rolled back so far.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	lock, err := gh.AdvisoryLock(ctx, m.db, gh.AdvisoryKey("gh:migrate"))
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock(context.WithoutCancel(ctx))
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkDirty(applied); err != nil {
+		return nil, err
+	}
+
+	done := []*Migration{}
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.find(applied[i].Version)
+		if migration == nil {
+			return done, fmt.Errorf("failed to roll back migration %d_%s: migration not found", applied[i].Version, applied[i].Name)
+		}
+
+		if !migration.Reversible() {
+			return done, fmt.Errorf("failed to roll back migration %s: %w", migration, ErrIrreversible)
+		}
+
+		err := m.run(ctx, migration, migration.Down, migration.DownFunc, func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to roll back migration %s: %w", migration, err)
+		}
+
+		m.db.Logger.Info(ctx, "rolled back migration %s", migration)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// execScript executes the statements of a migration file. Postgres can not prepare several
+// statements at once, so they are sent unprepared even if db has PrepareStmt.
+func execScript(db *gorm.DB, sql string) error {
+	var conn gorm.ConnPool
+	switch pool := db.Statement.ConnPool.(type) {
+	case *gorm.PreparedStmtTX:
+		conn = pool.Tx
+	case *gorm.PreparedStmtDB:
+		conn = pool.ConnPool
+	default:
+		return db.Exec(sql).Error
+	}
+
+	_, err := conn.ExecContext(db.Statement.Context, sql)
+	return err
+}
+
+// run executes fn (or sql if fn is nil) and record, in a transaction unless the migration opts out of it.
+func (m *Migrator) run(ctx context.Context, migration *Migration, sql string, fn MigrationFunc, record func(tx *gorm.DB) error) error {
+	step := func(tx *gorm.DB) error {
+		if fn != nil {
+			return fn(gh.WrapDB(tx))
+		}
+		return execScript(tx, sql)
+	}
+
+	db := m.db.WithContext(ctx)
+	if migration.NoTransaction {
+		if err := markDirty(db, migration); err != nil {
+			return err
+		}
+		if err := step(db); err != nil {
+			return err
+		}
+		return record(db)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := step(tx); err != nil {
+			return err
+		}
+		return record(tx)
+	})
+}
+
+// markDirty records the migration as dirty until record clears or deletes its row.
+func markDirty(db *gorm.DB, migration *Migration) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "version"}},
+		DoUpdates: clause.Assignments(map[string]any{"dirty": true}),
+	}).Create(&SchemaMigration{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		AppliedAt: time.Now(),
+		Checksum:  migration.Checksum(),
+		Dirty:     true,
+	}).Error
+}
+
+// checkDirty returns ErrDirty if an applied migration is dirty.
+func checkDirty(applied []SchemaMigration) error {
+	for _, a := range applied {
+		if a.Dirty {
+			return fmt.Errorf("%w: %d_%s", ErrDirty, a.Version, a.Name)
+		}
+	}
+	return nil
+}
+
+// ensureTable creates the schema_migrations table if it does not exist,
+// and adds the columns missing from tables created by older versions.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL,
+		checksum text NOT NULL DEFAULT '',
+		dirty boolean NOT NULL DEFAULT false
+	)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	err = db.Exec(`ALTER TABLE schema_migrations
+		ADD COLUMN IF NOT EXISTS checksum text NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS dirty boolean NOT NULL DEFAULT false`).Error
+	if err != nil {
+		return fmt.Errorf("failed to upgrade schema_migrations: %w", err)
+	}
+	return nil
+}
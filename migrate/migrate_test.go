@@ -0,0 +1,219 @@
+package migrate_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/abiiranathan/gh/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+var migrations = fstest.MapFS{
+	"migrations/0001_create_patients.up.sql":   {Data: []byte("CREATE TABLE patients (id bigserial PRIMARY KEY)")},
+	"migrations/0001_create_patients.down.sql": {Data: []byte("DROP TABLE patients")},
+	"migrations/0002_index_patients.up.sql":    {Data: []byte("-- gh:no-transaction\nCREATE INDEX CONCURRENTLY idx ON patients (id)")},
+	"migrations/README.md":                     {Data: []byte("migrations")},
+}
+
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+var lockKey = gh.AdvisoryKey("gh:migrate")
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTable(mock)
+}
+
+func expectTable(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+}
+
+func TestNewMigrator(t *testing.T) {
+	migrator, err := migrate.NewMigrator(nil, migrations, "migrations")
+	require.NoError(t, err)
+
+	all := migrator.Migrations()
+	require.Len(t, all, 2)
+	assert.Equal(t, "1_create_patients", all[0].String())
+	assert.Equal(t, "DROP TABLE patients", all[0].Down)
+	assert.False(t, all[0].NoTransaction)
+	assert.True(t, all[1].NoTransaction)
+
+	_, err = migrate.NewMigrator(nil, fstest.MapFS{"m/0001_a.down.sql": {}}, "m")
+	assert.ErrorContains(t, err, "no up migration")
+
+	_, err = migrate.NewMigrator(nil, fstest.MapFS{"m/0001_a.sql": {}, "m/0001_b.sql": {}}, "m")
+	assert.ErrorContains(t, err, "duplicate migration version 1")
+}
+
+func TestUp(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "schema_migrations" ORDER BY version`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE patients")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "schema_migrations" ("version","name","applied_at","checksum","dirty") VALUES ($1,$2,$3,$4,$5) ON CONFLICT ("version") DO UPDATE SET`)).
+		WithArgs(1, "create_patients", sqlmock.AnyArg(), checksum("CREATE TABLE patients (id bigserial PRIMARY KEY)"), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// 0002 runs outside a transaction, marked dirty while it runs.
+	mock.ExpectExec(regexp.QuoteMeta(`ON CONFLICT ("version") DO UPDATE SET "dirty"=$6`)).
+		WithArgs(2, "index_patients", sqlmock.AnyArg(), sqlmock.AnyArg(), true, true).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX CONCURRENTLY")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "schema_migrations"`).WithArgs(2, "index_patients", sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectUnlock(mock)
+
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpOutOfOrder(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}).AddRow(2, "index_patients"))
+	expectUnlock(mock)
+
+	_, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ErrOutOfOrder)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}).AddRow(1, "create_patients").AddRow(2, "index_patients"))
+	expectUnlock(mock)
+
+	// 0002 has no down migration.
+	_, err = migrator.Down(context.Background(), 1)
+	assert.ErrorIs(t, err, migrate.ErrIrreversible)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}).AddRow(1, "create_patients"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE patients").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "schema_migrations" WHERE version = $1`)).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	rolledBack, err := migrator.Down(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, rolledBack, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegister(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	backfill := func(tx *gh.GormDB) error {
+		return tx.DB().Exec("UPDATE patients SET name = 'unknown' WHERE name IS NULL").Error
+	}
+	assert.ErrorContains(t, migrator.Register(1, "backfill_names", backfill, nil), "duplicate migration version 1")
+	require.NoError(t, migrator.Register(3, "backfill_names", backfill, nil))
+	require.NoError(t, migrator.Register(0, "seed", backfill, nil))
+
+	versions := []int64{}
+	for _, m := range migrator.Migrations() {
+		versions = append(versions, m.Version)
+	}
+	assert.Equal(t, []int64{0, 1, 2, 3}, versions)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}).AddRow(0, "seed").AddRow(1, "create_patients").AddRow(2, "index_patients"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE patients SET name = 'unknown' WHERE name IS NULL")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO "schema_migrations"`).WithArgs(3, "backfill_names", sqlmock.AnyArg(), "", false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.False(t, applied[0].Reversible())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpPrepareStmt(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// Configured like gh.PgConnect, with prepared statements.
+	db, err := gh.PgConnectWithConn(sqlDB, io.Discard, logger.Silent, nil)
+	require.NoError(t, err)
+	require.True(t, db.PrepareStmt)
+
+	script := "CREATE TABLE wards (id bigserial PRIMARY KEY);\nCREATE TABLE beds (id bigserial PRIMARY KEY)"
+	migrator, err := migrate.NewMigrator(db, fstest.MapFS{"m/0001_create_wards.up.sql": {Data: []byte(script)}}, "m")
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("CREATE TABLE IF NOT EXISTS schema_migrations").
+		ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare("ALTER TABLE schema_migrations").
+		ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare(`SELECT \* FROM "schema_migrations"`).
+		ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"version", "name"}))
+
+	// The script is executed without being prepared.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(script)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare(`INSERT INTO "schema_migrations"`) // on the pool, then on the transaction
+	mock.ExpectPrepare(`INSERT INTO "schema_migrations"`).
+		ExpectExec().WithArgs(1, "create_wards", sqlmock.AnyArg(), checksum(script), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
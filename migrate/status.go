@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"slices"
+)
+
+// MigrationStatus is the state of the migrations of a database, e.g for deploy gates and health endpoints.
+type MigrationStatus struct {
+	Current  int64             `json:"current"`  // latest applied version, 0 if none
+	Applied  []SchemaMigration `json:"applied"`  // sorted by version
+	Pending  []*Migration      `json:"pending"`  // sorted by version
+	Dirty    []SchemaMigration `json:"dirty"`    // failed migrations run outside a transaction
+	Modified []*Migration      `json:"modified"` // applied migrations edited since, by checksum
+	Missing  []SchemaMigration `json:"missing"`  // applied migrations unknown to the Migrator
+}
+
+// UpToDate reports whether all migrations are applied and none is dirty.
+func (s *MigrationStatus) UpToDate() bool {
+	return len(s.Pending) == 0 && len(s.Dirty) == 0
+}
+
+// Status returns the status of the migrations. It does not take the migration lock.
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{
+		Applied:  applied,
+		Pending:  m.pending(applied),
+		Dirty:    []SchemaMigration{},
+		Modified: m.modified(applied),
+		Missing:  []SchemaMigration{},
+	}
+
+	for _, a := range applied {
+		status.Current = a.Version
+		if a.Dirty {
+			status.Dirty = append(status.Dirty, a)
+		}
+		if m.find(a.Version) == nil {
+			status.Missing = append(status.Missing, a)
+		}
+	}
+	return status, nil
+}
+
+// modified returns the applied migrations whose checksum changed. Rows without
+// a checksum, recorded before checksums were, are not compared.
+func (m *Migrator) modified(applied []SchemaMigration) []*Migration {
+	modified := []*Migration{}
+	for _, a := range applied {
+		migration := m.find(a.Version)
+		if migration != nil && a.Checksum != "" && a.Checksum != migration.Checksum() {
+			modified = append(modified, migration)
+		}
+	}
+	return modified
+}
+
+// find returns the migration version, or nil.
+func (m *Migrator) find(version int64) *Migration {
+	idx := slices.IndexFunc(m.migrations, func(migration *Migration) bool { return migration.Version == version })
+	if idx < 0 {
+		return nil
+	}
+	return m.migrations[idx]
+}
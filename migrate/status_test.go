@@ -0,0 +1,69 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/abiiranathan/gh/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var schemaMigrationColumns = []string{"version", "name", "applied_at", "checksum", "dirty"}
+
+func TestStatus(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	expectTable(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows(schemaMigrationColumns).
+			AddRow(1, "create_patients", time.Now(), checksum("CREATE TABLE patients (id serial PRIMARY KEY)"), false).
+			AddRow(2, "index_patients", time.Now(), "", true).
+			AddRow(3, "dropped_from_branch", time.Now(), "", false))
+
+	status, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualValues(t, 3, status.Current)
+	assert.Len(t, status.Applied, 3)
+	assert.Empty(t, status.Pending)
+	require.Len(t, status.Dirty, 1)
+	assert.EqualValues(t, 2, status.Dirty[0].Version)
+	require.Len(t, status.Modified, 1)
+	assert.Equal(t, "1_create_patients", status.Modified[0].String())
+	require.Len(t, status.Missing, 1)
+	assert.Equal(t, "dropped_from_branch", status.Missing[0].Name)
+	assert.False(t, status.UpToDate())
+}
+
+func TestUpRefusesDirtyAndModified(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	migrator, err := migrate.NewMigrator(db, migrations, "migrations")
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows(schemaMigrationColumns).AddRow(1, "create_patients", time.Now(), "", true))
+	expectUnlock(mock)
+
+	_, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ErrDirty)
+
+	migrator.VerifyChecksums = true
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows(schemaMigrationColumns).AddRow(1, "create_patients", time.Now(), "edited", false))
+	expectUnlock(mock)
+
+	_, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
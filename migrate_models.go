@@ -0,0 +1,296 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrDestructiveMigration is returned by MigrateModels when the migration would drop data
+// or narrow a column type and MigrateOptions.AllowDestructive is not set.
+var ErrDestructiveMigration = errors.New("destructive migration refused")
+
+// errPlanOnly rolls back the transaction of a dry run.
+var errPlanOnly = errors.New("plan only")
+
+// SchemaChange is a DDL statement of a MigrationPlan.
+type SchemaChange struct {
+	SQL         string `json:"sql"`
+	Destructive bool   `json:"destructive"`
+	Reason      string `json:"reason,omitempty"` // why the change is destructive
+}
+
+// MigrationPlan is the DDL run (or that would be run) by MigrateModels.
+type MigrationPlan struct {
+	Changes []SchemaChange `json:"changes"`
+}
+
+// Destructive returns the changes that drop data or narrow a column type.
+func (p *MigrationPlan) Destructive() []SchemaChange {
+	changes := []SchemaChange{}
+	for _, change := range p.Changes {
+		if change.Destructive {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// String returns the statements of the plan, one per line, destructive ones flagged with a comment.
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+	for _, change := range p.Changes {
+		b.WriteString(change.SQL)
+		b.WriteByte(';')
+		if change.Destructive {
+			b.WriteString(" -- DESTRUCTIVE: " + change.Reason)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// MigrateOptions are options for MigrateModelsOpts.
+type MigrateOptions struct {
+	// AllowDestructive applies changes dropping tables or columns, or narrowing column types.
+	AllowDestructive bool
+
+	// DryRun plans the migration without applying it.
+	DryRun bool
+
+	// Output is where the plan is printed. Default: os.Stdout. Use io.Discard to silence it.
+	Output io.Writer
+}
+
+// MigrateModels is like gorm's AutoMigrate but first prints the DDL it will run and refuses
+// destructive changes, returning ErrDestructiveMigration. See MigrateModelsOpts.
+func MigrateModels(db *gorm.DB, models ...any) (*MigrationPlan, error) {
+	return MigrateModelsOpts(db, MigrateOptions{}, models...)
+}
+
+// MigrateModelsOpts runs AutoMigrate for models in a transaction, recording the DDL it executes.
+// The plan is printed and the transaction is rolled back on a dry run, or if a change is
+// destructive and not allowed; otherwise it is committed. Postgres DDL is transactional,
+// so the plan is exactly what is applied.
+func MigrateModelsOpts(db *gorm.DB, opts MigrateOptions, models ...any) (*MigrationPlan, error) {
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	plan := &MigrationPlan{Changes: []SchemaChange{}}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		types, err := currentColumnTypes(tx)
+		if err != nil {
+			return err
+		}
+
+		recorder := &ddlRecorder{Interface: tx.Logger, statements: &ddlStatements{}}
+		if err := tx.Session(&gorm.Session{Logger: recorder}).AutoMigrate(models...); err != nil {
+			return fmt.Errorf("failed to migrate models: %w", err)
+		}
+
+		for _, sql := range recorder.statements.list() {
+			plan.Changes = append(plan.Changes, classifyChange(sql, types))
+		}
+
+		if len(plan.Changes) > 0 {
+			fmt.Fprint(output, plan)
+		}
+
+		if opts.DryRun {
+			return errPlanOnly
+		}
+
+		if destructive := plan.Destructive(); len(destructive) > 0 && !opts.AllowDestructive {
+			return fmt.Errorf("%w: %s: %s", ErrDestructiveMigration, destructive[0].SQL, destructive[0].Reason)
+		}
+		return nil
+	})
+
+	if errors.Is(err, errPlanOnly) {
+		err = nil
+	}
+	return plan, err
+}
+
+// currentColumnTypes returns the types of the columns of the tables of the current schema, by table.column.
+func currentColumnTypes(db *gorm.DB) (map[string]string, error) {
+	rows := []struct {
+		Table  string
+		Column string
+		Type   string
+	}{}
+
+	err := db.Raw(`SELECT c.relname AS table, a.attname AS column, format_type(a.atttypid, a.atttypmod) AS type
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		WHERE c.relnamespace = current_schema()::regnamespace AND c.relkind IN ('r', 'p')
+			AND a.attnum > 0 AND NOT a.attisdropped`).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read column types: %w", err)
+	}
+
+	types := make(map[string]string, len(rows))
+	for _, row := range rows {
+		types[row.Table+"."+row.Column] = row.Type
+	}
+	return types, nil
+}
+
+// ddlStatements collects the DDL statements executed by a migration.
+type ddlStatements struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (s *ddlStatements) add(sql string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, sql)
+}
+
+func (s *ddlStatements) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statements
+}
+
+// ddlRecorder is a gorm logger recording the successful DDL statements.
+type ddlRecorder struct {
+	logger.Interface
+	statements *ddlStatements
+}
+
+// LogMode implements logger.Interface, recording on the new logger too.
+func (l *ddlRecorder) LogMode(level logger.LogLevel) logger.Interface {
+	return &ddlRecorder{Interface: l.Interface.LogMode(level), statements: l.statements}
+}
+
+// Trace implements logger.Interface.
+func (l *ddlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if err == nil {
+		sql, _ := fc()
+		verb, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+		switch strings.ToUpper(verb) {
+		case "CREATE", "ALTER", "DROP", "COMMENT":
+			l.statements.add(sql)
+		}
+	}
+	l.Interface.Trace(ctx, begin, fc, err)
+}
+
+var (
+	dropPattern       = regexp.MustCompile(`(?i)^DROP TABLE|^ALTER TABLE .* DROP COLUMN`)
+	alterTypePattern  = regexp.MustCompile(`(?i)^ALTER TABLE (?:"[^"]*"\.)?"?([^".]+)"? ALTER COLUMN "?([^" ]+)"? TYPE (.+?)(?: USING .*)?$`)
+	typeModifierRegex = regexp.MustCompile(`^([a-z ]+?)\s*(?:\((\d+)(?:,\s*(\d+))?\))?$`)
+)
+
+// classifyChange flags the DDL statement sql as destructive if it drops a table or column,
+// or changes a column type other than by widening it.
+func classifyChange(sql string, types map[string]string) SchemaChange {
+	change := SchemaChange{SQL: sql}
+	if dropPattern.MatchString(sql) {
+		change.Destructive, change.Reason = true, "drops data"
+		return change
+	}
+
+	if m := alterTypePattern.FindStringSubmatch(sql); m != nil {
+		from := types[m[1]+"."+m[2]]
+		if !isWideningType(from, m[3]) {
+			change.Destructive = true
+			change.Reason = fmt.Sprintf("changes the type of %s.%s from %s to %s", m[1], m[2], from, m[3])
+		}
+	}
+	return change
+}
+
+var typeAliases = map[string]string{
+	"character varying":           "varchar",
+	"character":                   "char",
+	"int":                         "integer",
+	"int2":                        "smallint",
+	"int4":                        "integer",
+	"int8":                        "bigint",
+	"decimal":                     "numeric",
+	"bool":                        "boolean",
+	"timestamp with time zone":    "timestamptz",
+	"timestamp without time zone": "timestamp",
+	"float8":                      "double precision",
+	"float4":                      "real",
+}
+
+var integerRanks = map[string]int{"smallint": 1, "integer": 2, "bigint": 3}
+
+// isWideningType reports whether a column of type from can be changed to type to without losing data.
+// An unknown from type is not a widening.
+func isWideningType(from, to string) bool {
+	fromName, fromArgs := parseColumnType(from)
+	toName, toArgs := parseColumnType(to)
+	if fromName == "" {
+		return false
+	}
+
+	switch {
+	case fromName == toName && len(toArgs) == 0:
+		return true // size limit removed, e.g varchar(50) to varchar
+	case fromName == toName && fromName == "varchar":
+		return len(fromArgs) > 0 && toArgs[0] >= fromArgs[0]
+	case fromName == toName && fromName == "numeric":
+		// Both the integer digits and the scale must not shrink.
+		return len(fromArgs) > 0 && toArgs[0]-numericScale(toArgs) >= fromArgs[0]-numericScale(fromArgs) &&
+			numericScale(toArgs) >= numericScale(fromArgs)
+	case fromName == toName:
+		return len(fromArgs) == len(toArgs) && (len(toArgs) == 0 || toArgs[0] >= fromArgs[0])
+	case (fromName == "varchar" || fromName == "char") && toName == "text":
+		return true
+	case integerRanks[fromName] > 0 && integerRanks[toName] >= integerRanks[fromName]:
+		return true
+	case integerRanks[fromName] > 0 && toName == "numeric":
+		return len(toArgs) == 0
+	case fromName == "real" && toName == "double precision":
+		return true
+	case fromName == "timestamp" && toName == "timestamptz":
+		return true
+	}
+	return false
+}
+
+func numericScale(args []int) int {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return 0
+}
+
+// parseColumnType splits a column type into its canonical name and modifiers, e.g varchar(255).
+func parseColumnType(t string) (string, []int) {
+	m := typeModifierRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(t)))
+	if m == nil {
+		return "", nil
+	}
+
+	name := m[1]
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+
+	args := []int{}
+	for _, arg := range m[2:] {
+		if arg != "" {
+			n, _ := strconv.Atoi(arg)
+			args = append(args, n)
+		}
+	}
+	return name, args
+}
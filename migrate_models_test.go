@@ -0,0 +1,124 @@
+package gh_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type migratePatient struct {
+	ID   uint
+	Name string `gorm:"size:100"`
+}
+
+// expectExistingTable expects the queries of AutoMigrate for migrate_patients with a name varchar(size) column.
+func expectExistingTable(mock sqlmock.Sqlmock, size int) {
+	varchar := fmt.Sprintf("character varying(%d)", size)
+	mock.ExpectQuery("format_type").WillReturnRows(sqlmock.NewRows([]string{"table", "column", "type"}).
+		AddRow("migrate_patients", "id", "bigint").AddRow("migrate_patients", "name", varchar))
+	mock.ExpectQuery("FROM information_schema.tables").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	columnTypes := func() {
+		mock.ExpectQuery(`SELECT CURRENT_DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"db"}).AddRow("test"))
+		mock.ExpectQuery("FROM information_schema.columns AS c").WillReturnRows(sqlmock.NewRows([]string{"column_name", "nullable",
+			"udt_name", "length", "precision", "radix", "scale", "datetime_precision", "typlen", "default", "description", "identity"}).
+			AddRow("id", false, "int8", nil, 64, 2, 0, nil, 64, "nextval('migrate_patients_id_seq'::regclass)", nil, nil).
+			AddRow("name", true, "varchar", size, nil, nil, nil, nil, -8, nil, nil, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "migrate_patients" LIMIT`)).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery("SELECT constraint_name").WillReturnRows(sqlmock.NewRows([]string{"constraint_name"}))
+		mock.ExpectQuery("SELECT c.column_name, constraint_name").WillReturnRows(sqlmock.NewRows([]string{"column_name", "constraint_name", "constraint_type"}).
+			AddRow("id", "migrate_patients_pkey", "PRIMARY KEY"))
+		mock.ExpectQuery("AS data_type").WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type"}).
+			AddRow("id", "bigint").AddRow("name", varchar))
+	}
+
+	columnTypes()
+	mock.ExpectQuery("SELECT description").WillReturnRows(sqlmock.NewRows([]string{"description"}))
+	columnTypes() // AlterColumn reads them again
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "migrate_patients" ALTER COLUMN "name" TYPE varchar(100)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrateModelsCreatesTable(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("format_type").WillReturnRows(sqlmock.NewRows([]string{"table", "column", "type"}))
+	mock.ExpectQuery("FROM information_schema.tables").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "migrate_patients"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	var out bytes.Buffer
+	plan, err := gh.MigrateModelsOpts(db, gh.MigrateOptions{Output: &out}, &migratePatient{})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, plan.Changes, 1)
+	assert.False(t, plan.Changes[0].Destructive)
+	assert.Equal(t, `CREATE TABLE "migrate_patients" ("id" bigserial,"name" varchar(100),PRIMARY KEY ("id"));`+"\n", out.String())
+}
+
+func TestMigrateModelsDryRun(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("format_type").WillReturnRows(sqlmock.NewRows([]string{"table", "column", "type"}))
+	mock.ExpectQuery("FROM information_schema.tables").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "migrate_patients"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	plan, err := gh.MigrateModelsOpts(db, gh.MigrateOptions{DryRun: true, Output: io.Discard}, &migratePatient{})
+	require.NoError(t, err)
+	assert.Len(t, plan.Changes, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateModelsRefusesNarrowing(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	expectExistingTable(mock, 255)
+	mock.ExpectRollback()
+
+	var out bytes.Buffer
+	plan, err := gh.MigrateModelsOpts(db, gh.MigrateOptions{Output: &out}, &migratePatient{})
+	assert.ErrorIs(t, err, gh.ErrDestructiveMigration)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, plan.Destructive(), 1)
+	assert.Contains(t, plan.Destructive()[0].Reason, "from character varying(255) to varchar(100)")
+	assert.Contains(t, out.String(), "-- DESTRUCTIVE")
+}
+
+func TestMigrateModelsWidening(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	expectExistingTable(mock, 50)
+	mock.ExpectCommit()
+
+	plan, err := gh.MigrateModelsOpts(db, gh.MigrateOptions{Output: io.Discard}, &migratePatient{})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Destructive())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateModelsAllowDestructive(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	expectExistingTable(mock, 255)
+	mock.ExpectCommit()
+
+	plan, err := gh.MigrateModelsOpts(db, gh.MigrateOptions{AllowDestructive: true, Output: io.Discard}, &migratePatient{})
+	require.NoError(t, err)
+	assert.Len(t, plan.Destructive(), 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,165 @@
+package gh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage is a message written to the outbox_messages table by OutboxPublish.
+// Key is a unique idempotency key: messages are delivered at least once,
+// so consumers should use it to discard duplicates.
+type OutboxMessage struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Topic       string     `gorm:"not null" json:"topic"`
+	Key         string     `gorm:"not null;uniqueIndex" json:"key"`
+	Payload     JSONB      `json:"payload"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at"` // NULL until relayed
+}
+
+// TableName implements gorm's Tabler interface.
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
+
+// OutboxPublish writes a message with payload (encoded as JSON) to the outbox.
+// Called within a Transaction, the message is only relayed if the transaction commits,
+// so state changes and the events describing them can not diverge.
+//
+// The outbox_messages table must be migrated before use:
+//
+//	db.AutoMigrate(&gh.OutboxMessage{})
+//
+//	err := gdb.Transaction(func(tx *gh.GormDB) error {
+//		if err := tx.Create(&invoice); err != nil {
+//			return err
+//		}
+//		_, err := tx.OutboxPublish("invoice.created", invoice)
+//		return err
+//	})
+func (gdb *GormDB) OutboxPublish(topic string, payload any) (*OutboxMessage, error) {
+	data, err := NewJSONB(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &OutboxMessage{Topic: topic, Key: key, Payload: data}
+	if err := gdb.db.Create(msg).Error; err != nil {
+		return nil, fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return msg, nil
+}
+
+// newIdempotencyKey returns a random 128-bit hex key.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OutboxPublisher delivers an outbox message to a broker, e.g Kafka or NATS.
+type OutboxPublisher func(ctx context.Context, msg *OutboxMessage) error
+
+// OutboxRelay polls the outbox and hands unpublished messages to an OutboxPublisher,
+// in order. Several relays can run concurrently: each message batch is locked with
+// FOR UPDATE SKIP LOCKED while it is published.
+// Delivery is at-least-once: a message is published again if the relay fails
+// before marking it published.
+type OutboxRelay struct {
+	// BatchSize is the maximum number of messages locked and published at once. Default: 100.
+	BatchSize int
+
+	// PollInterval is the interval between polls when the outbox is empty. Default: 1s.
+	PollInterval time.Duration
+
+	db      *gorm.DB
+	publish OutboxPublisher
+}
+
+// NewOutboxRelay creates an OutboxRelay publishing the messages of db's outbox with publish.
+func NewOutboxRelay(db *gorm.DB, publish OutboxPublisher) *OutboxRelay {
+	return &OutboxRelay{db: db, publish: publish}
+}
+
+// Run relays messages until ctx is canceled and returns ctx.Err().
+// Errors are logged with db's logger and the failed message is retried on the next poll.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	poll := durationOr(r.PollInterval, time.Second)
+	for {
+		n, err := r.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.db.Logger.Error(ctx, "outbox relay: %v", err)
+		}
+
+		// Keep going while full batches are published.
+		if err == nil && n == r.batchSize() {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Flush publishes a batch of unpublished messages and returns the number published.
+// It stops at the first message that fails to publish, recording the error on the message,
+// so that messages are published in order.
+func (r *OutboxRelay) Flush(ctx context.Context) (int, error) {
+	published := 0
+	var publishErr error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		messages := []OutboxMessage{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("id").
+			Limit(r.batchSize()).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			msg := &messages[i]
+			if err := r.publish(ctx, msg); err != nil {
+				// Commit the messages published so far along with the failure.
+				publishErr = fmt.Errorf("failed to publish outbox message %s: %w", msg.Key, err)
+				return tx.Model(msg).Updates(map[string]any{"attempts": msg.Attempts + 1, "last_error": err.Error()}).Error
+			}
+
+			if err := tx.Model(msg).Update("published_at", time.Now()).Error; err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+func (r *OutboxRelay) batchSize() int {
+	if r.BatchSize <= 0 {
+		return 100
+	}
+	return r.BatchSize
+}
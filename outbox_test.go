@@ -0,0 +1,65 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxPublish(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "outbox_messages" .* RETURNING "id"`).
+		WithArgs("invoice.created", sqlmock.AnyArg(), `{"id":1}`, 0, "", sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	var msg *gh.OutboxMessage
+	err := gh.WrapDB(db).Transaction(func(tx *gh.GormDB) error {
+		var err error
+		msg, err = tx.OutboxPublish("invoice.created", map[string]int{"id": 1})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Len(t, msg.Key, 32)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRelayFlush(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox_messages" WHERE published_at IS NULL ORDER BY id LIMIT \$1 FOR UPDATE SKIP LOCKED`).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "attempts"}).
+			AddRow(1, "invoice.created", "a", `{"id":1}`, 0).
+			AddRow(2, "invoice.created", "b", `{"id":2}`, 0).
+			AddRow(3, "invoice.created", "c", `{"id":3}`, 0))
+	mock.ExpectExec(`UPDATE "outbox_messages" SET "published_at"=\$1 WHERE "id" = \$2`).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "outbox_messages" SET "attempts"=\$1,"last_error"=\$2 WHERE "id" = \$3`).
+		WithArgs(1, "broker down", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var keys []string
+	relay := gh.NewOutboxRelay(db, func(ctx context.Context, msg *gh.OutboxMessage) error {
+		keys = append(keys, msg.Key)
+		if msg.ID == 2 {
+			return errors.New("broker down")
+		}
+		return nil
+	})
+
+	n, err := relay.Flush(context.Background())
+	assert.ErrorContains(t, err, "broker down")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"a", "b"}, keys) // stops at the failed message
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
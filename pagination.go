@@ -0,0 +1,405 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// CountMode selects how the total number of records is computed by GetPaginatedOpts.
+type CountMode int
+
+const (
+	// CountExact counts the records with COUNT(*).
+	CountExact CountMode = iota
+
+	// CountEstimate uses the query planner's row estimate (EXPLAIN) instead of COUNT(*).
+	// It is fast on huge tables, but Count and TotalPages are approximate.
+	CountEstimate
+
+	// CountNone skips counting. Count and TotalPages are set to -1.
+	CountNone
+)
+
+// PaginationConfig bounds client supplied pagination parameters,
+// e.g to prevent ?page_size=100000 from loading a whole table.
+type PaginationConfig struct {
+	DefaultPageSize int    // page size used when the page size is less than 1
+	MaxPageSize     int    // larger page sizes are clamped to MaxPageSize. 0 means no limit.
+	DefaultSort     string // order applied if the query has no ORDER BY, e.g "id DESC"
+}
+
+// DefaultPaginationConfig provides sensible default pagination settings.
+func DefaultPaginationConfig() PaginationConfig {
+	return PaginationConfig{
+		DefaultPageSize: 20,
+		MaxPageSize:     100,
+	}
+}
+
+// Clamp returns the page and page size adjusted to the configuration.
+func (c PaginationConfig) Clamp(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = c.DefaultPageSize
+	}
+
+	if c.MaxPageSize > 0 && pageSize > c.MaxPageSize {
+		pageSize = c.MaxPageSize
+	}
+	return page, pageSize
+}
+
+// PageOptions are options for GetPaginatedOpts.
+type PageOptions struct {
+	CountMode CountMode
+
+	// Config clamps the page size and provides a default sort. If nil, the page size is used as is.
+	Config *PaginationConfig
+
+	// Facets are columns of the model for which per-value counts are computed with the
+	// same filters as the page, in one extra grouped query. The counts are returned
+	// in Extra["facets"] as a map[string][]FacetCount.
+	Facets []string
+}
+
+// FacetCount is the number of records having Value in a facet column.
+type FacetCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// GetPaginatedOpts is like GetPaginated but accepts options.
+// For CountEstimate and CountNone, HasNext is determined by fetching one extra record.
+func GetPaginatedOpts[T any](db *gorm.DB, model *T, page int, pageSize int, opts PageOptions) (*PagedResponse[T], error) {
+	return getPaginated[T, T](db, model, page, pageSize, opts)
+}
+
+// GetPaginatedInto paginates the records of model M and returns them as DTOs of type D,
+// so list endpoints can return trimmed response structs.
+// If mapper is nil, only the columns matching the fields of D are selected and scanned into D
+// (see gorm's smart select fields). Otherwise each M is converted with mapper.
+func GetPaginatedInto[M any, D any](db *gorm.DB, page int, pageSize int, mapper func(M) D) (*PagedResponse[D], error) {
+	if mapper == nil {
+		return getPaginated[M, D](db, new(M), page, pageSize, PageOptions{})
+	}
+
+	res, err := getPaginated[M, M](db, new(M), page, pageSize, PageOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return Map(res, mapper), nil
+}
+
+// Map converts the results of a paged response with fn, keeping the page bookkeeping and Extra.
+func Map[T any, U any](resp *PagedResponse[T], fn func(T) U) *PagedResponse[U] {
+	results := make([]U, len(resp.Results))
+	for i, result := range resp.Results {
+		results[i] = fn(result)
+	}
+
+	return &PagedResponse[U]{
+		Page:           resp.Page,
+		PageSize:       resp.PageSize,
+		TotalPages:     resp.TotalPages,
+		Count:          resp.Count,
+		HasNext:        resp.HasNext,
+		HasPrev:        resp.HasPrev,
+		Results:        results,
+		CountEstimated: resp.CountEstimated,
+		Extra:          resp.Extra,
+	}
+}
+
+// SetExtra sets an endpoint specific metadata value and returns the response for chaining.
+func (resp *PagedResponse[T]) SetExtra(key string, value any) *PagedResponse[T] {
+	if resp.Extra == nil {
+		resp.Extra = map[string]any{}
+	}
+	resp.Extra[key] = value
+	return resp
+}
+
+// getPaginated paginates the records of model, scanning them into R.
+func getPaginated[M any, R any](db *gorm.DB, model *M, page int, pageSize int, opts PageOptions) (*PagedResponse[R], error) {
+	results := []R{}
+
+	if opts.Config != nil {
+		page, pageSize = opts.Config.Clamp(page, pageSize)
+		if _, sorted := db.Statement.Clauses["ORDER BY"]; !sorted && opts.Config.DefaultSort != "" {
+			db = db.Order(opts.Config.DefaultSort)
+		}
+	}
+
+	// Page must be >= 1
+	if page < 1 {
+		page = 1
+	}
+
+	// Calculate offset and limit
+	offset := (page - 1) * pageSize
+	limit := pageSize
+	if opts.CountMode != CountExact {
+		limit++
+	}
+
+	// Each query gets its own copy of the statement, so they can run concurrently.
+	base := db.Session(&gorm.Session{})
+	countQuery := base.Model(model)
+	pageQuery := base.Model(model).Offset(offset).Limit(limit)
+
+	var totalCount int64 = -1
+	count := func(ctx context.Context) error {
+		var err error
+		switch opts.CountMode {
+		case CountExact:
+			err = countQuery.WithContext(ctx).Count(&totalCount).Error
+		case CountEstimate:
+			totalCount, err = estimateCount(countQuery.WithContext(ctx), model)
+		}
+		return err
+	}
+
+	queries := []func(ctx context.Context) error{count, func(ctx context.Context) error {
+		return pageQuery.WithContext(ctx).Find(&results).Error
+	}}
+
+	var facets map[string][]FacetCount
+	if len(opts.Facets) > 0 {
+		facetQuery := base.Model(model)
+		queries = append(queries, func(ctx context.Context) (err error) {
+			facets, err = facetCounts(facetQuery.WithContext(ctx), opts.Facets)
+			return err
+		})
+	}
+
+	// Retrieve total count of records after applying options
+	if err := runPageQueries(db, queries...); err != nil {
+		return nil, err
+	}
+
+	paginatedResponse := &PagedResponse[R]{
+		Page:           page,
+		PageSize:       pageSize,
+		HasNext:        int64(page*pageSize) < totalCount,
+		HasPrev:        page > 1,
+		Results:        results,
+		Count:          totalCount,
+		TotalPages:     -1,
+		CountEstimated: opts.CountMode == CountEstimate,
+	}
+
+	if opts.CountMode != CountExact {
+		paginatedResponse.HasNext = len(results) > pageSize
+		if paginatedResponse.HasNext {
+			paginatedResponse.Results = results[:pageSize]
+		}
+
+		// The estimate can not be lower than what we have seen.
+		if seen := int64(offset + len(paginatedResponse.Results)); opts.CountMode == CountEstimate && totalCount < seen {
+			paginatedResponse.Count = seen
+		}
+	}
+
+	if paginatedResponse.Count >= 0 && pageSize > 0 {
+		paginatedResponse.TotalPages = int64(math.Ceil(float64(paginatedResponse.Count) / float64(pageSize)))
+	}
+
+	if facets != nil {
+		paginatedResponse.SetExtra("facets", facets)
+	}
+	return paginatedResponse, nil
+}
+
+// facetCounts computes the per-value counts of columns with a single GROUPING SETS query
+// using the filters of db, which must have its model set.
+func facetCounts(db *gorm.DB, columns []string) (map[string][]FacetCount, error) {
+	if err := db.Statement.Parse(db.Statement.Model); err != nil {
+		return nil, err
+	}
+
+	selects := []string{}
+	sets := []string{}
+	for i, column := range columns {
+		field := db.Statement.Schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("unknown facet column %q for %s", column, db.Statement.Table)
+		}
+
+		quoted := quoteIdent(field.DBName)
+		selects = append(selects, fmt.Sprintf("%s AS f%d, GROUPING(%s) AS g%d", quoted, i, quoted, i))
+		sets = append(sets, "("+quoted+")")
+	}
+
+	// ORDER BY of the page query may reference columns that are not grouped.
+	delete(db.Statement.Clauses, "ORDER BY")
+
+	rows := []map[string]any{}
+	err := db.Select(strings.Join(selects, ", ") + ", COUNT(*) AS count").
+		Group("GROUPING SETS (" + strings.Join(sets, ", ") + ")").
+		Order("count DESC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute facets: %w", err)
+	}
+
+	facets := make(map[string][]FacetCount, len(columns))
+	for _, column := range columns {
+		facets[column] = []FacetCount{}
+	}
+
+	for _, row := range rows {
+		for i, column := range columns {
+			// GROUPING() is 0 for the facet the row was grouped by.
+			if toInt64(row[fmt.Sprintf("g%d", i)]) == 0 {
+				facets[column] = append(facets[column], FacetCount{
+					Value: row[fmt.Sprintf("f%d", i)],
+					Count: toInt64(row["count"]),
+				})
+			}
+		}
+	}
+	return facets, nil
+}
+
+// toInt64 converts an integer returned by the driver to int64.
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// runPageQueries runs the pagination queries (count, page, ...) concurrently on separate
+// connections, or sequentially if db is inside a transaction.
+func runPageQueries(db *gorm.DB, queries ...func(ctx context.Context) error) error {
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		for _, query := range queries {
+			if err := query(db.Statement.Context); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	g, ctx := errgroup.WithContext(db.Statement.Context)
+	for _, query := range queries {
+		g.Go(func() error {
+			return query(ctx)
+		})
+	}
+	return g.Wait()
+}
+
+// estimateCount returns the planner's estimate of the number of rows returned by
+// the query built on db, without executing it.
+func estimateCount(db *gorm.DB, model any) (int64, error) {
+	tx := db.Session(&gorm.Session{DryRun: true}).Model(model).Find(model)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	stmt := tx.Statement
+
+	var plan string
+	err := db.Statement.ConnPool.QueryRowContext(db.Statement.Context,
+		"EXPLAIN (FORMAT JSON) "+stmt.SQL.String(), stmt.Vars...).Scan(&plan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate count: %w", err)
+	}
+
+	var explain []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explain); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+
+	if len(explain) == 0 {
+		return 0, fmt.Errorf("failed to parse query plan: empty plan")
+	}
+	return int64(explain[0].Plan.PlanRows), nil
+}
+
+// Paginate retrieves a paginated list of T from a GormDB chain, so filters built
+// with the wrapper don't have to be unwrapped with DB().
+//
+//	res, err := gh.Paginate[Patient](gh.WrapDB(db).ILIKE("name", q).Order("id DESC"), page, pageSize)
+func Paginate[T any](gdb *GormDB, page int, pageSize int) (*PagedResponse[T], error) {
+	return GetPaginated(gdb.db, new(T), page, pageSize)
+}
+
+// PaginateOpts is like Paginate but accepts options.
+func PaginateOpts[T any](gdb *GormDB, page int, pageSize int, opts PageOptions) (*PagedResponse[T], error) {
+	return GetPaginatedOpts(gdb.db, new(T), page, pageSize, opts)
+}
+
+// PaginateRaw paginates the results of a raw SQL query (e.g built with QueryBuilder),
+// returning the same PagedResponse envelope as model queries.
+// The query is wrapped in SELECT COUNT(*) FROM (query) for the count and LIMIT/OFFSET is appended
+// for the page, so query must not have its own LIMIT or OFFSET.
+// Placeholders in query must use the ? syntax.
+func PaginateRaw[T any](db *gorm.DB, query string, args []any, page int, pageSize int) (*PagedResponse[T], error) {
+	results := []T{}
+
+	// Page must be >= 1
+	if page < 1 {
+		page = 1
+	}
+
+	offset := (page - 1) * pageSize
+	base := db.Session(&gorm.Session{})
+
+	var totalCount int64
+	err := runPageQueries(db, func(ctx context.Context) error {
+		return base.WithContext(ctx).Raw("SELECT COUNT(*) FROM ("+query+") AS paginated", args...).Scan(&totalCount).Error
+	}, func(ctx context.Context) error {
+		pageArgs := append(append([]any{}, args...), pageSize, offset)
+		return base.WithContext(ctx).Raw(query+" LIMIT ? OFFSET ?", pageArgs...).Scan(&results).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paginatedResponse := &PagedResponse[T]{
+		Page:     page,
+		PageSize: pageSize,
+		HasNext:  int64(page*pageSize) < totalCount,
+		HasPrev:  page > 1,
+		Results:  results,
+		Count:    totalCount,
+	}
+
+	if pageSize > 0 {
+		paginatedResponse.TotalPages = int64(math.Ceil(float64(totalCount) / float64(pageSize)))
+	}
+	return paginatedResponse, nil
+}
+
+// PaginateQuery paginates the results of a QueryBuilder query. See PaginateRaw.
+func PaginateQuery[T any](db *gorm.DB, qb *QueryBuilder, page int, pageSize int) (*PagedResponse[T], error) {
+	query, args, err := qb.buildGorm()
+	if err != nil {
+		return nil, err
+	}
+	return PaginateRaw[T](db, query, args, page, pageSize)
+}
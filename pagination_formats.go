@@ -0,0 +1,130 @@
+package gh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JSONAPIResource is a resource object of a JSON:API document.
+type JSONAPIResource struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// JSONAPIDocument is a JSON:API (https://jsonapi.org) top-level document for a collection.
+type JSONAPIDocument struct {
+	Data  []JSONAPIResource `json:"data"`
+	Meta  map[string]any    `json:"meta"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// ToJSONAPI converts a paged response to a JSON:API document of the given resource type.
+// The id of each resource is taken from the "id" field of its JSON encoding and the remaining
+// fields become its attributes. If u is not nil, pagination links are generated from it
+// using the page[number] and page[size] query parameters.
+func ToJSONAPI[T any](resp *PagedResponse[T], resourceType string, u *url.URL) (*JSONAPIDocument, error) {
+	doc := &JSONAPIDocument{
+		Data: make([]JSONAPIResource, 0, len(resp.Results)),
+		Meta: map[string]any{
+			"page":        resp.Page,
+			"page_size":   resp.PageSize,
+			"total_pages": resp.TotalPages,
+			"count":       resp.Count,
+		},
+	}
+
+	for key, value := range resp.Extra {
+		doc.Meta[key] = value
+	}
+
+	for _, result := range resp.Results {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+
+		attributes := map[string]any{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&attributes); err != nil {
+			return nil, fmt.Errorf("JSON:API resources must encode to JSON objects: %w", err)
+		}
+
+		id, ok := attributes["id"]
+		if !ok || id == nil {
+			return nil, fmt.Errorf("JSON:API resource %s has no id", resourceType)
+		}
+		delete(attributes, "id")
+
+		doc.Data = append(doc.Data, JSONAPIResource{
+			Type:       resourceType,
+			ID:         fmt.Sprint(id),
+			Attributes: attributes,
+		})
+	}
+
+	if u != nil {
+		doc.Links = pageLinks(resp, u, "page[number]", "page[size]")
+	}
+	return doc, nil
+}
+
+// WriteLinkHeaders sets the RFC 5988 Link header (first, prev, next, last) and the
+// X-Total-Count header on w. Links are generated from u using the page and page_size
+// query parameters.
+func (resp *PagedResponse[T]) WriteLinkHeaders(w http.ResponseWriter, u *url.URL) {
+	links := pageLinks(resp, u, "page", "page_size")
+
+	parts := []string{}
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if link, ok := links[rel]; ok {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, link, rel))
+		}
+	}
+
+	if len(parts) > 0 {
+		w.Header().Set("Link", strings.Join(parts, ", "))
+	}
+
+	if resp.Count >= 0 {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(resp.Count, 10))
+	}
+}
+
+// pageLinks returns the self, first, prev, next and last links of a paged response.
+// last is omitted if the total number of pages is unknown.
+func pageLinks[T any](resp *PagedResponse[T], u *url.URL, pageParam, sizeParam string) map[string]string {
+	link := func(page int) string {
+		q := u.Query()
+		q.Set(pageParam, strconv.Itoa(page))
+		q.Set(sizeParam, strconv.Itoa(resp.PageSize))
+
+		copied := *u
+		copied.RawQuery = q.Encode()
+		return copied.String()
+	}
+
+	links := map[string]string{
+		"self":  link(resp.Page),
+		"first": link(1),
+	}
+
+	if resp.HasPrev {
+		links["prev"] = link(resp.Page - 1)
+	}
+
+	if resp.HasNext {
+		links["next"] = link(resp.Page + 1)
+	}
+
+	if resp.TotalPages > 0 {
+		links["last"] = link(int(resp.TotalPages))
+	}
+	return links
+}
@@ -0,0 +1,64 @@
+package gh_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type apiPatient struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func testPagedResponse() *gh.PagedResponse[apiPatient] {
+	return &gh.PagedResponse[apiPatient]{
+		Page:       2,
+		PageSize:   2,
+		TotalPages: 3,
+		Count:      5,
+		HasNext:    true,
+		HasPrev:    true,
+		Results:    []apiPatient{{ID: 3, Name: "John"}, {ID: 4, Name: "Jane"}},
+	}
+}
+
+func TestToJSONAPI(t *testing.T) {
+	u, _ := url.Parse("https://api.example.com/patients?sort=name")
+
+	doc, err := gh.ToJSONAPI(testPagedResponse(), "patients", u)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": [
+			{"type": "patients", "id": "3", "attributes": {"name": "John"}},
+			{"type": "patients", "id": "4", "attributes": {"name": "Jane"}}
+		],
+		"meta": {"page": 2, "page_size": 2, "total_pages": 3, "count": 5},
+		"links": {
+			"self": "https://api.example.com/patients?page%5Bnumber%5D=2&page%5Bsize%5D=2&sort=name",
+			"first": "https://api.example.com/patients?page%5Bnumber%5D=1&page%5Bsize%5D=2&sort=name",
+			"prev": "https://api.example.com/patients?page%5Bnumber%5D=1&page%5Bsize%5D=2&sort=name",
+			"next": "https://api.example.com/patients?page%5Bnumber%5D=3&page%5Bsize%5D=2&sort=name",
+			"last": "https://api.example.com/patients?page%5Bnumber%5D=3&page%5Bsize%5D=2&sort=name"
+		}
+	}`, string(data))
+
+	_, err = gh.ToJSONAPI(&gh.PagedResponse[string]{Results: []string{"x"}}, "strings", nil)
+	assert.Error(t, err)
+}
+
+func TestWriteLinkHeaders(t *testing.T) {
+	u, _ := url.Parse("/patients?page=2&page_size=2")
+	w := httptest.NewRecorder()
+
+	testPagedResponse().WriteLinkHeaders(w, u)
+	assert.Equal(t, `</patients?page=1&page_size=2>; rel="first", </patients?page=1&page_size=2>; rel="prev", </patients?page=3&page_size=2>; rel="next", </patients?page=3&page_size=2>; rel="last"`, w.Header().Get("Link"))
+	assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+}
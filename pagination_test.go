@@ -0,0 +1,74 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGetPaginatedCountEstimate(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	// The count and the page are queried concurrently.
+	mock.MatchExpectationsInOrder(false)
+	explain := regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM "paginated_visits" WHERE doctor = $1`)
+	query := db.Where("doctor = ?", "Dr. Smith")
+	opts := gh.PageOptions{CountMode: gh.CountEstimate}
+
+	mock.ExpectQuery(explain).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 1234}}]`))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "paginated_visits" WHERE doctor = $1 LIMIT $2`)).
+		WithArgs("Dr. Smith", 26).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doctor"}).AddRow(1, "Dr. Smith").AddRow(2, "Dr. Smith"))
+
+	res, err := gh.GetPaginatedOpts(query, &paginatedVisit{}, 1, 25, opts)
+	require.NoError(t, err)
+	assert.True(t, res.CountEstimated)
+	assert.Equal(t, int64(1234), res.Count)
+	assert.Equal(t, int64(50), res.TotalPages)
+	assert.False(t, res.HasNext)
+	assert.Len(t, res.Results, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// An estimate lower than the records already seen falls back to their exact count.
+	mock.ExpectQuery(explain).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Plan Rows": 3}}]`))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "paginated_visits" WHERE doctor = $1 LIMIT $2 OFFSET $3`)).
+		WithArgs("Dr. Smith", 26, 25).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doctor"}).AddRow(26, "Dr. Smith").AddRow(27, "Dr. Smith"))
+
+	res, err = gh.GetPaginatedOpts(query, &paginatedVisit{}, 2, 25, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(27), res.Count)
+	assert.Equal(t, int64(2), res.TotalPages)
+	assert.False(t, res.HasNext)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A plan without rows is an error.
+	mock.ExpectQuery(explain).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[]`))
+	mock.ExpectQuery(`SELECT \* FROM "paginated_visits"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doctor"}))
+
+	_, err = gh.GetPaginatedOpts(query, &paginatedVisit{}, 1, 25, opts)
+	assert.ErrorContains(t, err, "empty plan")
+}
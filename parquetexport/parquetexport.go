@@ -0,0 +1,354 @@
+// Package parquetexport streams query results into Parquet files, e.g for the data team to load
+// the operational database into object storage without a separate ETL tool.
+//
+//	f, err := os.Create("visits.parquet")
+//	...
+//	err = parquetexport.Export[Visit](f, gh.WrapDB(db).Where("created_at >= ?", since), nil)
+package parquetexport
+
+import (
+	"cmp"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Options are options for ExportOpts.
+type Options struct {
+	gh.ExportOptions
+
+	// RowGroupSize is the maximum number of rows of a row group. Default: 100000.
+	RowGroupSize int64
+
+	// Compression is the compression codec of the columns. Default: parquet.Snappy.
+	Compression compress.Codec
+}
+
+// Export streams the rows of T matching the chain to w as a Parquet file. See ExportOpts.
+func Export[T any](w io.Writer, gdb *gh.GormDB, schema *parquet.Schema) error {
+	return ExportOpts[T](w, gdb, schema, Options{})
+}
+
+// ExportOpts streams the rows of T matching the chain to w as a Parquet file, iterating over them
+// with gh.Iter. If schema is nil, it is derived from the columns of T (opts.Columns, or all of them),
+// ordered by name: integers, floats, booleans and strings map to their Parquet types, times to UTC
+// timestamps in microseconds, and numeric(p,s) columns to decimals. Other types, e.g UUIDs or JSON, are
+// written as strings of their database value. Otherwise rows are written with parquet-go's mapping of
+// the fields of T to schema, e.g parquet.SchemaOf(new(T)) with `parquet` struct tags, and opts.Columns
+// is ignored.
+func ExportOpts[T any](w io.Writer, gdb *gh.GormDB, schema *parquet.Schema, opts Options) error {
+	db := gdb.DB()
+	options := []parquet.WriterOption{
+		parquet.MaxRowsPerRowGroup(cmp.Or(opts.RowGroupSize, 100_000)),
+		parquet.Compression(cmp.Or[compress.Codec](opts.Compression, &parquet.Snappy)),
+	}
+	rows := gh.Iter[T](db.Statement.Context, db, cmp.Or(opts.BatchSize, 1000), opts.OrderBy...)
+
+	if schema != nil {
+		writer := parquet.NewGenericWriter[T](w, append(options, schema)...)
+		for row, err := range rows {
+			if err != nil {
+				return fmt.Errorf("failed to export parquet: %w", err)
+			}
+			if _, err := writer.Write([]T{row}); err != nil {
+				return fmt.Errorf("failed to write parquet: %w", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to write parquet: %w", err)
+		}
+		return nil
+	}
+
+	columns, err := columnsOf[T](db, opts.Columns)
+	if err != nil {
+		return err
+	}
+
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column.field.DBName] = column.node
+	}
+
+	writer := parquet.NewWriter(w, append(options, parquet.NewSchema(db.Statement.Table, group))...)
+	ctx := context.Background()
+	for row, err := range rows {
+		if err != nil {
+			return fmt.Errorf("failed to export parquet: %w", err)
+		}
+
+		rv := reflect.ValueOf(&row).Elem()
+		values := make(parquet.Row, len(columns))
+		for i, column := range columns {
+			value, err := column.valueOf(ctx, rv)
+			if err != nil {
+				return err
+			}
+			values[i] = value.Level(0, 0, i)
+			if !value.IsNull() && column.node.Optional() {
+				values[i] = value.Level(0, 1, i)
+			}
+		}
+
+		if _, err := writer.WriteRows([]parquet.Row{values}); err != nil {
+			return fmt.Errorf("failed to write parquet: %w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write parquet: %w", err)
+	}
+	return nil
+}
+
+// column is a column of T with its Parquet node.
+type column struct {
+	field   *schema.Field
+	node    parquet.Node
+	convert func(v reflect.Value) (parquet.Value, error) // of a non-nil value
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	bytesType     = reflect.TypeOf([]byte(nil))
+	numericPrefix = regexp.MustCompile(`(?i)^(numeric|decimal)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+)
+
+// columnsOf returns the columns of T, all of them if names is empty, ordered by name like the
+// fields of a parquet.Group.
+func columnsOf[T any](db *gorm.DB, names []string) ([]column, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("failed to parse %T: %w", *new(T), err)
+	}
+
+	fields := []*schema.Field{}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName != "" && field.Readable && len(names) == 0 {
+			fields = append(fields, field)
+		}
+	}
+	for _, name := range names {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("unknown export column %q for %s", name, stmt.Schema.Table)
+		}
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b *schema.Field) int { return strings.Compare(a.DBName, b.DBName) })
+
+	columns := make([]column, len(fields))
+	for i, field := range fields {
+		columns[i] = columnOf(field)
+	}
+	return columns, nil
+}
+
+// columnOf maps field to a Parquet column. Columns are optional, except non-pointer primary keys
+// and NOT NULL columns.
+func columnOf(field *schema.Field) column {
+	c := column{field: field}
+	t := field.FieldType
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if m := numericPrefix.FindStringSubmatch(string(field.DataType)); m != nil {
+		precision, _ := strconv.Atoi(m[2])
+		scale, _ := strconv.Atoi(m[3])
+		c.node, c.convert = decimalColumn(precision, scale)
+	} else if field.Precision > 0 && (t.Kind() == reflect.String || field.Scale > 0) {
+		c.node, c.convert = decimalColumn(field.Precision, field.Scale)
+	} else {
+		c.node, c.convert = columnOfType(t)
+	}
+
+	if field.FieldType.Kind() == reflect.Pointer || !(field.PrimaryKey || field.NotNull) {
+		c.node = parquet.Optional(c.node)
+	}
+	return c
+}
+
+func columnOfType(t reflect.Type) (parquet.Node, func(reflect.Value) (parquet.Value, error)) {
+	switch {
+	case t == timeType:
+		return parquet.Timestamp(parquet.Microsecond), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.Int64Value(v.Interface().(time.Time).UnixMicro()), nil
+		}
+	case t == bytesType:
+		return parquet.Leaf(parquet.ByteArrayType), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.ByteArrayValue(v.Bytes()), nil
+		}
+	case t.Implements(valuerType) || reflect.PointerTo(t).Implements(valuerType):
+		return parquet.String(), stringValue
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return parquet.Leaf(parquet.BooleanType), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.BooleanValue(v.Bool()), nil
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return parquet.Int(32), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.Int32Value(int32(v.Int())), nil
+		}
+	case reflect.Int, reflect.Int64:
+		return parquet.Int(64), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.Int64Value(v.Int()), nil
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return parquet.Uint(32), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.Int32Value(int32(v.Uint())), nil
+		}
+	case reflect.Uint, reflect.Uint64:
+		return parquet.Uint(64), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.Int64Value(int64(v.Uint())), nil
+		}
+	case reflect.Float32:
+		return parquet.Leaf(parquet.FloatType), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.FloatValue(float32(v.Float())), nil
+		}
+	case reflect.Float64:
+		return parquet.Leaf(parquet.DoubleType), func(v reflect.Value) (parquet.Value, error) {
+			return parquet.DoubleValue(v.Float()), nil
+		}
+	}
+	return parquet.String(), stringValue
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// stringValue converts v, or its database value if it is a driver.Valuer, to a string.
+func stringValue(v reflect.Value) (parquet.Value, error) {
+	s, err := text(v)
+	if err != nil {
+		return parquet.Value{}, err
+	}
+	return parquet.ByteArrayValue([]byte(s)), nil
+}
+
+func text(v reflect.Value) (string, error) {
+	value := v.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		value = dv
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32), nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// decimalColumn returns a DECIMAL(precision, scale) column stored as an INT64 up to 18 digits,
+// or as a fixed length two's complement big-endian integer.
+func decimalColumn(precision, scale int) (parquet.Node, func(reflect.Value) (parquet.Value, error)) {
+	size := 0
+	typ := parquet.Int64Type
+	if precision > 18 {
+		size = (precision*3322/1000 + 1 + 7) / 8 // precision*log2(10) bits, plus the sign bit
+		typ = parquet.FixedLenByteArrayType(size)
+	}
+
+	return parquet.Decimal(scale, precision, typ), func(v reflect.Value) (parquet.Value, error) {
+		s, err := text(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+
+		unscaled, ok := unscaledDecimal(s, scale)
+		if !ok {
+			return parquet.Value{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		if len(unscaled.Text(10)) > precision+1 || unscaled.Sign() >= 0 && len(unscaled.Text(10)) > precision {
+			return parquet.Value{}, fmt.Errorf("decimal %s exceeds precision %d", s, precision)
+		}
+
+		if size == 0 {
+			return parquet.Int64Value(unscaled.Int64()), nil
+		}
+		return parquet.FixedLenByteArrayValue(twosComplement(unscaled, size)), nil
+	}
+}
+
+// unscaledDecimal returns s times 10^scale, rounded half away from zero.
+func unscaledDecimal(s string, scale int) (*big.Int, bool) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, false
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Abs(m).Lsh(m, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return q, true
+}
+
+// twosComplement returns the big-endian two's complement of n in size bytes.
+func twosComplement(n *big.Int, size int) []byte {
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(size*8)), n)
+	}
+	b := make([]byte, size)
+	return n.FillBytes(b)
+}
+
+// valueOf returns the value of the column of the row rv.
+func (c column) valueOf(ctx context.Context, rv reflect.Value) (parquet.Value, error) {
+	v := c.field.ReflectValueOf(ctx, rv)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return c.null()
+		}
+		v = v.Elem()
+	}
+
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		if dv, err := valuer.Value(); err == nil && dv == nil {
+			return c.null()
+		}
+	}
+
+	value, err := c.convert(v)
+	if err != nil {
+		return parquet.Value{}, fmt.Errorf("failed to export %s: %w", c.field.DBName, err)
+	}
+	return value, nil
+}
+
+func (c column) null() (parquet.Value, error) {
+	if !c.node.Optional() {
+		return parquet.Value{}, fmt.Errorf("NULL in required column %s", c.field.DBName)
+	}
+	return parquet.NullValue(), nil
+}
@@ -0,0 +1,123 @@
+package parquetexport_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/ghtest"
+	"github.com/abiiranathan/gh/parquetexport"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type invoice struct {
+	ID        uint
+	Patient   string `gorm:"not null"`
+	Amount    string `gorm:"type:numeric(10,2)"`
+	Balance   *float64
+	Paid      bool
+	CreatedAt time.Time
+}
+
+func invoiceRows() *sqlmock.Rows {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"id", "patient", "amount", "balance", "paid", "created_at"}).
+		AddRow(1, "John", "15000.50", 200.5, true, created).
+		AddRow(2, "Jane", "-0.125", nil, false, created)
+}
+
+func TestExport(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	mock.ExpectQuery(`SELECT \* FROM "invoices" ORDER BY "invoices"."id" LIMIT \$1`).
+		WithArgs(1001).
+		WillReturnRows(invoiceRows())
+
+	var buf bytes.Buffer
+	require.NoError(t, parquetexport.Export[invoice](&buf, gh.WrapDB(db), nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), f.NumRows())
+
+	columns := map[string]string{}
+	for _, field := range f.Schema().Fields() {
+		columns[field.Name()] = field.Type().String()
+		if field.Optional() {
+			columns[field.Name()] += " optional"
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"amount":     "DECIMAL(10,2) optional",
+		"balance":    "DOUBLE optional",
+		"created_at": "TIMESTAMP(isAdjustedToUTC=true,unit=MICROS) optional",
+		"id":         "INT(64,false)",
+		"paid":       "BOOLEAN optional",
+		"patient":    "STRING",
+	}, columns)
+
+	rows := make([]parquet.Row, 2)
+	reader := parquet.NewReader(f)
+	n, err := reader.ReadRows(rows)
+	require.Equal(t, 2, n)
+	require.NoError(t, reader.Close())
+
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).UnixMicro()
+	// Columns are ordered by name: amount, balance, created_at, id, paid, patient.
+	assert.Equal(t, int64(1500050), rows[0][0].Int64())
+	assert.Equal(t, 200.5, rows[0][1].Double())
+	assert.Equal(t, created, rows[0][2].Int64())
+	assert.Equal(t, uint64(1), rows[0][3].Uint64())
+	assert.True(t, rows[0][4].Boolean())
+	assert.Equal(t, "John", rows[0][5].String())
+
+	assert.Equal(t, int64(-13), rows[1][0].Int64()) // rounded half away from zero
+	assert.True(t, rows[1][1].IsNull())
+	assert.Equal(t, "Jane", rows[1][5].String())
+}
+
+type invoiceRecord struct {
+	ID        uint      `parquet:"id"`
+	Patient   string    `parquet:"patient"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(millisecond)"`
+}
+
+func (invoiceRecord) TableName() string { return "invoices" }
+
+func TestExportSchema(t *testing.T) {
+	gdb, mock := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	mock.ExpectQuery(`SELECT \* FROM "invoices" ORDER BY "invoices"."id" LIMIT \$1`).
+		WithArgs(1001).
+		WillReturnRows(invoiceRows())
+
+	var buf bytes.Buffer
+	schema := parquet.SchemaOf(new(invoiceRecord))
+	err := parquetexport.ExportOpts[invoiceRecord](&buf, gh.WrapDB(db), schema, parquetexport.Options{
+		Compression: &parquet.Zstd,
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	records, err := parquet.Read[invoiceRecord](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, []invoiceRecord{
+		{ID: 1, Patient: "John", CreatedAt: created},
+		{ID: 2, Patient: "Jane", CreatedAt: created},
+	}, records)
+}
+
+func TestExportErrors(t *testing.T) {
+	gdb, _ := ghtest.NewMockDB(t)
+	db := gdb.DB()
+	err := parquetexport.ExportOpts[invoice](&bytes.Buffer{}, gh.WrapDB(db), nil, parquetexport.Options{
+		ExportOptions: gh.ExportOptions{Columns: []string{"missing"}},
+	})
+	assert.EqualError(t, err, `unknown export column "missing" for invoices`)
+}
@@ -0,0 +1,228 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PartitionInterval is the range covered by each partition of a Partitioning.
+type PartitionInterval string
+
+const (
+	PartitionDaily   PartitionInterval = "daily"
+	PartitionWeekly  PartitionInterval = "weekly" // weeks start on Monday
+	PartitionMonthly PartitionInterval = "monthly"
+	PartitionYearly  PartitionInterval = "yearly"
+)
+
+// Partition is a partition of a partitioned table.
+type Partition struct {
+	Name  string `json:"name"`
+	Bound string `json:"bound"` // e.g FOR VALUES FROM ('2024-05-01') TO ('2024-06-01')
+}
+
+// Partitioning manages the time range partitions of a table partitioned by range on a
+// date or timestamp column. Partitions are named after the table and the start of their range,
+// e.g visits_p2024_05 for May 2024 with monthly partitions.
+//
+// The parent table must be created partitioned, which gorm's AutoMigrate can not do:
+//
+//	CREATE TABLE visits (id bigserial, created_at timestamptz NOT NULL, ...,
+//		PRIMARY KEY (id, created_at)) PARTITION BY RANGE (created_at);
+//
+//	p := gh.Partitioning{Table: "visits", Interval: gh.PartitionMonthly, Premake: 3, Retention: 2 * 365 * 24 * time.Hour}
+//	err := p.Maintain(ctx, db, time.Now()) // e.g daily with a Scheduler
+//
+// Range bounds are dates, interpreted in the session time zone for timestamptz columns.
+type Partitioning struct {
+	Table    string // parent table, optionally schema qualified
+	Interval PartitionInterval
+
+	// Premake is the number of partitions created ahead of the current one. Default: 3.
+	Premake int
+
+	// Retention is the age after which partitions are removed by ApplyRetention:
+	// a partition is removed once its whole range is older than Retention. 0 keeps all partitions.
+	Retention time.Duration
+
+	// Detach detaches old partitions instead of dropping them, e.g to archive them.
+	Detach bool
+}
+
+// layout returns the time layout of the partition name suffix.
+func (p Partitioning) layout() (string, error) {
+	switch p.Interval {
+	case PartitionDaily, PartitionWeekly:
+		return "2006_01_02", nil
+	case PartitionMonthly:
+		return "2006_01", nil
+	case PartitionYearly:
+		return "2006", nil
+	}
+	return "", fmt.Errorf("invalid partition interval %q", p.Interval)
+}
+
+// rangeOf returns the range [start, end) of the partition containing t.
+func (p Partitioning) rangeOf(t time.Time) (time.Time, time.Time) {
+	y, m, d := t.Date()
+	switch p.Interval {
+	case PartitionDaily:
+		start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	case PartitionWeekly:
+		start := time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 7)
+	case PartitionMonthly:
+		start := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	}
+}
+
+// PartitionName returns the name of the partition containing t.
+func (p Partitioning) PartitionName(t time.Time) (string, error) {
+	layout, err := p.layout()
+	if err != nil {
+		return "", err
+	}
+
+	start, _ := p.rangeOf(t)
+	return p.Table + "_p" + start.Format(layout), nil
+}
+
+// CreatePartition creates the partition containing t if it does not exist.
+func (p Partitioning) CreatePartition(ctx context.Context, db *gorm.DB, t time.Time) error {
+	name, err := p.PartitionName(t)
+	if err != nil {
+		return err
+	}
+
+	start, end := p.rangeOf(t)
+	return p.exec(ctx, db, "CREATE TABLE IF NOT EXISTS "+quoteIdent(name)+" PARTITION OF "+quoteIdent(p.Table)+
+		" FOR VALUES FROM ("+quoteLiteral(start.Format(time.DateOnly))+") TO ("+quoteLiteral(end.Format(time.DateOnly))+")")
+}
+
+// EnsurePartitions creates the partition containing now and the Premake following ones.
+func (p Partitioning) EnsurePartitions(ctx context.Context, db *gorm.DB, now time.Time) error {
+	premake := p.Premake
+	if premake <= 0 {
+		premake = 3
+	}
+
+	t := now
+	for i := 0; i <= premake; i++ {
+		if err := p.CreatePartition(ctx, db, t); err != nil {
+			return err
+		}
+		_, t = p.rangeOf(t)
+	}
+	return nil
+}
+
+// Partitions returns the partitions of the table, ordered by name.
+func (p Partitioning) Partitions(ctx context.Context, db *gorm.DB) ([]Partition, error) {
+	partitions := []Partition{}
+	err := db.WithContext(ctx).Raw(`SELECT c.relname AS name, pg_get_expr(c.relpartbound, c.oid) AS bound
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = ?::regclass ORDER BY c.relname`, quoteIdent(p.Table)).Scan(&partitions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", p.Table, err)
+	}
+	return partitions, nil
+}
+
+// AttachPartition attaches the existing table name as the partition containing t.
+func (p Partitioning) AttachPartition(ctx context.Context, db *gorm.DB, name string, t time.Time) error {
+	if _, err := p.layout(); err != nil {
+		return err
+	}
+
+	start, end := p.rangeOf(t)
+	return p.exec(ctx, db, "ALTER TABLE "+quoteIdent(p.Table)+" ATTACH PARTITION "+quoteIdent(name)+
+		" FOR VALUES FROM ("+quoteLiteral(start.Format(time.DateOnly))+") TO ("+quoteLiteral(end.Format(time.DateOnly))+")")
+}
+
+// DetachPartition detaches the partition name, which becomes a standalone table.
+// The partition name is unqualified: it is in the schema of the table.
+func (p Partitioning) DetachPartition(ctx context.Context, db *gorm.DB, name string) error {
+	return p.exec(ctx, db, "ALTER TABLE "+quoteIdent(p.Table)+" DETACH PARTITION "+p.qualify(name))
+}
+
+// ApplyRetention drops (or detaches if Detach is set) the partitions whose whole range
+// is older than Retention, and returns their names. Only partitions named by this
+// Partitioning are considered.
+func (p Partitioning) ApplyRetention(ctx context.Context, db *gorm.DB, now time.Time) ([]string, error) {
+	removed := []string{}
+	if p.Retention <= 0 {
+		return removed, nil
+	}
+
+	layout, err := p.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := p.Partitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.Add(-p.Retention)
+	prefix := p.Table[strings.LastIndex(p.Table, ".")+1:] + "_p"
+	for _, partition := range partitions {
+		suffix, ok := strings.CutPrefix(partition.Name, prefix)
+		if !ok {
+			continue
+		}
+
+		start, err := time.Parse(layout, suffix)
+		if err != nil {
+			continue
+		}
+
+		if _, end := p.rangeOf(start); end.After(cutoff) {
+			continue
+		}
+
+		if p.Detach {
+			err = p.DetachPartition(ctx, db, partition.Name)
+		} else {
+			err = p.exec(ctx, db, "DROP TABLE IF EXISTS "+p.qualify(partition.Name))
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, partition.Name)
+	}
+	return removed, nil
+}
+
+// Maintain creates the upcoming partitions and applies the retention policy.
+// It returns the names of the removed partitions.
+func (p Partitioning) Maintain(ctx context.Context, db *gorm.DB, now time.Time) ([]string, error) {
+	if err := p.EnsurePartitions(ctx, db, now); err != nil {
+		return nil, err
+	}
+	return p.ApplyRetention(ctx, db, now)
+}
+
+// qualify quotes the partition name with the schema of the table.
+func (p Partitioning) qualify(name string) string {
+	if i := strings.LastIndex(p.Table, "."); i >= 0 {
+		return quoteIdent(p.Table[:i]) + "." + quoteName(name)
+	}
+	return quoteName(name)
+}
+
+func (p Partitioning) exec(ctx context.Context, db *gorm.DB, sql string) error {
+	if err := db.WithContext(ctx).Exec(sql).Error; err != nil {
+		return fmt.Errorf("partitioning %s: %w", p.Table, err)
+	}
+	return nil
+}
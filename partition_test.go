@@ -0,0 +1,71 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionName(t *testing.T) {
+	at := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		interval gh.PartitionInterval
+		want     string
+	}{
+		{gh.PartitionDaily, "visits_p2024_05_15"},
+		{gh.PartitionWeekly, "visits_p2024_05_13"},
+		{gh.PartitionMonthly, "visits_p2024_05"},
+		{gh.PartitionYearly, "visits_p2024"},
+	}
+
+	for _, tt := range tests {
+		name, err := gh.Partitioning{Table: "visits", Interval: tt.interval}.PartitionName(at)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, name)
+	}
+
+	_, err := gh.Partitioning{Table: "visits", Interval: "hourly"}.PartitionName(at)
+	assert.Error(t, err)
+}
+
+func TestPartitioningMaintain(t *testing.T) {
+	db, mock := mockDB(t)
+	p := gh.Partitioning{Table: "public.visits", Interval: gh.PartitionMonthly, Premake: 1, Retention: 365 * 24 * time.Hour}
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "public"."visits_p2024_12" PARTITION OF "public"."visits" ` +
+		`FOR VALUES FROM ('2024-12-01') TO ('2025-01-01')`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "public"."visits_p2025_01" PARTITION OF "public"."visits" ` +
+		`FOR VALUES FROM ('2025-01-01') TO ('2025-02-01')`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT c.relname AS name`).WithArgs(`"public"."visits"`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "bound"}).
+			AddRow("visits_default", "DEFAULT").
+			AddRow("visits_p2023_11", "").
+			AddRow("visits_p2023_12", "").
+			AddRow("visits_p2024_01", ""))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "public"."visits_p2023_11"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	removed, err := p.Maintain(context.Background(), db, time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"visits_p2023_11"}, removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitioningDetach(t *testing.T) {
+	db, mock := mockDB(t)
+	p := gh.Partitioning{Table: "visits", Interval: gh.PartitionYearly}
+
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "visits" DETACH PARTITION "visits_p2020"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "visits" ATTACH PARTITION "archive"."visits_2020" FOR VALUES FROM ('2020-01-01') TO ('2021-01-01')`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	assert.NoError(t, p.DetachPartition(ctx, db, "visits_p2020"))
+	assert.NoError(t, p.AttachPartition(ctx, db, "archive.visits_2020", time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,63 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pivot builds a spreadsheet-style crosstab report from the chain (which must have a Model or Table):
+// one row per distinct value of rowColumn and one column per pivot value, holding the aggregate
+// valueExpr (e.g "SUM(total_amount)") of the matching rows, using FILTER (WHERE pivotColumn = value).
+//
+// If values is empty, the pivot values are discovered with SELECT DISTINCT pivotColumn.
+// Pivot columns are named after their values and are nil when no row matches.
+//
+//	// income per doctor per month
+//	report, err := gh.WrapDB(db.Table("income").Where("date >= ?", from)).
+//		Pivot(ctx, "doctor", "TO_CHAR(date, 'YYYY-MM')", "SUM(total_amount)")
+func (gdb *GormDB) Pivot(ctx context.Context, rowColumn, pivotColumn, valueExpr string, values ...any) ([]map[string]any, error) {
+	db := gdb.db.WithContext(ctx).Session(&gorm.Session{})
+
+	if len(values) == 0 {
+		if err := db.Distinct(pivotColumn).Order(pivotColumn).Pluck(pivotColumn, &values).Error; err != nil {
+			return nil, fmt.Errorf("failed to discover pivot values: %w", err)
+		}
+	}
+
+	columns := []string{rowColumn}
+	args := make([]any, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			columns = append(columns, fmt.Sprintf("%s FILTER (WHERE %s IS NULL) AS %s", valueExpr, pivotColumn, quoteName("null")))
+			continue
+		}
+
+		columns = append(columns, fmt.Sprintf("%s FILTER (WHERE %s = ?) AS %s", valueExpr, pivotColumn, quoteName(pivotName(value))))
+		args = append(args, value)
+	}
+
+	rows, err := db.Select(strings.Join(columns, ", "), args...).Group(rowColumn).Order(rowColumn).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMaps(rows)
+}
+
+// pivotName returns the column name of a pivot value.
+func pivotName(value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
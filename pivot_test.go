@@ -0,0 +1,33 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPivot(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT month FROM "income" WHERE year = $1 ORDER BY month`)).
+		WithArgs(2024).
+		WillReturnRows(sqlmock.NewRows([]string{"month"}).AddRow("2024-01").AddRow("2024-02"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT doctor, SUM(total_amount) FILTER (WHERE month = $1) AS "2024-01", SUM(total_amount) FILTER (WHERE month = $2) AS "2024-02" FROM "income" WHERE year = $3 GROUP BY "doctor" ORDER BY doctor`)).
+		WithArgs("2024-01", "2024-02", 2024).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("doctor").OfType("TEXT", ""),
+			sqlmock.NewColumn("2024-01").OfType("INT8", ""),
+			sqlmock.NewColumn("2024-02").OfType("INT8", ""),
+		).AddRow("Dr. Smith", int64(100), nil))
+
+	report, err := gh.WrapDB(db.Table("income").Where("year = ?", 2024)).
+		Pivot(context.Background(), "doctor", "month", "SUM(total_amount)")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"doctor": "Dr. Smith", "2024-01": int64(100), "2024-02": nil}}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
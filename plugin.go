@@ -0,0 +1,22 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrPluginNotRegistered is returned by chain methods whose callbacks are registered by a plugin
+// that was not registered with db.Use.
+var ErrPluginNotRegistered = errors.New("plugin not registered")
+
+// requirePlugin returns ErrPluginNotRegistered if the plugin name was not registered on db.
+// Callbacks are registered once at setup, with db.Use, since registering them while other
+// goroutines run statements is a data race.
+func requirePlugin(db *gorm.DB, name, use string) error {
+	if _, ok := db.Config.Plugins[name]; !ok {
+		return fmt.Errorf("%w: %s, register it with %s", ErrPluginNotRegistered, name, use)
+	}
+	return nil
+}
@@ -1,14 +1,174 @@
 package gh
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
 )
 
+// ErrInvalidQuery is returned by Build when clauses were combined in an impossible way.
+var ErrInvalidQuery = errors.New("invalid query")
+
 // QueryBuilder wraps the logic for building dynamic queries for GORM
 // that need to be execute by the db.Raw() method.
+//
+// Clauses are tracked separately and assembled in SQL order by Build,
+// so they can be added in any order.
 type QueryBuilder struct {
 	query string        // Initial query
-	args  []interface{} // Arguments
+	args  []interface{} // Arguments of the initial query
+
+	selects    []string   // Expressions appended to the select list
+	ctes       []fragment // Common table expressions: name AS (query)
+	recursive  bool       // Whether WITH RECURSIVE is used
+	joins      []fragment
+	conditions []fragment
+	groupBy    []string
+	having     []fragment
+	hasUnion   bool // Whether the query has been combined with UNION
+	orderBy    []string
+	limit      int
+	offset     int
+
+	params  map[string]interface{} // Named parameters resolved by Build
+	dialect Dialect                // Placeholder style of Build
+	err     error                  // First error, returned by Build
+}
+
+// fragment is a piece of SQL with its arguments. op joins it to the previous fragment.
+type fragment struct {
+	op   string
+	sql  string
+	args []interface{}
+}
+
+// Dialect selects the placeholder style of the query returned by Build.
+type Dialect int
+
+const (
+	// DialectGorm keeps ? placeholders, for db.Raw and db.Exec. This is the default.
+	// The jsonb ? operator must be escaped as ??: it is returned with the ?| and ?& operators
+	// as an argument that gorm writes verbatim.
+	DialectGorm Dialect = iota
+
+	// DialectPostgres numbers placeholders $1..$n, for pgx and database/sql.
+	// The jsonb ? operator must be escaped as ??, e.g "data ?? 'key'".
+	DialectPostgres
+)
+
+// rebind converts the ? placeholders of query to the placeholder style of d, and the ?? escapes
+// to the ? operator. Placeholders in single-quoted string literals are left untouched.
+//
+// gorm binds every ? of a raw query to the next argument, so for DialectGorm the ? that are not
+// placeholders (the ? operator, ?| and ?&, and ? in string literals) are returned as arguments
+// writing a literal ?.
+func (d Dialect) rebind(query string, args []interface{}) (string, []interface{}) {
+	if !strings.Contains(query, "?") {
+		return query, args
+	}
+
+	if d == DialectPostgres {
+		query, _ = placeholders(query, func(n int) string { return "$" + strconv.Itoa(n) })
+		return query, args
+	}
+
+	var b strings.Builder
+	bound := make([]interface{}, 0, len(args))
+	next := 0
+	literal := clause.Expr{SQL: "?"}
+	tokenize(query, func(kind queryToken, s string) {
+		switch kind {
+		case tokenPlaceholder:
+			b.WriteByte('?')
+			if next < len(args) {
+				bound = append(bound, args[next])
+				next++
+			}
+		case tokenEscape:
+			b.WriteByte('?')
+			bound = append(bound, literal)
+		default:
+			b.WriteString(s)
+			for range strings.Count(s, "?") {
+				bound = append(bound, literal)
+			}
+		}
+	})
+	return b.String(), append(bound, args[next:]...)
+}
+
+// placeholders replaces the ? placeholders of query with placeholder(n), numbered from 1, and
+// returns their count. ?? is replaced with a literal ?, e.g for the jsonb operator "data ?? 'key'".
+// The jsonb operators ?| and ?&, and ? in single-quoted string literals, are not placeholders.
+func placeholders(query string, placeholder func(n int) string) (string, int) {
+	var b strings.Builder
+	n := 0
+	tokenize(query, func(kind queryToken, s string) {
+		switch kind {
+		case tokenEscape:
+			b.WriteByte('?')
+		case tokenPlaceholder:
+			n++
+			b.WriteString(placeholder(n))
+		default:
+			b.WriteString(s)
+		}
+	})
+	return b.String(), n
+}
+
+// queryToken is the kind of a token of a query, see tokenize.
+type queryToken int
+
+const (
+	tokenText        queryToken = iota // SQL text, including the jsonb operators ?| and ?&
+	tokenLiteral                       // single-quoted string literal
+	tokenEscape                        // ??, the escaped jsonb ? operator
+	tokenPlaceholder                   // ? placeholder
+)
+
+// tokenize splits query into text, string literals, ?? escapes and ? placeholders and calls fn
+// with each token in order.
+func tokenize(query string, fn func(kind queryToken, s string)) {
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			fn(tokenText, query[start:end])
+		}
+	}
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			flush(i)
+			end := len(query)
+			if j := strings.IndexByte(query[i+1:], '\''); j >= 0 {
+				end = i + j + 2
+			}
+			fn(tokenLiteral, query[i:end])
+			i, start = end-1, end
+		case c != '?':
+		case i+1 < len(query) && query[i+1] == '?':
+			flush(i)
+			fn(tokenEscape, "??")
+			i, start = i+1, i+2
+		case i+1 < len(query) && (query[i+1] == '|' || query[i+1] == '&'):
+		default:
+			flush(i)
+			fn(tokenPlaceholder, "?")
+			start = i + 1
+		}
+	}
+	flush(len(query))
 }
 
 // NewQueryBuilder creates a new instance of the QueryBuilder.
@@ -40,46 +200,685 @@ func NewQueryBuilder(baseQuery string) *QueryBuilder {
 	}
 }
 
+// NewQueryBuilderFrom creates a QueryBuilder selecting columns from the subquery sub,
+// i.e "SELECT columns FROM (sub) AS alias", with the arguments of sub.
+func NewQueryBuilderFrom(columns string, sub *QueryBuilder, alias string) *QueryBuilder {
+	query, args, err := sub.build()
+	return &QueryBuilder{
+		query: "SELECT " + columns + " FROM (" + query + ") AS " + alias,
+		args:  append([]interface{}{}, args...),
+		err:   err,
+	}
+}
+
+// Clone returns a copy of the builder that can be modified without affecting qb,
+// e.g to branch a base report query per filter combination.
+func (qb *QueryBuilder) Clone() *QueryBuilder {
+	clone := *qb
+	clone.args = slices.Clone(qb.args)
+	clone.selects = slices.Clone(qb.selects)
+	clone.ctes = slices.Clone(qb.ctes)
+	clone.joins = slices.Clone(qb.joins)
+	clone.conditions = slices.Clone(qb.conditions)
+	clone.groupBy = slices.Clone(qb.groupBy)
+	clone.having = slices.Clone(qb.having)
+	clone.orderBy = slices.Clone(qb.orderBy)
+	clone.params = maps.Clone(qb.params)
+	return &clone
+}
+
+// Apply calls each fn with the builder, for table-driven query assembly.
+//
+//	filters := []func(*QueryBuilder){byDoctor(doctor), byPeriod(from, to)}
+//	qb := base.Clone().Apply(filters...)
+func (qb *QueryBuilder) Apply(fns ...func(*QueryBuilder)) *QueryBuilder {
+	for _, fn := range fns {
+		fn(qb)
+	}
+	return qb
+}
+
+// addError records the first error, returned by Build.
+func (qb *QueryBuilder) addError(err error) {
+	if qb.err == nil {
+		qb.err = err
+	}
+}
+
+// afterUnion records an error if clause is added after Union,
+// since it would apply to the last query only.
+func (qb *QueryBuilder) afterUnion(clause string) bool {
+	if qb.hasUnion {
+		qb.addError(fmt.Errorf("%w: %s can not follow UNION", ErrInvalidQuery, clause))
+	}
+	return qb.hasUnion
+}
+
 // Where adds a where condition. Takes care of appending AND if more that one call
 // has been made.
 // Note that if value == "", the where condition is ignored.
 func (qb *QueryBuilder) Where(condition string, value ...interface{}) *QueryBuilder {
 	if len(value) > 0 {
 		// If its an empty string, do nothing.
-		if len(value) == 1 {
-			if str, ok := value[0].(string); ok && str == "" {
-				return qb
-			}
+		if isEmptyValue(value) {
+			return qb
 		}
 
-		if len(qb.args) > 0 {
-			qb.query += " AND " + condition
-		} else {
-			qb.query += " WHERE " + condition
-		}
-		qb.args = append(qb.args, value...)
+		qb.where(condition, value)
 	}
 
 	return qb
 }
 
-// GroupBy adds a GROUP BY clause. Must be called after where to generate a proper query.
+// WhereArgs adds a where condition with any number of arguments, e.g "date BETWEEN ? AND ?".
+// Unlike Where, the condition is never skipped.
+func (qb *QueryBuilder) WhereArgs(condition string, args ...interface{}) *QueryBuilder {
+	return qb.where(condition, args)
+}
+
+// WhereRaw adds a where condition without arguments, e.g "deleted_at IS NULL".
+func (qb *QueryBuilder) WhereRaw(condition string) *QueryBuilder {
+	return qb.where(condition, nil)
+}
+
+// WhereIf adds the where condition only if cond is true.
+func (qb *QueryBuilder) WhereIf(cond bool, condition string, args ...interface{}) *QueryBuilder {
+	if !cond {
+		return qb
+	}
+	return qb.where(condition, args)
+}
+
+// OrWhere adds a where condition joined with OR to the previous conditions.
+// Use Group to control precedence. Like Where, the condition is ignored if value == "".
+func (qb *QueryBuilder) OrWhere(condition string, value ...interface{}) *QueryBuilder {
+	if len(value) > 0 && isEmptyValue(value) {
+		return qb
+	}
+	return qb.whereOp("OR", condition, value)
+}
+
+// Group adds the conditions added by fn as a parenthesized expression joined with AND.
+// Nothing is added if fn adds no conditions.
+//
+//	qb.Group(func(g *QueryBuilder) {
+//		g.Where("doctor = ?", a).OrWhere("doctor = ?", b)
+//	}).Where("status = ?", "paid") // WHERE (doctor = ? OR doctor = ?) AND status = ?
+func (qb *QueryBuilder) Group(fn func(*QueryBuilder)) *QueryBuilder {
+	return qb.group("AND", fn)
+}
+
+// OrGroup is like Group but joins the parenthesized expression with OR.
+func (qb *QueryBuilder) OrGroup(fn func(*QueryBuilder)) *QueryBuilder {
+	return qb.group("OR", fn)
+}
+
+func (qb *QueryBuilder) group(op string, fn func(*QueryBuilder)) *QueryBuilder {
+	sub := NewQueryBuilder("")
+	fn(sub)
+	if sub.err != nil {
+		qb.addError(sub.err)
+	}
+
+	if len(sub.conditions) == 0 {
+		return qb
+	}
+
+	condition, args := joinFragments(sub.conditions, "")
+	return qb.whereOp(op, "("+condition+")", args)
+}
+
+// WhereIn adds a "column IN (?, ?, ...)" condition with one placeholder per value.
+// The condition is ignored if values is empty.
+func (qb *QueryBuilder) WhereIn(column string, values []interface{}) *QueryBuilder {
+	if len(values) == 0 {
+		return qb
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return qb.where(column+" IN ("+placeholders+")", values)
+}
+
+// WhereInSub adds a "column IN (subquery)" condition with the arguments of sub.
+func (qb *QueryBuilder) WhereInSub(column string, sub *QueryBuilder) *QueryBuilder {
+	return qb.where(column+" IN ?", []interface{}{sub})
+}
+
+// where appends condition with WHERE or AND.
+func (qb *QueryBuilder) where(condition string, args []interface{}) *QueryBuilder {
+	return qb.whereOp("AND", condition, args)
+}
+
+// whereOp appends condition with WHERE or the given logical operator.
+func (qb *QueryBuilder) whereOp(op, condition string, args []interface{}) *QueryBuilder {
+	if qb.afterUnion("WHERE") {
+		return qb
+	}
+
+	condition, args = qb.spliceSubqueries(condition, args)
+	qb.conditions = append(qb.conditions, fragment{op: op, sql: condition, args: args})
+	return qb
+}
+
+// Join adds an INNER JOIN clause on table with the given ON condition and its arguments.
+func (qb *QueryBuilder) Join(table, onCondition string, args ...interface{}) *QueryBuilder {
+	return qb.join("JOIN", table, onCondition, args)
+}
+
+// LeftJoin adds a LEFT JOIN clause.
+func (qb *QueryBuilder) LeftJoin(table, onCondition string, args ...interface{}) *QueryBuilder {
+	return qb.join("LEFT JOIN", table, onCondition, args)
+}
+
+// RightJoin adds a RIGHT JOIN clause.
+func (qb *QueryBuilder) RightJoin(table, onCondition string, args ...interface{}) *QueryBuilder {
+	return qb.join("RIGHT JOIN", table, onCondition, args)
+}
+
+func (qb *QueryBuilder) join(kind, table, onCondition string, args []interface{}) *QueryBuilder {
+	if qb.afterUnion(kind) {
+		return qb
+	}
+
+	onCondition, args = qb.spliceSubqueries(onCondition, args)
+	qb.joins = append(qb.joins, fragment{op: kind, sql: table + " ON " + onCondition, args: args})
+	return qb
+}
+
+// GroupBy adds a GROUP BY clause.
 func (qb *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
-	if len(columns) > 0 {
-		qb.query += " GROUP BY " + strings.Join(columns, ", ")
+	if len(columns) > 0 && !qb.afterUnion("GROUP BY") {
+		qb.groupBy = append(qb.groupBy, columns...)
+	}
+	return qb
+}
+
+// Having adds a HAVING condition, joined with AND if more than one call has been made.
+// Like Where, the condition is ignored if value == "".
+func (qb *QueryBuilder) Having(condition string, value ...interface{}) *QueryBuilder {
+	if len(value) > 0 {
+		if isEmptyValue(value) || qb.afterUnion("HAVING") {
+			return qb
+		}
+
+		condition, value = qb.spliceSubqueries(condition, value)
+		qb.having = append(qb.having, fragment{op: "AND", sql: condition, args: value})
 	}
 	return qb
 }
 
-// OrderBy adds an ORDER BY clause. Must be called after Where and/or GroupBy
+// spliceSubqueries replaces the placeholders whose argument is a *QueryBuilder
+// with the parenthesized subquery and splices its arguments in their place.
+func (qb *QueryBuilder) spliceSubqueries(condition string, args []interface{}) (string, []interface{}) {
+	hasSub := false
+	for _, arg := range args {
+		if _, ok := arg.(*QueryBuilder); ok {
+			hasSub = true
+			break
+		}
+	}
+
+	if !hasSub {
+		return condition, args
+	}
+
+	var b strings.Builder
+	spliced := make([]interface{}, 0, len(args))
+	next := 0
+	tokenize(condition, func(kind queryToken, s string) {
+		if kind != tokenPlaceholder || next >= len(args) {
+			b.WriteString(s)
+			return
+		}
+
+		if sub, ok := args[next].(*QueryBuilder); ok {
+			query, subArgs, err := sub.build()
+			if err != nil {
+				qb.addError(err)
+			}
+			b.WriteString("(" + query + ")")
+			spliced = append(spliced, subArgs...)
+		} else {
+			b.WriteByte('?')
+			spliced = append(spliced, args[next])
+		}
+		next++
+	})
+	return b.String(), append(spliced, args[next:]...)
+}
+
+// isEmptyValue reports whether value is a single empty string.
+func isEmptyValue(value []interface{}) bool {
+	if len(value) == 1 {
+		if str, ok := value[0].(string); ok && str == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectWindow appends a window function expression to the select list of the initial query:
+// "expr OVER (PARTITION BY partitionBy ORDER BY orderBy) AS alias".
+// partitionBy and orderBy are optional.
+//
+//	qb.SelectWindow("SUM(total_amount)", "doctor", "date", "running_total").
+//		SelectWindow("ROW_NUMBER()", "doctor", "total_amount DESC", "rank")
+func (qb *QueryBuilder) SelectWindow(expr, partitionBy, orderBy, alias string) *QueryBuilder {
+	if qb.afterUnion("SELECT") {
+		return qb
+	}
+
+	var over []string
+	if partitionBy != "" {
+		over = append(over, "PARTITION BY "+partitionBy)
+	}
+	if orderBy != "" {
+		over = append(over, "ORDER BY "+orderBy)
+	}
+
+	qb.selects = append(qb.selects, expr+" OVER ("+strings.Join(over, " ")+") AS "+alias)
+	return qb
+}
+
+// fromIndex returns the index of the top-level FROM keyword of query, or -1.
+func fromIndex(query string) int {
+	depth := 0
+	inLiteral := false
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'':
+			inLiteral = !inLiteral
+		case inLiteral:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && i > 0 && isSpace(query[i-1]) && len(query) >= i+4 &&
+			strings.EqualFold(query[i:i+4], "FROM") && (len(query) == i+4 || isSpace(query[i+4])):
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t' || c == '\r'
+}
+
+// OrderBy adds an ORDER BY clause. After Union, it orders the combined result.
 func (qb *QueryBuilder) OrderBy(columns ...string) *QueryBuilder {
-	if len(columns) > 0 {
-		qb.query += " ORDER BY " + strings.Join(columns, ", ")
+	qb.orderBy = append(qb.orderBy, columns...)
+	return qb
+}
+
+// Limit adds a LIMIT clause. It is skipped if n is zero. After Union, it limits the combined result.
+func (qb *QueryBuilder) Limit(n int) *QueryBuilder {
+	if n < 0 {
+		qb.addError(fmt.Errorf("%w: negative LIMIT %d", ErrInvalidQuery, n))
+	}
+	qb.limit = n
+	return qb
+}
+
+// Offset adds an OFFSET clause. It is skipped if n is zero.
+func (qb *QueryBuilder) Offset(n int) *QueryBuilder {
+	if n < 0 {
+		qb.addError(fmt.Errorf("%w: negative OFFSET %d", ErrInvalidQuery, n))
+	}
+	qb.offset = n
+	return qb
+}
+
+// Bind sets the values of the :name placeholders of the query, which Build resolves
+// to positional ? placeholders lined up with the other arguments.
+// Casts (::type) and single-quoted string literals are left untouched.
+// Build returns an error if a placeholder has no value in params.
+//
+//	qb := NewQueryBuilder("SELECT * FROM visits").WhereArgs("date >= :from AND date < :to")
+//	qb.Where("doctor = ?", doctor).Bind(map[string]interface{}{"from": from, "to": to})
+func (qb *QueryBuilder) Bind(params map[string]interface{}) *QueryBuilder {
+	if qb.params == nil {
+		qb.params = map[string]interface{}{}
+	}
+
+	for name, value := range params {
+		qb.params[name] = value
+	}
+	return qb
+}
+
+// bindNamed resolves the :name placeholders of query to ? placeholders.
+func bindNamed(query string, queryArgs []interface{}, params map[string]interface{}) (string, []interface{}, error) {
+	var b strings.Builder
+	args := make([]interface{}, 0, len(queryArgs))
+	next := 0
+
+	var err error
+	tokenize(query, func(kind queryToken, s string) {
+		switch kind {
+		case tokenPlaceholder:
+			if next < len(queryArgs) {
+				args = append(args, queryArgs[next])
+				next++
+			}
+			b.WriteString(s)
+		case tokenText:
+			for i := 0; i < len(s) && err == nil; i++ {
+				c := s[i]
+				switch {
+				case c == ':' && i+1 < len(s) && s[i+1] == ':':
+					b.WriteString("::")
+					i++
+				case c == ':' && i+1 < len(s) && isIdentStart(s[i+1]):
+					end := i + 1
+					for end < len(s) && isIdentChar(s[end]) {
+						end++
+					}
+
+					name := s[i+1 : end]
+					value, ok := params[name]
+					if !ok {
+						err = fmt.Errorf("missing value for named parameter :%s", name)
+						return
+					}
+
+					b.WriteByte('?')
+					args = append(args, value)
+					i = end - 1
+				default:
+					b.WriteByte(c)
+				}
+			}
+		default:
+			b.WriteString(s)
+		}
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return b.String(), append(args, queryArgs[next:]...), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// With adds a common table expression (WITH name AS (query)) in front of the query.
+// query is either a SQL string with its args or a *QueryBuilder (args are then ignored).
+// name may include a column list, e.g "totals(doctor, amount)".
+func (qb *QueryBuilder) With(name string, query interface{}, args ...interface{}) *QueryBuilder {
+	switch q := query.(type) {
+	case *QueryBuilder:
+		query, args, err := q.build()
+		if err != nil {
+			qb.addError(err)
+		}
+		qb.ctes = append(qb.ctes, fragment{sql: name + " AS (" + query + ")", args: args})
+	case string:
+		qb.ctes = append(qb.ctes, fragment{sql: name + " AS (" + q + ")", args: args})
+	default:
+		qb.addError(fmt.Errorf("%w: With expects a string or *QueryBuilder, got %T", ErrInvalidQuery, query))
 	}
 	return qb
 }
 
-// Build returns the final query and its arguments.
+// WithRecursive is like With but emits WITH RECURSIVE, for hierarchical queries.
+func (qb *QueryBuilder) WithRecursive(name string, query interface{}, args ...interface{}) *QueryBuilder {
+	qb.recursive = true
+	return qb.With(name, query, args...)
+}
+
+// Union combines the query with other using UNION, removing duplicate rows.
+// Both queries are parenthesized so they can have their own ORDER BY and LIMIT,
+// while OrderBy, Limit and Offset called afterwards apply to the combined result.
+// Where, Join, GroupBy and Having can not be called after Union.
+//
+//	qb := NewQueryBuilder("SELECT doctor, total_amount FROM cash_income").
+//		UnionAll(NewQueryBuilder("SELECT doctor, total_amount FROM insurance_income")).
+//		OrderBy("total_amount DESC")
+func (qb *QueryBuilder) Union(other *QueryBuilder) *QueryBuilder {
+	return qb.union("UNION", other)
+}
+
+// UnionAll combines the query with other using UNION ALL, keeping duplicate rows.
+func (qb *QueryBuilder) UnionAll(other *QueryBuilder) *QueryBuilder {
+	return qb.union("UNION ALL", other)
+}
+
+func (qb *QueryBuilder) union(op string, other *QueryBuilder) *QueryBuilder {
+	query, args, err := other.build()
+	if err != nil {
+		qb.addError(err)
+	}
+
+	if !qb.hasUnion {
+		// Freeze the current query as the left operand.
+		left, leftArgs, err := qb.assemble()
+		if err != nil {
+			qb.addError(err)
+		}
+		qb.query = "(" + left + ")"
+		qb.args = leftArgs
+		qb.selects, qb.joins, qb.conditions, qb.groupBy, qb.having = nil, nil, nil, nil, nil
+		qb.orderBy, qb.limit, qb.offset = nil, 0, 0
+		qb.hasUnion = true
+	}
+
+	qb.query += " " + op + " (" + query + ")"
+	qb.args = append(qb.args, args...)
+	return qb
+}
+
+// Dialect sets the placeholder style of the query returned by Build.
+func (qb *QueryBuilder) Dialect(d Dialect) *QueryBuilder {
+	qb.dialect = d
+	return qb
+}
+
+// Build returns the final query and its arguments, with clauses in SQL order.
+// Placeholders are ? unless another Dialect was set.
+// If clauses were combined in an impossible way, the query is returned as far as it could be
+// assembled and the database reports the error: use BuildE or Validate to check it first.
 func (qb *QueryBuilder) Build() (string, []interface{}) {
-	return qb.query, qb.args
+	query, args, _ := qb.build()
+	return qb.dialect.rebind(query, args)
+}
+
+// BuildE is like Build but returns an error wrapping ErrInvalidQuery if clauses were combined
+// in an impossible way, or the number of placeholders does not match the number of arguments.
+func (qb *QueryBuilder) BuildE() (string, []interface{}, error) {
+	query, args, err := qb.build()
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, args = qb.dialect.rebind(query, args)
+	return query, args, nil
+}
+
+// buildGorm is like BuildE with DialectGorm regardless of the Dialect, for running the query with gorm.
+func (qb *QueryBuilder) buildGorm() (string, []interface{}, error) {
+	query, args, err := qb.build()
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, args = DialectGorm.rebind(query, args)
+	return query, args, nil
+}
+
+// MustBuild is like Build but panics if the query is invalid.
+func (qb *QueryBuilder) MustBuild() (string, []interface{}) {
+	query, args, err := qb.BuildE()
+	if err != nil {
+		panic(err)
+	}
+	return query, args
+}
+
+// ToSQL returns the query with its arguments interpolated as quoted literals,
+// for debugging and test snapshots. Never execute the returned SQL.
+func (qb *QueryBuilder) ToSQL() (string, error) {
+	query, args, err := qb.build()
+	if err != nil {
+		return "", err
+	}
+	return logger.ExplainSQL(query, nil, `'`, args...), nil
+}
+
+// build returns the query with ? placeholders, for embedding in other queries.
+// On error, the query and args are still returned as far as they could be assembled.
+func (qb *QueryBuilder) build() (string, []interface{}, error) {
+	query, args, err := qb.assemble()
+	if qb.err != nil {
+		err = qb.err
+	}
+
+	if len(qb.ctes) > 0 {
+		with := "WITH "
+		if qb.recursive {
+			with = "WITH RECURSIVE "
+		}
+
+		ctes, cteArgs := joinFragments(qb.ctes, ",")
+		query = with + ctes + " " + query
+		args = append(cteArgs, args...)
+	}
+
+	if qb.params != nil {
+		bound, boundArgs, bindErr := bindNamed(query, args, qb.params)
+		if bindErr != nil {
+			return query, args, cmp.Or(err, bindErr)
+		}
+		query, args = bound, boundArgs
+	}
+
+	if err != nil {
+		return query, args, err
+	}
+	return query, args, checkPlaceholders(query, args)
+}
+
+// Validate reports whether the query can be built, including whether the number
+// of ? placeholders matches the number of arguments. BuildE performs the same checks.
+func (qb *QueryBuilder) Validate() error {
+	_, _, err := qb.build()
+	return err
+}
+
+// checkPlaceholders returns an error if the number of ? placeholders of query does not match
+// the number of args.
+func checkPlaceholders(query string, args []interface{}) error {
+	if _, n := placeholders(query, func(int) string { return "?" }); n != len(args) {
+		return fmt.Errorf("%w: %d placeholders but %d arguments in %q", ErrInvalidQuery, n, len(args), query)
+	}
+	return nil
+}
+
+// assemble joins the initial query and the clauses (except WITH) in SQL order.
+func (qb *QueryBuilder) assemble() (query string, args []interface{}, err error) {
+	var b strings.Builder
+	args = append([]interface{}{}, qb.args...)
+	b.WriteString(qb.query)
+
+	if len(qb.selects) > 0 {
+		if i := fromIndex(qb.query); i >= 0 {
+			b.Reset()
+			b.WriteString(strings.TrimRight(qb.query[:i], " \n\t\r"))
+			b.WriteString(", " + strings.Join(qb.selects, ", ") + " ")
+			b.WriteString(qb.query[i:])
+		} else {
+			err = fmt.Errorf("%w: SelectWindow requires a FROM clause in the initial query", ErrInvalidQuery)
+		}
+	}
+
+	for _, join := range qb.joins {
+		b.WriteString(" " + join.op + " " + join.sql)
+		args = append(args, join.args...)
+	}
+
+	if len(qb.conditions) > 0 {
+		where, whereArgs := joinFragments(qb.conditions, "")
+		b.WriteString(" WHERE " + where)
+		args = append(args, whereArgs...)
+	}
+
+	if len(qb.groupBy) > 0 {
+		b.WriteString(" GROUP BY " + strings.Join(qb.groupBy, ", "))
+	}
+
+	if len(qb.having) > 0 {
+		having, havingArgs := joinFragments(qb.having, "")
+		b.WriteString(" HAVING " + having)
+		args = append(args, havingArgs...)
+	}
+
+	if len(qb.orderBy) > 0 {
+		b.WriteString(" ORDER BY " + strings.Join(qb.orderBy, ", "))
+	}
+
+	if qb.limit > 0 {
+		b.WriteString(" LIMIT ?")
+		args = append(args, qb.limit)
+	}
+
+	if qb.offset > 0 {
+		b.WriteString(" OFFSET ?")
+		args = append(args, qb.offset)
+	}
+	return b.String(), args, err
+}
+
+// joinFragments joins fragments with their operators, or with sep if it is not empty.
+func joinFragments(fragments []fragment, sep string) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	for i, f := range fragments {
+		if i > 0 {
+			if sep != "" {
+				b.WriteString(sep + " ")
+			} else {
+				b.WriteString(" " + f.op + " ")
+			}
+		}
+		b.WriteString(f.sql)
+		args = append(args, f.args...)
+	}
+	return b.String(), args
+}
+
+// Scan executes the query with db.Raw and scans the rows into dest.
+// The query is built with DialectGorm regardless of the Dialect since it is run by gorm.
+func (qb *QueryBuilder) Scan(db *gorm.DB, dest any) error {
+	return qb.ScanContext(context.Background(), db, dest)
+}
+
+// ScanContext is like Scan but runs the query with ctx.
+func (qb *QueryBuilder) ScanContext(ctx context.Context, db *gorm.DB, dest any) error {
+	query, args, err := qb.buildGorm()
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Raw(query, args...).Scan(dest).Error
+}
+
+// Exec executes the query with db.Exec and returns the number of rows affected.
+func (qb *QueryBuilder) Exec(db *gorm.DB) (int64, error) {
+	return qb.ExecContext(context.Background(), db)
+}
+
+// ExecContext is like Exec but runs the query with ctx.
+func (qb *QueryBuilder) ExecContext(ctx context.Context, db *gorm.DB) (int64, error) {
+	query, args, err := qb.buildGorm()
+	if err != nil {
+		return 0, err
+	}
+
+	result := db.WithContext(ctx).Exec(query, args...)
+	return result.RowsAffected, result.Error
 }
@@ -1,10 +1,15 @@
 package gh_test
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"regexp"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/abiiranathan/gh"
+	"gorm.io/gorm"
 )
 
 func TestQueryBuilder(t *testing.T) {
@@ -87,3 +92,528 @@ func TestQueryBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryBuilderJoin(t *testing.T) {
+	qb := gh.NewQueryBuilder("SELECT v.id, p.name FROM visits v")
+	qb.Join("patients p", "p.id = v.patient_id").
+		LeftJoin("invoices i", "i.visit_id = v.id AND i.status = ?", "paid").
+		RightJoin("doctors d", "d.id = v.doctor_id").
+		Where("d.name = ?", "Dr. Smith")
+
+	query, args := qb.MustBuild()
+
+	expectedQuery := "SELECT v.id, p.name FROM visits v JOIN patients p ON p.id = v.patient_id LEFT JOIN invoices i ON i.visit_id = v.id AND i.status = ? RIGHT JOIN doctors d ON d.id = v.doctor_id WHERE d.name = ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"paid", "Dr. Smith"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderHaving(t *testing.T) {
+	qb := gh.NewQueryBuilder("SELECT doctor, SUM(total_amount) AS total_amount FROM income_per_billable")
+	qb.Where("billable_type=?", "Consultation").
+		GroupBy("doctor").
+		Having("SUM(total_amount) > ?", 1000).
+		Having("COUNT(*) > ?", "").
+		Having("COUNT(*) < ?", 50).
+		OrderBy("total_amount DESC")
+
+	query, args := qb.MustBuild()
+
+	expectedQuery := "SELECT doctor, SUM(total_amount) AS total_amount FROM income_per_billable WHERE billable_type=? GROUP BY doctor HAVING SUM(total_amount) > ? AND COUNT(*) < ? ORDER BY total_amount DESC"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"Consultation", 1000, 50}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderLimitOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int
+		offset        int
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{"No limit", 0, 0, "SELECT * FROM visits ORDER BY id", []interface{}{}},
+		{"Limit", 20, 0, "SELECT * FROM visits ORDER BY id LIMIT ?", []interface{}{20}},
+		{"Limit and offset", 20, 40, "SELECT * FROM visits ORDER BY id LIMIT ? OFFSET ?", []interface{}{20, 40}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := gh.NewQueryBuilder("SELECT * FROM visits").
+				OrderBy("id").
+				Limit(tt.limit).
+				Offset(tt.offset).
+				MustBuild()
+
+			if query != tt.expectedQuery {
+				t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", tt.expectedQuery, query)
+			}
+
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", tt.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderWhereIn(t *testing.T) {
+	query, args := gh.NewQueryBuilder("SELECT * FROM visits").
+		WhereIn("doctor", []interface{}{"Dr. Smith", "Dr. Jones"}).
+		WhereIn("billable_type", nil).
+		WhereIn("status", []interface{}{"paid"}).
+		MustBuild()
+
+	expectedQuery := "SELECT * FROM visits WHERE doctor IN (?, ?) AND status IN (?)"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"Dr. Smith", "Dr. Jones", "paid"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderWhereArgs(t *testing.T) {
+	doctor := ""
+	query, args := gh.NewQueryBuilder("SELECT * FROM visits").
+		WhereArgs("date BETWEEN ? AND ?", "2023-01-01", "2023-12-31").
+		WhereRaw("deleted_at IS NULL").
+		WhereIf(doctor != "", "doctor = ?", doctor).
+		WhereIf(true, "status = ?", "").
+		MustBuild()
+
+	expectedQuery := "SELECT * FROM visits WHERE date BETWEEN ? AND ? AND deleted_at IS NULL AND status = ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"2023-01-01", "2023-12-31", ""}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderGroup(t *testing.T) {
+	query, args := gh.NewQueryBuilder("SELECT * FROM visits").
+		Group(func(g *gh.QueryBuilder) {
+			g.Where("doctor = ?", "Dr. Smith").OrWhere("doctor = ?", "Dr. Jones").OrWhere("doctor = ?", "")
+		}).
+		Group(func(g *gh.QueryBuilder) {}).
+		Where("status = ?", "paid").
+		OrGroup(func(g *gh.QueryBuilder) {
+			g.WhereRaw("urgent").Where("total_amount > ?", 100)
+		}).
+		MustBuild()
+
+	expectedQuery := "SELECT * FROM visits WHERE (doctor = ? OR doctor = ?) AND status = ? OR (urgent AND total_amount > ?)"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"Dr. Smith", "Dr. Jones", "paid", 100}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderBind(t *testing.T) {
+	qb := gh.NewQueryBuilder("SELECT date::date, doctor, ':skip' AS note FROM visits").
+		WhereArgs("date >= :from AND date < :to").
+		Where("doctor = ?", "Dr. Smith").
+		WhereArgs("total_amount > :min")
+
+	query, args, err := qb.Bind(map[string]interface{}{"from": "2023-01-01", "to": "2024-01-01", "min": 100}).BuildE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuery := "SELECT date::date, doctor, ':skip' AS note FROM visits WHERE date >= ? AND date < ? AND doctor = ? AND total_amount > ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"2023-01-01", "2024-01-01", "Dr. Smith", 100}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+
+	// ?? escapes and the jsonb ?| operator do not take positional arguments.
+	query, args, err = gh.NewQueryBuilder("SELECT * FROM visits").
+		WhereRaw("data ?? 'referral'").
+		Where("x = ?", "X").
+		WhereRaw("tags ?| array['urgent']").
+		WhereArgs("doctor = :doctor").
+		Where("y = ?", "Y").
+		Bind(map[string]interface{}{"doctor": "Dr. Smith"}).
+		Dialect(gh.DialectPostgres).
+		BuildE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuery = "SELECT * FROM visits WHERE data ? 'referral' AND x = $1 AND tags ?| array['urgent'] AND doctor = $2 AND y = $3"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs = []interface{}{"X", "Dr. Smith", "Y"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+
+	_, _, err = gh.NewQueryBuilder("SELECT * FROM visits WHERE doctor = :doctor").Bind(nil).BuildE()
+	if err == nil {
+		t.Error("expected an error for a missing named parameter")
+	}
+}
+
+func TestQueryBuilderWith(t *testing.T) {
+	totals := gh.NewQueryBuilder("SELECT doctor, SUM(total_amount) AS amount FROM income_per_billable").
+		Where("billable_type = ?", "Consultation").
+		GroupBy("doctor")
+
+	query, args := gh.NewQueryBuilder("SELECT * FROM totals t JOIN top d ON d.doctor = t.doctor").
+		With("totals", totals).
+		With("top(doctor)", "SELECT doctor FROM doctors WHERE rank <= ?", 10).
+		Where("t.amount > ?", 1000).
+		MustBuild()
+
+	expectedQuery := "WITH totals AS (SELECT doctor, SUM(total_amount) AS amount FROM income_per_billable WHERE billable_type = ? GROUP BY doctor), top(doctor) AS (SELECT doctor FROM doctors WHERE rank <= ?) SELECT * FROM totals t JOIN top d ON d.doctor = t.doctor WHERE t.amount > ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"Consultation", 10, 1000}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+
+	query, _ = gh.NewQueryBuilder("SELECT * FROM tree").
+		WithRecursive("tree", "SELECT id, parent_id FROM categories WHERE parent_id IS NULL UNION ALL SELECT c.id, c.parent_id FROM categories c JOIN tree ON c.parent_id = tree.id").
+		MustBuild()
+
+	expectedQuery = "WITH RECURSIVE tree AS (SELECT id, parent_id FROM categories WHERE parent_id IS NULL UNION ALL SELECT c.id, c.parent_id FROM categories c JOIN tree ON c.parent_id = tree.id) SELECT * FROM tree"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+}
+
+func TestQueryBuilderUnion(t *testing.T) {
+	query, args := gh.NewQueryBuilder("SELECT doctor, total_amount FROM cash_income").
+		Where("doctor = ?", "Dr. Smith").
+		UnionAll(gh.NewQueryBuilder("SELECT doctor, total_amount FROM insurance_income").Where("doctor = ?", "Dr. Jones")).
+		Union(gh.NewQueryBuilder("SELECT doctor, total_amount FROM other_income").OrderBy("total_amount").Limit(5)).
+		OrderBy("total_amount DESC").
+		Limit(10).
+		MustBuild()
+
+	expectedQuery := "(SELECT doctor, total_amount FROM cash_income WHERE doctor = ?) UNION ALL (SELECT doctor, total_amount FROM insurance_income WHERE doctor = ?) UNION (SELECT doctor, total_amount FROM other_income ORDER BY total_amount LIMIT ?) ORDER BY total_amount DESC LIMIT ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"Dr. Smith", "Dr. Jones", 5, 10}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderSubquery(t *testing.T) {
+	active := gh.NewQueryBuilder("SELECT id FROM doctors").Where("active = ?", true)
+	average := gh.NewQueryBuilder("SELECT AVG(total_amount) FROM visits").Where("date >= ?", "2023-01-01")
+
+	inner := gh.NewQueryBuilder("SELECT doctor_id, total_amount FROM visits").
+		WhereInSub("doctor_id", active).
+		Where("total_amount > ?", average)
+
+	query, args := gh.NewQueryBuilderFrom("doctor_id, SUM(total_amount)", inner, "v").
+		Where("doctor_id <> ?", 7).
+		GroupBy("doctor_id").
+		MustBuild()
+
+	expectedQuery := "SELECT doctor_id, SUM(total_amount) FROM (SELECT doctor_id, total_amount FROM visits WHERE doctor_id IN (SELECT id FROM doctors WHERE active = ?) AND total_amount > (SELECT AVG(total_amount) FROM visits WHERE date >= ?)) AS v WHERE doctor_id <> ? GROUP BY doctor_id"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{true, "2023-01-01", 7}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+
+	// The subquery replaces the placeholder, not the jsonb operators or a ? in a string literal.
+	query, args = gh.NewQueryBuilder("SELECT * FROM visits").
+		Where("data ?? 'referral' AND tags ?| array['urgent'] AND note <> '?' AND total_amount > ?", average).
+		Dialect(gh.DialectPostgres).
+		MustBuild()
+
+	expectedQuery = "SELECT * FROM visits WHERE data ? 'referral' AND tags ?| array['urgent'] AND note <> '?' AND total_amount > (SELECT AVG(total_amount) FROM visits WHERE date >= $1)"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs = []interface{}{"2023-01-01"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderDialect(t *testing.T) {
+	active := gh.NewQueryBuilder("SELECT id FROM doctors").Where("active = ?", true).Dialect(gh.DialectPostgres)
+
+	query, args := gh.NewQueryBuilder("SELECT * FROM visits").
+		Dialect(gh.DialectPostgres).
+		Where("note <> '?'", "").
+		WhereArgs("date BETWEEN ? AND ?", "2023-01-01", "2023-12-31").
+		WhereInSub("doctor_id", active).
+		Limit(10).
+		MustBuild()
+
+	expectedQuery := "SELECT * FROM visits WHERE date BETWEEN $1 AND $2 AND doctor_id IN (SELECT id FROM doctors WHERE active = $3) LIMIT $4"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"2023-01-01", "2023-12-31", true, 10}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+
+	query, _ = gh.NewQueryBuilder("SELECT * FROM visits").WhereRaw("note <> '?'").Where("id = ?", 1).Dialect(gh.DialectPostgres).MustBuild()
+	if expected := "SELECT * FROM visits WHERE note <> '?' AND id = $1"; query != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, query)
+	}
+
+	query, _ = gh.NewDeleteBuilder("visits").Where("id = ?", 1).Dialect(gh.DialectPostgres).MustBuild()
+	if expected := "DELETE FROM visits WHERE id = $1"; query != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, query)
+	}
+
+	// The jsonb ?| and ?& operators are not placeholders, and ?? escapes the ? operator.
+	base := gh.NewQueryBuilder("SELECT id, data ?? 'referral' AS referred FROM visits").
+		WhereRaw("tags ?| array['urgent']").
+		WhereRaw("tags ?& array['paid']")
+	if err := base.Validate(); err != nil {
+		t.Errorf("unexpected error for jsonb operators: %v", err)
+	}
+
+	query, _ = base.Where("id = ?", 1).Dialect(gh.DialectPostgres).Build()
+	if expected := "SELECT id, data ? 'referral' AS referred FROM visits WHERE tags ?| array['urgent'] AND tags ?& array['paid'] AND id = $1"; query != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, query)
+	}
+}
+
+func TestQueryBuilderExec(t *testing.T) {
+	db := dryRunDB(t)
+
+	var stmt *gorm.Statement
+	if err := db.Callback().Raw().After("gorm:raw").Register("test:capture", func(tx *gorm.DB) {
+		stmt = tx.Statement
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := gh.NewQueryBuilder("UPDATE visits SET status = 'paid'").
+		Dialect(gh.DialectPostgres).
+		Where("doctor = ?", "Dr. Smith").
+		ExecContext(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "UPDATE visits SET status = 'paid' WHERE doctor = $1"; stmt.SQL.String() != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, stmt.SQL.String())
+	}
+
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{"Dr. Smith"}) {
+		t.Errorf("Args mismatch: %v", stmt.Vars)
+	}
+
+	// gorm must not bind the jsonb operators and the ? of string literals.
+	_, err = gh.NewQueryBuilder("UPDATE visits SET status = 'paid?'").
+		WhereRaw("data ?? 'referral'").
+		WhereRaw("tags ?| array['urgent']").
+		Where("doctor = ?", "Dr. Smith").
+		Exec(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "UPDATE visits SET status = 'paid?' WHERE data ? 'referral' AND tags ?| array['urgent'] AND doctor = $1"; stmt.SQL.String() != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, stmt.SQL.String())
+	}
+
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{"Dr. Smith"}) {
+		t.Errorf("Args mismatch: %v", stmt.Vars)
+	}
+}
+
+func TestQueryBuilderScanJSONB(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM visits WHERE data ? 'referral' AND tags ?& array['paid'] AND doctor = $1`)).
+		WithArgs("Dr. Smith").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	var ids []int
+	err := gh.NewQueryBuilder("SELECT id FROM visits").
+		WhereRaw("data ?? 'referral'").
+		WhereRaw("tags ?& array['paid']").
+		Where("doctor = ?", "Dr. Smith").
+		Dialect(gh.DialectPostgres).
+		Scan(db, &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueryBuilderClauseOrder(t *testing.T) {
+	query, args := gh.NewQueryBuilder("SELECT doctor, SUM(total_amount) FROM visits v").
+		Limit(10).
+		OrderBy("2 DESC").
+		Having("SUM(total_amount) > ?", 100).
+		GroupBy("doctor").
+		Where("date >= ?", "2023-01-01").
+		Join("doctors d", "d.name = v.doctor").
+		Offset(20).
+		MustBuild()
+
+	expectedQuery := "SELECT doctor, SUM(total_amount) FROM visits v JOIN doctors d ON d.name = v.doctor WHERE date >= ? GROUP BY doctor HAVING SUM(total_amount) > ? ORDER BY 2 DESC LIMIT ? OFFSET ?"
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	expectedArgs := []interface{}{"2023-01-01", 100, 10, 20}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestQueryBuilderInvalid(t *testing.T) {
+	builders := map[string]*gh.QueryBuilder{
+		"where after union": gh.NewQueryBuilder("SELECT 1").Union(gh.NewQueryBuilder("SELECT 2")).Where("x = ?", 1),
+		"negative limit":    gh.NewQueryBuilder("SELECT 1").Limit(-1),
+		"invalid with":      gh.NewQueryBuilder("SELECT 1").With("x", 42),
+		"invalid subquery":  gh.NewQueryBuilder("SELECT 1").WhereInSub("id", gh.NewQueryBuilder("SELECT 2").Offset(-1)),
+	}
+
+	for name, qb := range builders {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := qb.BuildE(); !errors.Is(err, gh.ErrInvalidQuery) {
+				t.Errorf("expected ErrInvalidQuery, got %v", err)
+			}
+
+			defer func() {
+				if recover() == nil {
+					t.Error("expected MustBuild to panic")
+				}
+			}()
+			qb.MustBuild()
+		})
+	}
+}
+
+func TestQueryBuilderValidate(t *testing.T) {
+	valid := gh.NewQueryBuilder("SELECT * FROM visits WHERE note <> '?'").
+		WhereArgs("date BETWEEN ? AND ?", "2023-01-01", "2023-12-31")
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	missing := gh.NewQueryBuilder("SELECT * FROM visits").WhereArgs("date BETWEEN ? AND ?", "2023-01-01")
+	if err := missing.Validate(); !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+
+	if _, _, err := missing.BuildE(); !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected Build to fail with ErrInvalidQuery, got %v", err)
+	}
+
+	// Build returns the query for the database to report the error, e.g an unescaped ? operator.
+	query, args := gh.NewQueryBuilder("SELECT id, data ? 'referral' FROM visits").Where("id = ?", 1).Build()
+	if expected := "SELECT id, data ? 'referral' FROM visits WHERE id = ?"; query != expected {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expected, query)
+	}
+
+	if !reflect.DeepEqual(args, []interface{}{1}) {
+		t.Errorf("Args mismatch: %v", args)
+	}
+
+	_, _, err := gh.NewUpdateBuilder("visits").SetRaw("total = ? + ?", 1).Build()
+	if !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestQueryBuilderClone(t *testing.T) {
+	base := gh.NewQueryBuilder("SELECT * FROM visits").
+		Where("status = ?", "paid").
+		Bind(map[string]interface{}{"from": "2023-01-01"})
+
+	byDoctor := func(doctor string) func(*gh.QueryBuilder) {
+		return func(qb *gh.QueryBuilder) { qb.Where("doctor = ?", doctor) }
+	}
+
+	smith, smithArgs := base.Clone().Apply(byDoctor("Dr. Smith"), func(qb *gh.QueryBuilder) {
+		qb.WhereArgs("date >= :from").OrderBy("date")
+	}).MustBuild()
+
+	jones, jonesArgs := base.Clone().Apply(byDoctor("Dr. Jones")).MustBuild()
+	query, args := base.MustBuild()
+
+	assertBuilt(t, smith, smithArgs, "SELECT * FROM visits WHERE status = ? AND doctor = ? AND date >= ? ORDER BY date", []interface{}{"paid", "Dr. Smith", "2023-01-01"})
+	assertBuilt(t, jones, jonesArgs, "SELECT * FROM visits WHERE status = ? AND doctor = ?", []interface{}{"paid", "Dr. Jones"})
+	assertBuilt(t, query, args, "SELECT * FROM visits WHERE status = ?", []interface{}{"paid"})
+}
+
+func TestQueryBuilderSelectWindow(t *testing.T) {
+	query, args := gh.NewQueryBuilder("SELECT date, doctor, (SELECT 1 FROM x) AS one\nFROM visits").
+		SelectWindow("SUM(total_amount)", "doctor", "date", "running_total").
+		SelectWindow("ROW_NUMBER()", "", "total_amount DESC", "rank").
+		Where("doctor = ?", "Dr. Smith").
+		MustBuild()
+
+	assertBuilt(t, query, args,
+		"SELECT date, doctor, (SELECT 1 FROM x) AS one, SUM(total_amount) OVER (PARTITION BY doctor ORDER BY date) AS running_total, ROW_NUMBER() OVER (ORDER BY total_amount DESC) AS rank FROM visits WHERE doctor = ?",
+		[]interface{}{"Dr. Smith"})
+
+	err := gh.NewQueryBuilder("SELECT 1").SelectWindow("COUNT(*)", "", "", "total").Validate()
+	if !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestQueryBuilderToSQL(t *testing.T) {
+	sql, err := gh.NewQueryBuilder("SELECT * FROM visits").
+		Where("doctor = ?", "O'Brien").
+		WhereArgs("total_amount > ? AND paid = ?", 100, true).
+		Limit(5).
+		ToSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "SELECT * FROM visits WHERE doctor = 'O''Brien' AND total_amount > 100 AND paid = true LIMIT 5"; sql != expected {
+		t.Errorf("SQL mismatch:\nExpected: %s\nGot: %s", expected, sql)
+	}
+}
@@ -0,0 +1,24 @@
+package gh
+
+import "strings"
+
+// quoteIdent quotes a (possibly schema qualified) postgres identifier.
+// e.g quoteIdent("public.users") returns "public"."users"
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteName quotes a single postgres identifier, which may contain dots.
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a string as a postgres literal.
+// Use only where bind parameters are not supported (e.g DDL statements).
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,311 @@
+package gh
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RangeBound is the type of the bounds of a Range.
+type RangeBound interface {
+	int32 | int64 | float64 | time.Time
+}
+
+// Range is a value of a postgres range column: int4range (Range[int32]), int8range (Range[int64]),
+// numrange (Range[float64]), daterange, tsrange and tstzrange (Range[time.Time]).
+// A zero Range is stored as NULL; use NewRange for a bounded range.
+//
+//	type Booking struct {
+//		RoomID uint
+//		During gh.Range[time.Time] `gorm:"type:tstzrange"`
+//	}
+type Range[T RangeBound] struct {
+	Lower, Upper       T
+	LowerInc, UpperInc bool // the bound is inclusive
+	LowerInf, UpperInf bool // the range is unbounded on this side
+	Empty              bool // the empty range
+	Valid              bool // false for NULL
+}
+
+// NewRange returns the range [lower, upper), inclusive of lower and exclusive of upper.
+func NewRange[T RangeBound](lower, upper T) Range[T] {
+	return Range[T]{Lower: lower, Upper: upper, LowerInc: true, Valid: true}
+}
+
+// compareBounds returns -1, 0 or 1 if a is less than, equal to or greater than b.
+func compareBounds[T RangeBound](a, b T) int {
+	switch x := any(a).(type) {
+	case time.Time:
+		return x.Compare(any(b).(time.Time))
+	case int32:
+		return cmpOrdered(x, any(b).(int32))
+	case int64:
+		return cmpOrdered(x, any(b).(int64))
+	default:
+		return cmpOrdered(any(a).(float64), any(b).(float64))
+	}
+}
+
+func cmpOrdered[T int32 | int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Contains reports whether v is within the range.
+func (r Range[T]) Contains(v T) bool {
+	if !r.Valid || r.Empty {
+		return false
+	}
+
+	if !r.LowerInf {
+		if c := compareBounds(v, r.Lower); c < 0 || (c == 0 && !r.LowerInc) {
+			return false
+		}
+	}
+
+	if !r.UpperInf {
+		if c := compareBounds(v, r.Upper); c > 0 || (c == 0 && !r.UpperInc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Overlaps reports whether the ranges have a value in common.
+func (r Range[T]) Overlaps(other Range[T]) bool {
+	if !r.Valid || !other.Valid || r.Empty || other.Empty {
+		return false
+	}
+	return r.startsBeforeEndOf(other) && other.startsBeforeEndOf(r)
+}
+
+// startsBeforeEndOf reports whether the lower bound of r is before the upper bound of other.
+func (r Range[T]) startsBeforeEndOf(other Range[T]) bool {
+	if r.LowerInf || other.UpperInf {
+		return true
+	}
+
+	c := compareBounds(r.Lower, other.Upper)
+	return c < 0 || (c == 0 && r.LowerInc && other.UpperInc)
+}
+
+// Value implements driver.Valuer, encoding the range in its text form, e.g [1,10).
+func (r Range[T]) Value() (driver.Value, error) {
+	if !r.Valid {
+		return nil, nil
+	}
+
+	if r.Empty {
+		return "empty", nil
+	}
+
+	var b strings.Builder
+	if r.LowerInc && !r.LowerInf {
+		b.WriteByte('[')
+	} else {
+		b.WriteByte('(')
+	}
+
+	if !r.LowerInf {
+		b.WriteString(formatRangeBound(r.Lower))
+	}
+	b.WriteByte(',')
+	if !r.UpperInf {
+		b.WriteString(formatRangeBound(r.Upper))
+	}
+
+	if r.UpperInc && !r.UpperInf {
+		b.WriteByte(']')
+	} else {
+		b.WriteByte(')')
+	}
+	return b.String(), nil
+}
+
+func formatRangeBound[T RangeBound](v T) string {
+	switch x := any(v).(type) {
+	case time.Time:
+		return `"` + x.Format(time.RFC3339Nano) + `"`
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// Scan implements sql.Scanner.
+func (r *Range[T]) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*r = Range[T]{}
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("gh: cannot scan %T into Range", src)
+	}
+
+	if strings.EqualFold(text, "empty") {
+		*r = Range[T]{Empty: true, Valid: true}
+		return nil
+	}
+
+	if len(text) < 3 || !strings.ContainsRune("[(", rune(text[0])) || !strings.ContainsRune("])", rune(text[len(text)-1])) {
+		return fmt.Errorf("gh: invalid range %q", text)
+	}
+
+	p := &textParser{s: text[1 : len(text)-1]}
+	bounds := [2]*string{}
+	for i := range bounds {
+		if !p.done() && p.s[p.i] == '"' {
+			s, err := p.quoted(true)
+			if err != nil {
+				return fmt.Errorf("gh: invalid range %q", text)
+			}
+			bounds[i] = &s
+		} else {
+			start := p.i
+			for !p.done() && p.s[p.i] != ',' {
+				p.i++
+			}
+			if p.i > start {
+				s := p.s[start:p.i]
+				bounds[i] = &s
+			}
+		}
+
+		if i == 0 && !p.consume(",") {
+			return fmt.Errorf("gh: invalid range %q", text)
+		}
+	}
+
+	parsed := Range[T]{
+		LowerInc: text[0] == '[',
+		UpperInc: text[len(text)-1] == ']',
+		LowerInf: bounds[0] == nil,
+		UpperInf: bounds[1] == nil,
+		Valid:    true,
+	}
+
+	var err error
+	if bounds[0] != nil {
+		if parsed.Lower, err = parseRangeBound[T](*bounds[0]); err != nil {
+			return err
+		}
+	}
+	if bounds[1] != nil {
+		if parsed.Upper, err = parseRangeBound[T](*bounds[1]); err != nil {
+			return err
+		}
+	}
+
+	*r = parsed
+	return nil
+}
+
+func parseRangeBound[T RangeBound](s string) (T, error) {
+	var zero T
+	var v any
+	var err error
+
+	switch any(zero).(type) {
+	case time.Time:
+		err = fmt.Errorf("gh: invalid range bound %q", s)
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999", time.DateOnly, time.RFC3339Nano} {
+			if t, parseErr := time.Parse(layout, s); parseErr == nil {
+				v, err = t, nil
+				break
+			}
+		}
+	case int32:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		v = int32(n)
+	case int64:
+		v, err = strconv.ParseInt(s, 10, 64)
+	case float64:
+		v, err = strconv.ParseFloat(s, 64)
+	}
+
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// RangeContains filters the rows whose range column contains value, which can be an element
+// (time.Time, an integer or a float64) or a Range. An element is compared as the single-value range
+// [value,value], so the parameter takes the type of the column.
+func (gdb *GormDB) RangeContains(column string, value any) *GormDB {
+	switch v := value.(type) {
+	case time.Time:
+		value = Range[time.Time]{Lower: v, Upper: v, LowerInc: true, UpperInc: true, Valid: true}
+	case int:
+		value = Range[int64]{Lower: int64(v), Upper: int64(v), LowerInc: true, UpperInc: true, Valid: true}
+	case int32:
+		value = Range[int32]{Lower: v, Upper: v, LowerInc: true, UpperInc: true, Valid: true}
+	case int64:
+		value = Range[int64]{Lower: v, Upper: v, LowerInc: true, UpperInc: true, Valid: true}
+	case float64:
+		value = Range[float64]{Lower: v, Upper: v, LowerInc: true, UpperInc: true, Valid: true}
+	}
+
+	gdb.db = gdb.db.Where(column+" @> ?", value)
+	return gdb
+}
+
+// RangeOverlaps filters the rows whose range column overlaps r.
+func (gdb *GormDB) RangeOverlaps(column string, r driver.Valuer) *GormDB {
+	gdb.db = gdb.db.Where(column+" && ?", r)
+	return gdb
+}
+
+// RangeAdjacent filters the rows whose range column is adjacent to r (they touch without overlapping).
+func (gdb *GormDB) RangeAdjacent(column string, r driver.Valuer) *GormDB {
+	gdb.db = gdb.db.Where(column+" -|- ?", r)
+	return gdb
+}
+
+// ExclusionConstraint adds the constraint name to table if it does not exist, preventing rows with
+// equal equalColumns from having overlapping rangeColumn values, e.g double-booking a room:
+//
+//	err := gh.ExclusionConstraint(db, "bookings", "bookings_no_overlap", "during", "room_id")
+//
+// With equalColumns, the btree_gist extension is required, see EnsureExtensions(db, "btree_gist").
+func ExclusionConstraint(db *gorm.DB, table, name, rangeColumn string, equalColumns ...string) error {
+	var exists bool
+	err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = ? AND conrelid = ?::regclass)",
+		name, quoteIdent(table)).Scan(&exists).Error
+	if err != nil {
+		return fmt.Errorf("failed to look up constraint %s: %w", name, err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	elements := make([]string, 0, len(equalColumns)+1)
+	for _, column := range equalColumns {
+		elements = append(elements, quoteName(column)+" WITH =")
+	}
+	elements = append(elements, quoteName(rangeColumn)+" WITH &&")
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s EXCLUDE USING gist (%s)",
+		quoteIdent(table), quoteName(name), strings.Join(elements, ", "))
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to add exclusion constraint %s: %w", name, err)
+	}
+	return nil
+}
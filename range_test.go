@@ -0,0 +1,93 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type booking struct {
+	ID     uint
+	RoomID uint
+	During gh.Range[time.Time]
+}
+
+func TestRangeScanValue(t *testing.T) {
+	var r gh.Range[int32]
+	assert.NoError(t, r.Scan("[1,10)"))
+	assert.Equal(t, gh.NewRange[int32](1, 10), r)
+	assert.True(t, r.Contains(1))
+	assert.True(t, r.Contains(9))
+	assert.False(t, r.Contains(10))
+
+	assert.NoError(t, r.Scan("(,5]"))
+	assert.True(t, r.LowerInf)
+	assert.True(t, r.Contains(-100))
+	assert.True(t, r.Contains(5))
+	v, err := r.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "(,5]", v)
+
+	assert.NoError(t, r.Scan("empty"))
+	assert.True(t, r.Empty)
+	assert.False(t, r.Contains(0))
+	v, _ = r.Value()
+	assert.Equal(t, "empty", v)
+
+	assert.NoError(t, r.Scan(nil))
+	v, _ = r.Value()
+	assert.Nil(t, v)
+
+	var during gh.Range[time.Time]
+	assert.NoError(t, during.Scan(`["2024-05-01 09:00:00+00","2024-05-01 10:00:00+00")`))
+	assert.Equal(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), during.Lower.UTC())
+	v, _ = during.Value()
+	assert.Equal(t, `["2024-05-01T09:00:00Z","2024-05-01T10:00:00Z")`, v)
+
+	var dates gh.Range[time.Time]
+	assert.NoError(t, dates.Scan("[2024-05-01,2024-06-01)"))
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), dates.Upper)
+
+	var num gh.Range[float64]
+	assert.NoError(t, num.Scan("[1.5,2.5]"))
+	assert.True(t, num.Contains(2.5))
+
+	assert.Error(t, r.Scan("[1,2"))
+	assert.Error(t, r.Scan("[a,2)"))
+}
+
+func TestRangeOverlaps(t *testing.T) {
+	a := gh.NewRange[int64](1, 5)
+	assert.True(t, a.Overlaps(gh.NewRange[int64](4, 8)))
+	assert.False(t, a.Overlaps(gh.NewRange[int64](5, 8))) // [1,5) and [5,8) only touch
+	assert.True(t, a.Overlaps(gh.Range[int64]{LowerInf: true, UpperInf: true, Valid: true}))
+	assert.False(t, a.Overlaps(gh.Range[int64]{Empty: true, Valid: true}))
+}
+
+func TestRangeFilters(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	slot := gh.NewRange(at, at.Add(time.Hour))
+
+	sql := gh.WrapDB(dryRunDB(t)).RangeContains("during", at).RangeOverlaps("during", slot).RangeAdjacent("during", slot).
+		ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]booking{}) })
+	assert.Equal(t, `SELECT * FROM "bookings" WHERE during @> '["2024-05-01T09:30:00Z","2024-05-01T09:30:00Z"]' `+
+		`AND during && '["2024-05-01T09:30:00Z","2024-05-01T10:30:00Z")' AND during -|- '["2024-05-01T09:30:00Z","2024-05-01T10:30:00Z")'`, sql)
+}
+
+func TestExclusionConstraint(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = $1 AND conrelid = $2::regclass)")).
+		WithArgs("bookings_no_overlap", `"bookings"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "bookings" ADD CONSTRAINT "bookings_no_overlap" EXCLUDE USING gist ("room_id" WITH =, "during" WITH &&)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, gh.ExclusionConstraint(db, "bookings", "bookings_no_overlap", "during", "room_id"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,218 @@
+package gh
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// RawScan executes a raw query and returns each row as a map of column name to value,
+// for ad-hoc admin and report queries where defining a model is overkill.
+//
+// Postgres values are converted to natural Go types:
+// numeric becomes json.Number (no precision is lost), json and jsonb become json.RawMessage,
+// one-dimensional arrays become slices (e.g []int64, []string) and text becomes string.
+// Timestamps are time.Time and NULL is nil.
+func (gdb *GormDB) RawScan(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	rows, err := gdb.db.WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMaps(rows)
+}
+
+// scanMaps scans all rows into maps, converting values with convertPgValue.
+func scanMaps(rows *sql.Rows) ([]map[string]any, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columnTypes))
+		pointers := make([]any, len(columnTypes))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(columnTypes))
+		for i, ct := range columnTypes {
+			row[ct.Name()] = convertPgValue(ct, values[i])
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+// RawScanInto executes a raw query and scans the rows into a slice of T,
+// a struct whose fields are matched to the columns by gorm's naming rules.
+// T does not need to be a model with a table.
+func RawScanInto[T any](ctx context.Context, gdb *GormDB, query string, args ...any) ([]T, error) {
+	results := []T{}
+	if err := gdb.db.WithContext(ctx).Raw(query, args...).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// convertPgValue converts a value scanned from a column of type ct.
+func convertPgValue(ct *sql.ColumnType, value any) any {
+	if value == nil {
+		return nil
+	}
+
+	typeName := strings.ToUpper(ct.DatabaseTypeName())
+	text, isText := pgText(value)
+
+	switch {
+	case !isText:
+		return value
+	case typeName == "NUMERIC":
+		return json.Number(text)
+	case typeName == "JSON" || typeName == "JSONB":
+		if json.Valid([]byte(text)) {
+			return json.RawMessage(text)
+		}
+		return text
+	case strings.HasPrefix(typeName, "_"):
+		if elems, ok := parsePgArray(text); ok {
+			return convertPgArray(typeName[1:], elems)
+		}
+		return text
+	case typeName == "BYTEA":
+		return value
+	default:
+		return text
+	}
+}
+
+// pgText returns the textual form of a value returned by the driver as text.
+func pgText(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// convertPgArray converts the elements of an array of elemType to a typed slice,
+// or to []any if the array contains NULL (nil) elements or has an unknown element type.
+func convertPgArray(elemType string, elems []*string) any {
+	switch elemType {
+	case "INT2", "INT4", "INT8":
+		return typedArray(elems, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+	case "FLOAT4", "FLOAT8":
+		return typedArray(elems, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	case "BOOL":
+		return typedArray(elems, func(s string) (bool, error) { return s == "t", nil })
+	case "NUMERIC":
+		return typedArray(elems, func(s string) (json.Number, error) { return json.Number(s), nil })
+	case "TEXT", "VARCHAR", "BPCHAR", "UUID", "NAME":
+		return typedArray(elems, func(s string) (string, error) { return s, nil })
+	}
+	return typedArray[any](elems, nil)
+}
+
+// typedArray parses elems into []E. If an element is NULL or can not be parsed,
+// it returns []any with nil for NULL elements instead.
+func typedArray[E any](elems []*string, parse func(string) (E, error)) any {
+	if parse != nil {
+		values := make([]E, len(elems))
+		ok := true
+		for i, elem := range elems {
+			if elem == nil {
+				ok = false
+				break
+			}
+
+			v, err := parse(*elem)
+			if err != nil {
+				ok = false
+				break
+			}
+			values[i] = v
+		}
+
+		if ok {
+			return values
+		}
+	}
+
+	values := make([]any, len(elems))
+	for i, elem := range elems {
+		if elem == nil {
+			continue
+		}
+
+		values[i] = *elem
+		if parse != nil {
+			if v, err := parse(*elem); err == nil {
+				values[i] = v
+			}
+		}
+	}
+	return values
+}
+
+// parsePgArray parses a one-dimensional array in postgres text format, e.g {1,2,NULL} or
+// {"a b","c\"d"}. NULL elements are nil. It returns false for multi-dimensional arrays.
+func parsePgArray(text string) ([]*string, bool) {
+	if i := strings.Index(text, "="); strings.HasPrefix(text, "[") && i > 0 {
+		text = text[i+1:] // strip explicit bounds, e.g [0:1]={1,2}
+	}
+
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return nil, false
+	}
+
+	body := text[1 : len(text)-1]
+	elems := []*string{}
+	if body == "" {
+		return elems, true
+	}
+
+	for i := 0; i <= len(body); {
+		if i < len(body) && body[i] == '{' {
+			return nil, false
+		}
+
+		var elem strings.Builder
+		quoted := i < len(body) && body[i] == '"'
+		if quoted {
+			i++
+			for i < len(body) && body[i] != '"' {
+				if body[i] == '\\' && i+1 < len(body) {
+					i++
+				}
+				elem.WriteByte(body[i])
+				i++
+			}
+			i++ // closing quote
+		}
+
+		for i < len(body) && body[i] != ',' {
+			elem.WriteByte(body[i])
+			i++
+		}
+		i++ // comma
+
+		value := elem.String()
+		if !quoted && strings.EqualFold(value, "NULL") {
+			elems = append(elems, nil)
+		} else {
+			elems = append(elems, &value)
+		}
+	}
+	return elems, true
+}
@@ -0,0 +1,59 @@
+package gh_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawScan(t *testing.T) {
+	db, mock := mockDB(t)
+
+	createdAt := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("doctor").OfType("TEXT", ""),
+		sqlmock.NewColumn("total").OfType("NUMERIC", ""),
+		sqlmock.NewColumn("meta").OfType("JSONB", ""),
+		sqlmock.NewColumn("ids").OfType("_INT8", ""),
+		sqlmock.NewColumn("tags").OfType("_TEXT", ""),
+		sqlmock.NewColumn("scores").OfType("_FLOAT8", ""),
+		sqlmock.NewColumn("created_at").OfType("TIMESTAMPTZ", ""),
+		sqlmock.NewColumn("note").OfType("TEXT", ""),
+	).AddRow([]byte("Dr. Smith"), "12345678901234567890.25", []byte(`{"a":1}`), "{1,2,3}", `{"a b","c\"d",e}`, "{1.5,NULL}", createdAt, nil)
+	mock.ExpectQuery(`SELECT \* FROM report WHERE doctor = \$1`).WithArgs("Dr. Smith").WillReturnRows(rows)
+
+	results, err := gh.WrapDB(db).RawScan(context.Background(), "SELECT * FROM report WHERE doctor = ?", "Dr. Smith")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{
+		"doctor":     "Dr. Smith",
+		"total":      json.Number("12345678901234567890.25"),
+		"meta":       json.RawMessage(`{"a":1}`),
+		"ids":        []int64{1, 2, 3},
+		"tags":       []string{"a b", `c"d`, "e"},
+		"scores":     []any{1.5, nil},
+		"created_at": createdAt,
+		"note":       nil,
+	}}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawScanInto(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(`SELECT doctor, COUNT\(\*\) AS visits FROM visits GROUP BY doctor`).
+		WillReturnRows(sqlmock.NewRows([]string{"doctor", "visits"}).AddRow("Dr. Smith", 3).AddRow("Dr. Jones", 5))
+
+	type row struct {
+		Doctor string
+		Visits int
+	}
+
+	results, err := gh.RawScanInto[row](context.Background(), gh.WrapDB(db), "SELECT doctor, COUNT(*) AS visits FROM visits GROUP BY doctor")
+	assert.NoError(t, err)
+	assert.Equal(t, []row{{"Dr. Smith", 3}, {"Dr. Jones", 5}}, results)
+}
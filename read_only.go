@@ -0,0 +1,80 @@
+package gh
+
+import (
+	"database/sql"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrReadOnly is returned when a write is attempted through a read-only GormDB.
+var ErrReadOnly = errors.New("database is read-only")
+
+const readOnlyKey = "gh:read_only"
+
+// ReadOnlyPlugin is a gorm plugin registering the callbacks rejecting the writes of ReadOnly chains.
+//
+//	db.Use(&gh.ReadOnlyPlugin{})
+type ReadOnlyPlugin struct{}
+
+// Name implements gorm.Plugin.
+func (p *ReadOnlyPlugin) Name() string {
+	return readOnlyKey
+}
+
+// Initialize implements gorm.Plugin, registering the create, update, delete and raw callbacks.
+func (p *ReadOnlyPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("*").Register("gh:read_only_create", rejectWrites); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("gh:read_only_update", rejectWrites); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("gh:read_only_delete", rejectWrites); err != nil {
+		return err
+	}
+	return cb.Raw().Before("*").Register("gh:read_only_raw", rejectWrites)
+}
+
+// ReadOnly marks the chain as read-only: Create, Update, Delete and Exec statements
+// fail with ErrReadOnly and transactions are started with READ ONLY.
+// The returned GormDB can be reused, e.g for replica connections or view-only roles.
+// The ReadOnlyPlugin must be registered, otherwise the chain fails with ErrPluginNotRegistered.
+//
+//	db.Use(&gh.ReadOnlyPlugin{})
+//	replica := gh.WrapDB(replicaDB).ReadOnly()
+func (gdb *GormDB) ReadOnly() *GormDB {
+	if err := requirePlugin(gdb.db, readOnlyKey, "db.Use(&gh.ReadOnlyPlugin{})"); err != nil {
+		gdb.db = gdb.db.Session(&gorm.Session{})
+		gdb.db.AddError(err)
+		return gdb
+	}
+
+	gdb.db = gdb.db.Set(readOnlyKey, true).Session(&gorm.Session{})
+	return gdb
+}
+
+// IsReadOnly reports whether the chain was marked read-only with ReadOnly.
+func (gdb *GormDB) IsReadOnly() bool {
+	return isReadOnly(gdb.db)
+}
+
+func isReadOnly(db *gorm.DB) bool {
+	v, ok := db.Get(readOnlyKey)
+	return ok && v == true
+}
+
+// txOptions returns the options used to begin transactions on db.
+func txOptions(db *gorm.DB) []*sql.TxOptions {
+	if isReadOnly(db) {
+		return []*sql.TxOptions{{ReadOnly: true}}
+	}
+	return nil
+}
+
+func rejectWrites(db *gorm.DB) {
+	if isReadOnly(db) {
+		db.AddError(ErrReadOnly)
+	}
+}
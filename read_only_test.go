@@ -0,0 +1,36 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type readOnlyDepartment struct {
+	ID   uint
+	Name string
+}
+
+func TestReadOnly(t *testing.T) {
+	db := dryRunDB(t)
+	assert.ErrorIs(t, gh.WrapDB(db).ReadOnly().Find(&[]readOnlyDepartment{}), gh.ErrPluginNotRegistered)
+
+	assert.NoError(t, db.Use(&gh.ReadOnlyPlugin{}))
+	replica := gh.WrapDB(db).ReadOnly()
+	assert.True(t, replica.IsReadOnly())
+
+	var departments []readOnlyDepartment
+	assert.NoError(t, replica.Find(&departments))
+	assert.NoError(t, replica.Where("name = ?", "Lab").Find(&departments))
+
+	assert.ErrorIs(t, replica.Create(&readOnlyDepartment{Name: "Lab"}), gh.ErrReadOnly)
+	assert.ErrorIs(t, replica.Update(&readOnlyDepartment{ID: 1, Name: "Lab"}), gh.ErrReadOnly)
+	assert.ErrorIs(t, replica.Delete(&readOnlyDepartment{ID: 1}), gh.ErrReadOnly)
+	assert.ErrorIs(t, replica.DB().Exec("TRUNCATE read_only_departments").Error, gh.ErrReadOnly)
+
+	// The underlying database is not affected.
+	writable := gh.WrapDB(db)
+	assert.False(t, writable.IsReadOnly())
+	assert.NoError(t, writable.Create(&readOnlyDepartment{Name: "Lab"}))
+}
@@ -0,0 +1,213 @@
+package gh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// RedactMode controls how sensitive values are written to the logs.
+type RedactMode int
+
+const (
+	// RedactPlaceholder replaces sensitive values with [REDACTED].
+	RedactPlaceholder RedactMode = iota
+
+	// RedactHash replaces sensitive values with a short SHA-256 hash,
+	// so equal values can still be correlated across log lines.
+	RedactHash
+)
+
+var (
+	// "column" = $1, users.column ILIKE $2, "column"=$3
+	comparisonRegex = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_]*)"?\s*(?:=|<>|!=|>=|<=|>|<|\s(?:not\s+)?i?like\s)\s*\$(\d+)`)
+
+	// "column" IN ($1,$2)
+	inRegex = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_]*)"?\s+(?:not\s+)?in\s*\(([^)]*)\)`)
+
+	// INSERT INTO "table" ("a","b") VALUES ...
+	insertRegex = regexp.MustCompile(`(?is)^\s*insert\s+into\s+\S+\s*\(([^)]*)\)\s*values\s*(.*)$`)
+
+	placeholderRegex = regexp.MustCompile(`\$(\d+)`)
+)
+
+// RedactingLogger is a gorm logger that hides the values bound to sensitive columns
+// while still logging the SQL statement.
+// Columns are marked sensitive with the `gh:"sensitive"` struct tag or with Redact.
+//
+// Sensitive values are detected in INSERT column lists, SET assignments and
+// comparisons of the form column = ?, column ILIKE ? and column IN (?).
+//
+// Usage:
+//
+//	db.Logger = gh.NewRedactingLogger(db.Logger, gh.RedactPlaceholder, &User{}, &Diagnosis{})
+type RedactingLogger struct {
+	logger.Interface
+	mode    RedactMode
+	mu      sync.RWMutex
+	columns map[string]bool
+}
+
+// NewRedactingLogger wraps base, redacting the columns tagged `gh:"sensitive"` in models.
+func NewRedactingLogger(base logger.Interface, mode RedactMode, models ...any) *RedactingLogger {
+	l := &RedactingLogger{Interface: base, mode: mode, columns: map[string]bool{}}
+	cache := &sync.Map{}
+	for _, model := range models {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			continue
+		}
+
+		for _, field := range s.Fields {
+			if _, ok := ghTag(field)["SENSITIVE"]; ok && field.DBName != "" {
+				l.columns[field.DBName] = true
+			}
+		}
+	}
+	return l
+}
+
+// Redact marks columns as sensitive.
+func (l *RedactingLogger) Redact(columns ...string) *RedactingLogger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, column := range columns {
+		l.columns[strings.ToLower(column)] = true
+	}
+	return l
+}
+
+// LogMode implements logger.Interface, keeping redaction on the new logger.
+func (l *RedactingLogger) LogMode(level logger.LogLevel) logger.Interface {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	columns := make(map[string]bool, len(l.columns))
+	for column := range l.columns {
+		columns[column] = true
+	}
+	return &RedactingLogger{Interface: l.Interface.LogMode(level), mode: l.mode, columns: columns}
+}
+
+// ParamsFilter implements gorm.ParamsFilter by replacing the sensitive parameters.
+func (l *RedactingLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	sensitive := l.sensitiveParams(sql)
+	if len(sensitive) > 0 {
+		redacted := make([]any, len(params))
+		copy(redacted, params)
+		for i := range redacted {
+			if sensitive[i] {
+				redacted[i] = l.redact(redacted[i])
+			}
+		}
+		params = redacted
+	}
+
+	if filter, ok := l.Interface.(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
+func (l *RedactingLogger) redact(value any) string {
+	if l.mode == RedactHash {
+		sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	}
+	return "[REDACTED]"
+}
+
+// sensitiveParams returns the (0-based) indexes of the parameters bound to sensitive columns.
+func (l *RedactingLogger) sensitiveParams(sql string) map[int]bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	sensitive := map[int]bool{}
+	if len(l.columns) == 0 {
+		return sensitive
+	}
+
+	mark := func(placeholders string) {
+		for _, m := range placeholderRegex.FindAllStringSubmatch(placeholders, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				sensitive[n-1] = true
+			}
+		}
+	}
+
+	if m := insertRegex.FindStringSubmatch(sql); m != nil {
+		columns := strings.Split(m[1], ",")
+		for _, row := range splitTuples(m[2]) {
+			for i, value := range row {
+				if i < len(columns) && l.columns[unquoteColumn(columns[i])] {
+					mark(value)
+				}
+			}
+		}
+	}
+
+	for _, m := range comparisonRegex.FindAllStringSubmatch(sql, -1) {
+		if l.columns[strings.ToLower(m[1])] {
+			mark("$" + m[2])
+		}
+	}
+
+	for _, m := range inRegex.FindAllStringSubmatch(sql, -1) {
+		if l.columns[strings.ToLower(m[1])] {
+			mark(m[2])
+		}
+	}
+	return sensitive
+}
+
+// splitTuples splits "($1,$2),($3,$4) RETURNING ..." into [[$1 $2] [$3 $4]].
+func splitTuples(values string) [][]string {
+	var (
+		rows  [][]string
+		row   []string
+		depth int
+		start int
+	)
+
+	for i, c := range values {
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				row = nil
+				start = i + 1
+			}
+		case ',':
+			if depth == 1 {
+				row = append(row, values[start:i])
+				start = i + 1
+			}
+		case ')':
+			if depth == 1 {
+				rows = append(rows, append(row, values[start:i]))
+			}
+			depth--
+			if depth < 0 {
+				return rows
+			}
+		default:
+			if depth == 0 && c != ' ' && c != ',' {
+				return rows
+			}
+		}
+	}
+	return rows
+}
+
+func unquoteColumn(column string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(column), `"`))
+}
@@ -0,0 +1,71 @@
+package gh_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+)
+
+type redactedUser struct {
+	ID        uint
+	Name      string
+	Password  string `gh:"sensitive"`
+	Diagnosis string `gh:"sensitive"`
+}
+
+func TestRedactingLogger(t *testing.T) {
+	l := gh.NewRedactingLogger(logger.Discard, gh.RedactPlaceholder, &redactedUser{}).Redact("nin")
+
+	tests := []struct {
+		name   string
+		sql    string
+		params []any
+		want   []any
+	}{
+		{
+			name:   "Insert",
+			sql:    `INSERT INTO "redacted_users" ("name","password","diagnosis") VALUES ($1,$2,$3),($4,$5,$6) RETURNING "id"`,
+			params: []any{"John", "secret", "flu", "Jane", "secret2", "malaria"},
+			want:   []any{"John", "[REDACTED]", "[REDACTED]", "Jane", "[REDACTED]", "[REDACTED]"},
+		},
+		{
+			name:   "Update",
+			sql:    `UPDATE "redacted_users" SET "name"=$1,"password"=$2 WHERE "id" = $3`,
+			params: []any{"John", "secret", 1},
+			want:   []any{"John", "[REDACTED]", 1},
+		},
+		{
+			name:   "Where",
+			sql:    `SELECT * FROM "redacted_users" WHERE nin = $1 AND diagnosis ILIKE $2 AND name = $3`,
+			params: []any{"CM123", "%flu%", "John"},
+			want:   []any{"[REDACTED]", "[REDACTED]", "John"},
+		},
+		{
+			name:   "In",
+			sql:    `SELECT * FROM "redacted_users" WHERE "redacted_users"."diagnosis" IN ($1,$2) AND id > $3`,
+			params: []any{"flu", "malaria", 10},
+			want:   []any{"[REDACTED]", "[REDACTED]", 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, params := l.ParamsFilter(context.Background(), tt.sql, tt.params...)
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.want, params)
+		})
+	}
+}
+
+func TestRedactingLoggerHash(t *testing.T) {
+	l := gh.NewRedactingLogger(logger.Discard, gh.RedactHash, &redactedUser{})
+	l2 := l.LogMode(logger.Info).(*gh.RedactingLogger)
+
+	_, params := l2.ParamsFilter(context.Background(), `SELECT * FROM users WHERE password = $1`, "secret")
+	assert.True(t, strings.HasPrefix(params[0].(string), "sha256:"))
+	assert.NotContains(t, params[0], "secret")
+}
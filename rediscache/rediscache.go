@@ -0,0 +1,87 @@
+// Package rediscache implements gh.Cache with Redis, sharing the results of Cached queries
+// between instances:
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	err := db.Use(&gh.CachePlugin{Cache: rediscache.New(client, "myapp:")})
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is a gh.Cache storing entries in Redis.
+type Cache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// New returns a Cache storing entries in client under keys starting with prefix, e.g "myapp:".
+func New(client redis.UniversalClient, prefix string) *Cache {
+	return &Cache{client: client, prefix: prefix}
+}
+
+// Get implements gh.Cache.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Set implements gh.Cache.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+// Delete implements gh.Cache.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+// AddToSet implements gh.Cache with SADD and EXPIRE, in a transaction.
+func (c *Cache) AddToSet(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	values := make([]any, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, c.prefix+key, values...)
+		pipe.Expire(ctx, c.prefix+key, ttl)
+		return nil
+	})
+	return err
+}
+
+// PopSet implements gh.Cache with SMEMBERS and DEL, in a transaction.
+func (c *Cache) PopSet(ctx context.Context, key string) ([]string, error) {
+	var members *redis.StringSliceCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		members = pipe.SMembers(ctx, c.prefix+key)
+		pipe.Del(ctx, c.prefix+key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members.Val(), nil
+}
@@ -0,0 +1,52 @@
+package rediscache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/gh"
+	"github.com/abiiranathan/gh/rediscache"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	var cache gh.Cache = rediscache.New(client, "test:")
+	ctx := context.Background()
+
+	_, found, err := cache.Get(ctx, "departments")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, cache.Set(ctx, "departments", []byte("cached"), time.Minute))
+	assert.True(t, server.Exists("test:departments"))
+	assert.Equal(t, time.Minute, server.TTL("test:departments"))
+
+	data, found, err := cache.Get(ctx, "departments")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "cached", string(data))
+
+	require.NoError(t, cache.Delete(ctx, "departments", "missing"))
+	assert.False(t, server.Exists("test:departments"))
+
+	require.NoError(t, cache.AddToSet(ctx, "tag", time.Hour, "departments"))
+	require.NoError(t, cache.AddToSet(ctx, "tag", time.Hour, "department:1", "departments"))
+	assert.Equal(t, time.Hour, server.TTL("test:tag"))
+
+	members, err := cache.PopSet(ctx, "tag")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"departments", "department:1"}, members)
+	assert.False(t, server.Exists("test:tag"))
+
+	members, err = cache.PopSet(ctx, "tag")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
@@ -0,0 +1,121 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrTruncateCascade is returned by ResetDatabase when a table it does not truncate, e.g a kept one,
+// has a foreign key to a truncated table, so TRUNCATE ... CASCADE would truncate it too.
+var ErrTruncateCascade = errors.New("truncate would cascade to tables not reset")
+
+// ResetOptions are options for ResetDatabase.
+type ResetOptions struct {
+	// Schemas are the schemas of the tables truncated. Default: all except the system ones.
+	Schemas []string
+
+	// Keep are the tables not truncated, optionally schema qualified. Default: schema_migrations.
+	Keep []string
+
+	// Seeders, if set, are run after the truncation, in Environment.
+	// The seeds table is truncated too unless kept, so every seeder runs again.
+	Seeders     *SeederRegistry
+	Environment string
+}
+
+// ResetDatabase truncates the tables of the database with RESTART IDENTITY CASCADE, except the
+// tables in opts.Keep and those owned by extensions (e.g PostGIS's spatial_ref_sys), then re-seeds
+// it if opts.Seeders is set. It returns the truncated tables, e.g for test suites and preview environments.
+//
+// If a kept table, or a table outside opts.Schemas, has a foreign key to a truncated table, nothing is
+// truncated and ErrTruncateCascade is returned: CASCADE would truncate it too. Keep the referenced
+// tables as well, or drop the keep.
+func ResetDatabase(db *gorm.DB, opts ResetOptions) ([]string, error) {
+	keep := opts.Keep
+	if keep == nil {
+		keep = []string{"schema_migrations"}
+	}
+
+	filter := "n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'"
+	args := []any{}
+	if len(opts.Schemas) > 0 {
+		filter = "n.nspname IN ?"
+		args = append(args, opts.Schemas)
+	}
+
+	type tableRow struct {
+		Schema string
+		Name   string
+	}
+
+	rows := []tableRow{}
+	err := db.Raw(`SELECT n.nspname AS schema, c.relname AS name
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+filter+`
+			AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = c.oid AND d.deptype = 'e')
+		ORDER BY 1, 2`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	tables, quoted := []string{}, []string{}
+	for _, row := range rows {
+		name := row.Schema + "." + row.Name
+		if slices.Contains(keep, row.Name) || slices.Contains(keep, name) {
+			continue
+		}
+		tables = append(tables, name)
+		quoted = append(quoted, quoteName(row.Schema)+"."+quoteName(row.Name))
+	}
+
+	if len(tables) > 0 {
+		if err := checkTruncateCascade(db, tables); err != nil {
+			return nil, err
+		}
+		if err := db.Exec("TRUNCATE " + strings.Join(quoted, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			return nil, fmt.Errorf("failed to truncate tables: %w", err)
+		}
+	}
+
+	if opts.Seeders != nil {
+		if _, err := opts.Seeders.Run(db.Statement.Context, opts.Environment); err != nil {
+			return tables, err
+		}
+	}
+	return tables, nil
+}
+
+// checkTruncateCascade returns ErrTruncateCascade if tables not in tables reference them.
+// Partitions are skipped, they are truncated with their parent.
+func checkTruncateCascade(db *gorm.DB, tables []string) error {
+	type reference struct {
+		Referencing string
+		Referenced  string
+	}
+
+	refs := []reference{}
+	err := db.Raw(`SELECT DISTINCT n.nspname || '.' || c.relname AS referencing, rn.nspname || '.' || r.relname AS referenced
+		FROM pg_constraint k
+		JOIN pg_class c ON c.oid = k.conrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_class r ON r.oid = k.confrelid JOIN pg_namespace rn ON rn.oid = r.relnamespace
+		WHERE k.contype = 'f' AND NOT c.relispartition AND rn.nspname || '.' || r.relname IN ?
+		ORDER BY 1, 2`, tables).Scan(&refs).Error
+	if err != nil {
+		return fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+
+	dependents := []string{}
+	for _, ref := range refs {
+		if !slices.Contains(tables, ref.Referencing) {
+			dependents = append(dependents, ref.Referencing+" references "+ref.Referenced)
+		}
+	}
+	if len(dependents) > 0 {
+		return fmt.Errorf("%w: %s", ErrTruncateCascade, strings.Join(dependents, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gh_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetDatabase(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery("NOT EXISTS \\(SELECT 1 FROM pg_depend d").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name"}).
+			AddRow("audit", "logs").
+			AddRow("public", "patients").
+			AddRow("public", "schema_migrations").
+			AddRow("public", "seeds"))
+	mock.ExpectQuery("FROM pg_constraint k").WithArgs("audit.logs", "public.patients", "public.seeds").
+		WillReturnRows(sqlmock.NewRows([]string{"referencing", "referenced"}).AddRow("audit.logs", "public.patients"))
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "audit"."logs", "public"."patients", "public"."seeds" RESTART IDENTITY CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	tables, err := gh.ResetDatabase(db, gh.ResetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audit.logs", "public.patients", "public.seeds"}, tables)
+
+	// Re-seeding in a disallowed environment fails after the truncation.
+	mock.ExpectQuery(regexp.QuoteMeta("n.nspname IN ($1)")).WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name"}).AddRow("public", "patients").AddRow("public", "seeds"))
+	mock.ExpectQuery("FROM pg_constraint k").WithArgs("public.patients").
+		WillReturnRows(sqlmock.NewRows([]string{"referencing", "referenced"}))
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "public"."patients" RESTART`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = gh.ResetDatabase(db, gh.ResetOptions{
+		Schemas:     []string{"public"},
+		Keep:        []string{"public.seeds"},
+		Seeders:     gh.NewSeederRegistry(db),
+		Environment: "production",
+	})
+	assert.ErrorIs(t, err, gh.ErrSeedingNotAllowed)
+
+	// A kept table referencing a truncated one would be truncated by the cascade.
+	mock.ExpectQuery("NOT EXISTS \\(SELECT 1 FROM pg_depend d").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name"}).AddRow("public", "patients").AddRow("public", "reports"))
+	mock.ExpectQuery("FROM pg_constraint k").WithArgs("public.patients").
+		WillReturnRows(sqlmock.NewRows([]string{"referencing", "referenced"}).AddRow("public.reports", "public.patients"))
+
+	_, err = gh.ResetDatabase(db, gh.ResetOptions{Keep: []string{"reports"}})
+	assert.ErrorIs(t, err, gh.ErrTruncateCascade)
+	assert.ErrorContains(t, err, "public.reports references public.patients")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSetting is returned when a RLS session variable is not of the form prefix.name.
+var ErrInvalidSetting = errors.New("invalid session setting name")
+
+var settingRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.[a-z_][a-z0-9_]*$`)
+
+// WithRLS runs fn inside a transaction in which the given session variables are set with SET LOCAL,
+// so that Postgres row level security policies (see RLSPolicy) see the right identity.
+// Variables are reset when the transaction ends.
+//
+// Usage:
+//
+//	err := gdb.WithRLS(ctx, map[string]string{"app.current_user": "42", "app.tenant": "7"}, func(tx *gh.GormDB) error {
+//		return tx.Find(&invoices)
+//	})
+func (gdb *GormDB) WithRLS(ctx context.Context, settings map[string]string, fn func(*GormDB) error) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		if !settingRegex.MatchString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidSetting, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return gdb.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			err := tx.Session(&gorm.Session{NewDB: true}).
+				Exec("SELECT set_config(?, ?, true)", name, settings[name]).Error
+			if err != nil {
+				return fmt.Errorf("failed to set %s: %w", name, err)
+			}
+		}
+		return fn(&GormDB{db: tx})
+	})
+}
+
+// RLSPolicy describes a row level security policy restricting a table to the rows
+// whose Column matches the session variable Setting.
+type RLSPolicy struct {
+	Name    string // policy name, default: <table>_<column>_policy
+	Column  string // column compared with the setting, e.g tenant_id
+	Setting string // session variable, e.g app.tenant
+	Cast    string // type the setting is cast to, e.g bigint. If empty, the column is compared as text.
+	Force   bool   // apply the policy to the table owner too (FORCE ROW LEVEL SECURITY)
+}
+
+// SQL returns the statements that enable row level security on model's table and (re)create the policy.
+func (p RLSPolicy) SQL(db *gorm.DB, model any) ([]string, error) {
+	if !settingRegex.MatchString(p.Setting) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSetting, p.Setting)
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+
+	field := stmt.Schema.LookUpField(p.Column)
+	if field == nil {
+		return nil, fmt.Errorf("model %T has no %s column", model, p.Column)
+	}
+
+	name := p.Name
+	if name == "" {
+		name = stmt.Table + "_" + field.DBName + "_policy"
+	}
+
+	condition := fmt.Sprintf("%s::text = current_setting(%s, true)", quoteIdent(field.DBName), quoteLiteral(p.Setting))
+	if p.Cast != "" {
+		condition = fmt.Sprintf("%s = current_setting(%s, true)::%s", quoteIdent(field.DBName), quoteLiteral(p.Setting), p.Cast)
+	}
+
+	table := quoteIdent(stmt.Table)
+	stmts := []string{fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table)}
+	if p.Force {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", table))
+	}
+
+	stmts = append(stmts,
+		fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", quoteIdent(name), table),
+		fmt.Sprintf("CREATE POLICY %s ON %s USING (%s) WITH CHECK (%s)", quoteIdent(name), table, condition, condition),
+	)
+	return stmts, nil
+}
+
+// Apply executes the policy statements for model inside a transaction.
+func (p RLSPolicy) Apply(db *gorm.DB, model any) error {
+	stmts, err := p.SQL(db, model)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range stmts {
+			if err := tx.Exec(sql).Error; err != nil {
+				return fmt.Errorf("failed to apply RLS policy: %w", err)
+			}
+		}
+		return nil
+	})
+}
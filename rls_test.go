@@ -0,0 +1,36 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type rlsInvoice struct {
+	ID       uint
+	TenantID int64
+}
+
+func TestRLSPolicySQL(t *testing.T) {
+	db := dryRunDB(t)
+
+	stmts, err := gh.RLSPolicy{Column: "tenant_id", Setting: "app.tenant", Cast: "bigint", Force: true}.SQL(db, &rlsInvoice{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`ALTER TABLE "rls_invoices" ENABLE ROW LEVEL SECURITY`,
+		`ALTER TABLE "rls_invoices" FORCE ROW LEVEL SECURITY`,
+		`DROP POLICY IF EXISTS "rls_invoices_tenant_id_policy" ON "rls_invoices"`,
+		`CREATE POLICY "rls_invoices_tenant_id_policy" ON "rls_invoices" USING ("tenant_id" = current_setting('app.tenant', true)::bigint) WITH CHECK ("tenant_id" = current_setting('app.tenant', true)::bigint)`,
+	}, stmts)
+
+	_, err = gh.RLSPolicy{Column: "tenant_id", Setting: "tenant"}.SQL(db, &rlsInvoice{})
+	assert.ErrorIs(t, err, gh.ErrInvalidSetting)
+
+	_, err = gh.RLSPolicy{Column: "clinic_id", Setting: "app.tenant"}.SQL(db, &rlsInvoice{})
+	assert.Error(t, err)
+
+	err = gh.WrapDB(db).WithRLS(context.Background(), map[string]string{"app.user; --": "1"}, nil)
+	assert.ErrorIs(t, err, gh.ErrInvalidSetting)
+}
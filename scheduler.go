@@ -0,0 +1,229 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScheduledTask is the persisted state of a task registered with a Scheduler,
+// stored in the scheduled_tasks table.
+type ScheduledTask struct {
+	Name      string     `gorm:"primaryKey" json:"name"`
+	Schedule  string     `gorm:"not null" json:"schedule"`
+	NextRunAt time.Time  `gorm:"not null" json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	LastError string     `json:"last_error"` // empty if the last run succeeded
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName implements gorm's Tabler interface.
+func (ScheduledTask) TableName() string {
+	return "scheduled_tasks"
+}
+
+// TaskFunc is the function run by a scheduled task.
+type TaskFunc func(ctx context.Context) error
+
+type registeredTask struct {
+	schedule *cronSchedule
+	spec     string
+	fn       TaskFunc
+}
+
+// Scheduler runs registered Go functions on cron schedules. Schedules and the last run state
+// are stored in the scheduled_tasks table and every run is guarded by an advisory lock,
+// so with any number of replicas running the same Scheduler, a task runs once per activation.
+// A missed activation (e.g all replicas were down) runs once when a replica is back.
+//
+// The scheduled_tasks table must be migrated before use:
+//
+//	db.AutoMigrate(&gh.ScheduledTask{})
+//
+//	scheduler := gh.NewScheduler(db)
+//	scheduler.Register("refresh-income", "0 2 * * *", func(ctx context.Context) error {
+//		return gh.RefreshMaterializedView(ctx, db, "income_per_billable", true)
+//	})
+//	err := scheduler.Run(ctx)
+type Scheduler struct {
+	// PollInterval is the interval between checks for due tasks. Default: 15s.
+	PollInterval time.Duration
+
+	// Location is the time zone of the schedules. Default: time.Local.
+	Location *time.Location
+
+	db    *gorm.DB
+	mu    sync.Mutex
+	tasks map[string]*registeredTask
+}
+
+// NewScheduler creates a Scheduler storing its state in db.
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db, tasks: map[string]*registeredTask{}}
+}
+
+// Register adds a task running fn on schedule, a standard 5-field cron expression
+// (minute hour day-of-month month day-of-week, e.g "*/15 9-17 * * 1-5"),
+// a descriptor (@yearly, @monthly, @weekly, @daily, @hourly) or "@every <duration>".
+// Tasks must be registered before Run is called.
+func (s *Scheduler) Register(name, schedule string, fn TaskFunc) error {
+	parsed, err := parseCron(schedule)
+	if err != nil {
+		return err
+	}
+
+	if parsed.next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %q never runs", schedule)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[name]; exists {
+		return fmt.Errorf("task %s is already registered", name)
+	}
+	s.tasks[name] = &registeredTask{schedule: parsed, spec: schedule, fn: fn}
+	return nil
+}
+
+// Run saves the registered schedules and runs the due tasks until ctx is canceled.
+// It then waits for the running tasks and returns ctx.Err().
+// Task failures are recorded in LastError and logged with db's logger.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.saveSchedules(ctx); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(durationOr(s.PollInterval, 15*time.Second))
+	defer ticker.Stop()
+
+	for {
+		if err := s.runDue(ctx, &wg); err != nil && ctx.Err() == nil {
+			s.db.Logger.Error(ctx, "scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// saveSchedules inserts the registered tasks, rescheduling the tasks whose schedule changed.
+func (s *Scheduler) saveSchedules(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for name, task := range s.tasks {
+		row := ScheduledTask{Name: name, Schedule: task.spec, NextRunAt: task.schedule.next(now)}
+		err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"schedule": gorm.Expr("EXCLUDED.schedule"),
+				"next_run_at": gorm.Expr("CASE WHEN scheduled_tasks.schedule = EXCLUDED.schedule " +
+					"THEN scheduled_tasks.next_run_at ELSE EXCLUDED.next_run_at END"),
+			}),
+		}).Create(&row).Error
+		if err != nil {
+			return fmt.Errorf("failed to save schedule of task %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runDue starts the registered tasks that are due.
+func (s *Scheduler) runDue(ctx context.Context, wg *sync.WaitGroup) error {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.tasks))
+	for name := range s.tasks {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	due := []string{}
+	err := s.db.WithContext(ctx).Model(&ScheduledTask{}).
+		Where("name IN ? AND next_run_at <= ?", names, s.now()).
+		Pluck("name", &due).Error
+	if err != nil {
+		return err
+	}
+
+	for _, name := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.runTask(ctx, name); err != nil && ctx.Err() == nil {
+				s.db.Logger.Error(ctx, "scheduler: task %s: %v", name, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// runTask runs the task name if it is still due once its advisory lock is acquired.
+// If another replica holds the lock, the task is skipped.
+func (s *Scheduler) runTask(ctx context.Context, name string) error {
+	lock, err := TryAdvisoryLock(ctx, s.db, AdvisoryKey("gh:scheduler:"+name))
+	if err != nil || lock == nil {
+		return err
+	}
+	defer lock.Unlock(context.WithoutCancel(ctx))
+
+	// Another replica may have run it between the poll and the lock.
+	var state ScheduledTask
+	if err := s.db.WithContext(ctx).Where("name = ?", name).Take(&state).Error; err != nil {
+		return err
+	}
+
+	start := s.now()
+	if state.NextRunAt.After(start) {
+		return nil
+	}
+
+	s.mu.Lock()
+	task := s.tasks[name]
+	s.mu.Unlock()
+
+	runErr := s.call(ctx, task.fn)
+
+	updates := map[string]any{
+		"last_run_at": start,
+		"next_run_at": task.schedule.next(s.now()),
+		"last_error":  "",
+	}
+	if runErr != nil {
+		updates["last_error"] = runErr.Error()
+	}
+
+	err = s.db.WithContext(context.WithoutCancel(ctx)).Model(&state).Updates(updates).Error
+	if err != nil {
+		return err
+	}
+	return runErr
+}
+
+// call runs fn, converting a panic to an error.
+func (s *Scheduler) call(ctx context.Context, fn TaskFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Location != nil {
+		return time.Now().In(s.Location)
+	}
+	return time.Now()
+}
@@ -0,0 +1,69 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRegister(t *testing.T) {
+	scheduler := gh.NewScheduler(dryRunDB(t))
+	noop := func(ctx context.Context) error { return nil }
+
+	assert.NoError(t, scheduler.Register("refresh", "@daily", noop))
+	assert.Error(t, scheduler.Register("refresh", "@hourly", noop))
+	assert.Error(t, scheduler.Register("report", "* * *", noop))
+}
+
+func TestSchedulerRun(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "scheduled_tasks" ("name","schedule","next_run_at","last_run_at","last_error","updated_at") `+
+		`VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("name") DO UPDATE SET "next_run_at"=CASE WHEN scheduled_tasks.schedule = EXCLUDED.schedule `+
+		`THEN scheduled_tasks.next_run_at ELSE EXCLUDED.next_run_at END,"schedule"=EXCLUDED.schedule`)).
+		WithArgs("refresh", "@daily", sqlmock.AnyArg(), nil, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "name" FROM "scheduled_tasks" WHERE name IN ($1) AND next_run_at <= $2`)).
+		WithArgs("refresh", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("refresh"))
+
+	key := gh.AdvisoryKey("gh:scheduler:refresh")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "scheduled_tasks" WHERE name = $1 LIMIT $2`)).
+		WithArgs("refresh", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "schedule", "next_run_at"}).
+			AddRow("refresh", "@daily", time.Now().Add(-time.Minute)))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "scheduled_tasks" SET "last_error"=$1,"last_run_at"=$2,"next_run_at"=$3,"updated_at"=$4 WHERE "name" = $5`)).
+		WithArgs("", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "refresh").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	ran := make(chan struct{})
+	scheduler := gh.NewScheduler(db)
+	scheduler.PollInterval = time.Hour
+	assert.NoError(t, scheduler.Register("refresh", "@daily", func(ctx context.Context) error {
+		close(ran)
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("task did not run")
+	}
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
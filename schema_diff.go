@@ -0,0 +1,191 @@
+package gh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SchemaDiffKind is the kind of a SchemaDifference.
+type SchemaDiffKind string
+
+const (
+	TableAdded        SchemaDiffKind = "table_added"
+	TableRemoved      SchemaDiffKind = "table_removed"
+	ColumnAdded       SchemaDiffKind = "column_added"
+	ColumnRemoved     SchemaDiffKind = "column_removed"
+	ColumnChanged     SchemaDiffKind = "column_changed" // type, nullability or default
+	IndexAdded        SchemaDiffKind = "index_added"
+	IndexRemoved      SchemaDiffKind = "index_removed"
+	IndexChanged      SchemaDiffKind = "index_changed"
+	ForeignKeyAdded   SchemaDiffKind = "foreign_key_added"
+	ForeignKeyRemoved SchemaDiffKind = "foreign_key_removed"
+	ForeignKeyChanged SchemaDiffKind = "foreign_key_changed"
+)
+
+// SchemaDifference is a difference between two schemas, from a to b.
+type SchemaDifference struct {
+	Kind  SchemaDiffKind `json:"kind"`
+	Table string         `json:"table"`          // schema qualified
+	Name  string         `json:"name,omitempty"` // column, index or foreign key
+	From  string         `json:"from,omitempty"` // definition in a
+	To    string         `json:"to,omitempty"`   // definition in b
+}
+
+// String describes the difference, e.g "~ column public.visits.notes: text -> varchar(255) NOT NULL".
+func (d SchemaDifference) String() string {
+	kind := string(d.Kind)
+	idx := strings.LastIndexByte(kind, '_')
+	object := strings.ReplaceAll(kind[:idx], "_", " ")
+
+	name := d.Table
+	if d.Name != "" {
+		name += "." + d.Name
+	}
+
+	switch kind[idx+1:] {
+	case "added":
+		return strings.TrimSpace(fmt.Sprintf("+ %s %s %s", object, name, d.To))
+	case "removed":
+		return strings.TrimSpace(fmt.Sprintf("- %s %s %s", object, name, d.From))
+	}
+	return fmt.Sprintf("~ %s %s: %s -> %s", object, name, d.From, d.To)
+}
+
+// SchemaDiff is the list of differences returned by DiffSchemas.
+type SchemaDiff []SchemaDifference
+
+// String returns the differences, one per line.
+func (d SchemaDiff) String() string {
+	var b strings.Builder
+	for _, difference := range d {
+		b.WriteString(difference.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// SchemaSnapshot returns the schema of db as Introspect does. Snapshots can be stored as JSON
+// and compared with DiffSchemas, e.g in CI to detect drift between environments.
+func SchemaSnapshot(db *gorm.DB, schemas ...string) (*DatabaseSchema, error) {
+	return Introspect(db, schemas...)
+}
+
+// DiffSchemas returns the differences from schema a to schema b: tables, columns, indexes
+// and foreign keys added to b, removed from a or changed. It is empty if the schemas match.
+func DiffSchemas(a, b *DatabaseSchema) SchemaDiff {
+	diff := SchemaDiff{}
+	tablesA, tablesB := tablesByName(a), tablesByName(b)
+
+	for _, name := range sortedUnion(tablesA, tablesB) {
+		ta, inA := tablesA[name]
+		tb, inB := tablesB[name]
+		switch {
+		case !inA:
+			diff = append(diff, SchemaDifference{Kind: TableAdded, Table: name})
+		case !inB:
+			diff = append(diff, SchemaDifference{Kind: TableRemoved, Table: name})
+		default:
+			diff = append(diff, diffTables(name, ta, tb)...)
+		}
+	}
+	return diff
+}
+
+func diffTables(table string, a, b *TableInfo) SchemaDiff {
+	diff := SchemaDiff{}
+
+	columnsA, columnsB := map[string]string{}, map[string]string{}
+	for _, c := range a.Columns {
+		columnsA[c.Name] = columnDefinition(c)
+	}
+	for _, c := range b.Columns {
+		columnsB[c.Name] = columnDefinition(c)
+	}
+	diff = append(diff, diffDefinitions(table, columnsA, columnsB, ColumnAdded, ColumnRemoved, ColumnChanged)...)
+
+	indexesA, indexesB := map[string]string{}, map[string]string{}
+	for _, i := range a.Indexes {
+		indexesA[i.Name] = i.Definition
+	}
+	for _, i := range b.Indexes {
+		indexesB[i.Name] = i.Definition
+	}
+	diff = append(diff, diffDefinitions(table, indexesA, indexesB, IndexAdded, IndexRemoved, IndexChanged)...)
+
+	keysA, keysB := map[string]string{}, map[string]string{}
+	for _, fk := range a.ForeignKeys {
+		keysA[fk.Name] = foreignKeyDefinition(fk)
+	}
+	for _, fk := range b.ForeignKeys {
+		keysB[fk.Name] = foreignKeyDefinition(fk)
+	}
+	diff = append(diff, diffDefinitions(table, keysA, keysB, ForeignKeyAdded, ForeignKeyRemoved, ForeignKeyChanged)...)
+	return diff
+}
+
+// diffDefinitions compares the definitions of named objects of a table.
+func diffDefinitions(table string, a, b map[string]string, added, removed, changed SchemaDiffKind) SchemaDiff {
+	diff := SchemaDiff{}
+	for _, name := range sortedUnion(a, b) {
+		from, inA := a[name]
+		to, inB := b[name]
+		switch {
+		case !inA:
+			diff = append(diff, SchemaDifference{Kind: added, Table: table, Name: name, To: to})
+		case !inB:
+			diff = append(diff, SchemaDifference{Kind: removed, Table: table, Name: name, From: from})
+		case from != to:
+			diff = append(diff, SchemaDifference{Kind: changed, Table: table, Name: name, From: from, To: to})
+		}
+	}
+	return diff
+}
+
+func columnDefinition(c ColumnInfo) string {
+	definition := c.Type
+	if !c.Nullable {
+		definition += " NOT NULL"
+	}
+	if c.Default != nil {
+		definition += " DEFAULT " + *c.Default
+	}
+	return definition
+}
+
+func foreignKeyDefinition(fk ForeignKeyInfo) string {
+	return fmt.Sprintf("(%s) REFERENCES %s.%s (%s) ON UPDATE %s ON DELETE %s",
+		strings.Join(fk.Columns, ", "), fk.RefSchema, fk.RefTable, strings.Join(fk.RefColumns, ", "), fk.OnUpdate, fk.OnDelete)
+}
+
+func tablesByName(s *DatabaseSchema) map[string]*TableInfo {
+	tables := map[string]*TableInfo{}
+	if s == nil {
+		return tables
+	}
+
+	for i := range s.Schemas {
+		for j := range s.Schemas[i].Tables {
+			t := &s.Schemas[i].Tables[j]
+			tables[t.Schema+"."+t.Name] = t
+		}
+	}
+	return tables
+}
+
+// sortedUnion returns the keys of a and b, sorted.
+func sortedUnion[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
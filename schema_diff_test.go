@@ -0,0 +1,49 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	now := "now()"
+	staging := &gh.DatabaseSchema{Schemas: []gh.SchemaInfo{{Name: "public", Tables: []gh.TableInfo{
+		{Schema: "public", Name: "patients", Columns: []gh.ColumnInfo{
+			{Name: "id", Type: "bigint"},
+			{Name: "name", Type: "character varying(100)", Nullable: true},
+			{Name: "phone", Type: "text", Nullable: true},
+		}, Indexes: []gh.IndexInfo{
+			{Name: "idx_patients_name", Definition: "CREATE INDEX idx_patients_name ON public.patients USING btree (name)"},
+		}},
+		{Schema: "public", Name: "audit_logs"},
+	}}}}
+
+	production := &gh.DatabaseSchema{Schemas: []gh.SchemaInfo{{Name: "public", Tables: []gh.TableInfo{
+		{Schema: "public", Name: "patients", Columns: []gh.ColumnInfo{
+			{Name: "id", Type: "bigint"},
+			{Name: "name", Type: "character varying(255)"},
+			{Name: "created_at", Type: "timestamp with time zone", Default: &now},
+		}, Indexes: []gh.IndexInfo{
+			{Name: "idx_patients_name", Definition: "CREATE UNIQUE INDEX idx_patients_name ON public.patients USING btree (name)"},
+		}},
+		{Schema: "public", Name: "visits"},
+	}}}}
+
+	assert.Empty(t, gh.DiffSchemas(staging, staging))
+
+	diff := gh.DiffSchemas(staging, production)
+	require.Len(t, diff, 6)
+	assert.Equal(t, gh.SchemaDifference{Kind: gh.TableRemoved, Table: "public.audit_logs"}, diff[0])
+	assert.Equal(t, `- table public.audit_logs
++ column public.patients.created_at timestamp with time zone NOT NULL DEFAULT now()
+~ column public.patients.name: character varying(100) -> character varying(255) NOT NULL
+- column public.patients.phone text
+~ index public.patients.idx_patients_name: CREATE INDEX idx_patients_name ON public.patients USING btree (name) -> CREATE UNIQUE INDEX idx_patients_name ON public.patients USING btree (name)
++ table public.visits
+`, diff.String())
+
+	assert.Len(t, gh.DiffSchemas(nil, production), 2)
+}
@@ -0,0 +1,248 @@
+package gh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// PII kinds of the `gh:"pii:<kind>"` struct tag, see ScrubDatabase.
+const (
+	PIIName      = "name"       // fake full name, e.g "Grace Okello"
+	PIIFirstName = "first_name" // fake first name
+	PIILastName  = "last_name"  // fake last name
+	PIIEmail     = "email"      // e.g user-3fa85f64c1@example.com
+	PIIPhone     = "phone"      // fake phone number in the +1555 fictional range
+	PIIAddress   = "address"    // fake street address
+	PIIHash      = "hash"       // 16 hex characters of the salted SHA-256 of the value, the default
+	PIIRedact    = "redact"     // [REDACTED]
+	PIINull      = "null"       // NULL
+)
+
+var (
+	fakeFirstNames = []string{
+		"Grace", "John", "Amina", "Peter", "Sarah", "David", "Esther", "Joseph", "Ruth", "Samuel",
+		"Mary", "Daniel", "Faith", "Moses", "Joan", "Isaac", "Rose", "Paul", "Ann", "Brian",
+	}
+	fakeLastNames = []string{
+		"Okello", "Smith", "Nakato", "Mugisha", "Johnson", "Achieng", "Brown", "Kato", "Namubiru", "Wilson",
+		"Otieno", "Taylor", "Nansubuga", "Ssempala", "Clark", "Auma", "Walker", "Byaruhanga", "Hall", "Akello",
+	}
+	fakeStreets = []string{"Kampala Road", "Main Street", "Oak Avenue", "Church Lane", "Hill Road", "Park Drive"}
+)
+
+// ScrubOptions are the options of ScrubDatabase.
+type ScrubOptions struct {
+	// Database is the name of the database scrubbed, checked against current_database()
+	// so that production is not scrubbed by mistake. Required.
+	Database string
+
+	// Salt is mixed in the values before hashing them. Fakes are derived from the salted hash, so the
+	// same value gets the same fake in every table and joins on scrubbed columns still work.
+	// Default: random, so fakes differ between runs.
+	Salt string
+
+	// BatchSize is the number of rows updated per transaction. Default: 1000.
+	BatchSize int
+
+	// Fakers are custom PII kinds, called with the value and a seed derived from its salted hash.
+	Fakers map[string]func(value string, seed uint64) any
+}
+
+// piiColumn is a column of a model tagged `gh:"pii"`.
+type piiColumn struct {
+	field *schema.Field
+	kind  string
+}
+
+// ScrubDatabase rewrites the columns of models tagged `gh:"pii:<kind>"` with fakes or hashes, e.g to
+// produce a safe staging copy of production data. A bare `gh:"pii"` hashes the value. NULL and empty
+// values are kept as is. Rows are updated in batches by primary key, without hooks.
+//
+//	type Patient struct {
+//		ID    uint
+//		Name  string  `gh:"pii:name"`
+//		Phone *string `gh:"pii:phone"`
+//		NIN   string  `gh:"pii"`
+//	}
+//
+//	err := gh.ScrubDatabase(ctx, stagingDB, gh.ScrubOptions{Database: "clinic_staging"}, &Patient{}, &Visit{})
+func ScrubDatabase(ctx context.Context, db *gorm.DB, opts ScrubOptions, models ...any) error {
+	if opts.Database == "" {
+		return errors.New("scrub database name is required")
+	}
+
+	db = db.WithContext(ctx)
+	var current string
+	if err := db.Raw("SELECT current_database()").Scan(&current).Error; err != nil {
+		return fmt.Errorf("failed to get current database: %w", err)
+	}
+	if current != opts.Database {
+		return fmt.Errorf("refusing to scrub %s: connected to %s", opts.Database, current)
+	}
+
+	if opts.Salt == "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		opts.Salt = hex.EncodeToString(salt)
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1000
+	}
+
+	for _, model := range models {
+		if err := scrubModel(db, model, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrubModel scrubs the PII columns of model, in batches ordered by primary key.
+func scrubModel(db *gorm.DB, model any, opts ScrubOptions) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	columns := []piiColumn{}
+	for _, field := range stmt.Schema.Fields {
+		kind, ok := ghTag(field)["PII"]
+		if !ok || field.DBName == "" {
+			continue
+		}
+		if kind == "PII" { // bare `gh:"pii"`, parsed with its own name as value
+			kind = PIIHash
+		}
+
+		_, custom := opts.Fakers[kind]
+		switch kind {
+		case PIINull:
+		case PIIName, PIIFirstName, PIILastName, PIIEmail, PIIPhone, PIIAddress, PIIHash, PIIRedact:
+			if t := field.IndirectFieldType; t.Kind() != reflect.String {
+				return fmt.Errorf("pii column %s.%s must be a string, got %s", stmt.Table, field.DBName, t)
+			}
+		default:
+			if !custom {
+				return fmt.Errorf("unknown pii kind %q of %s.%s", kind, stmt.Table, field.DBName)
+			}
+		}
+		columns = append(columns, piiColumn{field: field, kind: kind})
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	ks, err := newKeyset(db, model, nil)
+	if err != nil {
+		return err
+	}
+
+	selects := []string{}
+	for _, c := range ks.columns {
+		selects = append(selects, c.field.DBName)
+	}
+	for _, c := range columns {
+		selects = append(selects, c.field.DBName)
+	}
+
+	var last []any
+	for {
+		rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+		// Soft deleted rows are copied to staging too, their PII must be scrubbed.
+		query := db.Unscoped().Model(model).Select(selects).Order(ks.order(false)).Limit(opts.BatchSize)
+		if last != nil {
+			query = query.Where(ks.after(last, false))
+		}
+		if err := query.Find(rows.Interface()).Error; err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", stmt.Table, err)
+		}
+
+		slice := rows.Elem()
+		if slice.Len() == 0 {
+			return nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < slice.Len(); i++ {
+				rv := slice.Index(i)
+				values := map[string]any{}
+				for _, c := range columns {
+					value, zero := c.field.ValueOf(db.Statement.Context, rv)
+					if zero {
+						continue
+					}
+
+					text := fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)).Interface())
+					values[c.field.DBName] = scrubValue(c.kind, text, opts)
+				}
+				if len(values) == 0 {
+					continue
+				}
+
+				where := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Table(stmt.Table)
+				for _, field := range stmt.Schema.PrimaryFields {
+					where = where.Where(quoteName(field.DBName)+" = ?", field.ReflectValueOf(db.Statement.Context, rv).Interface())
+				}
+				if err := where.UpdateColumns(values).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", stmt.Table, err)
+		}
+
+		if slice.Len() < opts.BatchSize {
+			return nil
+		}
+
+		lastRow := slice.Index(slice.Len() - 1)
+		last = make([]any, len(ks.columns))
+		for i, c := range ks.columns {
+			last[i] = c.field.ReflectValueOf(db.Statement.Context, lastRow).Interface()
+		}
+	}
+}
+
+// scrubValue returns the replacement of value for the PII kind.
+func scrubValue(kind, value string, opts ScrubOptions) any {
+	sum := sha256.Sum256([]byte(opts.Salt + "\x00" + value))
+	seed := binary.BigEndian.Uint64(sum[:8])
+	pick := func(values []string, n uint64) string {
+		return values[n%uint64(len(values))]
+	}
+
+	switch kind {
+	case PIIName:
+		return pick(fakeFirstNames, seed) + " " + pick(fakeLastNames, seed>>32)
+	case PIIFirstName:
+		return pick(fakeFirstNames, seed)
+	case PIILastName:
+		return pick(fakeLastNames, seed>>32)
+	case PIIEmail:
+		return "user-" + hex.EncodeToString(sum[:5]) + "@example.com"
+	case PIIPhone:
+		return fmt.Sprintf("+1555%07d", seed%10_000_000)
+	case PIIAddress:
+		return fmt.Sprintf("%d %s", seed%999+1, pick(fakeStreets, seed>>32))
+	case PIIHash:
+		return hex.EncodeToString(sum[:8])
+	case PIIRedact:
+		return "[REDACTED]"
+	case PIINull:
+		return nil
+	}
+	return opts.Fakers[kind](value, seed)
+}
@@ -0,0 +1,90 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type scrubPatient struct {
+	ID        uint
+	Name      string  `gh:"pii:name"`
+	Email     string  `gh:"pii:email"`
+	Phone     *string `gh:"pii:phone"`
+	NIN       string  `gh:"pii"`
+	Diagnosis string
+}
+
+func TestScrubDatabase(t *testing.T) {
+	db, mock := mockDB(t)
+	ctx := context.Background()
+	opts := gh.ScrubOptions{Database: "clinic_staging", Salt: "pepper", BatchSize: 2}
+
+	mock.ExpectQuery(`SELECT current_database\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_database"}).AddRow("clinic_staging"))
+	mock.ExpectQuery(`SELECT "id","name","email","phone","nin" FROM "scrub_patients" ORDER BY "scrub_patients"."id" LIMIT \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "phone", "nin"}).
+			AddRow(1, "John Doe", "john@example.org", "+256700000001", "CM1234").
+			AddRow(2, "Jane Roe", "", nil, ""))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "scrub_patients" SET "email"=\$1,"name"=\$2,"nin"=\$3,"phone"=\$4 WHERE "id" = \$5`).
+		WithArgs("user-69bb753c99@example.com", "Grace Otieno", "82eb432064b81883", "+15550370547", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "scrub_patients" SET "name"=\$1 WHERE "id" = \$2`).
+		WithArgs("Grace Akello", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT "id","name","email","phone","nin" FROM "scrub_patients" WHERE "scrub_patients"."id" > \$1 ORDER BY "scrub_patients"."id" LIMIT \$2`).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "phone", "nin"}))
+
+	require.NoError(t, gh.ScrubDatabase(ctx, db, opts, &scrubPatient{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SELECT current_database\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_database"}).AddRow("clinic"))
+	err := gh.ScrubDatabase(ctx, db, opts, &scrubPatient{})
+	assert.EqualError(t, err, "refusing to scrub clinic_staging: connected to clinic")
+
+	type badKind struct {
+		ID   uint
+		Name string `gh:"pii:nickname"`
+	}
+	mock.ExpectQuery(`SELECT current_database\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_database"}).AddRow("clinic_staging"))
+	err = gh.ScrubDatabase(ctx, db, opts, &badKind{})
+	assert.EqualError(t, err, `unknown pii kind "nickname" of bad_kinds.name`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type scrubDischarge struct {
+	ID        uint
+	Name      string `gh:"pii:name"`
+	DeletedAt gorm.DeletedAt
+}
+
+func TestScrubDatabaseSoftDeleted(t *testing.T) {
+	db, mock := mockDB(t)
+	opts := gh.ScrubOptions{Database: "clinic_staging", Salt: "pepper", BatchSize: 10}
+
+	// Soft deleted rows are read and scrubbed like the others.
+	mock.ExpectQuery(`SELECT current_database\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"current_database"}).AddRow("clinic_staging"))
+	mock.ExpectQuery(`SELECT "id","name" FROM "scrub_discharges" ORDER BY "scrub_discharges"."id" LIMIT \$1$`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "John Doe"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "scrub_discharges" SET "name"=\$1 WHERE "id" = \$2$`).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, gh.ScrubDatabase(context.Background(), db, opts, &scrubDischarge{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,274 @@
+package gh
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SearchDocument is a document of a search index, identified by the primary key of its model.
+type SearchDocument struct {
+	ID   string // primary key, joined with "-" if composite
+	Body any    // returned by the mapping function of the model, e.g a map or a struct
+}
+
+// SearchIndex is an external search index, e.g an adapter of an Elasticsearch or Meilisearch client.
+type SearchIndex interface {
+	// Upsert adds or replaces documents of index.
+	Upsert(ctx context.Context, index string, docs []SearchDocument) error
+
+	// Delete removes documents of index by ID. Missing documents are not an error.
+	Delete(ctx context.Context, index string, ids []string) error
+}
+
+// Indexer is a gorm plugin keeping a SearchIndex in sync with the models registered with IndexModel:
+// created and updated models are upserted, and deleted models removed. Updated rows are reloaded by
+// primary key, so partial updates index the whole row. Updates and deletes by conditions only, without
+// the primary key in the model, are not seen.
+//
+// Documents are written after the statement, before its transaction commits. Index errors are logged
+// with db's logger and do not fail the write: run ReindexAll to repair the index.
+//
+//	indexer := gh.NewIndexer(meili)
+//	gh.IndexModel(indexer, "patients", func(p *Patient) (any, error) {
+//		return map[string]any{"name": p.Name, "phone": p.Phone}, nil
+//	})
+//	db.Use(indexer)
+type Indexer struct {
+	// BatchSize is the number of documents upserted at once by ReindexAll. Default: 500.
+	BatchSize int
+
+	index  SearchIndex
+	mu     sync.RWMutex
+	models map[reflect.Type]*indexedModel
+}
+
+// indexedModel is a model registered with IndexModel.
+type indexedModel struct {
+	name    string
+	docs    func(ctx context.Context, db *gorm.DB, values []reflect.Value, reload bool) ([]SearchDocument, error)
+	reindex func(ctx context.Context, ix *Indexer, db *gorm.DB) error
+}
+
+// NewIndexer creates an Indexer of index.
+func NewIndexer(index SearchIndex) *Indexer {
+	return &Indexer{index: index, models: map[reflect.Type]*indexedModel{}}
+}
+
+// IndexModel registers T in the search index name, with mapping returning the document of a model.
+func IndexModel[T any](ix *Indexer, name string, mapping func(model *T) (any, error)) {
+	model := &indexedModel{name: name}
+
+	docsOf := func(rows []*T, pk []*schema.Field) ([]SearchDocument, error) {
+		docs := make([]SearchDocument, 0, len(rows))
+		for _, row := range rows {
+			body, err := mapping(row)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, SearchDocument{ID: documentID(pk, reflect.ValueOf(row).Elem()), Body: body})
+		}
+		return docs, nil
+	}
+
+	model.docs = func(ctx context.Context, db *gorm.DB, values []reflect.Value, reload bool) ([]SearchDocument, error) {
+		pk := db.Statement.Schema.PrimaryFields
+		rows := make([]*T, 0, len(values))
+		if !reload {
+			for _, rv := range values {
+				rows = append(rows, rv.Addr().Interface().(*T))
+			}
+			return docsOf(rows, pk)
+		}
+
+		keys := keysOf(ctx, pk, values)
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Where(columnList(pk)+" IN ?", keys).
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		return docsOf(rows, pk)
+	}
+
+	model.reindex = func(ctx context.Context, ix *Indexer, db *gorm.DB) error {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(new(T)); err != nil {
+			return err
+		}
+
+		batch := []*T{}
+		flush := func() error {
+			docs, err := docsOf(batch, stmt.Schema.PrimaryFields)
+			if err != nil {
+				return err
+			}
+			batch = batch[:0]
+			return ix.index.Upsert(ctx, name, docs)
+		}
+
+		for row, err := range Iter[T](ctx, db, ix.batchSize()) {
+			if err != nil {
+				return err
+			}
+			batch = append(batch, &row)
+			if len(batch) == ix.batchSize() {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if len(batch) > 0 {
+			return flush()
+		}
+		return nil
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.models[reflect.TypeFor[T]()] = model
+}
+
+// documentID returns the primary key of the row rv.
+func documentID(pk []*schema.Field, rv reflect.Value) string {
+	parts := make([]string, len(pk))
+	for i, field := range pk {
+		parts[i] = fmt.Sprint(reflect.Indirect(field.ReflectValueOf(context.Background(), rv)).Interface())
+	}
+	return strings.Join(parts, "-")
+}
+
+// keysOf returns the primary keys of the rows, as values or tuples of values if composite.
+// Rows with a zero key are skipped.
+func keysOf(ctx context.Context, pk []*schema.Field, values []reflect.Value) []any {
+	keys := []any{}
+	for _, rv := range values {
+		key := make([]any, len(pk))
+		zero := false
+		for i, field := range pk {
+			var z bool
+			key[i], z = field.ValueOf(ctx, rv)
+			zero = zero || z
+		}
+
+		if zero {
+			continue
+		}
+		if len(key) == 1 {
+			keys = append(keys, key[0])
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// columnList returns the quoted columns of fields, in parentheses if there are several.
+func columnList(fields []*schema.Field) string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = quoteName(field.DBName)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return "(" + strings.Join(names, ",") + ")"
+}
+
+// Name implements gorm.Plugin.
+func (ix *Indexer) Name() string {
+	return "gh:indexer"
+}
+
+// Initialize implements gorm.Plugin, registering the create, update and delete callbacks.
+func (ix *Indexer) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("gh:indexer", func(db *gorm.DB) { ix.sync(db, false, false) }); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("gh:indexer", func(db *gorm.DB) { ix.sync(db, true, false) }); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("gh:indexer", func(db *gorm.DB) { ix.sync(db, false, true) })
+}
+
+// sync upserts or deletes the documents of the models of the statement.
+func (ix *Indexer) sync(db *gorm.DB, reload, remove bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.RowsAffected == 0 || len(db.Statement.Schema.PrimaryFields) == 0 {
+		return
+	}
+
+	ix.mu.RLock()
+	model := ix.models[db.Statement.Schema.ModelType]
+	ix.mu.RUnlock()
+	if model == nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	values := []reflect.Value{}
+	forEachModelValue(db.Statement, func(rv reflect.Value) {
+		values = append(values, rv)
+	})
+
+	var err error
+	if remove {
+		ids := []string{}
+		pk := db.Statement.Schema.PrimaryFields
+		for _, rv := range values {
+			if _, zero := pk[0].ValueOf(ctx, rv); !zero {
+				ids = append(ids, documentID(pk, rv))
+			}
+		}
+		if len(ids) > 0 {
+			err = ix.index.Delete(ctx, model.name, ids)
+		}
+	} else {
+		var docs []SearchDocument
+		docs, err = model.docs(ctx, db, values, reload)
+		if err == nil && len(docs) > 0 {
+			err = ix.index.Upsert(ctx, model.name, docs)
+		}
+	}
+
+	if err != nil {
+		db.Logger.Error(ctx, "failed to sync search index %s: %v", model.name, err)
+	}
+}
+
+// ReindexAll upserts every row of the registered models in their search index, streaming them
+// with Iter, e.g to backfill a new index or repair it after errors. Documents of deleted rows
+// are not removed.
+func (ix *Indexer) ReindexAll(ctx context.Context, db *gorm.DB) error {
+	ix.mu.RLock()
+	models := make([]*indexedModel, 0, len(ix.models))
+	for _, model := range ix.models {
+		models = append(models, model)
+	}
+	ix.mu.RUnlock()
+	slices.SortFunc(models, func(a, b *indexedModel) int { return cmp.Compare(a.name, b.name) })
+
+	for _, model := range models {
+		if err := model.reindex(ctx, ix, db); err != nil {
+			return fmt.Errorf("failed to reindex %s: %w", model.name, err)
+		}
+	}
+	return nil
+}
+
+func (ix *Indexer) batchSize() int {
+	if ix.BatchSize <= 0 {
+		return 500
+	}
+	return ix.BatchSize
+}
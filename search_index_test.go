@@ -0,0 +1,88 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIndex records the calls of a gh.SearchIndex.
+type memoryIndex struct {
+	upserts map[string][]gh.SearchDocument
+	deletes map[string][]string
+}
+
+func (m *memoryIndex) Upsert(ctx context.Context, index string, docs []gh.SearchDocument) error {
+	m.upserts[index] = append(m.upserts[index], docs...)
+	return nil
+}
+
+func (m *memoryIndex) Delete(ctx context.Context, index string, ids []string) error {
+	m.deletes[index] = append(m.deletes[index], ids...)
+	return nil
+}
+
+type indexedDrug struct {
+	ID   uint
+	Name string
+	Form string
+}
+
+func TestIndexer(t *testing.T) {
+	db, mock := mockDB(t)
+	index := &memoryIndex{upserts: map[string][]gh.SearchDocument{}, deletes: map[string][]string{}}
+	indexer := gh.NewIndexer(index)
+	indexer.BatchSize = 2
+	gh.IndexModel(indexer, "drugs", func(d *indexedDrug) (any, error) {
+		return map[string]any{"name": d.Name, "form": d.Form}, nil
+	})
+	require.NoError(t, db.Use(indexer))
+
+	mock.ExpectQuery(`INSERT INTO "indexed_drugs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	require.NoError(t, db.Create(&indexedDrug{Name: "Amoxicillin", Form: "capsule"}).Error)
+
+	// Partial updates reload the row.
+	mock.ExpectExec(`UPDATE "indexed_drugs" SET "form"=\$1 WHERE "id" = \$2`).
+		WithArgs("syrup", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "indexed_drugs" WHERE "id" IN \(\$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "form"}).AddRow(1, "Amoxicillin", "syrup"))
+	require.NoError(t, db.Model(&indexedDrug{ID: 1}).Update("form", "syrup").Error)
+
+	mock.ExpectExec(`DELETE FROM "indexed_drugs" WHERE "indexed_drugs"."id" = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, db.Delete(&indexedDrug{ID: 1}).Error)
+
+	assert.Equal(t, []gh.SearchDocument{
+		{ID: "1", Body: map[string]any{"name": "Amoxicillin", "form": "capsule"}},
+		{ID: "1", Body: map[string]any{"name": "Amoxicillin", "form": "syrup"}},
+	}, index.upserts["drugs"])
+	assert.Equal(t, []string{"1"}, index.deletes["drugs"])
+
+	// ReindexAll upserts every row, in batches.
+	index.upserts = map[string][]gh.SearchDocument{}
+	mock.ExpectQuery(`SELECT \* FROM "indexed_drugs" ORDER BY "indexed_drugs"."id" LIMIT \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "form"}).
+			AddRow(1, "Amoxicillin", "syrup").
+			AddRow(2, "Paracetamol", "tablet").
+			AddRow(3, "Ibuprofen", "tablet"))
+	mock.ExpectQuery(`SELECT \* FROM "indexed_drugs" WHERE "indexed_drugs"."id" > \$1 ORDER BY "indexed_drugs"."id" LIMIT \$2`).
+		WithArgs(uint(2), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "form"}).AddRow(3, "Ibuprofen", "tablet"))
+
+	require.NoError(t, indexer.ReindexAll(context.Background(), db))
+	ids := []string{}
+	for _, doc := range index.upserts["drugs"] {
+		ids = append(ids, doc.ID)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
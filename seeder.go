@@ -0,0 +1,172 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSeedingNotAllowed is returned by SeederRegistry.Run in an environment where seeding is not allowed.
+var ErrSeedingNotAllowed = errors.New("seeding is not allowed in this environment")
+
+// SeedRecord records a seeder that ran, in the seeds table.
+type SeedRecord struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+// TableName implements gorm's Tabler interface.
+func (SeedRecord) TableName() string {
+	return "seeds"
+}
+
+// SeedFunc loads data. It runs in a transaction.
+type SeedFunc func(tx *GormDB) error
+
+type registeredSeeder struct {
+	deps []string
+	fn   SeedFunc
+}
+
+// SeederRegistry runs registered seeders, e.g demo and reference data, after their dependencies.
+// Each seeder runs once: the seeders that ran are recorded in the seeds table and skipped afterwards.
+//
+// The seeds table must be migrated before use:
+//
+//	db.AutoMigrate(&gh.SeedRecord{})
+//
+//	seeders := gh.NewSeederRegistry(db)
+//	seeders.RegisterSeeder("departments", nil, seedDepartments)
+//	seeders.RegisterSeeder("doctors", []string{"departments"}, seedDoctors)
+//	ran, err := seeders.Run(ctx, os.Getenv("APP_ENV"))
+type SeederRegistry struct {
+	// Environments are the environments where Run is allowed. Default: development and staging.
+	Environments []string
+
+	db      *gorm.DB
+	mu      sync.Mutex
+	seeders map[string]*registeredSeeder
+	order   []string // registration order
+}
+
+// NewSeederRegistry creates a SeederRegistry recording its seeders in db.
+func NewSeederRegistry(db *gorm.DB) *SeederRegistry {
+	return &SeederRegistry{db: db, seeders: map[string]*registeredSeeder{}}
+}
+
+// RegisterSeeder adds the seeder name, run after the seeders deps.
+func (r *SeederRegistry) RegisterSeeder(name string, deps []string, fn SeedFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.seeders[name]; exists {
+		return fmt.Errorf("seeder %s is already registered", name)
+	}
+	r.seeders[name] = &registeredSeeder{deps: deps, fn: fn}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Run runs the seeders that have not run yet, each in its own transaction, dependencies first
+// and otherwise in registration order. It returns the names of the seeders that ran, stopping at
+// the first failure. It returns ErrSeedingNotAllowed if environment is not in Environments.
+// An advisory lock ensures a single instance seeds the database at a time.
+func (r *SeederRegistry) Run(ctx context.Context, environment string) ([]string, error) {
+	environments := r.Environments
+	if len(environments) == 0 {
+		environments = []string{"development", "staging"}
+	}
+
+	if !slices.Contains(environments, environment) {
+		return nil, fmt.Errorf("%w: %q", ErrSeedingNotAllowed, environment)
+	}
+
+	order, err := r.sorted()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := AdvisoryLock(ctx, r.db, AdvisoryKey("gh:seed"))
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock(context.WithoutCancel(ctx))
+
+	seeded := []string{}
+	if err := r.db.WithContext(ctx).Model(&SeedRecord{}).Pluck("name", &seeded).Error; err != nil {
+		return nil, fmt.Errorf("failed to read seeds: %w", err)
+	}
+
+	ran := []string{}
+	for _, name := range order {
+		if slices.Contains(seeded, name) {
+			continue
+		}
+
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := r.seeders[name].fn(WrapDB(tx)); err != nil {
+				return err
+			}
+			return tx.Create(&SeedRecord{Name: name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("failed to run seeder %s: %w", name, err)
+		}
+
+		r.db.Logger.Info(ctx, "ran seeder %s", name)
+		ran = append(ran, name)
+	}
+	return ran, nil
+}
+
+// sorted returns the seeders ordered so that each comes after its dependencies.
+func (r *SeederRegistry) sorted() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := map[string]int{}
+	order := make([]string, 0, len(r.order))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("seeder dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+
+		seeder, ok := r.seeders[name]
+		if !ok {
+			return fmt.Errorf("seeder %s depends on unknown seeder %s", path[len(path)-1], name)
+		}
+
+		state[name] = visiting
+		for _, dep := range seeder.deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range r.order {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,65 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeederRegistry(t *testing.T) {
+	db, mock := mockDB(t)
+	seeders := gh.NewSeederRegistry(db)
+
+	seed := func(sql string) gh.SeedFunc {
+		return func(tx *gh.GormDB) error { return tx.DB().Exec(sql).Error }
+	}
+	require.NoError(t, seeders.RegisterSeeder("doctors", []string{"departments"}, seed("INSERT INTO doctors")))
+	require.NoError(t, seeders.RegisterSeeder("departments", nil, seed("INSERT INTO departments")))
+	require.NoError(t, seeders.RegisterSeeder("wards", []string{"departments"}, seed("INSERT INTO wards")))
+	assert.ErrorContains(t, seeders.RegisterSeeder("wards", nil, nil), "seeder wards is already registered")
+
+	_, err := seeders.Run(context.Background(), "production")
+	assert.ErrorIs(t, err, gh.ErrSeedingNotAllowed)
+
+	key := gh.AdvisoryKey("gh:seed")
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "name" FROM "seeds"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("departments"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO doctors").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "seeds" ("name","applied_at") VALUES ($1,$2)`)).
+		WithArgs("doctors", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO wards").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO "seeds"`).WithArgs("wards", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(true))
+
+	ran, err := seeders.Run(context.Background(), "staging")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doctors", "wards"}, ran)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSeederRegistryDependencies(t *testing.T) {
+	db, _ := mockDB(t)
+	noop := func(tx *gh.GormDB) error { return nil }
+
+	seeders := gh.NewSeederRegistry(db)
+	require.NoError(t, seeders.RegisterSeeder("a", []string{"b"}, noop))
+	require.NoError(t, seeders.RegisterSeeder("b", []string{"a"}, noop))
+	_, err := seeders.Run(context.Background(), "development")
+	assert.ErrorContains(t, err, "seeder dependency cycle: a -> b -> a")
+
+	seeders = gh.NewSeederRegistry(db)
+	require.NoError(t, seeders.RegisterSeeder("a", []string{"missing"}, noop))
+	_, err = seeders.Run(context.Background(), "development")
+	assert.ErrorContains(t, err, "seeder a depends on unknown seeder missing")
+}
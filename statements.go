@@ -0,0 +1,136 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// StatementOrder is the order of the statements returned by StatStatements.
+type StatementOrder string
+
+const (
+	ByTotalTime StatementOrder = "total_time" // where the database spends its time
+	ByMeanTime  StatementOrder = "mean_time"  // the slowest statements
+	ByCalls     StatementOrder = "calls"      // the most frequent statements
+)
+
+// StatementStats holds the statistics of a normalized statement from pg_stat_statements.
+// Times are in milliseconds.
+type StatementStats struct {
+	QueryID        int64   `json:"query_id"`
+	Query          string  `json:"query"`
+	Calls          int64   `json:"calls"`
+	TotalTime      float64 `json:"total_time"`
+	MeanTime       float64 `json:"mean_time"`
+	MaxTime        float64 `json:"max_time"`
+	Rows           int64   `json:"rows"`
+	SharedBlksHit  int64   `json:"shared_blks_hit"`
+	SharedBlksRead int64   `json:"shared_blks_read"`
+}
+
+// StatStatements returns the top limit statements of the current database in the given order,
+// combining the entries of all users, e.g for a "slowest queries" endpoint.
+// Queries are normalized with NormalizeQuery.
+//
+// The pg_stat_statements extension (postgres 13+) must be in shared_preload_libraries and created
+// in the database, otherwise the error wraps ErrExtensionUnavailable.
+func StatStatements(db *gorm.DB, order StatementOrder, limit int) ([]StatementStats, error) {
+	switch order {
+	case ByTotalTime, ByMeanTime, ByCalls:
+	default:
+		return nil, fmt.Errorf("%w: unknown statement order %q", ErrInvalidQuery, order)
+	}
+
+	stats := []StatementStats{}
+	err := db.Raw(`SELECT queryid AS query_id, min(query) AS query, sum(calls) AS calls,
+			sum(total_exec_time) AS total_time, sum(total_exec_time) / NULLIF(sum(calls), 0) AS mean_time,
+			max(max_exec_time) AS max_time, sum(rows) AS rows,
+			sum(shared_blks_hit) AS shared_blks_hit, sum(shared_blks_read) AS shared_blks_read
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database()) AND queryid IS NOT NULL
+		GROUP BY queryid
+		ORDER BY `+string(order)+` DESC NULLS LAST
+		LIMIT ?`, limit).Scan(&stats).Error
+	if err != nil {
+		return nil, statStatementsError(err)
+	}
+
+	for i := range stats {
+		stats[i].Query = NormalizeQuery(stats[i].Query)
+	}
+	return stats, nil
+}
+
+// ResetStatStatements discards the statistics gathered by pg_stat_statements for all databases,
+// e.g after a deployment. By default, only superusers can reset them.
+func ResetStatStatements(db *gorm.DB) error {
+	if err := db.Exec("SELECT pg_stat_statements_reset()").Error; err != nil {
+		return statStatementsError(err)
+	}
+	return nil
+}
+
+func statStatementsError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "42P01", "42883", "55000": // undefined_table, undefined_function, object_not_in_prerequisite_state
+			return fmt.Errorf("failed to read pg_stat_statements: %w: %w", ErrExtensionUnavailable, err)
+		case "42501":
+			return fmt.Errorf("failed to read pg_stat_statements: %w: %w", ErrInsufficientPrivilege, err)
+		}
+	}
+	return fmt.Errorf("failed to read pg_stat_statements: %w", err)
+}
+
+// NormalizeQuery strips the comments (e.g sqlcommenter tags) of a query and collapses its whitespace,
+// so statements differing only in formatting read the same. Quoted strings and identifiers are kept as is.
+func NormalizeQuery(query string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := len(query) - 1
+			if j := strings.IndexByte(query[i+1:], c); j >= 0 {
+				end = i + 1 + j
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteString(query[i : end+1])
+			i = end
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end - 1
+			space = true
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			i += end + 1
+			space = true
+		case isSpace(c):
+			space = true
+		default:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatStatements(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(`FROM pg_stat_statements(.|\n)*ORDER BY mean_time DESC NULLS LAST`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"query_id", "query", "calls", "total_time", "mean_time", "max_time", "rows"}).
+			AddRow(42, "SELECT *\n  FROM visits /* route:visits */ WHERE id = $1", 10, 120.0, 12.0, 40.0, 10))
+
+	stats, err := gh.StatStatements(db, gh.ByMeanTime, 5)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, gh.StatementStats{QueryID: 42, Query: "SELECT * FROM visits WHERE id = $1",
+		Calls: 10, TotalTime: 120, MeanTime: 12, MaxTime: 40, Rows: 10}, stats[0])
+}
+
+func TestStatStatementsErrors(t *testing.T) {
+	db, mock := mockDB(t)
+
+	_, err := gh.StatStatements(db, "total_time; DROP TABLE visits", 5)
+	assert.ErrorIs(t, err, gh.ErrInvalidQuery)
+
+	mock.ExpectQuery("FROM pg_stat_statements").
+		WillReturnError(&pgconn.PgError{Code: "42P01", Message: `relation "pg_stat_statements" does not exist`})
+	_, err = gh.StatStatements(db, gh.ByCalls, 5)
+	assert.ErrorIs(t, err, gh.ErrExtensionUnavailable)
+}
+
+func TestResetStatStatements(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectExec(`SELECT pg_stat_statements_reset\(\)`).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, gh.ResetStatStatements(db))
+
+	mock.ExpectExec(`SELECT pg_stat_statements_reset\(\)`).
+		WillReturnError(&pgconn.PgError{Code: "42501", Message: "permission denied"})
+	assert.ErrorIs(t, gh.ResetStatStatements(db), gh.ErrInsufficientPrivilege)
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := map[string]string{
+		"SELECT  1":                                  "SELECT 1",
+		"SELECT 1 -- trailing\nFROM t":               "SELECT 1 FROM t",
+		"/*app='api'*/ SELECT\t*\nFROM t":            "SELECT * FROM t",
+		"SELECT 'a  -- b' ,\"x  y\" FROM t":          "SELECT 'a  -- b' ,\"x  y\" FROM t",
+		"SELECT 'unterminated":                       "SELECT 'unterminated",
+		"SELECT 1 /* unterminated":                   "SELECT 1",
+		"  UPDATE t SET a = $1 /* c */ WHERE b = $2": "UPDATE t SET a = $1 WHERE b = $2",
+	}
+
+	for query, want := range tests {
+		assert.Equal(t, want, gh.NormalizeQuery(query), query)
+	}
+}
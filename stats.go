@@ -0,0 +1,100 @@
+package gh
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DatabaseStats holds the size and activity statistics of the current database, as returned by Stats.
+type DatabaseStats struct {
+	Name      string       `json:"name"`
+	SizeBytes int64        `json:"size_bytes"`
+	Tables    []TableStats `json:"tables"`  // largest first
+	Indexes   []IndexStats `json:"indexes"` // largest first
+}
+
+// TableStats holds the size, tuple counts and maintenance times of a table.
+// Times are nil if the table was never vacuumed or analyzed.
+type TableStats struct {
+	Schema          string     `json:"schema"`
+	Name            string     `json:"name"`
+	TotalBytes      int64      `json:"total_bytes"` // table, indexes and TOAST
+	TableBytes      int64      `json:"table_bytes"`
+	IndexBytes      int64      `json:"index_bytes"`
+	LiveTuples      int64      `json:"live_tuples"`
+	DeadTuples      int64      `json:"dead_tuples"`
+	SeqScans        int64      `json:"seq_scans"`
+	IndexScans      int64      `json:"index_scans"`
+	LastVacuum      *time.Time `json:"last_vacuum"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum"`
+	LastAnalyze     *time.Time `json:"last_analyze"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze"`
+}
+
+// DeadTupleRatio returns the fraction of dead tuples of the table, an estimate of its bloat.
+func (s TableStats) DeadTupleRatio() float64 {
+	if total := s.LiveTuples + s.DeadTuples; total > 0 {
+		return float64(s.DeadTuples) / float64(total)
+	}
+	return 0
+}
+
+// IndexStats holds the size and usage of an index.
+type IndexStats struct {
+	Schema        string `json:"schema"`
+	Table         string `json:"table"`
+	Name          string `json:"name"`
+	Bytes         int64  `json:"bytes"`
+	Scans         int64  `json:"scans"`
+	TuplesRead    int64  `json:"tuples_read"`
+	TuplesFetched int64  `json:"tuples_fetched"`
+	Unique        bool   `json:"unique"`
+}
+
+// Stats reads the size of the database and the statistics of its user tables and indexes,
+// limited to the given tables if any. Counters are cumulative since the last statistics reset.
+func Stats(db *gorm.DB, tables ...string) (*DatabaseStats, error) {
+	var size struct {
+		Name      string
+		SizeBytes int64
+	}
+
+	err := db.Raw("SELECT current_database() AS name, pg_database_size(current_database()) AS size_bytes").
+		Scan(&size).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	stats := &DatabaseStats{Name: size.Name, SizeBytes: size.SizeBytes, Tables: []TableStats{}, Indexes: []IndexStats{}}
+
+	filter, args := "TRUE", []any{}
+	if len(tables) > 0 {
+		filter, args = "s.relname IN ?", []any{tables}
+	}
+
+	err = db.Raw(`SELECT s.schemaname AS schema, s.relname AS name,
+			pg_total_relation_size(s.relid) AS total_bytes, pg_relation_size(s.relid) AS table_bytes,
+			pg_indexes_size(s.relid) AS index_bytes, s.n_live_tup AS live_tuples, s.n_dead_tup AS dead_tuples,
+			s.seq_scan AS seq_scans, COALESCE(s.idx_scan, 0) AS index_scans,
+			s.last_vacuum, s.last_autovacuum, s.last_analyze, s.last_autoanalyze
+		FROM pg_stat_user_tables s
+		WHERE `+filter+`
+		ORDER BY total_bytes DESC, 1, 2`, args...).Scan(&stats.Tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table statistics: %w", err)
+	}
+
+	err = db.Raw(`SELECT s.schemaname AS schema, s.relname AS table, s.indexrelname AS name,
+			pg_relation_size(s.indexrelid) AS bytes, s.idx_scan AS scans,
+			s.idx_tup_read AS tuples_read, s.idx_tup_fetch AS tuples_fetched, i.indisunique AS unique
+		FROM pg_stat_user_indexes s
+		JOIN pg_index i ON i.indexrelid = s.indexrelid
+		WHERE `+filter+`
+		ORDER BY bytes DESC, 1, 2, 3`, args...).Scan(&stats.Indexes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index statistics: %w", err)
+	}
+	return stats, nil
+}
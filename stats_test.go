@@ -0,0 +1,50 @@
+package gh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	db, mock := mockDB(t)
+	vacuumed := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT current_database\\(\\) AS name, pg_database_size").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "size_bytes"}).AddRow("clinic", 1<<30))
+	mock.ExpectQuery("FROM pg_stat_user_tables s").WithArgs("visits").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "total_bytes", "table_bytes", "index_bytes",
+			"live_tuples", "dead_tuples", "seq_scans", "index_scans",
+			"last_vacuum", "last_autovacuum", "last_analyze", "last_autoanalyze"}).
+			AddRow("public", "visits", 3000, 2000, 1000, 900, 100, 4, 50, nil, vacuumed, nil, vacuumed))
+	mock.ExpectQuery("FROM pg_stat_user_indexes s").WithArgs("visits").
+		WillReturnRows(sqlmock.NewRows([]string{"schema", "table", "name", "bytes", "scans", "tuples_read", "tuples_fetched", "unique"}).
+			AddRow("public", "visits", "visits_pkey", 1000, 50, 60, 50, true))
+
+	stats, err := gh.Stats(db, "visits")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "clinic", stats.Name)
+	assert.Equal(t, int64(1<<30), stats.SizeBytes)
+
+	require.Len(t, stats.Tables, 1)
+	visits := stats.Tables[0]
+	assert.Equal(t, int64(3000), visits.TotalBytes)
+	assert.Nil(t, visits.LastVacuum)
+	require.NotNil(t, visits.LastAutovacuum)
+	assert.True(t, vacuumed.Equal(*visits.LastAutovacuum))
+	assert.InDelta(t, 0.1, visits.DeadTupleRatio(), 1e-9)
+
+	require.Len(t, stats.Indexes, 1)
+	assert.Equal(t, gh.IndexStats{Schema: "public", Table: "visits", Name: "visits_pkey", Bytes: 1000,
+		Scans: 50, TuplesRead: 60, TuplesFetched: 50, Unique: true}, stats.Indexes[0])
+}
+
+func TestDeadTupleRatioEmptyTable(t *testing.T) {
+	assert.Zero(t, gh.TableStats{}.DeadTupleRatio())
+}
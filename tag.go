@@ -0,0 +1,170 @@
+package gh
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const tagsKey = "gh:tags"
+
+// Tag tags the statements of the chain, e.g with the feature or team running them, for cost
+// attribution across logs, metrics and pg_stat_statements. Tags are:
+//   - appended to the statements as a sqlcommenter comment, e.g /*tags='report%3Aincome'*/,
+//     so they show in pg_stat_activity, the server logs and query insights tools.
+//   - appended to the statements written to the logger of the chain.
+//   - returned by QueryTags, e.g for the labels of metrics recorded in callbacks.
+//
+// Tags of successive calls are added to each other.
+//
+// The TagPlugin must be registered, otherwise the chain fails with ErrPluginNotRegistered.
+//
+//	db.Use(&gh.TagPlugin{})
+//	err := gdb.Tag("report:income", "team:billing").DB().Raw(incomeSQL).Scan(&rows).Error
+func (gdb *GormDB) Tag(tags ...string) *GormDB {
+	if err := requirePlugin(gdb.db, tagsKey, "db.Use(&gh.TagPlugin{})"); err != nil {
+		gdb.db = gdb.db.Session(&gorm.Session{})
+		gdb.db.AddError(err)
+		return gdb
+	}
+
+	all := slices.Clone(QueryTags(gdb.db))
+	for _, tag := range tags {
+		if tag != "" && !slices.Contains(all, tag) {
+			all = append(all, tag)
+		}
+	}
+
+	base := gdb.db.Logger
+	if l, ok := base.(*tagLogger); ok {
+		base = l.Interface
+	}
+	gdb.db = gdb.db.Set(tagsKey, all).Session(&gorm.Session{Logger: &tagLogger{Interface: base, tags: all}})
+	return gdb
+}
+
+// QueryTags returns the tags of the statement of db, set with Tag.
+//
+//	db.Callback().Query().After("gorm:query").Register("metrics", func(db *gorm.DB) {
+//		queries.WithLabelValues(strings.Join(gh.QueryTags(db), ",")).Inc()
+//	})
+func QueryTags(db *gorm.DB) []string {
+	tags, _ := db.Get(tagsKey)
+	t, _ := tags.([]string)
+	return t
+}
+
+// tagComment returns the sqlcommenter comment of tags, with its leading space.
+func tagComment(tags []string) string {
+	value := strings.ReplaceAll(url.QueryEscape(strings.Join(tags, ",")), "+", "%20")
+	return " /*tags='" + value + "'*/"
+}
+
+// TagPlugin is a gorm plugin registering the callbacks commenting the statements of Tag chains.
+//
+//	db.Use(&gh.TagPlugin{})
+type TagPlugin struct{}
+
+// Name implements gorm.Plugin.
+func (p *TagPlugin) Name() string {
+	return tagsKey
+}
+
+// Initialize implements gorm.Plugin, registering the callbacks of every processor.
+func (p *TagPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	// The connection is swapped around the statement itself, after gorm:begin_transaction
+	// and before gorm:commit_or_rollback_transaction, which need the original one.
+	const commit = "gorm:commit_or_rollback_transaction"
+	registrations := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Before(commit).Register,
+		cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Before(commit).Register,
+		cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Before(commit).Register,
+		cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Before(commit).Register,
+		cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Before(commit).Register,
+		cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Before(commit).Register,
+	}
+	for i, register := range registrations {
+		name, fn := "gh:tag_comment", commentStatement
+		if i%2 == 1 {
+			name, fn = "gh:tag_comment_restore", restoreConnPool
+		}
+		if err := register(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commentStatement makes the connection of the statement append the comment of its tags.
+func commentStatement(db *gorm.DB) {
+	tags := QueryTags(db)
+	if len(tags) == 0 || db.Error != nil {
+		return
+	}
+	if _, ok := db.Statement.ConnPool.(*commentConnPool); !ok {
+		db.Statement.ConnPool = &commentConnPool{ConnPool: db.Statement.ConnPool, comment: tagComment(tags)}
+	}
+}
+
+func restoreConnPool(db *gorm.DB) {
+	if pool, ok := db.Statement.ConnPool.(*commentConnPool); ok {
+		db.Statement.ConnPool = pool.ConnPool
+	}
+}
+
+// commentConnPool appends a comment to the statements it runs.
+type commentConnPool struct {
+	gorm.ConnPool
+	comment string
+}
+
+func (p *commentConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.ConnPool.PrepareContext(ctx, query+p.comment)
+}
+
+func (p *commentConnPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.ConnPool.ExecContext(ctx, query+p.comment, args...)
+}
+
+func (p *commentConnPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.ConnPool.QueryContext(ctx, query+p.comment, args...)
+}
+
+func (p *commentConnPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return p.ConnPool.QueryRowContext(ctx, query+p.comment, args...)
+}
+
+// tagLogger appends the comment of tags to the statements it logs.
+type tagLogger struct {
+	logger.Interface
+	tags []string
+}
+
+// LogMode implements logger.Interface, keeping the tags on the new logger.
+func (l *tagLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &tagLogger{Interface: l.Interface.LogMode(level), tags: l.tags}
+}
+
+// Trace implements logger.Interface.
+func (l *tagLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, func() (string, int64) {
+		sql, rows := fc()
+		return sql + tagComment(l.tags), rows
+	}, err)
+}
+
+// ParamsFilter implements gorm.ParamsFilter, delegating to the wrapped logger, e.g a RedactingLogger.
+func (l *tagLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if filter, ok := l.Interface.(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
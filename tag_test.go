@@ -0,0 +1,63 @@
+package gh_test
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type taggedInvoice struct {
+	ID     uint
+	Amount float64
+}
+
+func TestTag(t *testing.T) {
+	db, mock := mockDB(t)
+	var logs bytes.Buffer
+	db.Logger = logger.New(log.New(&logs, "", 0), logger.Config{LogLevel: logger.Info})
+	assert.ErrorIs(t, gh.WrapDB(db).Tag("report:income").DB().Find(&[]taggedInvoice{}).Error, gh.ErrPluginNotRegistered)
+	require.NoError(t, db.Use(&gh.TagPlugin{}))
+
+	gdb := gh.WrapDB(db).Tag("report:income").Tag("team:billing", "report:income")
+	assert.Equal(t, []string{"report:income", "team:billing"}, gh.QueryTags(gdb.DB()))
+
+	mock.ExpectQuery(`SELECT \* FROM "tagged_invoices" /\*tags='report%3Aincome%2Cteam%3Abilling'\*/`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount"}).AddRow(1, 100))
+	require.NoError(t, gdb.DB().Find(&[]taggedInvoice{}).Error)
+	assert.Contains(t, logs.String(), `SELECT * FROM "tagged_invoices" /*tags='report%3Aincome%2Cteam%3Abilling'*/`)
+
+	// Writes are tagged inside their default transaction.
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	txDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, txDB.Use(&gh.TagPlugin{}))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "tagged_invoices" \("amount"\) VALUES \(\$1\) RETURNING "id" /\*tags='report%3Aincome%2Cteam%3Abilling'\*/`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	require.NoError(t, gh.WrapDB(txDB).Tag("report:income", "team:billing").DB().Create(&taggedInvoice{Amount: 50}).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Untagged chains are not commented.
+	db, mock = mockDB(t)
+	mock.ExpectExec(`^DELETE FROM "tagged_invoices" WHERE "tagged_invoices"."id" = \$1$`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, db.Delete(&taggedInvoice{ID: 2}).Error)
+	assert.Empty(t, gh.QueryTags(db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
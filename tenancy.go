@@ -0,0 +1,109 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTenant is returned when a tenant schema name is not a valid identifier.
+var ErrInvalidTenant = errors.New("invalid tenant schema name")
+
+var tenantRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// validateTenant checks that tenant is a lower case postgres identifier.
+func validateTenant(tenant string) error {
+	if !tenantRegex.MatchString(tenant) || tenant == "public" || tenant == "pg_catalog" {
+		return fmt.Errorf("%w: %q", ErrInvalidTenant, tenant)
+	}
+	return nil
+}
+
+// ForTenant runs fn on a dedicated connection whose search_path is set to the tenant's schema
+// (followed by public for shared tables and extensions), so unqualified table names resolve
+// to the tenant's tables. The search_path is reset before the connection is returned to the pool,
+// which is why the tenant connection is only available inside fn.
+//
+// Usage:
+//
+//	err := gdb.ForTenant(ctx, "clinic_42", func(tx *gh.GormDB) error {
+//		return tx.Find(&patients)
+//	})
+func (gdb *GormDB) ForTenant(ctx context.Context, tenant string, fn func(*GormDB) error) error {
+	if err := validateTenant(tenant); err != nil {
+		return err
+	}
+
+	return gdb.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		session := conn.Session(&gorm.Session{NewDB: true})
+
+		searchPath := quoteIdent(tenant) + ", public"
+		if err := session.Exec("SELECT set_config('search_path', ?, false)", searchPath).Error; err != nil {
+			return fmt.Errorf("failed to set search_path for tenant %s: %w", tenant, err)
+		}
+
+		err := fn(&GormDB{db: session})
+
+		// Reset even if ctx was canceled, the connection goes back to the pool.
+		reset := session.Session(&gorm.Session{Context: context.Background()}).Exec("RESET search_path").Error
+		if reset != nil {
+			return errors.Join(err, fmt.Errorf("failed to reset search_path: %w", reset))
+		}
+		return err
+	})
+}
+
+// CreateTenantSchema creates the schema of tenant if it does not exist.
+func CreateTenantSchema(db *gorm.DB, tenant string) error {
+	if err := validateTenant(tenant); err != nil {
+		return err
+	}
+
+	if err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdent(tenant)).Error; err != nil {
+		return fmt.Errorf("failed to create schema for tenant %s: %w", tenant, err)
+	}
+	return nil
+}
+
+// DropTenantSchema drops the schema of tenant and, if cascade is true, all the objects it contains.
+func DropTenantSchema(db *gorm.DB, tenant string, cascade bool) error {
+	if err := validateTenant(tenant); err != nil {
+		return err
+	}
+
+	sql := "DROP SCHEMA IF EXISTS " + quoteIdent(tenant)
+	if cascade {
+		sql += " CASCADE"
+	}
+
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to drop schema for tenant %s: %w", tenant, err)
+	}
+	return nil
+}
+
+// MigrateTenant creates the schema of tenant if needed and auto-migrates models into it.
+func MigrateTenant(ctx context.Context, db *gorm.DB, tenant string, models ...any) error {
+	if err := CreateTenantSchema(db.WithContext(ctx), tenant); err != nil {
+		return err
+	}
+
+	return WrapDB(db).ForTenant(ctx, tenant, func(tx *GormDB) error {
+		if err := tx.DB().AutoMigrate(models...); err != nil {
+			return fmt.Errorf("failed to migrate tenant %s: %w", tenant, err)
+		}
+		return nil
+	})
+}
+
+// TenantSchemas returns the names of the existing schemas that match the pattern (LIKE syntax).
+// e.g TenantSchemas(db, "clinic_%")
+func TenantSchemas(db *gorm.DB, pattern string) ([]string, error) {
+	schemas := []string{}
+	err := db.Raw("SELECT schema_name FROM information_schema.schemata WHERE schema_name LIKE ? ORDER BY schema_name",
+		pattern).Scan(&schemas).Error
+	return schemas, err
+}
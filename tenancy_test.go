@@ -0,0 +1,23 @@
+package gh_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantSchemaValidation(t *testing.T) {
+	db := dryRunDB(t)
+
+	for _, tenant := range []string{"", "public", "Clinic", "clinic-42", `x"; DROP TABLE users; --`} {
+		assert.ErrorIs(t, gh.CreateTenantSchema(db, tenant), gh.ErrInvalidTenant, tenant)
+		assert.ErrorIs(t, gh.DropTenantSchema(db, tenant, true), gh.ErrInvalidTenant, tenant)
+
+		err := gh.WrapDB(db).ForTenant(context.Background(), tenant, func(*gh.GormDB) error { return nil })
+		assert.ErrorIs(t, err, gh.ErrInvalidTenant, tenant)
+	}
+
+	assert.NoError(t, gh.CreateTenantSchema(db, "clinic_42"))
+}
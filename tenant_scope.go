@@ -0,0 +1,234 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrMissingTenant is returned when a statement on a tenant scoped model has no tenant in its context.
+	ErrMissingTenant = errors.New("missing tenant in context")
+
+	// ErrCrossTenant is returned when a statement writes a row belonging to another tenant.
+	ErrCrossTenant = errors.New("cross-tenant write refused")
+)
+
+type tenantKey struct{}
+
+// WithTenantID returns a copy of ctx carrying the tenant id used by the TenantScope plugin.
+func WithTenantID(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantIDFromContext returns the tenant id stored in ctx by WithTenantID.
+func TenantIDFromContext(ctx context.Context) (any, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tenantID := ctx.Value(tenantKey{})
+	return tenantID, tenantID != nil
+}
+
+// TenantScope is a gorm plugin that scopes every query, update and delete on the registered
+// models to the tenant in the statement context (tenant_id = ?) and sets the tenant column on create.
+// Writes of rows whose tenant column holds another tenant fail with ErrCrossTenant, and upserts
+// (ON CONFLICT DO UPDATE, e.g Save) leave the conflicting rows of other tenants unchanged.
+// Updates and deletes without conditions still fail with gorm.ErrMissingWhereClause, unless
+// AllowGlobalUpdate is set, rather than writing every row of the tenant.
+//
+// Statements without a tenant in their context fail with ErrMissingTenant
+// unless Optional is true, in which case they run unscoped.
+// Raw SQL statements are not scoped.
+//
+// Usage:
+//
+//	db.Use(gh.NewTenantScope(&Patient{}, &Invoice{}))
+//	ctx := gh.WithTenantID(r.Context(), clinic.ID)
+//	gh.WrapDB(db).WithContext(ctx).Find(&patients) // WHERE "patients"."tenant_id" = ?
+type TenantScope struct {
+	Column   string // tenant column, default: tenant_id
+	Optional bool   // run statements without a tenant unscoped instead of failing
+	models   []any
+	tables   map[string]bool
+}
+
+// NewTenantScope creates a TenantScope plugin for the given models.
+func NewTenantScope(models ...any) *TenantScope {
+	return &TenantScope{models: models, tables: map[string]bool{}}
+}
+
+// Name implements gorm.Plugin.
+func (s *TenantScope) Name() string {
+	return "gh:tenant_scope"
+}
+
+// Initialize implements gorm.Plugin.
+func (s *TenantScope) Initialize(db *gorm.DB) error {
+	if s.Column == "" {
+		s.Column = "tenant_id"
+	}
+
+	for _, model := range s.models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		if stmt.Schema.LookUpField(s.Column) == nil {
+			return fmt.Errorf("model %T has no %s column", model, s.Column)
+		}
+		s.tables[stmt.Table] = true
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gh:tenant_scope_create", s.beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("gh:tenant_scope_query", s.scope); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("gh:tenant_scope_row", s.scope); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gh:tenant_scope_update", s.beforeUpdate); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("gh:tenant_scope_delete", s.beforeDelete)
+}
+
+// tenant returns the tenant of the statement. ok is false if the statement is not scoped.
+func (s *TenantScope) tenant(db *gorm.DB) (tenantID any, ok bool) {
+	if db.Error != nil || db.Statement.Schema == nil || !s.tables[db.Statement.Table] || db.Statement.SQL.Len() > 0 {
+		return nil, false
+	}
+
+	tenantID, ok = TenantIDFromContext(db.Statement.Context)
+	if !ok && !s.Optional {
+		db.AddError(fmt.Errorf("%w: table %s", ErrMissingTenant, db.Statement.Table))
+	}
+	return tenantID, ok
+}
+
+func (s *TenantScope) scope(db *gorm.DB) {
+	tenantID, ok := s.tenant(db)
+	if !ok {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.Column}, Value: tenantID},
+	}})
+}
+
+func (s *TenantScope) beforeDelete(db *gorm.DB) {
+	// Without conditions, gorm:delete must still refuse to delete every row of the tenant.
+	if hasConditions(db) {
+		s.scope(db)
+	}
+}
+
+func (s *TenantScope) beforeCreate(db *gorm.DB) {
+	tenantID, ok := s.tenant(db)
+	if !ok {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField(s.Column)
+	forEachModelValue(db.Statement, func(rv reflect.Value) {
+		value, zero := field.ValueOf(db.Statement.Context, rv)
+		if zero {
+			db.AddError(field.Set(db.Statement.Context, rv, tenantID))
+		} else if !sameTenant(value, tenantID) {
+			db.AddError(fmt.Errorf("%w: %s %v", ErrCrossTenant, s.Column, value))
+		}
+	})
+
+	// An upsert, e.g Save falling back to create when its scoped update matched no row, must not
+	// update the conflicting row of another tenant.
+	if c, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+		if onConflict, _ := c.Expression.(clause.OnConflict); !onConflict.DoNothing {
+			onConflict.Where.Exprs = append(onConflict.Where.Exprs,
+				clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.Column}, Value: tenantID})
+			db.Statement.AddClause(onConflict)
+		}
+	}
+}
+
+func (s *TenantScope) beforeUpdate(db *gorm.DB) {
+	tenantID, ok := s.tenant(db)
+	if !ok {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField(s.Column)
+	var value any
+	switch dest := db.Statement.Dest.(type) {
+	case map[string]any:
+		if v, ok := dest[field.DBName]; ok {
+			value = v
+		} else {
+			value = dest[field.Name]
+		}
+	default:
+		if rv := reflect.Indirect(reflect.ValueOf(dest)); rv.Kind() == reflect.Struct {
+			value, _ = field.ValueOf(db.Statement.Context, rv)
+		}
+	}
+
+	if value != nil && !reflect.ValueOf(value).IsZero() && !sameTenant(value, tenantID) {
+		db.AddError(fmt.Errorf("%w: %s %v", ErrCrossTenant, s.Column, value))
+		return
+	}
+
+	// Without conditions, gorm:update must still refuse to update every row of the tenant.
+	if hasConditions(db) {
+		s.scope(db)
+	}
+}
+
+// hasConditions reports whether an update or delete has WHERE conditions, a model or destination
+// with a primary key that gorm turns into conditions, or is allowed to run without conditions.
+func hasConditions(db *gorm.DB) bool {
+	if _, ok := db.Statement.Clauses["WHERE"]; ok || db.AllowGlobalUpdate {
+		return true
+	}
+
+	for _, value := range []any{db.Statement.Model, db.Statement.Dest} {
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		switch rv.Kind() {
+		case reflect.Struct:
+			if hasPrimaryKey(db, rv) {
+				return true
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct && hasPrimaryKey(db, elem) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasPrimaryKey reports whether the struct rv of the statement's model has a primary key value.
+func hasPrimaryKey(db *gorm.DB, rv reflect.Value) bool {
+	if rv.Type() != db.Statement.Schema.ModelType {
+		return false
+	}
+
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		if _, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+			return true
+		}
+	}
+	return false
+}
+
+func sameTenant(a, b any) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
@@ -0,0 +1,83 @@
+package gh_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type scopedPatient struct {
+	ID       uint
+	TenantID uint
+	Name     string
+}
+
+func TestTenantScope(t *testing.T) {
+	db := dryRunDB(t)
+	assert.NoError(t, db.Use(gh.NewTenantScope(&scopedPatient{})))
+
+	ctx := gh.WithTenantID(context.Background(), uint(7))
+
+	var patients []scopedPatient
+	stmt := db.WithContext(ctx).Where("name = ?", "John").Find(&patients).Statement
+	assert.Equal(t, `SELECT * FROM "scoped_patients" WHERE name = $1 AND "scoped_patients"."tenant_id" = $2`, stmt.SQL.String())
+	assert.Equal(t, []any{"John", uint(7)}, stmt.Vars)
+
+	patient := scopedPatient{Name: "John"}
+	assert.NoError(t, db.WithContext(ctx).Create(&patient).Error)
+	assert.Equal(t, uint(7), patient.TenantID)
+
+	other := scopedPatient{Name: "Jane", TenantID: 8}
+	assert.ErrorIs(t, db.WithContext(ctx).Create(&other).Error, gh.ErrCrossTenant)
+
+	other.ID = 1
+	assert.ErrorIs(t, db.WithContext(ctx).Save(&other).Error, gh.ErrCrossTenant)
+
+	stmt = db.WithContext(ctx).Delete(&scopedPatient{ID: 1}).Statement
+	assert.Equal(t, `DELETE FROM "scoped_patients" WHERE "scoped_patients"."tenant_id" = $1 AND "scoped_patients"."id" = $2`, stmt.SQL.String())
+
+	assert.ErrorIs(t, db.Find(&patients).Error, gh.ErrMissingTenant)
+}
+
+func TestTenantScopeMissingWhereClause(t *testing.T) {
+	db := dryRunDB(t)
+	assert.NoError(t, db.Use(gh.NewTenantScope(&scopedPatient{})))
+	ctx := gh.WithTenantID(context.Background(), uint(7))
+
+	// The tenant condition alone must not turn them into deletes and updates of every row of the tenant.
+	assert.ErrorIs(t, db.WithContext(ctx).Delete(&scopedPatient{}).Error, gorm.ErrMissingWhereClause)
+	assert.ErrorIs(t, db.WithContext(ctx).Model(&scopedPatient{}).Update("name", "John").Error, gorm.ErrMissingWhereClause)
+
+	stmt := db.WithContext(ctx).Model(&scopedPatient{ID: 1}).Update("name", "John").Statement
+	assert.Equal(t, `UPDATE "scoped_patients" SET "name"=$1 WHERE "scoped_patients"."tenant_id" = $2 AND "id" = $3`, stmt.SQL.String())
+
+	stmt = db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&scopedPatient{}).Statement
+	assert.Equal(t, `DELETE FROM "scoped_patients" WHERE "scoped_patients"."tenant_id" = $1`, stmt.SQL.String())
+
+	stmt = db.WithContext(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&scopedPatient{}).Update("name", "John").Statement
+	assert.Equal(t, `UPDATE "scoped_patients" SET "name"=$1 WHERE "scoped_patients"."tenant_id" = $2`, stmt.SQL.String())
+}
+
+func TestTenantScopeSaveForeignKey(t *testing.T) {
+	db, mock := mockDB(t)
+	assert.NoError(t, db.Use(gh.NewTenantScope(&scopedPatient{})))
+	ctx := gh.WithTenantID(context.Background(), uint(7))
+
+	// Patient 1 belongs to tenant 8: the scoped update matches no row and Save falls back to an
+	// upsert, which must not overwrite it.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "scoped_patients" SET "tenant_id"=$1,"name"=$2 WHERE "scoped_patients"."tenant_id" = $3 AND "id" = $4`)).
+		WithArgs(uint(7), "Mallory", uint(7), uint(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "scoped_patients" ("tenant_id","name","id") VALUES ($1,$2,$3) ON CONFLICT ("id") DO UPDATE SET "tenant_id"="excluded"."tenant_id","name"="excluded"."name" WHERE "scoped_patients"."tenant_id" = $4 RETURNING "id"`)).
+		WithArgs(uint(7), "Mallory", uint(1), uint(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	patient := scopedPatient{ID: 1, TenantID: 7, Name: "Mallory"}
+	assert.NoError(t, db.WithContext(ctx).Save(&patient).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
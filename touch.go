@@ -0,0 +1,141 @@
+package gh
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Touch sets the timestamp columns of model to the current time, by default its UpdatedAt column
+// (the field tracked with autoUpdateTime), without loading the row or running hooks.
+// The fields of model are set too. model must have a primary key.
+//
+//	err := gdb.Touch(&visit)
+//	err := gdb.Touch(&user, "last_seen_at")
+func (gdb *GormDB) Touch(model any, columns ...string) error {
+	stmt := &gorm.Statement{DB: gdb.db}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	if len(columns) == 0 {
+		field := updatedAtField(stmt.Schema)
+		if field == nil {
+			return fmt.Errorf("%T has no updated_at column", model)
+		}
+		columns = []string{field.DBName}
+	}
+
+	now := gdb.db.NowFunc()
+	values := make(map[string]any, len(columns))
+	for _, column := range columns {
+		values[column] = touchValue(stmt.Schema.LookUpField(column), now)
+	}
+
+	if err := gdb.db.Model(model).UpdateColumns(values).Error; err != nil {
+		return fmt.Errorf("failed to touch %T: %w", model, err)
+	}
+	return nil
+}
+
+// updatedAtField returns the field of s updated automatically, e.g UpdatedAt, or its updated_at column.
+func updatedAtField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.AutoUpdateTime > 0 {
+			return field
+		}
+	}
+	return s.LookUpField("updated_at")
+}
+
+// touchValue returns now in the unit of the field, e.g unix seconds for an autoUpdateTime int field.
+func touchValue(field *schema.Field, now time.Time) any {
+	if field == nil {
+		return now
+	}
+
+	switch field.AutoUpdateTime {
+	case schema.UnixSecond:
+		return now.Unix()
+	case schema.UnixMillisecond:
+		return now.UnixMilli()
+	case schema.UnixNanosecond:
+		return now.UnixNano()
+	}
+	return now
+}
+
+// TouchParents is a gorm plugin touching the parents of models when they are created, updated or
+// deleted, e.g setting the updated_at of a visit when a prescription is added. Parents are the belongs
+// to associations tagged `gh:"touch"`, or `gh:"touch:<column>"` to set another column than UpdatedAt.
+// Parents are touched with Touch semantics, so their own parents are not.
+//
+//	type Prescription struct {
+//		ID      uint
+//		VisitID uint
+//		Visit   Visit `gh:"touch"`
+//	}
+//
+//	db.Use(&gh.TouchParents{})
+type TouchParents struct{}
+
+// Name implements gorm.Plugin.
+func (p *TouchParents) Name() string {
+	return "gh:touch_parents"
+}
+
+// Initialize implements gorm.Plugin, registering the create, update and delete callbacks.
+func (p *TouchParents) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("gh:touch_parents", p.touch); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("gh:touch_parents", p.touch); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("gh:touch_parents", p.touch)
+}
+
+// touch touches the tagged parents of the models of the statement, skipping zero foreign keys.
+func (p *TouchParents) touch(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || db.RowsAffected == 0 {
+		return
+	}
+
+	for _, rel := range db.Statement.Schema.Relationships.BelongsTo {
+		tag, ok := ghTag(rel.Field)["TOUCH"]
+		if !ok || len(rel.References) != 1 {
+			continue
+		}
+
+		column := tag
+		if column == "TOUCH" { // bare `gh:"touch"`, parsed with its own name as value
+			field := updatedAtField(rel.FieldSchema)
+			if field == nil {
+				continue
+			}
+			column = field.DBName
+		}
+
+		ref := rel.References[0]
+		keys := []any{}
+		forEachModelValue(db.Statement, func(rv reflect.Value) {
+			if key, zero := ref.ForeignKey.ValueOf(db.Statement.Context, rv); !zero {
+				keys = append(keys, key)
+			}
+		})
+		if len(keys) == 0 {
+			continue
+		}
+
+		err := db.Session(&gorm.Session{NewDB: true}).Table(rel.FieldSchema.Table).
+			Where(quoteName(ref.PrimaryKey.DBName)+" IN ?", keys).
+			UpdateColumn(column, touchValue(rel.FieldSchema.LookUpField(column), db.NowFunc())).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to touch %s of %s: %w", rel.Name, db.Statement.Schema.Name, err))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gh_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type touchVisit struct {
+	ID         uint
+	UpdatedAt  time.Time
+	LastSeenAt int64 `gorm:"autoUpdateTime:milli"`
+}
+
+type touchPrescription struct {
+	ID           uint
+	Drug         string
+	TouchVisitID uint
+	TouchVisit   touchVisit `gh:"touch"`
+}
+
+func TestTouch(t *testing.T) {
+	db, mock := mockDB(t)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	db.Config.NowFunc = func() time.Time { return now }
+
+	mock.ExpectExec(`UPDATE "touch_visits" SET "updated_at"=\$1 WHERE "id" = \$2`).
+		WithArgs(now, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	visit := &touchVisit{ID: 7}
+	require.NoError(t, gh.WrapDB(db).Touch(visit))
+	assert.Equal(t, now, visit.UpdatedAt)
+
+	mock.ExpectExec(`UPDATE "touch_visits" SET "last_seen_at"=\$1 WHERE "id" = \$2`).
+		WithArgs(now.UnixMilli(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gh.WrapDB(db).Touch(visit, "last_seen_at"))
+
+	assert.ErrorContains(t, gh.WrapDB(db).Touch(&Department{ID: 1}), "has no updated_at column")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTouchParents(t *testing.T) {
+	db, mock := mockDB(t)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	db.Config.NowFunc = func() time.Time { return now }
+	require.NoError(t, db.Use(&gh.TouchParents{}))
+
+	mock.ExpectQuery(`INSERT INTO "touch_prescriptions"`).
+		WithArgs("Amoxicillin", 7, "Paracetamol", 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectExec(`UPDATE "touch_visits" SET "updated_at"=\$1 WHERE "id" IN \(\$2,\$3\)`).
+		WithArgs(now, 7, 8).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, gh.WrapDB(db).Create(&[]touchPrescription{
+		{Drug: "Amoxicillin", TouchVisitID: 7},
+		{Drug: "Paracetamol", TouchVisitID: 8},
+	}))
+
+	mock.ExpectExec(`DELETE FROM "touch_prescriptions" WHERE "touch_prescriptions"."id" = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "touch_visits" SET "updated_at"=\$1 WHERE "id" IN \(\$2\)`).
+		WithArgs(now, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, gh.WrapDB(db).Delete(&touchPrescription{ID: 1, TouchVisitID: 7}))
+
+	// Deletes by conditions do not know the parents.
+	mock.ExpectExec(`DELETE FROM "touch_prescriptions" WHERE drug = \$1`).
+		WithArgs("Aspirin").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	require.NoError(t, gh.WrapDB(db).Delete(&touchPrescription{}, "drug = ?", "Aspirin"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
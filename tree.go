@@ -0,0 +1,124 @@
+package gh
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TreeNode is a row of an adjacency-list hierarchy with its depth below the root
+// of the query (0 for the root) and its children.
+type TreeNode[T any] struct {
+	Node     T              `json:"node"`
+	Depth    int            `json:"depth"`
+	Children []*TreeNode[T] `json:"children,omitempty"`
+}
+
+// treeRow is a row scanned from a recursive tree query.
+type treeRow[T any] struct {
+	Node     T       `gorm:"embedded"`
+	GhDepth  int     `gorm:"column:gh_depth"`
+	GhID     string  `gorm:"column:gh_id"`
+	GhParent *string `gorm:"column:gh_parent"`
+}
+
+// Descendants loads the subtree of the row of T whose idColumn is rootID, following parentColumn
+// (which references idColumn) with a WITH RECURSIVE query, and returns it as a nested tree.
+// Cycles in the data are detected and not followed.
+// It returns gorm.ErrRecordNotFound if there is no row with rootID.
+// The statement is raw SQL: conditions and scopes of db are not applied.
+//
+//	tree, err := gh.Descendants[Category](db, "id", "parent_id", 1)
+func Descendants[T any](db *gorm.DB, idColumn, parentColumn string, rootID any) (*TreeNode[T], error) {
+	nodes, err := WithDepth[T](db, idColumn, parentColumn, rootID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// WithDepth is like Descendants but returns the subtree as a flat list of nodes ordered by depth,
+// and does not descend below maxDepth (0 for no limit). Children are linked as well,
+// so nodes[0] is the nested tree.
+func WithDepth[T any](db *gorm.DB, idColumn, parentColumn string, rootID any, maxDepth int) ([]*TreeNode[T], error) {
+	table, err := tableName(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+
+	id, parent := quoteName(idColumn), quoteName(parentColumn)
+	limit := ""
+	args := []any{rootID}
+	if maxDepth > 0 {
+		limit = " AND p.gh_depth < ?"
+		args = append(args, maxDepth)
+	}
+
+	sql := fmt.Sprintf(`WITH RECURSIVE gh_tree AS (
+	SELECT t.*, 0 AS gh_depth, ARRAY[t.%[2]s] AS gh_path, NULL::text AS gh_parent FROM %[1]s t WHERE t.%[2]s = ?
+	UNION ALL
+	SELECT c.*, p.gh_depth + 1, p.gh_path || c.%[2]s, p.%[2]s::text
+	FROM %[1]s c JOIN gh_tree p ON c.%[3]s = p.%[2]s
+	WHERE NOT c.%[2]s = ANY(p.gh_path)%[4]s
+)
+SELECT *, %[2]s::text AS gh_id FROM gh_tree ORDER BY gh_depth`, quoteIdent(table), id, parent, limit)
+
+	rows := []treeRow[T]{}
+	if err := db.Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tree of %s: %w", table, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	nodes := make([]*TreeNode[T], len(rows))
+	byID := make(map[string]*TreeNode[T], len(rows))
+	for i, row := range rows {
+		nodes[i] = &TreeNode[T]{Node: row.Node, Depth: row.GhDepth}
+		byID[row.GhID] = nodes[i]
+		if row.GhParent != nil {
+			if p, ok := byID[*row.GhParent]; ok {
+				p.Children = append(p.Children, nodes[i])
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// Ancestors loads the ancestors of the row of T whose idColumn is id, following parentColumn
+// with a WITH RECURSIVE query. They are ordered from the root down to the row itself, which is last.
+// It returns gorm.ErrRecordNotFound if there is no row with id.
+// The statement is raw SQL: conditions and scopes of db are not applied.
+//
+//	breadcrumbs, err := gh.Ancestors[Category](db, "id", "parent_id", 42)
+func Ancestors[T any](db *gorm.DB, idColumn, parentColumn string, id any) ([]T, error) {
+	table, err := tableName(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf(`WITH RECURSIVE gh_tree AS (
+	SELECT t.*, 0 AS gh_depth, ARRAY[t.%[2]s] AS gh_path FROM %[1]s t WHERE t.%[2]s = ?
+	UNION ALL
+	SELECT a.*, c.gh_depth + 1, c.gh_path || a.%[2]s
+	FROM %[1]s a JOIN gh_tree c ON a.%[2]s = c.%[3]s
+	WHERE NOT a.%[2]s = ANY(c.gh_path)
+)
+SELECT * FROM gh_tree ORDER BY gh_depth DESC`, quoteIdent(table), quoteName(idColumn), quoteName(parentColumn))
+
+	rows := []treeRow[T]{}
+	if err := db.Raw(sql, id).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load ancestors in %s: %w", table, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	ancestors := make([]T, len(rows))
+	for i, row := range rows {
+		ancestors[i] = row.Node
+	}
+	return ancestors, nil
+}
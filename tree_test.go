@@ -0,0 +1,71 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type treeCategory struct {
+	ID       uint
+	ParentID *uint
+	Name     string
+}
+
+func TestDescendants(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(`WITH RECURSIVE gh_tree AS \(.*FROM "tree_categories" t WHERE t."id" = \$1.*` +
+		`JOIN gh_tree p ON c."parent_id" = p."id".*SELECT \*, "id"::text AS gh_id FROM gh_tree ORDER BY gh_depth`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "name", "gh_depth", "gh_path", "gh_parent", "gh_id"}).
+			AddRow(1, nil, "Clinic", 0, "{1}", nil, "1").
+			AddRow(2, 1, "Surgery", 1, "{1,2}", "1", "2").
+			AddRow(3, 1, "Lab", 1, "{1,3}", "1", "3").
+			AddRow(4, 2, "Theatre", 2, "{1,2,4}", "2", "4"))
+
+	tree, err := gh.Descendants[treeCategory](db, "id", "parent_id", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Clinic", tree.Node.Name)
+	assert.Len(t, tree.Children, 2)
+	assert.Equal(t, "Surgery", tree.Children[0].Node.Name)
+	assert.Equal(t, 1, tree.Children[0].Depth)
+	assert.Equal(t, "Theatre", tree.Children[0].Children[0].Node.Name)
+	assert.Empty(t, tree.Children[1].Children)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithDepthLimit(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(`WHERE NOT c."id" = ANY\(p.gh_path\) AND p.gh_depth < \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "gh_depth", "gh_parent", "gh_id"}))
+
+	_, err := gh.WithDepth[treeCategory](db, "id", "parent_id", 1, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAncestors(t *testing.T) {
+	db, mock := mockDB(t)
+
+	mock.ExpectQuery(`JOIN gh_tree c ON a."id" = c."parent_id".*ORDER BY gh_depth DESC`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "name", "gh_depth", "gh_path"}).
+			AddRow(1, nil, "Clinic", 2, "{4,2,1}").
+			AddRow(2, 1, "Surgery", 1, "{4,2}").
+			AddRow(4, 2, "Theatre", 0, "{4}"))
+
+	ancestors, err := gh.Ancestors[treeCategory](db, "id", "parent_id", 4)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, a := range ancestors {
+		names = append(names, a.Name)
+	}
+	assert.Equal(t, []string{"Clinic", "Surgery", "Theatre"}, names)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,102 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// ErrUniqueViolation is matched by the UniqueViolationError of IsUnique checks, see UniqueHook.
+var ErrUniqueViolation = errors.New("unique violation")
+
+// UniqueViolationError reports that a row of Table already has the Value in Column.
+type UniqueViolationError struct {
+	Table  string
+	Column string
+	Value  any
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("%s %v already exists", e.Column, e.Value)
+}
+
+// Unwrap returns ErrUniqueViolation, so errors.Is(err, ErrUniqueViolation) is true.
+func (e *UniqueViolationError) Unwrap() error {
+	return ErrUniqueViolation
+}
+
+// IsUnique reports whether no row of the table of model has value in column, ignoring the row with the
+// primary key excludeID, e.g the row being updated. If excludeID is nil or zero, no row is ignored.
+// Soft deleted rows are ignored, unless the chain is Unscoped.
+//
+//	unique, err := gdb.IsUnique(&User{}, "email", req.Email, user.ID)
+func (gdb *GormDB) IsUnique(model any, column string, value any, excludeID any) (bool, error) {
+	stmt := &gorm.Statement{DB: gdb.db}
+	if err := stmt.Parse(model); err != nil {
+		return false, fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+
+	// A zero instance, since gorm adds the primary key of the model to the conditions.
+	query := gdb.db.Model(reflect.New(stmt.Schema.ModelType).Interface()).Select("1").Where(quoteName(column)+" = ?", value)
+	if excludeID != nil && !reflect.ValueOf(excludeID).IsZero() {
+		if stmt.Schema.PrioritizedPrimaryField == nil {
+			return false, fmt.Errorf("%T has no primary key", model)
+		}
+		query = query.Where(quoteName(stmt.Schema.PrioritizedPrimaryField.DBName)+" <> ?", excludeID)
+	}
+
+	var exists bool
+	if err := gdb.db.Raw("SELECT EXISTS (?)", query).Scan(&exists).Error; err != nil {
+		return false, fmt.Errorf("failed to check unique %s.%s: %w", stmt.Schema.Table, column, err)
+	}
+	return !exists, nil
+}
+
+// UniqueHook returns a function checking with IsUnique that the columns of a model are unique,
+// to call from its BeforeSave hook. It returns a *UniqueViolationError for the first duplicate,
+// e.g to respond with 409 Conflict before the insert fails on the unique constraint.
+// Nil pointers are not checked, since NULLs never conflict.
+//
+//	var uniqueUser = gh.UniqueHook("email", "username")
+//
+//	func (u *User) BeforeSave(tx *gorm.DB) error {
+//		return uniqueUser(tx, u)
+//	}
+func UniqueHook(columns ...string) func(tx *gorm.DB, model any) error {
+	return func(tx *gorm.DB, model any) error {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse %T: %w", model, err)
+		}
+
+		rv := reflect.Indirect(reflect.ValueOf(model))
+		var id any
+		if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+			id, _ = field.ValueOf(tx.Statement.Context, rv)
+		}
+
+		gdb := WrapDB(tx.Session(&gorm.Session{NewDB: true}))
+		for _, column := range columns {
+			field := stmt.Schema.LookUpField(column)
+			if field == nil {
+				return fmt.Errorf("%T has no column %q", model, column)
+			}
+
+			value, zero := field.ValueOf(tx.Statement.Context, rv)
+			if zero && field.FieldType.Kind() == reflect.Pointer {
+				continue
+			}
+
+			unique, err := gdb.IsUnique(model, field.DBName, value, id)
+			if err != nil {
+				return err
+			}
+			if !unique {
+				return &UniqueViolationError{Table: stmt.Schema.Table, Column: field.DBName, Value: value}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package gh_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type uniqueUser struct {
+	ID       uint
+	Email    string
+	Username *string
+}
+
+var checkUniqueUser = gh.UniqueHook("email", "username")
+
+func (u *uniqueUser) BeforeSave(tx *gorm.DB) error {
+	return checkUniqueUser(tx, u)
+}
+
+func TestIsUnique(t *testing.T) {
+	db, mock := mockDB(t)
+	gdb := gh.WrapDB(db)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "unique_users" WHERE "email" = \$1\)`).
+		WithArgs("jane@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	unique, err := gdb.IsUnique(&uniqueUser{}, "email", "jane@example.com", nil)
+	require.NoError(t, err)
+	assert.False(t, unique)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "unique_users" WHERE "email" = \$1 AND "id" <> \$2\)`).
+		WithArgs("jane@example.com", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	unique, err = gdb.IsUnique(&uniqueUser{}, "email", "jane@example.com", 4)
+	require.NoError(t, err)
+	assert.True(t, unique)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUniqueHook(t *testing.T) {
+	db, mock := mockDB(t)
+
+	// The nil username is not checked.
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "unique_users" WHERE "email" = \$1\)`).
+		WithArgs("jane@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	err := gh.WrapDB(db).Create(&uniqueUser{Email: "jane@example.com"})
+	assert.ErrorIs(t, err, gh.ErrUniqueViolation)
+	assert.EqualError(t, err, "email jane@example.com already exists")
+
+	var violation *gh.UniqueViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "unique_users", violation.Table)
+
+	// Updates exclude the row itself.
+	username := "jane"
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "unique_users" WHERE "email" = \$1 AND "id" <> \$2\)`).
+		WithArgs("jane@example.com", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "unique_users" WHERE "username" = \$1 AND "id" <> \$2\)`).
+		WithArgs("jane", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE "unique_users" SET "email"=\$1,"username"=\$2 WHERE "id" = \$3`).
+		WithArgs("jane@example.com", "jane", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, gh.WrapDB(db).Update(&uniqueUser{ID: 3, Email: "jane@example.com", Username: &username}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
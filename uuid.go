@@ -0,0 +1,233 @@
+package gh
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// UUID is a RFC 9562 UUID stored in a postgres uuid column. A zero UUID is stored as NULL.
+type UUID [16]byte
+
+var uuidState struct {
+	sync.Mutex
+	ms  int64
+	seq uint16
+}
+
+// NewUUIDv7 returns a time-ordered version 7 UUID: the first 48 bits are the Unix time in
+// milliseconds, so new primary keys are appended to the index instead of scattered like random UUIDs.
+// UUIDs generated by the process within the same millisecond are ordered too.
+func NewUUIDv7() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[6:]); err != nil {
+		return u, err
+	}
+
+	uuidState.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= uuidState.ms {
+		// Same millisecond (or clock moved back): increment the 12-bit sequence.
+		ms = uuidState.ms
+		uuidState.seq++
+		if uuidState.seq > 0xfff {
+			ms++
+			uuidState.seq = 0
+		}
+	} else {
+		uuidState.seq = binary.BigEndian.Uint16(u[6:8]) & 0x7ff // leave room to increment
+	}
+	uuidState.ms = ms
+	seq := uuidState.seq
+	uuidState.Unlock()
+
+	u[0], u[1], u[2], u[3], u[4], u[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	binary.BigEndian.PutUint16(u[6:8], 0x7000|seq) // version 7
+	u[8] = u[8]&0x3f | 0x80                        // RFC 9562 variant
+	return u, nil
+}
+
+// ParseUUID parses a UUID in its canonical form, e.g 0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("gh: invalid UUID %q", s)
+	}
+
+	compact := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(compact)); err != nil {
+		return u, fmt.Errorf("gh: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// IsZero reports whether u is the zero (nil) UUID.
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// Time returns the creation time of a version 7 UUID.
+func (u UUID) Time() time.Time {
+	ms := int64(u[0])<<40 | int64(u[1])<<32 | int64(u[2])<<24 | int64(u[3])<<16 | int64(u[4])<<8 | int64(u[5])
+	return time.UnixMilli(ms)
+}
+
+// String returns the canonical form of u.
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// GormDataType returns the column type used by gorm migrations.
+func (UUID) GormDataType() string {
+	return "uuid"
+}
+
+// Value implements driver.Valuer.
+func (u UUID) Value() (driver.Value, error) {
+	if u.IsZero() {
+		return nil, nil
+	}
+	return u.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (u *UUID) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*u = UUID{}
+		return nil
+	case []byte:
+		if len(v) == 16 {
+			copy(u[:], v)
+			return nil
+		}
+		return u.Scan(string(v))
+	case string:
+		parsed, err := ParseUUID(v)
+		if err != nil {
+			return err
+		}
+		*u = parsed
+		return nil
+	}
+	return fmt.Errorf("gh: cannot scan %T into UUID", src)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(data []byte) error {
+	parsed, err := ParseUUID(string(data))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// UUIDPK is a UUID primary key to embed in models instead of gorm.Model's serial ID.
+// With the UUIDv7 plugin, a time-ordered UUID is generated when a record is created without one.
+//
+//	type Patient struct {
+//		gh.UUIDPK
+//		Name string
+//	}
+type UUIDPK struct {
+	ID UUID `gorm:"primaryKey" json:"id"`
+}
+
+// UUIDDefaultPK is a UUID primary key generated by the database with DEFAULT gen_random_uuid()
+// (postgres 13+, or the pgcrypto extension before). The generated ID is read back on create.
+type UUIDDefaultPK struct {
+	ID UUID `gorm:"primaryKey;default:gen_random_uuid()" json:"id"`
+}
+
+// SetUUIDDefault sets DEFAULT gen_random_uuid() on the uuid column of an existing table,
+// e.g when migrating a table to server-side generated UUIDs.
+func SetUUIDDefault(db *gorm.DB, table, column string) error {
+	sql := "ALTER TABLE " + quoteIdent(table) + " ALTER COLUMN " + quoteName(column) + " SET DEFAULT gen_random_uuid()"
+	if err := db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("failed to set default of %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// UUIDv7 is a gorm plugin that generates a UUIDv7 for the zero UUID primary keys
+// (e.g UUIDPK) of created records. Keys with a database default (e.g UUIDDefaultPK) are left to the database.
+//
+// Usage:
+//
+//	db.Use(&gh.UUIDv7{})
+type UUIDv7 struct{}
+
+// Name implements gorm.Plugin.
+func (p *UUIDv7) Name() string {
+	return "gh:uuidv7"
+}
+
+// Initialize implements gorm.Plugin by registering the create callback.
+func (p *UUIDv7) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("gh:uuidv7", p.beforeCreate)
+}
+
+var uuidType = reflect.TypeOf(UUID{})
+
+func (p *UUIDv7) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	var fields []*schema.Field
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		if field.FieldType == uuidType && field.DefaultValue == "" {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			p.setIDs(db, fields, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		p.setIDs(db, fields, rv)
+	}
+}
+
+// setIDs generates the zero UUID fields of the struct value rv.
+func (p *UUIDv7) setIDs(db *gorm.DB, fields []*schema.Field, rv reflect.Value) {
+	ctx := db.Statement.Context
+	for _, field := range fields {
+		if _, isZero := field.ValueOf(ctx, rv); !isZero {
+			continue
+		}
+
+		id, err := NewUUIDv7()
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		if err := field.Set(ctx, rv, id); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package gh_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+)
+
+type uuidPatient struct {
+	gh.UUIDPK
+	Name string
+}
+
+type uuidInvoice struct {
+	gh.UUIDDefaultPK
+	Total int
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	prev, err := gh.NewUUIDv7()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x70), prev[6]&0xf0) // version 7
+	assert.Equal(t, byte(0x80), prev[8]&0xc0) // variant
+	assert.WithinDuration(t, time.Now(), prev.Time(), time.Second)
+
+	for i := 0; i < 1000; i++ {
+		u, err := gh.NewUUIDv7()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, bytes.Compare(u[:], prev[:]), "UUIDs must be ordered")
+		prev = u
+	}
+}
+
+func TestUUID(t *testing.T) {
+	u, err := gh.ParseUUID("0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f")
+	assert.NoError(t, err)
+	assert.Equal(t, "0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f", u.String())
+
+	_, err = gh.ParseUUID("0190a5e67b3c7d2e8f001a2b3c4d5e6f")
+	assert.Error(t, err)
+
+	var scanned gh.UUID
+	assert.NoError(t, scanned.Scan([]byte("0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f")))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan(nil))
+	assert.True(t, scanned.IsZero())
+
+	v, err := gh.UUID{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	data, err := json.Marshal(uuidPatient{UUIDPK: gh.UUIDPK{ID: u}, Name: "Jane"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f","Name":"Jane"}`, string(data))
+
+	var decoded uuidPatient
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, u, decoded.ID)
+}
+
+func TestUUIDv7Plugin(t *testing.T) {
+	db, mock := mockDB(t)
+	assert.NoError(t, db.Use(&gh.UUIDv7{}))
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "uuid_patients" ("id","name") VALUES ($1,$2),($3,$4)`)).
+		WithArgs(sqlmock.AnyArg(), "Jane", "0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f", "John").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	existing, _ := gh.ParseUUID("0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f")
+	patients := []uuidPatient{{Name: "Jane"}, {UUIDPK: gh.UUIDPK{ID: existing}, Name: "John"}}
+	assert.NoError(t, db.Create(&patients).Error)
+	assert.False(t, patients[0].ID.IsZero())
+	assert.Equal(t, existing, patients[1].ID)
+
+	// The database generates keys with a default.
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "uuid_invoices" ("total") VALUES ($1) RETURNING "id"`)).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("0190a5e6-7b3c-7d2e-8f00-1a2b3c4d5e6f"))
+
+	invoice := uuidInvoice{Total: 100}
+	assert.NoError(t, db.Create(&invoice).Error)
+	assert.Equal(t, existing, invoice.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
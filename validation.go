@@ -0,0 +1,154 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrValidation is matched by the ValidationError of the ValidationPlugin.
+var ErrValidation = errors.New("validation failed")
+
+// StructValidator validates the fields of a struct, e.g the *validator.Validate of
+// github.com/go-playground/validator/v10.
+type StructValidator interface {
+	StructCtx(ctx context.Context, s any) error
+}
+
+// Validatable is implemented by models validating themselves, e.g the fields depending on each other.
+type Validatable interface {
+	Validate() error
+}
+
+// FieldError is the failed validation of a field. Field is empty for errors of the model.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Tag     string `json:"tag,omitempty"` // e.g required, email, max
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the failed validations of a model, e.g to respond with 422 Unprocessable Entity.
+type ValidationError struct {
+	Model  string       `json:"model"`
+	Fields []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return "invalid " + e.Model + ": " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns ErrValidation, so errors.Is(err, ErrValidation) is true.
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// validatorFieldError is implemented by the field errors of go-playground/validator.
+type validatorFieldError interface {
+	Field() string
+	Tag() string
+	Param() string
+	Error() string
+}
+
+// ValidationPlugin is a gorm plugin validating models before they are created or updated, with Validator
+// and their Validate method if they are Validatable. Failures abort the statement with a *ValidationError.
+// Updates are only validated when the model is the updated value (Save, Updates(&model)), not for
+// Update(column, value) or Updates(map), whose model may not be loaded.
+//
+//	db.Use(&gh.ValidationPlugin{Validator: validator.New(validator.WithRequiredStructEnabled())})
+type ValidationPlugin struct {
+	// Validator validates the fields of the models, e.g with struct tags. Optional.
+	Validator StructValidator
+}
+
+// Name implements gorm.Plugin.
+func (p *ValidationPlugin) Name() string {
+	return "gh:validation"
+}
+
+// Initialize implements gorm.Plugin, registering the create and update callbacks.
+func (p *ValidationPlugin) Initialize(db *gorm.DB) error {
+	err := db.Callback().Create().Before("gorm:create").Register("gh:validation_create", p.validate)
+	if err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("gh:validation_update", p.validateUpdate)
+}
+
+func (p *ValidationPlugin) validateUpdate(db *gorm.DB) {
+	if sameValue(db.Statement.Model, db.Statement.Dest) {
+		p.validate(db)
+	}
+}
+
+func (p *ValidationPlugin) validate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	forEachModelValue(db.Statement, func(rv reflect.Value) {
+		if db.Error != nil {
+			return // the first invalid model aborts the statement
+		}
+
+		model := rv.Addr().Interface()
+		fields := []FieldError{}
+		if p.Validator != nil {
+			if err := p.Validator.StructCtx(db.Statement.Context, model); err != nil {
+				fields = append(fields, fieldErrors(err)...)
+			}
+		}
+		if v, ok := model.(Validatable); ok {
+			if err := v.Validate(); err != nil {
+				fields = append(fields, fieldErrors(err)...)
+			}
+		}
+
+		if len(fields) > 0 {
+			db.AddError(&ValidationError{Model: db.Statement.Schema.Name, Fields: fields})
+		}
+	})
+}
+
+// fieldErrors converts the errors of a validator, e.g validator.ValidationErrors, into FieldErrors.
+func fieldErrors(err error) []FieldError {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Fields
+	}
+
+	if rv := reflect.ValueOf(err); rv.Kind() == reflect.Slice {
+		fields := make([]FieldError, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			fe, ok := rv.Index(i).Interface().(validatorFieldError)
+			if !ok {
+				return []FieldError{{Message: err.Error()}}
+			}
+			fields = append(fields, FieldError{Field: fe.Field(), Tag: fe.Tag(), Param: fe.Param(), Message: fe.Error()})
+		}
+		return fields
+	}
+	return []FieldError{{Message: err.Error()}}
+}
+
+// sameValue reports whether a and b are the same pointer, slice or map.
+func sameValue(a, b any) bool {
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !ra.IsValid() || !rb.IsValid() || ra.Type() != rb.Type() {
+		return false
+	}
+
+	switch ra.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		return ra.Pointer() == rb.Pointer()
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/abiiranathan/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldError and fieldErrors mimic the errors of go-playground/validator.
+type fieldError struct{ field, tag string }
+
+func (e fieldError) Field() string { return e.field }
+func (e fieldError) Tag() string   { return e.tag }
+func (e fieldError) Param() string { return "" }
+func (e fieldError) Error() string { return e.field + " is " + e.tag }
+
+type fieldErrors []fieldError
+
+func (e fieldErrors) Error() string { return "invalid fields" }
+
+// requiredValidator fails for empty names.
+type requiredValidator struct{}
+
+func (requiredValidator) StructCtx(ctx context.Context, s any) error {
+	if p, ok := s.(*validatedPrescription); ok && p.Drug == "" {
+		return fieldErrors{{field: "Drug", tag: "required"}}
+	}
+	return nil
+}
+
+type validatedPrescription struct {
+	ID    uint
+	Drug  string
+	Doses int
+}
+
+func (p *validatedPrescription) Validate() error {
+	if p.Doses < 1 {
+		return errors.New("doses must be positive")
+	}
+	return nil
+}
+
+func TestValidationPlugin(t *testing.T) {
+	db, mock := mockDB(t)
+	require.NoError(t, db.Use(&gh.ValidationPlugin{Validator: requiredValidator{}}))
+	gdb := gh.WrapDB(db)
+
+	err := gdb.Create(&validatedPrescription{})
+	assert.ErrorIs(t, err, gh.ErrValidation)
+	assert.EqualError(t, err, "invalid validatedPrescription: Drug is required; doses must be positive")
+
+	var validationErr *gh.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []gh.FieldError{
+		{Field: "Drug", Tag: "required", Message: "Drug is required"},
+		{Message: "doses must be positive"},
+	}, validationErr.Fields)
+
+	// Batches are refused if any model is invalid.
+	err = gdb.Create(&[]validatedPrescription{{Drug: "Amoxicillin", Doses: 3}, {Doses: 3}})
+	assert.ErrorIs(t, err, gh.ErrValidation)
+
+	mock.ExpectQuery(`INSERT INTO "validated_prescriptions"`).
+		WithArgs("Amoxicillin", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	require.NoError(t, gdb.Create(&validatedPrescription{Drug: "Amoxicillin", Doses: 3}))
+
+	err = gdb.Update(&validatedPrescription{ID: 1, Doses: 2})
+	assert.ErrorIs(t, err, gh.ErrValidation)
+
+	// Column updates do not validate the model.
+	mock.ExpectExec(`UPDATE "validated_prescriptions" SET "doses"=\$1 WHERE "id" = \$2`).
+		WithArgs(0, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, db.Model(&validatedPrescription{ID: 1}).Update("doses", 0).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
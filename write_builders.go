@@ -0,0 +1,317 @@
+package gh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// InsertBuilder builds INSERT statements for db.Exec or db.Raw (with RETURNING).
+/*
+Example Usage:
+
+	ib := NewInsertBuilder("visits", "doctor", "total_amount").
+		Values("Dr. Smith", 100).
+		Values("Dr. Jones", 250).
+		OnConflictDoNothing("id").
+		Returning("id")
+
+	query, args, err := ib.Build()
+*/
+type InsertBuilder struct {
+	table      string
+	columns    []string
+	rows       [][]interface{}
+	onConflict string
+	returning  []string
+	dialect    Dialect
+	err        error
+}
+
+// NewInsertBuilder creates an InsertBuilder inserting into the given columns of table.
+func NewInsertBuilder(table string, columns ...string) *InsertBuilder {
+	return &InsertBuilder{table: table, columns: columns}
+}
+
+// Values adds a row. Build returns an error if the number of values does not match the columns.
+func (ib *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	if len(values) != len(ib.columns) && ib.err == nil {
+		ib.err = fmt.Errorf("%w: insert into %s expects %d values, got %d", ErrInvalidQuery, ib.table, len(ib.columns), len(values))
+	}
+	ib.rows = append(ib.rows, values)
+	return ib
+}
+
+// OnConflictDoNothing adds ON CONFLICT (target) DO NOTHING.
+// Without target, any constraint violation is ignored.
+func (ib *InsertBuilder) OnConflictDoNothing(target ...string) *InsertBuilder {
+	ib.onConflict = " ON CONFLICT" + conflictTarget(target) + " DO NOTHING"
+	return ib
+}
+
+// OnConflictUpdate adds ON CONFLICT (target) DO UPDATE SET column = EXCLUDED.column
+// for each of the given columns, i.e an upsert.
+func (ib *InsertBuilder) OnConflictUpdate(target []string, columns ...string) *InsertBuilder {
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		set[i] = column + " = EXCLUDED." + column
+	}
+	ib.onConflict = " ON CONFLICT" + conflictTarget(target) + " DO UPDATE SET " + strings.Join(set, ", ")
+	return ib
+}
+
+func conflictTarget(target []string) string {
+	if len(target) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(target, ", ") + ")"
+}
+
+// Returning adds a RETURNING clause.
+func (ib *InsertBuilder) Returning(columns ...string) *InsertBuilder {
+	ib.returning = columns
+	return ib
+}
+
+// Dialect sets the placeholder style of the query returned by Build.
+func (ib *InsertBuilder) Dialect(d Dialect) *InsertBuilder {
+	ib.dialect = d
+	return ib
+}
+
+// Build returns the final query and its arguments.
+func (ib *InsertBuilder) Build() (string, []interface{}, error) {
+	if ib.err != nil {
+		return "", nil, ib.err
+	}
+
+	if len(ib.rows) == 0 {
+		return "", nil, fmt.Errorf("%w: insert into %s has no values", ErrInvalidQuery, ib.table)
+	}
+
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ib.columns)), ", ") + ")"
+
+	rows := make([]string, len(ib.rows))
+	args := make([]interface{}, 0, len(ib.rows)*len(ib.columns))
+	for i, row := range ib.rows {
+		rows[i] = placeholders
+		args = append(args, row...)
+	}
+
+	query := "INSERT INTO " + ib.table + " (" + strings.Join(ib.columns, ", ") + ") VALUES " +
+		strings.Join(rows, ", ") + ib.onConflict + returningClause(ib.returning)
+	query, args = ib.dialect.rebind(query, args)
+	return query, args, nil
+}
+
+// MustBuild is like Build but panics if the statement is invalid.
+func (ib *InsertBuilder) MustBuild() (string, []interface{}) {
+	query, args, err := ib.Build()
+	if err != nil {
+		panic(err)
+	}
+	return query, args
+}
+
+// UpdateBuilder builds UPDATE statements.
+/*
+Example Usage:
+
+	ub := NewUpdateBuilder("visits").
+		SetMap(map[string]interface{}{"status": "paid", "paid_at": time.Now()}).
+		SetRaw("version = version + 1").
+		Where("id = ?", id)
+
+	query, args, err := ub.Build()
+*/
+type UpdateBuilder struct {
+	table     string
+	set       []string
+	args      []interface{}
+	where     *QueryBuilder
+	all       bool
+	returning []string
+	dialect   Dialect
+}
+
+// NewUpdateBuilder creates an UpdateBuilder for table.
+func NewUpdateBuilder(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table, where: NewQueryBuilder("")}
+}
+
+// Set sets column to value.
+func (ub *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	return ub.SetRaw(column+" = ?", value)
+}
+
+// SetMap sets each column of values. Columns are sorted so the statement is deterministic.
+func (ub *UpdateBuilder) SetMap(values map[string]interface{}) *UpdateBuilder {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	for _, column := range columns {
+		ub.Set(column, values[column])
+	}
+	return ub
+}
+
+// SetRaw adds a SET expression with its arguments, e.g "visits = visits + ?".
+func (ub *UpdateBuilder) SetRaw(expr string, args ...interface{}) *UpdateBuilder {
+	expr, args = ub.where.spliceSubqueries(expr, args)
+	ub.set = append(ub.set, expr)
+	ub.args = append(ub.args, args...)
+	return ub
+}
+
+// Where adds a where condition. Unlike QueryBuilder.Where, it is never skipped, even if value == "".
+func (ub *UpdateBuilder) Where(condition string, value ...interface{}) *UpdateBuilder {
+	ub.where.WhereArgs(condition, value...)
+	return ub
+}
+
+// WhereArgs adds a where condition like QueryBuilder.WhereArgs.
+func (ub *UpdateBuilder) WhereArgs(condition string, args ...interface{}) *UpdateBuilder {
+	ub.where.WhereArgs(condition, args...)
+	return ub
+}
+
+// WhereIn adds a where condition like QueryBuilder.WhereIn.
+func (ub *UpdateBuilder) WhereIn(column string, values []interface{}) *UpdateBuilder {
+	ub.where.WhereIn(column, values)
+	return ub
+}
+
+// All allows the statement to update every row of the table, without a where condition.
+func (ub *UpdateBuilder) All() *UpdateBuilder {
+	ub.all = true
+	return ub
+}
+
+// Returning adds a RETURNING clause.
+func (ub *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
+	ub.returning = columns
+	return ub
+}
+
+// Dialect sets the placeholder style of the query returned by Build.
+func (ub *UpdateBuilder) Dialect(d Dialect) *UpdateBuilder {
+	ub.dialect = d
+	return ub
+}
+
+// Build returns the final query and its arguments.
+// Without a where condition, e.g WhereIn with no values, it fails with ErrInvalidQuery unless All is called.
+func (ub *UpdateBuilder) Build() (string, []interface{}, error) {
+	if len(ub.set) == 0 {
+		return "", nil, fmt.Errorf("%w: update of %s sets no columns", ErrInvalidQuery, ub.table)
+	}
+
+	where, whereArgs, err := ub.where.build()
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make([]interface{}, 0, len(ub.args)+len(whereArgs))
+	args = append(append(args, ub.args...), whereArgs...)
+	query := "UPDATE " + ub.table + " SET " + strings.Join(ub.set, ", ") + where + returningClause(ub.returning)
+	if err := checkPlaceholders(query, args); err != nil {
+		return "", nil, err
+	}
+	if where == "" && !ub.all {
+		return "", nil, fmt.Errorf("%w: update of %s has no where condition, call All to update every row", ErrInvalidQuery, ub.table)
+	}
+	query, args = ub.dialect.rebind(query, args)
+	return query, args, nil
+}
+
+// MustBuild is like Build but panics if the statement is invalid.
+func (ub *UpdateBuilder) MustBuild() (string, []interface{}) {
+	query, args, err := ub.Build()
+	if err != nil {
+		panic(err)
+	}
+	return query, args
+}
+
+// DeleteBuilder builds DELETE statements.
+type DeleteBuilder struct {
+	table     string
+	where     *QueryBuilder
+	all       bool
+	returning []string
+	dialect   Dialect
+}
+
+// NewDeleteBuilder creates a DeleteBuilder for table.
+func NewDeleteBuilder(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table, where: NewQueryBuilder("")}
+}
+
+// Where adds a where condition. Unlike QueryBuilder.Where, it is never skipped, even if value == "".
+func (d *DeleteBuilder) Where(condition string, value ...interface{}) *DeleteBuilder {
+	d.where.WhereArgs(condition, value...)
+	return d
+}
+
+// WhereArgs adds a where condition like QueryBuilder.WhereArgs.
+func (d *DeleteBuilder) WhereArgs(condition string, args ...interface{}) *DeleteBuilder {
+	d.where.WhereArgs(condition, args...)
+	return d
+}
+
+// WhereIn adds a where condition like QueryBuilder.WhereIn.
+func (d *DeleteBuilder) WhereIn(column string, values []interface{}) *DeleteBuilder {
+	d.where.WhereIn(column, values)
+	return d
+}
+
+// All allows the statement to delete every row of the table, without a where condition.
+func (d *DeleteBuilder) All() *DeleteBuilder {
+	d.all = true
+	return d
+}
+
+// Returning adds a RETURNING clause.
+func (d *DeleteBuilder) Returning(columns ...string) *DeleteBuilder {
+	d.returning = columns
+	return d
+}
+
+// Dialect sets the placeholder style of the query returned by Build.
+func (d *DeleteBuilder) Dialect(dialect Dialect) *DeleteBuilder {
+	d.dialect = dialect
+	return d
+}
+
+// Build returns the final query and its arguments.
+// Without a where condition, e.g WhereIn with no values, it fails with ErrInvalidQuery unless All is called.
+func (d *DeleteBuilder) Build() (string, []interface{}, error) {
+	where, args, err := d.where.build()
+	if err != nil {
+		return "", nil, err
+	}
+	if where == "" && !d.all {
+		return "", nil, fmt.Errorf("%w: delete of %s has no where condition, call All to delete every row", ErrInvalidQuery, d.table)
+	}
+	query, args := d.dialect.rebind("DELETE FROM "+d.table+where+returningClause(d.returning), args)
+	return query, args, nil
+}
+
+// MustBuild is like Build but panics if the statement is invalid.
+func (d *DeleteBuilder) MustBuild() (string, []interface{}) {
+	query, args, err := d.Build()
+	if err != nil {
+		panic(err)
+	}
+	return query, args
+}
+
+func returningClause(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return " RETURNING " + strings.Join(columns, ", ")
+}
@@ -0,0 +1,90 @@
+package gh_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/abiiranathan/gh"
+)
+
+func assertBuilt(t *testing.T, query string, args []interface{}, expectedQuery string, expectedArgs []interface{}) {
+	t.Helper()
+
+	if query != expectedQuery {
+		t.Errorf("Query mismatch:\nExpected: %s\nGot: %s", expectedQuery, query)
+	}
+
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Args mismatch:\nExpected: %v\nGot: %v", expectedArgs, args)
+	}
+}
+
+func TestInsertBuilder(t *testing.T) {
+	query, args := gh.NewInsertBuilder("visits", "doctor", "total_amount").
+		Values("Dr. Smith", 100).
+		Values("Dr. Jones", 250).
+		OnConflictUpdate([]string{"doctor"}, "total_amount").
+		Returning("id").
+		MustBuild()
+
+	assertBuilt(t, query, args,
+		"INSERT INTO visits (doctor, total_amount) VALUES (?, ?), (?, ?) ON CONFLICT (doctor) DO UPDATE SET total_amount = EXCLUDED.total_amount RETURNING id",
+		[]interface{}{"Dr. Smith", 100, "Dr. Jones", 250})
+
+	query, _ = gh.NewInsertBuilder("visits", "doctor").Values("Dr. Smith").OnConflictDoNothing().MustBuild()
+	assertBuilt(t, query, nil, "INSERT INTO visits (doctor) VALUES (?) ON CONFLICT DO NOTHING", nil)
+
+	_, _, err := gh.NewInsertBuilder("visits", "doctor", "total_amount").Values("Dr. Smith").Build()
+	if !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for a mismatched number of values, got %v", err)
+	}
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	query, args := gh.NewUpdateBuilder("visits").
+		SetMap(map[string]interface{}{"status": "paid", "doctor": "Dr. Smith"}).
+		SetRaw("version = version + ?", 1).
+		Where("id = ?", 42).
+		Where("branch = ?", "").
+		Returning("id", "version").
+		MustBuild()
+
+	assertBuilt(t, query, args,
+		"UPDATE visits SET doctor = ?, status = ?, version = version + ? WHERE id = ? AND branch = ? RETURNING id, version",
+		[]interface{}{"Dr. Smith", "paid", 1, 42, ""})
+}
+
+func TestDeleteBuilder(t *testing.T) {
+	query, args := gh.NewDeleteBuilder("visits").
+		WhereIn("id", []interface{}{1, 2}).
+		WhereArgs("date < ?", "2020-01-01").
+		Returning("id").
+		MustBuild()
+
+	assertBuilt(t, query, args,
+		"DELETE FROM visits WHERE id IN (?, ?) AND date < ? RETURNING id",
+		[]interface{}{1, 2, "2020-01-01"})
+}
+
+func TestWriteBuildersRequireWhere(t *testing.T) {
+	// An empty value does not drop the condition.
+	query, args := gh.NewDeleteBuilder("visits").Where("id = ?", "").MustBuild()
+	assertBuilt(t, query, args, "DELETE FROM visits WHERE id = ?", []interface{}{""})
+
+	_, _, err := gh.NewDeleteBuilder("visits").WhereIn("id", nil).Build()
+	if !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for a delete without where, got %v", err)
+	}
+
+	_, _, err = gh.NewUpdateBuilder("visits").Set("status", "paid").Build()
+	if !errors.Is(err, gh.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for an update without where, got %v", err)
+	}
+
+	query, args = gh.NewUpdateBuilder("visits").Set("status", "paid").All().MustBuild()
+	assertBuilt(t, query, args, "UPDATE visits SET status = ?", []interface{}{"paid"})
+
+	query, args = gh.NewDeleteBuilder("visits").All().MustBuild()
+	assertBuilt(t, query, args, "DELETE FROM visits", []interface{}{})
+}